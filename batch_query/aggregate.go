@@ -0,0 +1,212 @@
+package batch_query
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type aggrKind uint8
+
+const (
+	aggrCounter aggrKind = iota
+	aggrGauge
+	aggrTimer
+)
+
+// In-memory storage of series aggregated between flushes.
+type aggrStore struct {
+	mux sync.RWMutex
+	idx map[string]*aggrSeries
+	// Optional callback of new series registration, calls under lock.
+	hook func(x *aggrSeries)
+}
+
+// Series of metric family with concrete labels values.
+//
+// Counters and gauges keeps in value, timers keeps cumulative count and sum (in nanoseconds) of observations and
+// peak (maximum) observation since last flush.
+type aggrSeries struct {
+	family string
+	kind   aggrKind
+	// Key-value pairs.
+	labels []string
+
+	value, count, sum, peak int64
+}
+
+func newAggrStore() *aggrStore {
+	return &aggrStore{idx: make(map[string]*aggrSeries)}
+}
+
+// Get existing or register new series. Labels must be specified as key-value pairs.
+func (s *aggrStore) get(family string, kind aggrKind, labels ...string) *aggrSeries {
+	key := aggrKey(family, labels)
+	s.mux.RLock()
+	x, ok := s.idx[key]
+	s.mux.RUnlock()
+	if ok {
+		return x
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if x, ok = s.idx[key]; ok {
+		return x
+	}
+	x = &aggrSeries{
+		family: family,
+		kind:   kind,
+		labels: append([]string(nil), labels...),
+	}
+	s.idx[key] = x
+	if s.hook != nil {
+		s.hook(x)
+	}
+	return x
+}
+
+// Get existing series or nil.
+func (s *aggrStore) find(family string, labels ...string) *aggrSeries {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.idx[aggrKey(family, labels)]
+}
+
+// Drop all series.
+func (s *aggrStore) reset() {
+	s.mux.Lock()
+	s.idx = make(map[string]*aggrSeries)
+	s.mux.Unlock()
+}
+
+// Walk over all series sorted by family and labels.
+func (s *aggrStore) each(fn func(x *aggrSeries)) {
+	s.mux.RLock()
+	buf := make([]*aggrSeries, 0, len(s.idx))
+	for _, x := range s.idx {
+		buf = append(buf, x)
+	}
+	s.mux.RUnlock()
+	sort.Slice(buf, func(i, j int) bool {
+		if buf[i].family != buf[j].family {
+			return buf[i].family < buf[j].family
+		}
+		return strings.Join(buf[i].labels, "\xff") < strings.Join(buf[j].labels, "\xff")
+	})
+	for _, x := range buf {
+		fn(x)
+	}
+}
+
+func aggrKey(family string, labels []string) string {
+	if len(labels) == 0 {
+		return family
+	}
+	return family + "\xff" + strings.Join(labels, "\xff")
+}
+
+func (x *aggrSeries) add(delta int64) {
+	atomic.AddInt64(&x.value, delta)
+}
+
+func (x *aggrSeries) set(value int64) {
+	atomic.StoreInt64(&x.value, value)
+}
+
+func (x *aggrSeries) observe(dur time.Duration) {
+	atomic.AddInt64(&x.count, 1)
+	atomic.AddInt64(&x.sum, int64(dur))
+	for {
+		cur := atomic.LoadInt64(&x.peak)
+		if int64(dur) <= cur || atomic.CompareAndSwapInt64(&x.peak, cur, int64(dur)) {
+			break
+		}
+	}
+}
+
+func (x *aggrSeries) load() int64 {
+	return atomic.LoadInt64(&x.value)
+}
+
+// Get timer's count and sum of observations and peak since the last reset.
+func (x *aggrSeries) loadTimer() (count int64, sum, peak time.Duration) {
+	count = atomic.LoadInt64(&x.count)
+	sum = time.Duration(atomic.LoadInt64(&x.sum))
+	peak = time.Duration(atomic.LoadInt64(&x.peak))
+	return
+}
+
+// Reset reported peak. Peak stays if greater observation came after reporting.
+func (x *aggrSeries) resetPeak(peak time.Duration) {
+	atomic.CompareAndSwapInt64(&x.peak, int64(peak), 0)
+}
+
+// Timer's peak reported by flush, resets after successful write only.
+type aggrPeak struct {
+	x    *aggrSeries
+	peak time.Duration
+}
+
+// Base of aggregating writers: registers events in the store.
+type aggrMetrics struct {
+	name string
+	s    *aggrStore
+}
+
+func (m aggrMetrics) Fetch() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", single).add(1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", single, "type", ioIn).add(1)
+}
+
+func (m aggrMetrics) OK(dur time.Duration) {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", single).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", single, "type", ioOK).add(1)
+	m.s.get("batch_query_timing", aggrTimer, "query", m.name, "entity", single).observe(dur)
+}
+
+func (m aggrMetrics) NotFound() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", single).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", single, "type", io404).add(1)
+}
+
+func (m aggrMetrics) Timeout() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", single).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", single, "type", ioTO).add(1)
+}
+
+func (m aggrMetrics) Interrupt() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", single).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", single, "type", ioInt).add(1)
+}
+
+func (m aggrMetrics) Fail() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", single).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", single, "type", ioFail).add(1)
+}
+
+func (m aggrMetrics) Batch() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", batch).add(1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", batch, "type", ioIn).add(1)
+}
+
+func (m aggrMetrics) BatchOK(dur time.Duration) {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", batch).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", batch, "type", ioOK).add(1)
+	m.s.get("batch_query_timing", aggrTimer, "query", m.name, "entity", batch).observe(dur)
+}
+
+func (m aggrMetrics) BatchFail() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", batch).add(-1)
+	m.s.get("batch_query_io", aggrCounter, "query", m.name, "entity", batch, "type", ioFail).add(1)
+}
+
+func (m aggrMetrics) BufferIn(reason string) {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", buffer).add(1)
+	m.s.get("batch_query_bufio", aggrCounter, "query", m.name, "reason", reason).add(1)
+}
+
+func (m aggrMetrics) BufferOut() {
+	m.s.get("batch_query_size", aggrGauge, "query", m.name, "entity", buffer).add(-1)
+}
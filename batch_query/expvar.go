@@ -0,0 +1,99 @@
+package batch_query
+
+import (
+	"expvar"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ExpvarMetrics is expvar implementation of batch_query.MetricsWriter.
+//
+// Metrics publishes to expvar map "batch_query" (see /debug/vars) as nested map per query name. Counters and gauges
+// writes as numbers, timers writes as objects with fields "count", "sum" and "max" (in seconds, maximum observation
+// at all), series with dynamic labels writes as nested maps keyed by label value. Writers with the same name share
+// the same series.
+type ExpvarMetrics struct {
+	aggrMetrics
+}
+
+var _ = NewExpvarMetrics
+
+// NewExpvarMetrics makes new writer publishes metrics to expvar.
+//
+// Returns an error if expvar name "batch_query" is already published and it isn't a map.
+func NewExpvarMetrics(name string) (*ExpvarMetrics, error) {
+	s, err := expvarGetStore(expvarRoot, name)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpvarMetrics{aggrMetrics: aggrMetrics{
+		name: name,
+		s:    s,
+	}}, nil
+}
+
+// Name of expvar map metrics publishes to.
+const expvarRoot = "batch_query"
+
+var (
+	expvarMux    sync.Mutex
+	expvarStores = make(map[string]*aggrStore)
+)
+
+// Get existing or make new store published under given name of root map.
+func expvarGetStore(root, name string) (*aggrStore, error) {
+	expvarMux.Lock()
+	defer expvarMux.Unlock()
+	if s, ok := expvarStores[root+"."+name]; ok {
+		return s, nil
+	}
+	var mp *expvar.Map
+	switch v := expvar.Get(root).(type) {
+	case nil:
+		mp = expvar.NewMap(root)
+	case *expvar.Map:
+		mp = v
+	default:
+		return nil, fmt.Errorf("batch_query: expvar name %q is already published as %T", root, v)
+	}
+	sub := new(expvar.Map).Init()
+	mp.Set(name, sub)
+	s := newAggrStore()
+	// Skip name label, it's a key of sub.
+	s.hook = func(x *aggrSeries) { expvarPublish(sub, x, x.labels[2:]) }
+	expvarStores[root+"."+name] = s
+	return s, nil
+}
+
+// Publish series to mp. Each pair of labels makes nested map keyed by label value.
+func expvarPublish(mp *expvar.Map, x *aggrSeries, labels []string) {
+	key := x.family
+	for i := 1; i < len(labels); i += 2 {
+		sub, ok := mp.Get(key).(*expvar.Map)
+		if !ok {
+			sub = new(expvar.Map).Init()
+			mp.Set(key, sub)
+		}
+		mp, key = sub, labels[i]
+	}
+	mp.Set(key, x)
+}
+
+// String implements expvar.Var interface.
+func (x *aggrSeries) String() string {
+	if x.kind != aggrTimer {
+		return strconv.FormatInt(x.load(), 10)
+	}
+	var buf []byte
+	buf = append(buf, `{"count":`...)
+	buf = strconv.AppendInt(buf, atomic.LoadInt64(&x.count), 10)
+	buf = append(buf, `,"sum":`...)
+	buf = strconv.AppendFloat(buf, time.Duration(atomic.LoadInt64(&x.sum)).Seconds(), 'f', -1, 64)
+	buf = append(buf, `,"max":`...)
+	buf = strconv.AppendFloat(buf, time.Duration(atomic.LoadInt64(&x.peak)).Seconds(), 'f', -1, 64)
+	buf = append(buf, '}')
+	return string(buf)
+}
@@ -0,0 +1,80 @@
+package batch_query
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics("expvar_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Fetch()
+	m.OK(1500 * time.Millisecond)
+	m.Fetch()
+	m.NotFound()
+	m.BufferIn("full")
+	m.Batch()
+	m.BatchOK(500 * time.Millisecond)
+
+	var root map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("batch_query").String()), &root); err != nil {
+		t.Fatal(err)
+	}
+	got := root["expvar_test"]
+	const expected = `{
+		"batch_query_bufio": {
+			"full": 1
+		},
+		"batch_query_io": {
+			"batch": {
+				"in": 1,
+				"success": 1
+			},
+			"single": {
+				"in": 2,
+				"not_found": 1,
+				"success": 1
+			}
+		},
+		"batch_query_size": {
+			"batch": 0,
+			"buffer": 1,
+			"single": 0
+		},
+		"batch_query_timing": {
+			"batch": {
+				"count": 1,
+				"max": 0.5,
+				"sum": 0.5
+			},
+			"single": {
+				"count": 1,
+				"max": 1.5,
+				"sum": 1.5
+			}
+		}
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "batch_query_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name, "test"); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
@@ -0,0 +1,289 @@
+package batch_query
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	influxDefaultInterval   = 10 * time.Second
+	influxDefaultPacketSize = 1432
+)
+
+// InfluxMetrics is InfluxDB line protocol implementation of batch_query.MetricsWriter.
+//
+// Events aggregates in memory and periodically flushes to the underlying writer: one line per series, measurement is
+// a metric family, labels writes as tags. Counters and gauges writes to field "value", timers writes to fields
+// "count", "sum" and "max" (in seconds, maximum observation since previous successful flush).
+type InfluxMetrics struct {
+	aggrMetrics
+	conf influxConfig
+	w    io.Writer
+
+	mux   sync.Mutex
+	buf   bytes.Buffer
+	peaks []aggrPeak
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// InfluxOption describes InfluxMetrics option.
+type InfluxOption func(*influxConfig)
+
+type influxConfig struct {
+	interval time.Duration
+	tags     map[string]string
+	psize    int
+}
+
+// WithInfluxInterval sets flush interval.
+func WithInfluxInterval(interval time.Duration) InfluxOption {
+	return func(c *influxConfig) {
+		c.interval = interval
+	}
+}
+
+// WithInfluxTags sets tags to apply to all lines.
+func WithInfluxTags(tags map[string]string) InfluxOption {
+	return func(c *influxConfig) {
+		if c.tags == nil {
+			c.tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			c.tags[k] = v
+		}
+	}
+}
+
+// WithInfluxPacketSize sets maximum size of UDP packet. Lines batches into packets up to that size.
+func WithInfluxPacketSize(size int) InfluxOption {
+	return func(c *influxConfig) {
+		c.psize = size
+	}
+}
+
+var _, _, _ = NewInfluxMetrics, NewInfluxMetricsFile, NewInfluxMetricsUDP
+
+// NewInfluxMetrics makes new writer flushes lines to w.
+//
+// If w implements io.Closer it will be closed on Close call.
+func NewInfluxMetrics(name string, w io.Writer, opts ...InfluxOption) *InfluxMetrics {
+	m := &InfluxMetrics{
+		aggrMetrics: aggrMetrics{
+			name: name,
+			s:    newAggrStore(),
+		},
+		conf: influxConfig{
+			interval: influxDefaultInterval,
+			psize:    influxDefaultPacketSize,
+		},
+		w:    w,
+		done: make(chan struct{}),
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	if m.conf.interval <= 0 {
+		m.conf.interval = influxDefaultInterval
+	}
+	m.wg.Add(1)
+	go m.loop()
+	return m
+}
+
+// NewInfluxMetricsFile makes new writer appends lines to the file.
+func NewInfluxMetricsFile(name, path string, opts ...InfluxOption) (*InfluxMetrics, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewInfluxMetrics(name, f, opts...), nil
+}
+
+// NewInfluxMetricsUDP makes new writer sends lines to InfluxDB (or Telegraf) UDP listener.
+func NewInfluxMetricsUDP(name, addr string, opts ...InfluxOption) (*InfluxMetrics, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var c influxConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.psize <= 0 {
+		c.psize = influxDefaultPacketSize
+	}
+	return NewInfluxMetrics(name, &influxPacketWriter{conn: conn, size: c.psize}, opts...), nil
+}
+
+// Sync writes actual state of all series.
+func (m *InfluxMetrics) Sync() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.buf.Reset()
+	m.peaks = m.peaks[:0]
+	ts := strconv.FormatInt(time.Now().UnixNano(), 10)
+	m.s.each(func(x *aggrSeries) {
+		m.writeLine(x, ts)
+	})
+	if m.buf.Len() == 0 {
+		return nil
+	}
+	if _, err := m.w.Write(m.buf.Bytes()); err != nil {
+		return err
+	}
+	for _, p := range m.peaks {
+		p.x.resetPeak(p.peak)
+	}
+	return nil
+}
+
+// Close stops background flushing, writes the rest of data and closes underlying writer.
+func (m *InfluxMetrics) Close() (err error) {
+	m.once.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		err = m.Sync()
+		if c, ok := m.w.(io.Closer); ok {
+			if err1 := c.Close(); err == nil {
+				err = err1
+			}
+		}
+	})
+	return
+}
+
+func (m *InfluxMetrics) loop() {
+	defer m.wg.Done()
+	t := time.NewTicker(m.conf.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			_ = m.Sync()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *InfluxMetrics) writeLine(x *aggrSeries, ts string) {
+	influxEscape(&m.buf, x.family, ", ")
+	if len(m.conf.tags) > 0 {
+		// Merge const tags with labels, tags must be sorted by key.
+		tags := make([]string, 0, len(m.conf.tags)*2+len(x.labels))
+		for k, v := range m.conf.tags {
+			tags = append(tags, k, v)
+		}
+		tags = append(tags, x.labels...)
+		m.writeTags(influxSortPairs(tags))
+	} else {
+		m.writeTags(influxSortPairs(x.labels))
+	}
+	m.buf.WriteByte(' ')
+	switch x.kind {
+	case aggrTimer:
+		count, sum, peak := x.loadTimer()
+		m.peaks = append(m.peaks, aggrPeak{x, peak})
+		m.buf.WriteString("count=")
+		m.buf.WriteString(strconv.FormatInt(count, 10))
+		m.buf.WriteString("i,sum=")
+		m.buf.WriteString(strconv.FormatFloat(sum.Seconds(), 'f', -1, 64))
+		m.buf.WriteString(",max=")
+		m.buf.WriteString(strconv.FormatFloat(peak.Seconds(), 'f', -1, 64))
+	default:
+		m.buf.WriteString("value=")
+		m.buf.WriteString(strconv.FormatInt(x.load(), 10))
+		m.buf.WriteByte('i')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(ts)
+	m.buf.WriteByte('\n')
+}
+
+func (m *InfluxMetrics) writeTags(pairs []string) {
+	for i := 1; i < len(pairs); i += 2 {
+		if len(pairs[i]) == 0 {
+			// Empty tag values aren't allowed.
+			continue
+		}
+		m.buf.WriteByte(',')
+		influxEscape(&m.buf, pairs[i-1], ",= ")
+		m.buf.WriteByte('=')
+		influxEscape(&m.buf, pairs[i], ",= ")
+	}
+}
+
+// Sort key-value pairs by key.
+func influxSortPairs(pairs []string) []string {
+	if len(pairs) <= 2 {
+		return pairs
+	}
+	type kv struct{ k, v string }
+	buf := make([]kv, 0, len(pairs)/2)
+	for i := 1; i < len(pairs); i += 2 {
+		buf = append(buf, kv{pairs[i-1], pairs[i]})
+	}
+	sort.Slice(buf, func(i, j int) bool { return buf[i].k < buf[j].k })
+	r := make([]string, 0, len(pairs))
+	for i := 0; i < len(buf); i++ {
+		r = append(r, buf[i].k, buf[i].v)
+	}
+	return r
+}
+
+// Write s escaping special characters and newlines.
+func influxEscape(buf *bytes.Buffer, s, special string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\n' || c == '\r' {
+			// Line protocol can't carry line breaks, so they become spaces and escape as spaces do.
+			c = ' '
+		}
+		if c == '\\' || strings.IndexByte(special, c) != -1 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+}
+
+// Writer splits lines to UDP packets.
+type influxPacketWriter struct {
+	conn net.Conn
+	size int
+}
+
+func (w *influxPacketWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		lim := len(p)
+		if lim > w.size {
+			// Cut packet by the last line end fits to packet.
+			if i := bytes.LastIndexByte(p[:w.size], '\n'); i >= 0 {
+				lim = i + 1
+			} else if i = bytes.IndexByte(p, '\n'); i >= 0 {
+				// Single line exceeds packet size, send it as is.
+				lim = i + 1
+			}
+		}
+		var k int
+		k, err = w.conn.Write(p[:lim])
+		n += k
+		if err != nil {
+			return
+		}
+		p = p[lim:]
+	}
+	return
+}
+
+func (w *influxPacketWriter) Close() error {
+	return w.conn.Close()
+}
@@ -0,0 +1,50 @@
+package batch_query
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewInfluxMetrics("test\nq", &buf, WithInfluxInterval(time.Hour), WithInfluxTags(map[string]string{"host": "h1"}))
+	defer func() { _ = m.Close() }()
+
+	m.Fetch()
+	m.Fetch()
+	m.OK(time.Second)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`batch_query_size,entity=single,host=h1,query=test\ q value=1i `,
+		`batch_query_io,entity=single,host=h1,query=test\ q,type=in value=2i `,
+		`batch_query_timing,entity=single,host=h1,query=test\ q count=1i,sum=1,max=1 `,
+	} {
+		if !strings.Contains(out, "\n"+line) && !strings.HasPrefix(out, line) {
+			t.Errorf("line %q not found in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestInfluxEscape(t *testing.T) {
+	for _, tc := range []struct {
+		s, special, expect string
+	}{
+		{"size", ", ", "size"},
+		{"a,b c", ", ", `a\,b\ c`},
+		{"a=b", ", ", "a=b"},
+		{"a=b", ",= ", `a\=b`},
+		{`a\b`, ", ", `a\\b`},
+		// Line breaks escape as spaces.
+		{"queue\nsize\r", ", ", `queue\ size\ `},
+	} {
+		var buf bytes.Buffer
+		if influxEscape(&buf, tc.s, tc.special); buf.String() != tc.expect {
+			t.Errorf("%q: got %q, expected %q", tc.s, buf.String(), tc.expect)
+		}
+	}
+}
@@ -1,41 +1,117 @@
 package batch_query
 
-import "log"
+import (
+	"log"
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
+)
 
 // LogMetrics is Log implementation of batch_query.MetricsWriter.
 //
-// Don't use in production. Only for debug purposes.
+// Don't use in production without limits (see NewLogMetricsWithLimits). Only for debug purposes.
+//
+// Deprecated: use SlogMetrics instead.
 type LogMetrics struct {
 	name string
+	lim  *loglimit.Limiter
 }
 
-var _ = NewLogMetrics
+var _, _ = NewLogMetrics, NewLogMetricsWithLimits
 
 func NewLogMetrics(name string) *LogMetrics {
-	m := &LogMetrics{name}
+	m := &LogMetrics{name: name}
+	return m
+}
+
+// NewLogMetricsWithLimits makes new writer with sampling and/or rate limiting of events.
+func NewLogMetricsWithLimits(name string, opts ...LogLimitOption) *LogMetrics {
+	m := &LogMetrics{name: name}
+	m.lim = newLogLimiter(opts, func(event string, n uint64) {
+		log.Printf("batch_query %s: suppressed %d events of type %s\n", name, n, event)
+	})
 	return m
 }
 
-func (m LogMetrics) FindIn() {
+func (m LogMetrics) Fetch() {
+	if !m.lim.Allow("Fetch") {
+		return
+	}
 	log.Printf("batch_query %s: new item income\n", m.name)
 }
 
-func (m LogMetrics) FindOut() {
-	log.Printf("batch_query %s: item outcome\n", m.name)
+func (m LogMetrics) OK(dur time.Duration) {
+	if !m.lim.Allow("OK") {
+		return
+	}
+	log.Printf("batch_query %s: item fetched in %s\n", m.name, dur)
+}
+
+func (m LogMetrics) NotFound() {
+	if !m.lim.Allow("NotFound") {
+		return
+	}
+	log.Printf("batch_query %s: item not found\n", m.name)
+}
+
+func (m LogMetrics) Timeout() {
+	if !m.lim.Allow("Timeout") {
+		return
+	}
+	log.Printf("batch_query %s: item fetch timed out\n", m.name)
 }
 
-func (m LogMetrics) FindFail() {
+func (m LogMetrics) Interrupt() {
+	if !m.lim.Allow("Interrupt") {
+		return
+	}
+	log.Printf("batch_query %s: item fetch interrupted\n", m.name)
+}
+
+func (m LogMetrics) Fail() {
+	if !m.lim.Allow("Fail") {
+		return
+	}
 	log.Printf("batch_query %s: item processing fail\n", m.name)
 }
 
-func (m LogMetrics) BatchIn() {
+func (m LogMetrics) Batch() {
+	if !m.lim.Allow("Batch") {
+		return
+	}
 	log.Printf("batch_query %s: new batch completed\n", m.name)
 }
 
-func (m LogMetrics) BatchOut() {
-	log.Printf("batch_query %s: batch processed\n", m.name)
+func (m LogMetrics) BatchOK(dur time.Duration) {
+	if !m.lim.Allow("BatchOK") {
+		return
+	}
+	log.Printf("batch_query %s: batch processed in %s\n", m.name, dur)
 }
 
 func (m LogMetrics) BatchFail() {
+	if !m.lim.Allow("BatchFail") {
+		return
+	}
 	log.Printf("batch_query %s: batch failed\n", m.name)
 }
+
+func (m LogMetrics) BufferIn(reason string) {
+	if !m.lim.Allow("BufferIn") {
+		return
+	}
+	log.Printf("batch_query %s: item come to the buffer due to reason %s\n", m.name, reason)
+}
+
+func (m LogMetrics) BufferOut() {
+	if !m.lim.Allow("BufferOut") {
+		return
+	}
+	log.Printf("batch_query %s: item leave the buffer\n", m.name)
+}
+
+// Close stops reporting of suppressed events.
+func (m LogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
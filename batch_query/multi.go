@@ -0,0 +1,131 @@
+package batch_query
+
+import (
+	"io"
+	"time"
+)
+
+// MultiMetrics is fan-out implementation of batch_query.MetricsWriter.
+//
+// Each event forwards to all child writers in order they were specified. Allows to use several writers at once, eg
+// Prometheus writer in production together with log writer for debug purposes.
+type MultiMetrics struct {
+	w    []MetricsWriter
+	conf multiConfig
+}
+
+// MultiOption describes MultiMetrics option.
+type MultiOption func(*multiConfig)
+
+type multiConfig struct {
+	isolate bool
+	onPanic func(w MetricsWriter, r any)
+}
+
+// WithMultiRecover enables panic isolation: panic in child writer recovers and doesn't affect the rest of writers.
+// Optional fn receives failed writer and recovered value.
+func WithMultiRecover(fn func(w MetricsWriter, r any)) MultiOption {
+	return func(c *multiConfig) {
+		c.isolate = true
+		c.onPanic = fn
+	}
+}
+
+var _, _ = NewMultiMetrics, NewMultiMetricsWithOptions
+
+// NewMultiMetrics makes new writer forwards events to given writers.
+func NewMultiMetrics(writers ...MetricsWriter) *MultiMetrics {
+	return NewMultiMetricsWithOptions(writers)
+}
+
+// NewMultiMetricsWithOptions makes new writer forwards events to given writers and applies given options to it.
+//
+// Nil writers skips.
+func NewMultiMetricsWithOptions(writers []MetricsWriter, opts ...MultiOption) *MultiMetrics {
+	m := &MultiMetrics{w: make([]MetricsWriter, 0, len(writers))}
+	for _, w := range writers {
+		if w != nil {
+			m.w = append(m.w, w)
+		}
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	return m
+}
+
+func (m MultiMetrics) Fetch() {
+	m.each(func(w MetricsWriter) { w.Fetch() })
+}
+
+func (m MultiMetrics) OK(dur time.Duration) {
+	m.each(func(w MetricsWriter) { w.OK(dur) })
+}
+
+func (m MultiMetrics) NotFound() {
+	m.each(func(w MetricsWriter) { w.NotFound() })
+}
+
+func (m MultiMetrics) Timeout() {
+	m.each(func(w MetricsWriter) { w.Timeout() })
+}
+
+func (m MultiMetrics) Interrupt() {
+	m.each(func(w MetricsWriter) { w.Interrupt() })
+}
+
+func (m MultiMetrics) Fail() {
+	m.each(func(w MetricsWriter) { w.Fail() })
+}
+
+func (m MultiMetrics) Batch() {
+	m.each(func(w MetricsWriter) { w.Batch() })
+}
+
+func (m MultiMetrics) BatchOK(dur time.Duration) {
+	m.each(func(w MetricsWriter) { w.BatchOK(dur) })
+}
+
+func (m MultiMetrics) BatchFail() {
+	m.each(func(w MetricsWriter) { w.BatchFail() })
+}
+
+func (m MultiMetrics) BufferIn(reason string) {
+	m.each(func(w MetricsWriter) { w.BufferIn(reason) })
+}
+
+func (m MultiMetrics) BufferOut() {
+	m.each(func(w MetricsWriter) { w.BufferOut() })
+}
+
+// Close closes all child writers implement io.Closer and returns the first occurred error.
+func (m MultiMetrics) Close() (err error) {
+	m.each(func(w MetricsWriter) {
+		if c, ok := w.(io.Closer); ok {
+			if err1 := c.Close(); err == nil {
+				err = err1
+			}
+		}
+	})
+	return
+}
+
+func (m MultiMetrics) each(fn func(w MetricsWriter)) {
+	for i := 0; i < len(m.w); i++ {
+		if m.conf.isolate {
+			m.safe(m.w[i], fn)
+		} else {
+			fn(m.w[i])
+		}
+	}
+}
+
+// Call fn and recover panic.
+func (m MultiMetrics) safe(w MetricsWriter, fn func(w MetricsWriter)) {
+	defer func() {
+		if r := recover(); r != nil && m.conf.onPanic != nil {
+			m.conf.onPanic(w, r)
+		}
+	}()
+	fn(w)
+}
@@ -0,0 +1,71 @@
+package batch_query
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Child writer logs its id on each event and optionally panics.
+type multiTestWriter struct {
+	MetricsWriter
+	id   int
+	log  *[]int
+	fail bool
+}
+
+func (w *multiTestWriter) Fetch() {
+	*w.log = append(*w.log, w.id)
+	if w.fail {
+		panic("writer failed")
+	}
+}
+
+func TestMultiMetrics(t *testing.T) {
+	var log []int
+	a := &multiTestWriter{id: 1, log: &log}
+	b := &multiTestWriter{id: 2, log: &log}
+	c := &multiTestWriter{id: 3, log: &log}
+
+	t.Run("order", func(t *testing.T) {
+		log = log[:0]
+		NewMultiMetrics(a, nil, b, c).Fetch()
+		if s := fmt.Sprint(log); s != "[1 2 3]" {
+			t.Errorf("got order %s, expected [1 2 3]", s)
+		}
+	})
+	t.Run("recover", func(t *testing.T) {
+		log = log[:0]
+		b.fail = true
+		defer func() { b.fail = false }()
+		var (
+			failed MetricsWriter
+			value  any
+		)
+		m := NewMultiMetricsWithOptions([]MetricsWriter{a, b, c}, WithMultiRecover(func(w MetricsWriter, r any) {
+			failed, value = w, r
+		}))
+		m.Fetch()
+		// Panic of one writer doesn't affect the rest of writers.
+		if s := fmt.Sprint(log); s != "[1 2 3]" {
+			t.Errorf("got order %s, expected [1 2 3]", s)
+		}
+		if failed != b || value != "writer failed" {
+			t.Errorf("got failed writer %v and value %v", failed, value)
+		}
+	})
+	t.Run("panic", func(t *testing.T) {
+		log = log[:0]
+		b.fail = true
+		defer func() { b.fail = false }()
+		defer func() {
+			if r := recover(); r != "writer failed" {
+				t.Errorf("panic expected, got %v", r)
+			}
+			// Writers after failed one don't receive event without isolation.
+			if s := fmt.Sprint(log); s != "[1 2]" {
+				t.Errorf("got order %s, expected [1 2]", s)
+			}
+		}()
+		NewMultiMetrics(a, b, c).Fetch()
+	})
+}
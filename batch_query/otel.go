@@ -0,0 +1,211 @@
+package batch_query
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of batch_query.MetricsWriter.
+type OTelMetrics struct {
+	name string
+	// Precomputed attributes of fixed labels combinations.
+	single, batch, buffer metric.MeasurementOption
+	ins                   *otelInstruments
+	done                  *uint32
+}
+
+type otelInstruments struct {
+	size   metric.Int64UpDownCounter
+	io     metric.Int64Counter
+	bufIO  metric.Int64Counter
+	timing metric.Float64Histogram
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/batch_query"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(name string, opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		name: name,
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+	}
+	m.single = m.entityAttr(single)
+	m.batch = m.entityAttr(batch)
+	m.buffer = m.entityAttr(buffer)
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.size = otelCheck(meter.Int64UpDownCounter("batch_query_size",
+		metric.WithDescription("Indicates entities distribution by types.")))
+	ins.io = otelCheck(meter.Int64Counter("batch_query_io",
+		metric.WithDescription("How many entities processed.")))
+	ins.bufIO = otelCheck(meter.Int64Counter("batch_query_bufio",
+		metric.WithDescription("Buffer operations.")))
+
+	ins.timing = otelCheck(meter.Float64Histogram("batch_query_timing",
+		metric.WithDescription("How many worker waits due to delayed execution."),
+		metric.WithUnit("s")))
+
+	return ins
+}
+
+func (m OTelMetrics) Fetch() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, 1, m.single)
+	m.ins.io.Add(ctx, 1, m.ioAttr(single, ioIn))
+}
+
+func (m OTelMetrics) OK(dur time.Duration) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.single)
+	m.ins.io.Add(ctx, 1, m.ioAttr(single, ioOK))
+	m.ins.timing.Record(ctx, dur.Seconds(), m.single)
+}
+
+func (m OTelMetrics) NotFound() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.single)
+	m.ins.io.Add(ctx, 1, m.ioAttr(single, io404))
+}
+
+func (m OTelMetrics) Timeout() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.single)
+	m.ins.io.Add(ctx, 1, m.ioAttr(single, ioTO))
+}
+
+func (m OTelMetrics) Interrupt() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.single)
+	m.ins.io.Add(ctx, 1, m.ioAttr(single, ioInt))
+}
+
+func (m OTelMetrics) Fail() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.single)
+	m.ins.io.Add(ctx, 1, m.ioAttr(single, ioFail))
+}
+
+func (m OTelMetrics) Batch() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, 1, m.batch)
+	m.ins.io.Add(ctx, 1, m.ioAttr(batch, ioIn))
+}
+
+func (m OTelMetrics) BatchOK(dur time.Duration) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.batch)
+	m.ins.io.Add(ctx, 1, m.ioAttr(batch, ioOK))
+	m.ins.timing.Record(ctx, dur.Seconds(), m.batch)
+}
+
+func (m OTelMetrics) BatchFail() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.batch)
+	m.ins.io.Add(ctx, 1, m.ioAttr(batch, ioFail))
+}
+
+func (m OTelMetrics) BufferIn(reason string) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, 1, m.buffer)
+	m.ins.bufIO.Add(ctx, 1, metric.WithAttributes(attribute.String("query", m.name), attribute.String("reason", reason)))
+}
+
+func (m OTelMetrics) BufferOut() {
+	if m.closed() {
+		return
+	}
+	m.ins.size.Add(context.Background(), -1, m.buffer)
+}
+
+func (m OTelMetrics) entityAttr(entity string) metric.MeasurementOption {
+	return metric.WithAttributeSet(attribute.NewSet(attribute.String("query", m.name), attribute.String("entity", entity)))
+}
+
+func (m OTelMetrics) ioAttr(entity, typ string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("query", m.name), attribute.String("entity", entity), attribute.String("type", typ))
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports.
+func (m OTelMetrics) Close() error {
+	atomic.StoreUint32(m.done, 1)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
@@ -0,0 +1,108 @@
+package batch_query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))))
+
+	m.Fetch()
+	m.Fetch()
+	m.Fetch()
+	m.BufferIn("size")
+	m.BufferIn("size")
+	m.BufferOut()
+	m.Batch()
+	m.BatchOK(2 * time.Millisecond)
+	m.OK(time.Millisecond)
+	m.NotFound()
+
+	rm := otelCollect(t, r)
+	otelExpectSum(t, rm, "batch_query_size", 1, "query", "test", "entity", "single")
+	otelExpectSum(t, rm, "batch_query_size", 0, "query", "test", "entity", "batch")
+	otelExpectSum(t, rm, "batch_query_size", 1, "query", "test", "entity", "buffer")
+	otelExpectSum(t, rm, "batch_query_io", 3, "query", "test", "entity", "single", "type", "in")
+	otelExpectSum(t, rm, "batch_query_io", 1, "query", "test", "entity", "single", "type", "success")
+	otelExpectSum(t, rm, "batch_query_io", 1, "query", "test", "entity", "single", "type", "not_found")
+	otelExpectSum(t, rm, "batch_query_io", 1, "query", "test", "entity", "batch", "type", "success")
+	otelExpectSum(t, rm, "batch_query_bufio", 2, "query", "test", "reason", "size")
+	otelExpectHist(t, rm, "batch_query_timing", 1, .001, "query", "test", "entity", "single")
+	otelExpectHist(t, rm, "batch_query_timing", 1, .002, "query", "test", "entity", "batch")
+
+	t.Run("close", func(t *testing.T) {
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		m.Fetch()
+		otelExpectSum(t, otelCollect(t, r), "batch_query_io", 3, "query", "test", "entity", "single", "type", "in")
+	})
+}
+
+func otelCollect(t *testing.T, r *sdkmetric.ManualReader) (rm metricdata.ResourceMetrics) {
+	t.Helper()
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// Find metric by name.
+func otelMetric(rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for _, mt := range sm.Metrics {
+			if mt.Name == name {
+				return mt
+			}
+		}
+	}
+	return metricdata.Metrics{}
+}
+
+// Check if attributes set consists of exactly given key-value pairs.
+func otelAttrsMatch(set attribute.Set, kv ...string) bool {
+	if set.Len() != len(kv)/2 {
+		return false
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if v, ok := set.Value(attribute.Key(kv[i])); !ok || v.AsString() != kv[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func otelExpectSum(t *testing.T, rm metricdata.ResourceMetrics, name string, value int64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Value != value {
+				t.Errorf("%s%v: got %d, expected %d", name, kv, p.Value, value)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
+
+func otelExpectHist(t *testing.T, rm metricdata.ResourceMetrics, name string, count uint64, sum float64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Histogram[float64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Count != count || p.Sum != sum {
+				t.Errorf("%s%v: got count %d sum %g, expected count %d sum %g", name, kv, p.Count, p.Sum, count, sum)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
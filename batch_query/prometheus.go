@@ -1,7 +1,10 @@
 package batch_query
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -22,109 +25,299 @@ const (
 // PrometheusMetrics is a Prometheus implementation of batch_query.MetricsWriter.
 type PrometheusMetrics struct {
 	name string
+	hist string
 	prec time.Duration
+	sec  bool
+	exm  func() prometheus.Labels
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+	lim  promLimit
+}
+
+// Collection of vectors registered in the same registry.
+type promVecs struct {
+	size   *prometheus.GaugeVec
+	io     *prometheus.CounterVec
+	bufIO  *prometheus.CounterVec
+	timing *promHistVec
+
+	labelOverflow *prometheus.CounterVec
+
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
 }
 
 var (
-	promSize   *prometheus.GaugeVec
-	promIO     *prometheus.CounterVec
-	promBufIO  *prometheus.CounterVec
-	promTiming *prometheus.HistogramVec
+	// Default buckets of timing metrics, in units of precision.
+	promBuckets = append(prometheus.DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000, 5000}...)
+
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
 
-	_ = NewPrometheusMetrics
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
 )
 
 func NewPrometheusMetrics(name string) *PrometheusMetrics {
-	return NewPrometheusMetricsWP(name, time.Nanosecond)
+	return NewPrometheusMetricsWithOptions(name)
 }
 
+// NewPrometheusMetricsWP makes new writer observes durations in units of given precision.
+//
+// Kept for compatibility, use NewPrometheusMetricsWithOptions with WithPrecision or WithSeconds instead.
 func NewPrometheusMetricsWP(name string, precision time.Duration) *PrometheusMetrics {
-	if precision == 0 {
-		precision = time.Nanosecond
+	return NewPrometheusMetricsWithOptions(name, WithPrecision(precision))
+}
+
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+//
+// Timing options (precision, seconds, buckets and native histograms settings) belong to the query name: writers of the
+// name observe durations with options of its first writer, options of the rest are ignored.
+func NewPrometheusMetricsWithOptions(name string, opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
 	}
+	vec := getPromVecs(&c)
+	t := vec.timing.adopt(name, &c)
 	m := &PrometheusMetrics{
 		name: name,
-		prec: precision,
+		hist: t.hist,
+		prec: t.prec,
+		sec:  t.sec,
+		exm:  c.exemplar,
+		vec:  vec,
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+		lim:  promLimit{limit: c.labelLimit, logger: c.labelLogger},
 	}
+	m.hnd.Store(newPromHandles(m.vec, name, m.hist, m.lim))
 	return m
 }
 
-func init() {
-	promSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "batch_query_size",
-		Help: "Indicates entities distribution by types.",
-	}, []string{"query", "entity"})
-	promIO = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "batch_query_io",
-		Help: "How many entities processed.",
-	}, []string{"query", "entity", "type"})
-	promBufIO = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "batch_query_bufio",
-		Help: "Buffer operations.",
-	}, []string{"query", "reason"})
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
+}
+
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
+
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.size = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "batch_query_size",
+		Help:        "Indicates entities distribution by types.",
+		ConstLabels: c.constLabels,
+	}, []string{"query", "entity"})).(*prometheus.GaugeVec)
+	v.io = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "batch_query_io",
+		Help:        "How many entities processed.",
+		ConstLabels: c.constLabels,
+	}, []string{"query", "entity", "type"})).(*prometheus.CounterVec)
+	v.bufIO = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "batch_query_bufio",
+		Help:        "Buffer operations.",
+		ConstLabels: c.constLabels,
+	}, []string{"query", "reason"})).(*prometheus.CounterVec)
+
+	v.timing = v.register(newPromHistVec(prometheus.HistogramOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "batch_query_timing",
+		Help:        "How many worker waits due to delayed execution.",
+		ConstLabels: c.constLabels,
+	}, []string{"query", "entity"})).(*promHistVec)
+
+	v.labelOverflow = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "batch_query_label_overflow",
+		Help:        "How many events with label values over cardinality limit folded into __other__ value.",
+		ConstLabels: c.constLabels,
+	}, []string{"query", "label"})).(*prometheus.CounterVec)
+
+	return v
+}
 
-	buckets := append(prometheus.DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000, 5000}...)
-	promTiming = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "batch_query_timing",
-		Help:    "How many worker waits due to delayed execution.",
-		Buckets: buckets,
-	}, []string{"query", "entity"})
+// Convert duration to units of observation.
+func (m PrometheusMetrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
 
-	prometheus.MustRegister(promSize, promIO, promBufIO, promTiming)
+// Observe duration with exemplar if provider specified.
+func (m PrometheusMetrics) observe(o prometheus.Observer, dur time.Duration) {
+	v := m.units(dur)
+	if m.exm != nil {
+		if lbl := m.exm(); len(lbl) > 0 && promExemplarRunes(lbl) <= prometheus.ExemplarMaxRunes {
+			if eo, ok := o.(prometheus.ExemplarObserver); ok {
+				eo.ObserveWithExemplar(v, lbl)
+				return
+			}
+		}
+	}
+	o.Observe(v)
+}
+
+// Count runes of exemplar labels.
+func promExemplarRunes(lbl prometheus.Labels) (n int) {
+	for k, v := range lbl {
+		n += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	return
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
 }
 
 func (m PrometheusMetrics) Fetch() {
-	promSize.WithLabelValues(m.name, single).Inc()
-	promIO.WithLabelValues(m.name, single, ioIn).Inc()
+	h := m.handles()
+	h.sizeSingle.Inc()
+	h.singleIn.Inc()
 }
 
 func (m PrometheusMetrics) OK(dur time.Duration) {
-	promSize.WithLabelValues(m.name, single).Dec()
-	promIO.WithLabelValues(m.name, single, ioOK).Inc()
-	promTiming.WithLabelValues(m.name, single).Observe(float64(dur / m.prec))
+	h := m.handles()
+	h.sizeSingle.Dec()
+	h.singleOK.Inc()
+	m.observe(h.timingSingle, dur)
 }
 
 func (m PrometheusMetrics) NotFound() {
-	promSize.WithLabelValues(m.name, single).Dec()
-	promIO.WithLabelValues(m.name, single, io404).Inc()
+	h := m.handles()
+	h.sizeSingle.Dec()
+	h.singleNotFound.Inc()
 }
 
 func (m PrometheusMetrics) Timeout() {
-	promSize.WithLabelValues(m.name, single).Dec()
-	promIO.WithLabelValues(m.name, single, ioTO).Inc()
+	h := m.handles()
+	h.sizeSingle.Dec()
+	h.singleTimeout.Inc()
 }
 
 func (m PrometheusMetrics) Interrupt() {
-	promSize.WithLabelValues(m.name, single).Dec()
-	promIO.WithLabelValues(m.name, single, ioInt).Inc()
+	h := m.handles()
+	h.sizeSingle.Dec()
+	h.singleInterrupt.Inc()
 }
 
 func (m PrometheusMetrics) Fail() {
-	promSize.WithLabelValues(m.name, single).Dec()
-	promIO.WithLabelValues(m.name, single, ioFail).Inc()
+	h := m.handles()
+	h.sizeSingle.Dec()
+	h.singleFail.Inc()
 }
 
 func (m PrometheusMetrics) Batch() {
-	promSize.WithLabelValues(m.name, batch).Inc()
-	promIO.WithLabelValues(m.name, batch, ioIn).Inc()
+	h := m.handles()
+	h.sizeBatch.Inc()
+	h.batchIn.Inc()
 }
 
 func (m PrometheusMetrics) BatchOK(dur time.Duration) {
-	promSize.WithLabelValues(m.name, batch).Dec()
-	promIO.WithLabelValues(m.name, batch, ioOK).Inc()
-	promTiming.WithLabelValues(m.name, batch).Observe(float64(dur / m.prec))
+	h := m.handles()
+	h.sizeBatch.Dec()
+	h.batchOK.Inc()
+	m.observe(h.timingBatch, dur)
 }
 
 func (m PrometheusMetrics) BatchFail() {
-	promSize.WithLabelValues(m.name, batch).Dec()
-	promIO.WithLabelValues(m.name, batch, ioFail).Inc()
+	h := m.handles()
+	h.sizeBatch.Dec()
+	h.batchFail.Inc()
 }
 
 func (m PrometheusMetrics) BufferIn(reason string) {
-	promSize.WithLabelValues(m.name, buffer).Inc()
-	promBufIO.WithLabelValues(m.name, reason).Inc()
+	h := m.handles()
+	h.sizeBuffer.Inc()
+	h.buffer.get(reason).in.Inc()
 }
 
 func (m PrometheusMetrics) BufferOut() {
-	promSize.WithLabelValues(m.name, buffer).Dec()
+	m.handles().sizeBuffer.Dec()
+}
+
+// Forget removes all series of the query and all buffer reasons seen by the writer.
+//
+// Writer may be used further, but series will appear again on the next events. Note, writers of the same query share
+// series, so forget them after all writers of the query become unused.
+func (m PrometheusMetrics) Forget() {
+	match := prometheus.Labels{"query": m.name}
+	m.vec.size.DeletePartialMatch(match)
+	m.vec.io.DeletePartialMatch(match)
+	m.vec.bufIO.DeletePartialMatch(match)
+	m.vec.timing.DeletePartialMatch(match)
+	m.vec.labelOverflow.DeletePartialMatch(match)
+	m.hnd.Store(nil)
+}
+
+// Close removes all series of the writer (see Forget) and releases shared collectors. The last closed writer of the
+// registerer unregisters them. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		m.Forget()
+		m.vec.timing.release(m.name)
+		putPromVecs(m.vec)
+	}
+	return nil
 }
@@ -0,0 +1,237 @@
+package batch_query
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of batch_query.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot queries. Metrics names and labels are the same as
+// PrometheusMetrics has, but query name is a constant label of each writer's collector, so both implementations can't
+// be mixed in the same registerer. Options WithNativeHistograms, WithoutClassicBuckets and WithExemplars are ignored.
+type CollectorMetrics struct {
+	name string
+	reg  prometheus.Registerer
+	prec time.Duration
+	sec  bool
+	desc *collDescs
+	lim  promLimit
+
+	sizeSingle, sizeBatch, sizeBuffer promreg.Counter
+
+	singleIn, singleOK, singleNotFound, singleTimeout, singleInterrupt, singleFail,
+	batchIn, batchOK, batchFail promreg.Counter
+
+	timingSingle, timingBatch *promreg.Histogram
+
+	buffer promCache[promreg.Counter]
+
+	labelOverflow promreg.Counter
+}
+
+type collDescs struct {
+	size, io, bufIO, timing *prometheus.Desc
+	labelOverflow           *prometheus.Desc
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same query and registerer share
+// counters, ie constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(name string, opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	m := &CollectorMetrics{
+		name:         name,
+		reg:          c.reg,
+		prec:         c.prec,
+		sec:          c.sec,
+		desc:         newCollDescs(&c, name),
+		lim:          promLimit{limit: c.labelLimit, logger: c.labelLogger},
+		timingSingle: promreg.NewHistogram(c.buckets()),
+		timingBatch:  promreg.NewHistogram(c.buckets()),
+	}
+	m.buffer.bind = func(string) *promreg.Counter {
+		return &promreg.Counter{}
+	}
+	if m.lim.limit > 0 {
+		overflow := m.lim.handler(func() { m.labelOverflow.Add(promreg.Shard(), 1) }, name)
+		m.buffer.limit, m.buffer.overflow = m.lim.limit, overflow
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig, name string) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		cl := prometheus.Labels{"query": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, cl)
+	}
+	return &collDescs{
+		size:   desc("batch_query_size", "Indicates entities distribution by types.", "entity"),
+		io:     desc("batch_query_io", "How many entities processed.", "entity", "type"),
+		bufIO:  desc("batch_query_bufio", "Buffer operations.", "reason"),
+		timing: desc("batch_query_timing", "How many worker waits due to delayed execution.", "entity"),
+		labelOverflow: desc("batch_query_label_overflow",
+			"How many events with label values over cardinality limit folded into __other__ value.", "label"),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.size, d.io, d.bufIO, d.timing, d.labelOverflow} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	gauge := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c.Load()), lvs...)
+	}
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	gauge(d.size, &m.sizeSingle, single)
+	gauge(d.size, &m.sizeBatch, batch)
+	gauge(d.size, &m.sizeBuffer, buffer)
+
+	counter(d.io, &m.singleIn, single, ioIn)
+	counter(d.io, &m.singleOK, single, ioOK)
+	counter(d.io, &m.singleNotFound, single, io404)
+	counter(d.io, &m.singleTimeout, single, ioTO)
+	counter(d.io, &m.singleInterrupt, single, ioInt)
+	counter(d.io, &m.singleFail, single, ioFail)
+	counter(d.io, &m.batchIn, batch, ioIn)
+	counter(d.io, &m.batchOK, batch, ioOK)
+	counter(d.io, &m.batchFail, batch, ioFail)
+	m.buffer.each(func(reason string, c *promreg.Counter) {
+		counter(d.bufIO, c, reason)
+	})
+
+	ch <- collHistogram(d.timing, m.timingSingle, m.units, single)
+	ch <- collHistogram(d.timing, m.timingBatch, m.units, batch)
+	if m.lim.limit > 0 {
+		ch <- prometheus.MustNewConstMetric(d.labelOverflow, prometheus.CounterValue, float64(m.labelOverflow.Load()),
+			promLimitLabel)
+	}
+}
+
+// Convert duration to units of observation.
+func (m *CollectorMetrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+func (m *CollectorMetrics) Fetch() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, 1)
+	m.singleIn.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) OK(dur time.Duration) {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleOK.Add(sh, 1)
+	m.timingSingle.Observe(sh, m.units(dur), dur)
+}
+
+func (m *CollectorMetrics) NotFound() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleNotFound.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Timeout() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleTimeout.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Interrupt() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleInterrupt.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Fail() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleFail.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Batch() {
+	sh := promreg.Shard()
+	m.sizeBatch.Add(sh, 1)
+	m.batchIn.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) BatchOK(dur time.Duration) {
+	sh := promreg.Shard()
+	m.sizeBatch.Add(sh, -1)
+	m.batchOK.Add(sh, 1)
+	m.timingBatch.Observe(sh, m.units(dur), dur)
+}
+
+func (m *CollectorMetrics) BatchFail() {
+	sh := promreg.Shard()
+	m.sizeBatch.Add(sh, -1)
+	m.batchFail.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) BufferIn(reason string) {
+	sh := promreg.Shard()
+	m.sizeBuffer.Add(sh, 1)
+	m.buffer.get(reason).Add(sh, 1)
+}
+
+func (m *CollectorMetrics) BufferOut() {
+	m.sizeBuffer.Add(promreg.Shard(), -1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
+
+// Make constant histogram of sharded one.
+func collHistogram(desc *prometheus.Desc, h *promreg.Histogram, units func(time.Duration) float64,
+	lvs ...string) prometheus.Metric {
+	upper, cnt, sum := h.Load()
+	buckets := make(map[float64]uint64, len(upper))
+	for j := range upper {
+		buckets[upper[j]] = cnt[j]
+	}
+	return prometheus.MustNewConstHistogram(desc, cnt[len(upper)], units(sum), buckets, lvs...)
+}
@@ -0,0 +1,156 @@
+package batch_query
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics bound to the query name. Binds once to avoid labels hashing and vectors locking on every event.
+type promHandles struct {
+	sizeSingle, sizeBatch, sizeBuffer prometheus.Gauge
+
+	singleIn, singleOK, singleNotFound, singleTimeout, singleInterrupt, singleFail,
+	batchIn, batchOK, batchFail prometheus.Counter
+
+	timingSingle, timingBatch prometheus.Observer
+
+	buffer promCache[promBufferHandles]
+}
+
+// Metrics bound to the query name and buffer reason.
+type promBufferHandles struct {
+	in prometheus.Counter
+}
+
+func newPromHandles(v *promVecs, name, hist string, lim promLimit) *promHandles {
+	h := &promHandles{
+		sizeSingle: v.size.WithLabelValues(name, single),
+		sizeBatch:  v.size.WithLabelValues(name, batch),
+		sizeBuffer: v.size.WithLabelValues(name, buffer),
+
+		singleIn:        v.io.WithLabelValues(name, single, ioIn),
+		singleOK:        v.io.WithLabelValues(name, single, ioOK),
+		singleNotFound:  v.io.WithLabelValues(name, single, io404),
+		singleTimeout:   v.io.WithLabelValues(name, single, ioTO),
+		singleInterrupt: v.io.WithLabelValues(name, single, ioInt),
+		singleFail:      v.io.WithLabelValues(name, single, ioFail),
+		batchIn:         v.io.WithLabelValues(name, batch, ioIn),
+		batchOK:         v.io.WithLabelValues(name, batch, ioOK),
+		batchFail:       v.io.WithLabelValues(name, batch, ioFail),
+
+		timingSingle: v.timing.vec(hist).WithLabelValues(name, single),
+		timingBatch:  v.timing.vec(hist).WithLabelValues(name, batch),
+	}
+	h.buffer.bind = func(reason string) *promBufferHandles {
+		return &promBufferHandles{in: v.bufIO.WithLabelValues(name, reason)}
+	}
+	if lim.limit > 0 {
+		overflow := lim.handler(v.labelOverflow.WithLabelValues(name, promLimitLabel).Inc, name)
+		h.buffer.limit, h.buffer.overflow = lim.limit, overflow
+	}
+	return h
+}
+
+// Get handles bound to the writer. Binds them again after Forget.
+func (m PrometheusMetrics) handles() *promHandles {
+	for {
+		if h := m.hnd.Load(); h != nil {
+			return h
+		}
+		m.hnd.CompareAndSwap(nil, newPromHandles(m.vec, m.name, m.hist, m.lim))
+	}
+}
+
+// Concurrent cache of metrics bound to values of dynamic label. Values are few (buckets, sub-queues, reasons), so
+// cache copies on write and reads without locks.
+type promCache[T any] struct {
+	mux  sync.Mutex
+	buf  atomic.Pointer[map[string]*T]
+	bind func(value string) *T
+
+	// Cardinality limit of values. Values over the limit folds into promOther.
+	limit    int
+	other    atomic.Pointer[T]
+	overflow func(value string)
+}
+
+func (c *promCache[T]) get(value string) *T {
+	if buf := c.buf.Load(); buf != nil {
+		if h, ok := (*buf)[value]; ok {
+			return h
+		}
+		if h := c.other.Load(); h != nil && len(*buf) >= c.limit {
+			c.overflow(value)
+			return h
+		}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var buf map[string]*T
+	if old := c.buf.Load(); old != nil {
+		if h, ok := (*old)[value]; ok {
+			return h
+		}
+		if c.limit > 0 && len(*old) >= c.limit {
+			h := c.other.Load()
+			if h == nil {
+				h = c.bind(promOther)
+				c.other.Store(h)
+			}
+			c.overflow(value)
+			return h
+		}
+		buf = make(map[string]*T, len(*old)+1)
+		for k, v := range *old {
+			buf[k] = v
+		}
+	} else {
+		buf = make(map[string]*T, 1)
+	}
+	h := c.bind(value)
+	buf[value] = h
+	c.buf.Store(&buf)
+	return h
+}
+
+func (c *promCache[T]) each(fn func(value string, h *T)) {
+	if buf := c.buf.Load(); buf != nil {
+		for value, h := range *buf {
+			fn(value, h)
+		}
+	}
+	if h := c.other.Load(); h != nil {
+		fn(promOther, h)
+	}
+}
+
+const (
+	// Value of dynamic label to fold values over cardinality limit.
+	promOther = "__other__"
+	// Name of dynamic label limited by WithLabelLimit.
+	promLimitLabel = "reason"
+)
+
+// Cardinality limit of dynamic label.
+type promLimit struct {
+	limit  int
+	logger *slog.Logger
+}
+
+// Make handler of values over the limit: counts them using inc and logs the first one.
+func (l promLimit) handler(inc func(), name string) func(value string) {
+	var once sync.Once
+	return func(value string) {
+		inc()
+		if l.logger != nil {
+			once.Do(func() {
+				l.logger.Warn("label cardinality limit exceeded", "query", name, "label", promLimitLabel,
+					"value", value, "limit", l.limit)
+			})
+		}
+	}
+}
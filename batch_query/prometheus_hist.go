@@ -0,0 +1,99 @@
+package batch_query
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Histogram vector shared by writers with different timing options.
+//
+// Each timing options set (see promConfig.histKey) observes into own vector, all vectors collects as one metric. Queue
+// name belongs to timing options of its first writer, so all series of the name have the same units and buckets.
+type promHistVec struct {
+	opts   prometheus.HistogramOpts
+	labels []string
+
+	mux   sync.RWMutex
+	vecs  map[string]*prometheus.HistogramVec
+	owner map[string]*promHistOwner
+}
+
+// Timing options of the name and count of writers use it.
+type promHistOwner struct {
+	hist string
+	prec time.Duration
+	sec  bool
+	refs int
+}
+
+func newPromHistVec(opts prometheus.HistogramOpts, labels []string) *promHistVec {
+	return &promHistVec{
+		opts:   opts,
+		labels: labels,
+		vecs:   make(map[string]*prometheus.HistogramVec),
+		owner:  make(map[string]*promHistOwner),
+	}
+}
+
+// Bind name to timing options of config, unless name already has an owner. Returns timing options of the name.
+func (h *promHistVec) adopt(name string, c *promConfig) promHistOwner {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if o, ok := h.owner[name]; ok {
+		o.refs++
+		return *o
+	}
+	hist := c.histKey()
+	if _, ok := h.vecs[hist]; !ok {
+		opts := h.opts
+		opts.Buckets = c.buckets()
+		opts.NativeHistogramBucketFactor = c.nhFactor
+		opts.NativeHistogramMaxBucketNumber = c.nhMaxBuckets
+		opts.NativeHistogramMinResetDuration = c.nhMinReset
+		h.vecs[hist] = prometheus.NewHistogramVec(opts, h.labels)
+	}
+	o := &promHistOwner{hist: hist, prec: c.prec, sec: c.sec, refs: 1}
+	h.owner[name] = o
+	return *o
+}
+
+// Release name by closed writer. Name without writers may be adopted again with other timing options.
+func (h *promHistVec) release(name string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if o, ok := h.owner[name]; ok {
+		if o.refs--; o.refs <= 0 {
+			delete(h.owner, name)
+		}
+	}
+}
+
+// Get vector of timing options.
+func (h *promHistVec) vec(hist string) *prometheus.HistogramVec {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.vecs[hist]
+}
+
+func (h *promHistVec) DeletePartialMatch(labels prometheus.Labels) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	for _, v := range h.vecs {
+		v.DeletePartialMatch(labels)
+	}
+}
+
+func (h *promHistVec) Describe(ch chan<- *prometheus.Desc) {
+	// Buckets don't affect description, so all vectors have the same one.
+	prometheus.NewHistogramVec(h.opts, h.labels).Describe(ch)
+}
+
+func (h *promHistVec) Collect(ch chan<- prometheus.Metric) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	for _, v := range h.vecs {
+		v.Collect(ch)
+	}
+}
@@ -0,0 +1,316 @@
+package batch_query
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPrometheusMetricsRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := []PrometheusOption{WithRegisterer(reg), WithNamespace("app"), WithSubsystem("svc"),
+		WithConstLabels(prometheus.Labels{"env": "test"})}
+	a := NewPrometheusMetricsWithOptions("a", opts...)
+	b := NewPrometheusMetricsWithOptions("b", opts...)
+	a.Fetch()
+	b.Fetch()
+	b.Fetch()
+
+	mfs := promGather(t, reg)
+	for _, name := range []string{"batch_query_io", "app_batch_query_io"} {
+		if _, ok := mfs[name]; ok {
+			t.Errorf("unexpected family %s", name)
+		}
+	}
+	mf, ok := mfs["app_svc_batch_query_io"]
+	if !ok {
+		t.Fatal("family app_svc_batch_query_io expected")
+	}
+	got := make(map[string]float64)
+	for _, m := range mf.GetMetric() {
+		lbl := promLabels(m)
+		if lbl["env"] != "test" {
+			t.Errorf("labels %v: expected env=test", lbl)
+		}
+		got[lbl["query"]] += m.GetCounter().GetValue()
+	}
+	if len(got) != 2 || got["a"] != 1 || got["b"] != 2 {
+		t.Errorf("app_svc_batch_query_io: got %v, expected a=1 b=2", got)
+	}
+	// Private registerer doesn't touch the default one.
+	if mfs, err := prometheus.DefaultGatherer.Gather(); err == nil {
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), "app_svc_") {
+				t.Errorf("family %s registered in default registerer", mf.GetName())
+			}
+		}
+	}
+
+	// The last closed writer unregisters collectors and releases registerer.
+	_ = a.Close()
+	if mfs := promGather(t, reg); len(mfs) == 0 {
+		t.Error("collectors of unclosed writer expected")
+	}
+	_ = b.Close()
+	_ = b.Close()
+	if mfs := promGather(t, reg); len(mfs) != 0 {
+		t.Errorf("no families expected after close, got %d", len(mfs))
+	}
+	promMux.Lock()
+	defer promMux.Unlock()
+	for k := range promIdx {
+		if k.reg == reg {
+			t.Error("closed registerer kept in index")
+		}
+	}
+}
+
+func TestPrometheusMetricsTimingOptions(t *testing.T) {
+	// Sum of durations observed by series of the query.
+	sum := func(t *testing.T, g prometheus.Gatherer, name string) (s float64) {
+		t.Helper()
+		for _, mt := range promGather(t, g)["batch_query_timing"].GetMetric() {
+			for _, lp := range mt.GetLabel() {
+				if lp.GetName() == "query" && lp.GetValue() == name {
+					s += mt.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+		return
+	}
+	t.Run("default registry", func(t *testing.T) {
+		a := NewPrometheusMetricsWP("timing_a", time.Millisecond)
+		b := NewPrometheusMetricsWP("timing_b", time.Microsecond)
+		for _, m := range []*PrometheusMetrics{a, b} {
+			m.OK(1500 * time.Microsecond)
+		}
+		if s := sum(t, prometheus.DefaultGatherer, "timing_a"); s != 1.5 {
+			t.Errorf("sum %v in milliseconds, want 1.5", s)
+		}
+		if s := sum(t, prometheus.DefaultGatherer, "timing_b"); s != 1500 {
+			t.Errorf("sum %v in microseconds, want 1500", s)
+		}
+		_ = a.Close()
+		_ = b.Close()
+	})
+	t.Run("private registry", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		a := NewPrometheusMetricsWithOptions("a", WithRegisterer(reg), WithSeconds())
+		// Writer of the same query observes with options of the first one.
+		a1 := NewPrometheusMetricsWithOptions("a", WithRegisterer(reg), WithPrecision(time.Millisecond))
+		b := NewPrometheusMetricsWithOptions("b", WithRegisterer(reg), WithPrecision(time.Millisecond),
+			WithBuckets(1, 2))
+		for _, m := range []*PrometheusMetrics{a, a1, b} {
+			m.OK(1500 * time.Microsecond)
+		}
+		if s := sum(t, reg, "a"); s != .003 {
+			t.Errorf("sum %v in seconds, want .003", s)
+		}
+		if s := sum(t, reg, "b"); s != 1.5 {
+			t.Errorf("sum %v in milliseconds, want 1.5", s)
+		}
+		for _, m := range []*PrometheusMetrics{a, a1, b} {
+			_ = m.Close()
+		}
+	})
+}
+
+func TestPrometheusMetricsExemplars(t *testing.T) {
+	long := prometheus.Labels{"trace_id": strings.Repeat("f", prometheus.ExemplarMaxRunes)}
+	for _, tc := range []struct {
+		name string
+		lbl  prometheus.Labels
+		want bool
+	}{
+		{"labels", prometheus.Labels{"trace_id": "abc"}, true},
+		{"empty", nil, false},
+		{"too long", long, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			m := NewPrometheusMetricsWithOptions("test", WithRegisterer(reg), WithSeconds(),
+				WithExemplars(func() prometheus.Labels { return tc.lbl }))
+			m.OK(500 * time.Millisecond)
+			var exm []*dto.Exemplar
+			for _, mt := range promGather(t, reg)["batch_query_timing"].GetMetric() {
+				for _, b := range mt.GetHistogram().GetBucket() {
+					if b.GetExemplar() != nil {
+						exm = append(exm, b.GetExemplar())
+					}
+				}
+			}
+			if !tc.want {
+				if len(exm) > 0 {
+					t.Errorf("no exemplars expected, got %v", exm)
+				}
+				return
+			}
+			if len(exm) != 1 {
+				t.Fatalf("one exemplar expected, got %d", len(exm))
+			}
+			if v := exm[0].GetValue(); v != .5 {
+				t.Errorf("exemplar value %v, want .5", v)
+			}
+			lp := exm[0].GetLabel()
+			if len(lp) != 1 || lp[0].GetName() != "trace_id" || lp[0].GetValue() != "abc" {
+				t.Errorf("exemplar labels %v, want trace_id=abc", lp)
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsLabelLimit(t *testing.T) {
+	type writer interface {
+		BufferIn(reason string)
+	}
+	for _, tc := range []struct {
+		name string
+		make func(t *testing.T, opts ...PrometheusOption) writer
+	}{
+		{"prometheus", func(t *testing.T, opts ...PrometheusOption) writer {
+			return NewPrometheusMetricsWithOptions("test", opts...)
+		}},
+		{"collector", func(t *testing.T, opts ...PrometheusOption) writer {
+			m, err := NewCollectorMetrics("test", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := tc.make(t, WithRegisterer(reg), WithLabelLimit(2), WithLabelLimitLogger(slog.New(h)))
+			for _, v := range []string{"a", "b", "c", "d", "a", "c"} {
+				m.BufferIn(v)
+			}
+			mfs := promGather(t, reg)
+			got := make(map[string]float64)
+			for _, mt := range mfs["batch_query_bufio"].GetMetric() {
+				got[promLabels(mt)["reason"]] = mt.GetCounter().GetValue()
+			}
+			if want := map[string]float64{"a": 2, "b": 1, promOther: 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("reason values: got %v, expected %v", got, want)
+			}
+			var overflow []string
+			for _, mt := range mfs["batch_query_label_overflow"].GetMetric() {
+				overflow = append(overflow, fmt.Sprintf("%v %v", promLabels(mt), mt.GetCounter().GetValue()))
+			}
+			if want := []string{"map[label:reason query:test] 3"}; !reflect.DeepEqual(overflow, want) {
+				t.Errorf("batch_query_label_overflow: got %q, expected %q", overflow, want)
+			}
+			// Only the first overflow is logged.
+			h.expect(t, "WARN label cardinality limit exceeded query=test label=reason value=c limit=2")
+		})
+	}
+}
+
+func TestPrometheusMetricsNativeHistograms(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []PrometheusOption
+		classic bool
+	}{
+		{"classic", []PrometheusOption{WithSeconds(), WithNativeHistograms(1.1, 100, time.Hour)}, true},
+		{"native", []PrometheusOption{WithSeconds(), WithNativeHistograms(1.1, 100, time.Hour),
+			WithoutClassicBuckets()}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			m := NewPrometheusMetricsWithOptions("test", append(tc.opts, WithRegisterer(reg))...)
+			for _, dur := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 10 * time.Millisecond, time.Second} {
+				m.OK(dur)
+			}
+			var h *dto.Histogram
+			for _, mt := range promGather(t, reg)["batch_query_timing"].GetMetric() {
+				if mt.GetHistogram().GetSampleCount() > 0 {
+					h = mt.GetHistogram()
+				}
+			}
+			if h == nil {
+				t.Fatal("observed batch_query_timing series expected")
+			}
+			promExpectNative(t, h, 4, tc.classic)
+		})
+	}
+}
+
+func BenchmarkPrometheusMetrics_OK(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Fetch()
+		m.OK(time.Millisecond)
+	}
+}
+
+func BenchmarkPrometheusMetrics_BufferIn(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.BufferIn("size")
+		m.BufferOut()
+	}
+}
+
+// Check native histogram: bucket factor 1.1 rounds to schema 3 (neighbour bounds ratio 2^(2^-3) ~ 1.09), spans cover
+// all deltas and absolute counts of populated buckets sum to count.
+func promExpectNative(t *testing.T, h *dto.Histogram, count uint64, classic bool) {
+	t.Helper()
+	if h.GetSampleCount() != count {
+		t.Errorf("sample count %d, expected %d", h.GetSampleCount(), count)
+	}
+	if h.Schema == nil || h.GetSchema() != 3 {
+		t.Errorf("schema %v, expected 3", h.Schema)
+	}
+	spans := h.GetPositiveSpan()
+	if len(spans) == 0 {
+		t.Fatal("positive spans expected")
+	}
+	var n uint32
+	for _, sp := range spans {
+		n += sp.GetLength()
+	}
+	if deltas := h.GetPositiveDelta(); int(n) != len(deltas) {
+		t.Errorf("spans cover %d buckets, got %d deltas", n, len(deltas))
+	}
+	var abs, total int64
+	for _, d := range h.GetPositiveDelta() {
+		abs += d
+		total += abs
+	}
+	if uint64(total)+h.GetZeroCount() != count {
+		t.Errorf("native buckets count %d, expected %d", uint64(total)+h.GetZeroCount(), count)
+	}
+	if has := len(h.GetBucket()) > 0; has != classic {
+		t.Errorf("classic buckets presence %t, expected %t", has, classic)
+	}
+}
+
+func promGather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		r[mf.GetName()] = mf
+	}
+	return r
+}
+
+func promLabels(m *dto.Metric) map[string]string {
+	r := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		r[lp.GetName()] = lp.GetValue()
+	}
+	return r
+}
@@ -0,0 +1,204 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Values of labels, the same as parent package uses.
+const (
+	single = "single"
+	batch  = "batch"
+	buffer = "buffer"
+
+	ioIn   = "in"
+	ioOK   = "success"
+	ioTO   = "timeout"
+	ioInt  = "interrupt"
+	io404  = "not_found"
+	ioFail = "fail"
+)
+
+// Metrics is a dependency-free Prometheus implementation of batch_query.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as batch_query.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	name    string
+	reg     *Registry
+	prec    time.Duration
+	sec     bool
+	desc    *descs
+	id      string
+	created time.Time
+
+	sizeSingle, sizeBatch, sizeBuffer promreg.Counter
+
+	singleIn, singleOK, singleNotFound, singleTimeout, singleInterrupt, singleFail,
+	batchIn, batchOK, batchFail promreg.Counter
+
+	timingSingle, timingBatch *promreg.Histogram
+
+	buffer promreg.Cache[promreg.Counter]
+}
+
+type descs struct {
+	size, io, bufIO, timing *promreg.Desc
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same query and registry share counters, ie constructor returns already registered writer.
+func NewMetrics(name string, opts ...Option) *Metrics {
+	c := config{
+		reg:  DefaultRegistry,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	m := &Metrics{
+		name:         name,
+		reg:          c.reg,
+		prec:         c.prec,
+		sec:          c.sec,
+		desc:         newDescs(&c, name),
+		id:           "batch_query|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels) + "|" + name,
+		created:      time.Now(),
+		timingSingle: promreg.NewHistogram(c.buckets()),
+		timingBatch:  promreg.NewHistogram(c.buckets()),
+	}
+	m.buffer.Bind = func(string) *promreg.Counter {
+		return &promreg.Counter{}
+	}
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config, name string) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		cl := map[string]string{"query": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, cl, labels...)
+	}
+	return &descs{
+		size:   desc("batch_query_size", "Indicates entities distribution by types.", "entity"),
+		io:     desc("batch_query_io", "How many entities processed.", "entity", "type"),
+		bufIO:  desc("batch_query_bufio", "Buffer operations.", "reason"),
+		timing: desc("batch_query_timing", "How many worker waits due to delayed execution.", "entity"),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	g.Gauge(d.size, &m.sizeSingle, single)
+	g.Gauge(d.size, &m.sizeBatch, batch)
+	g.Gauge(d.size, &m.sizeBuffer, buffer)
+
+	g.Counter(d.io, &m.singleIn, m.created, single, ioIn)
+	g.Counter(d.io, &m.singleOK, m.created, single, ioOK)
+	g.Counter(d.io, &m.singleNotFound, m.created, single, io404)
+	g.Counter(d.io, &m.singleTimeout, m.created, single, ioTO)
+	g.Counter(d.io, &m.singleInterrupt, m.created, single, ioInt)
+	g.Counter(d.io, &m.singleFail, m.created, single, ioFail)
+	g.Counter(d.io, &m.batchIn, m.created, batch, ioIn)
+	g.Counter(d.io, &m.batchOK, m.created, batch, ioOK)
+	g.Counter(d.io, &m.batchFail, m.created, batch, ioFail)
+	m.buffer.Each(func(reason string, c *promreg.Counter) {
+		g.Counter(d.bufIO, c, m.created, reason)
+	})
+
+	g.Histogram(d.timing, m.timingSingle, m.units, m.created, single)
+	g.Histogram(d.timing, m.timingBatch, m.units, m.created, batch)
+}
+
+// Convert duration to units of observation.
+func (m *Metrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+func (m *Metrics) Fetch() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, 1)
+	m.singleIn.Add(sh, 1)
+}
+
+func (m *Metrics) OK(dur time.Duration) {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleOK.Add(sh, 1)
+	m.timingSingle.Observe(sh, m.units(dur), dur)
+}
+
+func (m *Metrics) NotFound() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleNotFound.Add(sh, 1)
+}
+
+func (m *Metrics) Timeout() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleTimeout.Add(sh, 1)
+}
+
+func (m *Metrics) Interrupt() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleInterrupt.Add(sh, 1)
+}
+
+func (m *Metrics) Fail() {
+	sh := promreg.Shard()
+	m.sizeSingle.Add(sh, -1)
+	m.singleFail.Add(sh, 1)
+}
+
+func (m *Metrics) Batch() {
+	sh := promreg.Shard()
+	m.sizeBatch.Add(sh, 1)
+	m.batchIn.Add(sh, 1)
+}
+
+func (m *Metrics) BatchOK(dur time.Duration) {
+	sh := promreg.Shard()
+	m.sizeBatch.Add(sh, -1)
+	m.batchOK.Add(sh, 1)
+	m.timingBatch.Observe(sh, m.units(dur), dur)
+}
+
+func (m *Metrics) BatchFail() {
+	sh := promreg.Shard()
+	m.sizeBatch.Add(sh, -1)
+	m.batchFail.Add(sh, 1)
+}
+
+func (m *Metrics) BufferIn(reason string) {
+	sh := promreg.Shard()
+	m.sizeBuffer.Add(sh, 1)
+	m.buffer.Get(reason).Add(sh, 1)
+}
+
+func (m *Metrics) BufferOut() {
+	m.sizeBuffer.Add(promreg.Shard(), -1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
@@ -0,0 +1,88 @@
+package promtext
+
+import (
+	"sort"
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Option describes writer option.
+type Option func(*config)
+
+type config struct {
+	reg         *Registry
+	prec        time.Duration
+	namespace   string
+	subsystem   string
+	constLabels map[string]string
+	sec         bool
+	bkt         []float64
+}
+
+// WithRegistry sets registry to register writer in. DefaultRegistry is used by default.
+func WithRegistry(reg *Registry) Option {
+	return func(c *config) {
+		c.reg = reg
+	}
+}
+
+// WithPrecision sets units of timing metrics. Nanoseconds are used by default. Ignored if WithSeconds specified.
+func WithPrecision(prec time.Duration) Option {
+	return func(c *config) {
+		c.prec = prec
+	}
+}
+
+// WithNamespace sets namespace (prefix) of metrics names.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets subsystem of metrics names. Subsystem writes between namespace and name.
+func WithSubsystem(subsystem string) Option {
+	return func(c *config) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets labels to apply to all metrics of writer.
+func WithConstLabels(labels map[string]string) Option {
+	return func(c *config) {
+		if c.constLabels == nil {
+			c.constLabels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			c.constLabels[k] = v
+		}
+	}
+}
+
+// WithSeconds makes timing metrics to observe durations in seconds with default Prometheus buckets.
+func WithSeconds() Option {
+	return func(c *config) {
+		c.sec = true
+	}
+}
+
+// WithBuckets sets custom buckets of timing metrics, in units of observation.
+func WithBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.bkt = append([]float64(nil), buckets...)
+		sort.Float64s(c.bkt)
+	}
+}
+
+// Get buckets of timing metrics.
+func (c *config) buckets() []float64 {
+	switch {
+	case len(c.bkt) > 0:
+		return c.bkt
+	case c.sec:
+		return promreg.DefBuckets
+	default:
+		return promreg.PrecBuckets
+	}
+}
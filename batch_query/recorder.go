@@ -0,0 +1,214 @@
+package batch_query
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RecorderMetrics is in-memory implementation of batch_query.MetricsWriter designed for unit tests.
+//
+// Records every call with its arguments and keeps counters, gauges and timers the same way as aggregating writers do
+// (metric families and labels are the same as in PrometheusMetrics). Safe for concurrent use.
+//
+// Usage example:
+//
+//	m.AssertCount(t, "Timeout", 1)
+//	n := m.Counter("batch_query_io", "entity", "single", "type", "timeout")
+type RecorderMetrics struct {
+	aggrMetrics
+
+	mux sync.Mutex
+	buf []RecorderEvent
+}
+
+// RecorderEvent describes single recorded call.
+type RecorderEvent struct {
+	// Method name, eg "Fetch".
+	Method string
+	// Arguments in order of method signature.
+	Args []any
+}
+
+// TestingT is a subset of testing.TB used by assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+var _ = NewRecorderMetrics
+
+func NewRecorderMetrics(name string) *RecorderMetrics {
+	m := &RecorderMetrics{
+		aggrMetrics: aggrMetrics{
+			name: name,
+			s:    newAggrStore(),
+		},
+	}
+	return m
+}
+
+func (m *RecorderMetrics) Fetch() {
+	m.record("Fetch")
+	m.aggrMetrics.Fetch()
+}
+
+func (m *RecorderMetrics) OK(dur time.Duration) {
+	m.record("OK", dur)
+	m.aggrMetrics.OK(dur)
+}
+
+func (m *RecorderMetrics) NotFound() {
+	m.record("NotFound")
+	m.aggrMetrics.NotFound()
+}
+
+func (m *RecorderMetrics) Timeout() {
+	m.record("Timeout")
+	m.aggrMetrics.Timeout()
+}
+
+func (m *RecorderMetrics) Interrupt() {
+	m.record("Interrupt")
+	m.aggrMetrics.Interrupt()
+}
+
+func (m *RecorderMetrics) Fail() {
+	m.record("Fail")
+	m.aggrMetrics.Fail()
+}
+
+func (m *RecorderMetrics) Batch() {
+	m.record("Batch")
+	m.aggrMetrics.Batch()
+}
+
+func (m *RecorderMetrics) BatchOK(dur time.Duration) {
+	m.record("BatchOK", dur)
+	m.aggrMetrics.BatchOK(dur)
+}
+
+func (m *RecorderMetrics) BatchFail() {
+	m.record("BatchFail")
+	m.aggrMetrics.BatchFail()
+}
+
+func (m *RecorderMetrics) BufferIn(reason string) {
+	m.record("BufferIn", reason)
+	m.aggrMetrics.BufferIn(reason)
+}
+
+func (m *RecorderMetrics) BufferOut() {
+	m.record("BufferOut")
+	m.aggrMetrics.BufferOut()
+}
+
+// Events returns copy of recorded events log.
+func (m *RecorderMetrics) Events() []RecorderEvent {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]RecorderEvent(nil), m.buf...)
+}
+
+// Count returns how many times method was called.
+//
+// Optional args filters events by leading arguments. Arguments compares using ==, so their types must be the same as
+// in method signature.
+func (m *RecorderMetrics) Count(method string, args ...any) (n int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i := 0; i < len(m.buf); i++ {
+		if e := &m.buf[i]; e.Method == method && e.match(args) {
+			n++
+		}
+	}
+	return
+}
+
+// Counter returns value of counter. Labels must be specified as key-value pairs excluding query name, eg
+// m.Counter("batch_query_io", "entity", "single", "type", "timeout").
+func (m *RecorderMetrics) Counter(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrCounter {
+		return x.load()
+	}
+	return 0
+}
+
+// Gauge returns value of gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Gauge(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrGauge {
+		return x.load()
+	}
+	return 0
+}
+
+// Timer returns count and sum of timer observations. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Timer(family string, labels ...string) (count int64, sum time.Duration) {
+	if x := m.find(family, labels); x != nil && x.kind == aggrTimer {
+		count, sum = atomic.LoadInt64(&x.count), time.Duration(atomic.LoadInt64(&x.sum))
+	}
+	return
+}
+
+// Clear drops all recorded events and metrics.
+func (m *RecorderMetrics) Clear() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.buf = m.buf[:0]
+	m.s.reset()
+}
+
+// AssertCount checks if method was called exactly n times. Optional args filters events as in Count method.
+func (m *RecorderMetrics) AssertCount(t TestingT, method string, n int, args ...any) bool {
+	t.Helper()
+	if c := m.Count(method, args...); c != n {
+		t.Errorf("%s%s: expected %d calls, got %d", method, fmtArgs(args), n, c)
+		return false
+	}
+	return true
+}
+
+// AssertValue checks value of counter or gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) AssertValue(t TestingT, family string, value int64, labels ...string) bool {
+	t.Helper()
+	var v int64
+	if x := m.find(family, labels); x != nil && x.kind != aggrTimer {
+		v = x.load()
+	}
+	if v != value {
+		t.Errorf("%s%v: expected %d, got %d", family, labels, value, v)
+		return false
+	}
+	return true
+}
+
+func (m *RecorderMetrics) record(method string, args ...any) {
+	m.mux.Lock()
+	m.buf = append(m.buf, RecorderEvent{Method: method, Args: args})
+	m.mux.Unlock()
+}
+
+func (m *RecorderMetrics) find(family string, labels []string) *aggrSeries {
+	return m.s.find(family, append([]string{"query", m.name}, labels...)...)
+}
+
+// Check if leading arguments of event are equal to args.
+func (e *RecorderEvent) match(args []any) bool {
+	if len(args) > len(e.Args) {
+		return false
+	}
+	for i := 0; i < len(args); i++ {
+		if e.Args[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func fmtArgs(args []any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return fmt.Sprint(args)
+}
@@ -0,0 +1,56 @@
+package batch_query
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics("test")
+	m.Fetch()
+	m.Fetch()
+	m.OK(time.Millisecond)
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "Fetch", 2) ||
+		!m.AssertCount(&ft, "OK", 1, time.Millisecond) ||
+		!m.AssertValue(&ft, "batch_query_io", 2, "entity", "single", "type", "in") {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "Fail", 1) },
+			"Fail: expected 1 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertCount(t, "OK", 1, time.Second) },
+			"OK[1s]: expected 1 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertValue(t, "batch_query_size", 0, "entity", "single") },
+			"batch_query_size[entity single]: expected 0, got 1"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package batch_query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRewriteMetrics(t *testing.T) {
+	lower := func(value string) (string, bool) { return strings.ToLower(value), true }
+	for _, tc := range []struct {
+		name    string
+		opts    []RewriteOption
+		in, out string
+	}{
+		{"no options", nil, "user:1/a.b", "user:1/a.b"},
+		{"prefix", []RewriteOption{WithRewriteRules(RewritePrefix("user:", "user"))}, "user:18237", "user"},
+		{"prefix mismatch", []RewriteOption{WithRewriteRules(RewritePrefix("user:", "user"))}, "admin:1", "admin:1"},
+		{"regexp", []RewriteOption{WithRewriteRules(RewriteRegexp(`^(\w+)-\d+$`, "$1"))}, "shard-17", "shard"},
+		{"regexp mismatch", []RewriteOption{WithRewriteRules(RewriteRegexp(`^(\w+)-\d+$`, "$1"))}, "shard", "shard"},
+		{"callback", []RewriteOption{WithRewriteRules(lower)}, "SubQ", "subq"},
+		{"first matched rule wins", []RewriteOption{WithRewriteRules(RewritePrefix("user", "a"),
+			RewritePrefix("user:", "b"))}, "user:1", "a"},
+		{"rules of several options", []RewriteOption{WithRewriteRules(RewritePrefix("a", "x")),
+			WithRewriteRules(RewritePrefix("b", "y"))}, "b1", "y"},
+		{"sanitize", []RewriteOption{WithRewriteSanitize()}, "a.b c:d|e/\u0444-_1", "a_b_c_d_e__-_1"},
+		{"rule and sanitize", []RewriteOption{WithRewriteRules(RewriteRegexp(`\d+`, "{id}")), WithRewriteSanitize()},
+			"user:42", "user__id_"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := NewRecorderMetrics("test")
+			m := NewRewriteMetrics(rec, tc.opts...)
+			// The second call takes rewritten value from cache.
+			m.BufferIn(tc.in)
+			m.BufferIn(tc.in)
+			rec.AssertCount(t, "BufferIn", 2, tc.out)
+		})
+	}
+}
+
+func TestRewriteMetricsCache(t *testing.T) {
+	rec := NewRecorderMetrics("test")
+	m := NewRewriteMetrics(rec, WithRewriteRules(RewritePrefix("user:", "user")))
+	// Values over the cache limit are rewritten on each event.
+	for i := 0; i < 2*rewriteCacheSize; i++ {
+		m.BufferIn(fmt.Sprintf("user:%d", i))
+	}
+	rec.AssertCount(t, "BufferIn", 2*rewriteCacheSize, "user")
+	if n := len(*m.cache.buf.Load()); n != rewriteCacheSize {
+		t.Errorf("cached %d values, expected %d", n, rewriteCacheSize)
+	}
+}
+
+func TestRewriteSanitize(t *testing.T) {
+	for _, v := range []string{
+		"", "plain", "a.b.c", "k:v|c@0.5#t:1,x", "tab\tline\r\nend", "\u043a\u044d\u0448", "user/42?q=1",
+	} {
+		r := rewriteSanitize(v)
+		// Each rune maps to exactly one character, so values don't collapse.
+		if n := len([]rune(v)); len(r) != n {
+			t.Errorf("%q: got %q of length %d, expected %d", v, r, len(r), n)
+		}
+		// Sanitized value is a valid Graphite path segment and contains no StatsD special characters.
+		if r != "" && graphiteSanitize(r) != r {
+			t.Errorf("%q: got %q, changed by Graphite sanitization to %q", v, r, graphiteSanitize(r))
+		}
+		if strings.ContainsAny(r, ".:|@#, \t\r\n") {
+			t.Errorf("%q: got %q, contains StatsD special characters", v, r)
+		}
+	}
+}
+
+func TestRewriteRegexpInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("panic on invalid pattern expected")
+		}
+	}()
+	RewriteRegexp("(", "")
+}
+
+type rewriteTestCloser struct {
+	*RecorderMetrics
+	closed bool
+}
+
+func (c *rewriteTestCloser) Close() error {
+	c.closed = true
+	return errors.New("closed")
+}
+
+func TestRewriteMetricsClose(t *testing.T) {
+	if err := NewRewriteMetrics(NewRecorderMetrics("test")).Close(); err != nil {
+		t.Errorf("close of writer without io.Closer: got %v", err)
+	}
+	c := &rewriteTestCloser{RecorderMetrics: NewRecorderMetrics("test")}
+	if err := NewRewriteMetrics(c).Close(); err == nil || !c.closed {
+		t.Errorf("close of wrapped writer expected, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package batch_query
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
+)
+
+// SlogMetrics is a log/slog implementation of batch_query.MetricsWriter.
+//
+// Each event writes as a separate record with message equal to method name (eg "BufferOut") and arguments as
+// attributes.
+//
+// Events Timeout, Interrupt, Fail and BatchFail logs with slog.LevelWarn, the rest with slog.LevelDebug. Levels may be
+// changed using options.
+type SlogMetrics struct {
+	l    *slog.Logger
+	conf slogConfig
+	lim  *loglimit.Limiter
+}
+
+// SlogOption describes SlogMetrics option.
+type SlogOption func(*slogConfig)
+
+type slogConfig struct {
+	level  slog.Level
+	levels map[string]slog.Level
+	limits []LogLimitOption
+}
+
+// WithSlogLevel sets level of events haven't own level.
+func WithSlogLevel(level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.level = level
+	}
+}
+
+// WithSlogEventLevel sets level of given event, eg WithSlogEventLevel("BufferOut", slog.LevelInfo).
+func WithSlogEventLevel(event string, level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.levels[event] = level
+	}
+}
+
+// WithSlogLimits enables sampling and/or rate limiting of events.
+//
+// Reports about suppressed events writes with level of corresponding event.
+func WithSlogLimits(opts ...LogLimitOption) SlogOption {
+	return func(c *slogConfig) {
+		c.limits = append(c.limits, opts...)
+	}
+}
+
+var _ = NewSlogMetrics
+
+// NewSlogMetrics makes new writer logs events to logger. If logger is nil, slog.Default() will use.
+//
+// Records contain attribute "query" with query name.
+func NewSlogMetrics(name string, logger *slog.Logger, opts ...SlogOption) *SlogMetrics {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &SlogMetrics{
+		l: logger.With(slog.String("query", name)),
+		conf: slogConfig{
+			level: slog.LevelDebug,
+			levels: map[string]slog.Level{
+				"Timeout":   slog.LevelWarn,
+				"Interrupt": slog.LevelWarn,
+				"Fail":      slog.LevelWarn,
+				"BatchFail": slog.LevelWarn,
+			},
+		},
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	m.lim = newLogLimiter(m.conf.limits, m.suppressed)
+	return m
+}
+
+func (m SlogMetrics) Fetch() {
+	m.log("Fetch")
+}
+
+func (m SlogMetrics) OK(dur time.Duration) {
+	m.log("OK", slog.Duration("duration", dur))
+}
+
+func (m SlogMetrics) NotFound() {
+	m.log("NotFound")
+}
+
+func (m SlogMetrics) Timeout() {
+	m.log("Timeout")
+}
+
+func (m SlogMetrics) Interrupt() {
+	m.log("Interrupt")
+}
+
+func (m SlogMetrics) Fail() {
+	m.log("Fail")
+}
+
+func (m SlogMetrics) Batch() {
+	m.log("Batch")
+}
+
+func (m SlogMetrics) BatchOK(dur time.Duration) {
+	m.log("BatchOK", slog.Duration("duration", dur))
+}
+
+func (m SlogMetrics) BatchFail() {
+	m.log("BatchFail")
+}
+
+func (m SlogMetrics) BufferIn(reason string) {
+	m.log("BufferIn", slog.String("reason", reason))
+}
+
+func (m SlogMetrics) BufferOut() {
+	m.log("BufferOut")
+}
+
+// Close stops reporting of suppressed events.
+func (m SlogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
+
+func (m SlogMetrics) log(event string, attrs ...slog.Attr) {
+	ctx, level := context.Background(), m.level(event)
+	if !m.l.Enabled(ctx, level) || !m.lim.Allow(event) {
+		return
+	}
+	m.l.LogAttrs(ctx, level, event, attrs...)
+}
+
+func (m SlogMetrics) suppressed(event string, n uint64) {
+	m.l.LogAttrs(context.Background(), m.level(event), "suppressed events",
+		slog.String("event", event), slog.Uint64("count", n))
+}
+
+func (m SlogMetrics) level(event string) slog.Level {
+	if level, ok := m.conf.levels[event]; ok {
+		return level
+	}
+	return m.conf.level
+}
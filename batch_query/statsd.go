@@ -0,0 +1,95 @@
+package batch_query
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/statsd"
+)
+
+// StatsDMetrics is a StatsD implementation of batch_query.MetricsWriter.
+//
+// Counters and timers sends as is, gauges keeps locally and sends actual values.
+type StatsDMetrics struct {
+	name string
+	c    *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(name, addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{
+		name: name,
+		c:    c,
+	}
+	return m, nil
+}
+
+func (m StatsDMetrics) Fetch() {
+	m.c.GaugeAdd("batch_query_size", 1, "query", m.name, "entity", single)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", single, "type", ioIn)
+}
+
+func (m StatsDMetrics) OK(dur time.Duration) {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", single)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", single, "type", ioOK)
+	m.c.Timing("batch_query_timing", dur, "query", m.name, "entity", single)
+}
+
+func (m StatsDMetrics) NotFound() {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", single)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", single, "type", io404)
+}
+
+func (m StatsDMetrics) Timeout() {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", single)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", single, "type", ioTO)
+}
+
+func (m StatsDMetrics) Interrupt() {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", single)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", single, "type", ioInt)
+}
+
+func (m StatsDMetrics) Fail() {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", single)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", single, "type", ioFail)
+}
+
+func (m StatsDMetrics) Batch() {
+	m.c.GaugeAdd("batch_query_size", 1, "query", m.name, "entity", batch)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", batch, "type", ioIn)
+}
+
+func (m StatsDMetrics) BatchOK(dur time.Duration) {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", batch)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", batch, "type", ioOK)
+	m.c.Timing("batch_query_timing", dur, "query", m.name, "entity", batch)
+}
+
+func (m StatsDMetrics) BatchFail() {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", batch)
+	m.c.Count("batch_query_io", 1, "query", m.name, "entity", batch, "type", ioFail)
+}
+
+func (m StatsDMetrics) BufferIn(reason string) {
+	m.c.GaugeAdd("batch_query_size", 1, "query", m.name, "entity", buffer)
+	m.c.Count("batch_query_bufio", 1, "query", m.name, "reason", reason)
+}
+
+func (m StatsDMetrics) BufferOut() {
+	m.c.GaugeAdd("batch_query_size", -1, "query", m.name, "entity", buffer)
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
@@ -0,0 +1,39 @@
+package batch_query
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsDMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	m, err := NewStatsDMetrics("test", conn.LocalAddr().String(), WithStatsDPrefix("app."),
+		WithStatsDFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = m.Close() }()
+
+	m.Fetch()
+	m.OK(1500 * time.Microsecond)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "app.batch_query_size.test.single:1|g\napp.batch_query_io.test.single.in:1|c\n" +
+		"app.batch_query_size.test.single:0|g\napp.batch_query_io.test.single.success:1|c\n" +
+		"app.batch_query_timing.test.single:1.5|ms"
+	if got := string(buf[:n]); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
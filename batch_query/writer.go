@@ -0,0 +1,47 @@
+package batch_query
+
+import "time"
+
+// MetricsWriter describes batch_query.MetricsWriter interface.
+//
+// Declared locally to keep writers independent of upstream package version. All writers of the package implement it.
+type MetricsWriter interface {
+	// Fetch registers income of new item.
+	Fetch()
+	// OK registers successfully fetched item and how many time fetch took.
+	OK(dur time.Duration)
+	// NotFound registers item not found.
+	NotFound()
+	// Timeout registers item fetch timeout.
+	Timeout()
+	// Interrupt registers item fetch interruption.
+	Interrupt()
+	// Fail registers item fetch fail.
+	Fail()
+	// Batch registers new batch.
+	Batch()
+	// BatchOK registers successfully processed batch and how many time processing took.
+	BatchOK(dur time.Duration)
+	// BatchFail registers batch processing fail.
+	BatchFail()
+	// BufferIn registers income of item to the buffer. Param reason indicates why item was buffered.
+	BufferIn(reason string)
+	// BufferOut registers outgoing of item from the buffer.
+	BufferOut()
+}
+
+var (
+	_ MetricsWriter = (*PrometheusMetrics)(nil)
+	_ MetricsWriter = (*CollectorMetrics)(nil)
+	_ MetricsWriter = (*ExpvarMetrics)(nil)
+	_ MetricsWriter = (*RewriteMetrics)(nil)
+	_ MetricsWriter = (*OTelMetrics)(nil)
+	_ MetricsWriter = (*StatsDMetrics)(nil)
+	_ MetricsWriter = (*InfluxMetrics)(nil)
+	_ MetricsWriter = (*GraphiteMetrics)(nil)
+	_ MetricsWriter = (*LogMetrics)(nil)
+	_ MetricsWriter = (*MultiMetrics)(nil)
+	_ MetricsWriter = (*RecorderMetrics)(nil)
+	_ MetricsWriter = (*SlogMetrics)(nil)
+	_ MetricsWriter = (*SummaryLogMetrics)(nil)
+)
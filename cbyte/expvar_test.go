@@ -0,0 +1,47 @@
+package cbyte
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Alloc(64)
+	m.Grow(64, 128)
+	m.Free(128)
+
+	var got any
+	if err := json.Unmarshal([]byte(expvar.Get("cbyte").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{
+		"cbyte_alloc": 1,
+		"cbyte_free": 1,
+		"cbyte_grow": 1,
+		"cbyte_mem": 0
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "cbyte_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
@@ -0,0 +1,50 @@
+package cbyte
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewInfluxMetrics(&buf, WithInfluxInterval(time.Hour), WithInfluxTags(map[string]string{"host": "h1"}))
+	defer func() { _ = m.Close() }()
+
+	m.Alloc(64)
+	m.Alloc(64)
+	m.Free(64)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`cbyte_alloc,host=h1 value=2i `,
+		`cbyte_mem,host=h1 value=64i `,
+		`cbyte_free,host=h1 value=1i `,
+	} {
+		if !strings.Contains(out, "\n"+line) && !strings.HasPrefix(out, line) {
+			t.Errorf("line %q not found in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestInfluxEscape(t *testing.T) {
+	for _, tc := range []struct {
+		s, special, expect string
+	}{
+		{"size", ", ", "size"},
+		{"a,b c", ", ", `a\,b\ c`},
+		{"a=b", ", ", "a=b"},
+		{"a=b", ",= ", `a\=b`},
+		{`a\b`, ", ", `a\\b`},
+		// Line breaks escape as spaces.
+		{"queue\nsize\r", ", ", `queue\ size\ `},
+	} {
+		var buf bytes.Buffer
+		if influxEscape(&buf, tc.s, tc.special); buf.String() != tc.expect {
+			t.Errorf("%q: got %q, expected %q", tc.s, buf.String(), tc.expect)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package cbyte
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of cbyte.MetricsWriter.
+type OTelMetrics struct {
+	ins  *otelInstruments
+	done *uint32
+}
+
+type otelInstruments struct {
+	alloc,
+	grow,
+	free metric.Int64Counter
+	mem metric.Int64UpDownCounter
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/cbyte"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+	}
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.alloc = otelCheck(meter.Int64Counter("cbyte_alloc",
+		metric.WithDescription("Count of alloc calls.")))
+	ins.grow = otelCheck(meter.Int64Counter("cbyte_grow",
+		metric.WithDescription("Count of realloc (grow) calls.")))
+	ins.free = otelCheck(meter.Int64Counter("cbyte_free",
+		metric.WithDescription("Count of free calls.")))
+
+	ins.mem = otelCheck(meter.Int64UpDownCounter("cbyte_mem",
+		metric.WithDescription("How many memory managed by cbyte."),
+		metric.WithUnit("By")))
+
+	return ins
+}
+
+func (m OTelMetrics) Alloc(cap uint64) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.alloc.Add(ctx, 1)
+	m.ins.mem.Add(ctx, int64(cap))
+}
+
+func (m OTelMetrics) Grow(capOld, cap uint64) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.grow.Add(ctx, 1)
+	m.ins.mem.Add(ctx, int64(cap)-int64(capOld))
+}
+
+func (m OTelMetrics) Free(cap uint64) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.free.Add(ctx, 1)
+	m.ins.mem.Add(ctx, -int64(cap))
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports.
+func (m OTelMetrics) Close() error {
+	atomic.StoreUint32(m.done, 1)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
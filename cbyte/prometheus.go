@@ -1,57 +1,171 @@
 package cbyte
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 // PrometheusMetrics implement cbyte.MetricsWriter interface.
-type PrometheusMetrics struct{}
+type PrometheusMetrics struct {
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+}
+
+// Collection of collectors registered in the same registry.
+type promVecs struct {
+	alloc,
+	grow,
+	free *prometheus.CounterVec
+	mem prometheus.Gauge
+
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
+}
 
 var (
-	promAlloc,
-	promGrow,
-	promFree *prometheus.CounterVec
-	promMem prometheus.Gauge
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
 
-	_ = NewPrometheusMetrics
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
 )
 
-func init() {
-	promAlloc = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbyte_alloc",
-		Help: "Count of alloc calls.",
-	}, []string{})
-	promGrow = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbyte_grow",
-		Help: "Count of realloc (grow) calls.",
-	}, []string{})
-	promFree = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbyte_free",
-		Help: "Count of free calls.",
-	}, []string{})
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return NewPrometheusMetricsWithOptions()
+}
 
-	promMem = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "cbyte_mem",
-		Help: "How many memory managed by cbyte.",
-	})
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+func NewPrometheusMetricsWithOptions(opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &PrometheusMetrics{
+		vec:  getPromVecs(&c),
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+	}
+	m.hnd.Store(newPromHandles(m.vec))
+	return m
+}
 
-	prometheus.MustRegister(promMem, promAlloc, promGrow, promFree)
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
 }
 
-func NewPrometheusMetrics() *PrometheusMetrics {
-	m := &PrometheusMetrics{}
-	return m
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
+
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.alloc = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbyte_alloc",
+		Help:        "Count of alloc calls.",
+		ConstLabels: c.constLabels,
+	}, []string{})).(*prometheus.CounterVec)
+	v.grow = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbyte_grow",
+		Help:        "Count of realloc (grow) calls.",
+		ConstLabels: c.constLabels,
+	}, []string{})).(*prometheus.CounterVec)
+	v.free = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbyte_free",
+		Help:        "Count of free calls.",
+		ConstLabels: c.constLabels,
+	}, []string{})).(*prometheus.CounterVec)
+
+	v.mem = v.register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbyte_mem",
+		Help:        "How many memory managed by cbyte.",
+		ConstLabels: c.constLabels,
+	})).(prometheus.Gauge)
+
+	return v
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
 }
 
 func (m PrometheusMetrics) Alloc(cap uint64) {
-	promAlloc.WithLabelValues().Add(1)
-	promMem.Add(float64(cap))
+	m.handles().alloc.Inc()
+	m.vec.mem.Add(float64(cap))
 }
 
 func (m PrometheusMetrics) Grow(capOld, cap uint64) {
-	promGrow.WithLabelValues().Add(1)
-	promMem.Add(float64(cap - capOld))
+	m.handles().grow.Inc()
+	m.vec.mem.Add(float64(cap - capOld))
 }
 
 func (m PrometheusMetrics) Free(cap uint64) {
-	promFree.WithLabelValues().Add(1)
-	promMem.Sub(float64(cap))
+	m.handles().free.Inc()
+	m.vec.mem.Sub(float64(cap))
+}
+
+// Close releases shared collectors, the last closed writer of the registerer unregisters them. Package has no named
+// components, so writer has no own series to remove.
+//
+// Writer keeps applying events after Close: memory allocated before Close may be freed later, so shared gauges must
+// follow it. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		putPromVecs(m.vec)
+	}
+	return nil
 }
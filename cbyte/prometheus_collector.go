@@ -0,0 +1,109 @@
+package cbyte
+
+import (
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of cbyte.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot allocations. Metrics names and labels are the same as
+// PrometheusMetrics has, but both implementations can't be mixed in the same registerer.
+type CollectorMetrics struct {
+	reg  prometheus.Registerer
+	desc *collDescs
+
+	alloc, grow, free, mem promreg.Counter
+}
+
+type collDescs struct {
+	alloc, grow, free, mem *prometheus.Desc
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same registerer share counters, ie
+// constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &CollectorMetrics{
+		reg:  c.reg,
+		desc: newCollDescs(&c),
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, c.constLabels)
+	}
+	return &collDescs{
+		alloc: desc("cbyte_alloc", "Count of alloc calls."),
+		grow:  desc("cbyte_grow", "Count of realloc (grow) calls."),
+		free:  desc("cbyte_free", "Count of free calls."),
+		mem:   desc("cbyte_mem", "How many memory managed by cbyte."),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.alloc, d.grow, d.free, d.mem} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	gauge := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c.Load()), lvs...)
+	}
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	counter(d.alloc, &m.alloc)
+	counter(d.grow, &m.grow)
+	counter(d.free, &m.free)
+	gauge(d.mem, &m.mem)
+}
+
+func (m *CollectorMetrics) Alloc(cap uint64) {
+	sh := promreg.Shard()
+	m.alloc.Add(sh, 1)
+	m.mem.Add(sh, int64(cap))
+}
+
+func (m *CollectorMetrics) Grow(capOld, cap uint64) {
+	sh := promreg.Shard()
+	m.grow.Add(sh, 1)
+	m.mem.Add(sh, int64(cap)-int64(capOld))
+}
+
+func (m *CollectorMetrics) Free(cap uint64) {
+	sh := promreg.Shard()
+	m.free.Add(sh, 1)
+	m.mem.Add(sh, -int64(cap))
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
@@ -0,0 +1,26 @@
+package cbyte
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Counters bound once to avoid vectors locking on every event.
+type promHandles struct {
+	alloc, grow, free prometheus.Counter
+}
+
+func newPromHandles(v *promVecs) *promHandles {
+	return &promHandles{
+		alloc: v.alloc.WithLabelValues(),
+		grow:  v.grow.WithLabelValues(),
+		free:  v.free.WithLabelValues(),
+	}
+}
+
+// Get handles bound to the writer.
+func (m PrometheusMetrics) handles() *promHandles {
+	for {
+		if h := m.hnd.Load(); h != nil {
+			return h
+		}
+		m.hnd.CompareAndSwap(nil, newPromHandles(m.vec))
+	}
+}
@@ -0,0 +1,73 @@
+package cbyte
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusOption describes PrometheusMetrics option.
+type PrometheusOption func(*promConfig)
+
+type promConfig struct {
+	reg prometheus.Registerer
+
+	namespace, subsystem string
+	constLabels          prometheus.Labels
+}
+
+// WithRegisterer sets registerer to use instead of prometheus.DefaultRegisterer.
+func WithRegisterer(reg prometheus.Registerer) PrometheusOption {
+	return func(c *promConfig) {
+		c.reg = reg
+	}
+}
+
+// WithNamespace sets namespace (prefix) of all metrics names.
+func WithNamespace(namespace string) PrometheusOption {
+	return func(c *promConfig) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets subsystem of all metrics names. Subsystem places between namespace and metric name.
+func WithSubsystem(subsystem string) PrometheusOption {
+	return func(c *promConfig) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets constant labels to apply to all metrics.
+//
+// Labels names must not intersect with variable labels of metrics.
+func WithConstLabels(labels prometheus.Labels) PrometheusOption {
+	return func(c *promConfig) {
+		if c.constLabels == nil {
+			c.constLabels = make(prometheus.Labels, len(labels))
+		}
+		for k, v := range labels {
+			c.constLabels[k] = v
+		}
+	}
+}
+
+// Build string representation of options affects metrics descriptions.
+func (c *promConfig) key() string {
+	var buf strings.Builder
+	buf.WriteString(c.namespace)
+	buf.WriteByte('|')
+	buf.WriteString(c.subsystem)
+	keys := make([]string, 0, len(c.constLabels))
+	for k := range c.constLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteByte('|')
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(c.constLabels[k])
+	}
+	return buf.String()
+}
@@ -0,0 +1,132 @@
+package cbyte
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/prom"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusRemoteWriter sends metrics of Prometheus writers to remote storage (Prometheus, VictoriaMetrics, Mimir, ...)
+// using remote-write 1.0 protocol.
+//
+// Designed for nodes can't be scraped. Writer periodically snapshots all series of gatherer and buffers their samples.
+// Buffer sends in batches (snappy-compressed protobuf WriteRequest per batch). If storage is unavailable, samples keeps
+// in the buffer and sending retries with exponential backoff. Buffer is bounded, the oldest samples drops on overflow.
+type PrometheusRemoteWriter struct {
+	w *prom.RemoteWriter
+}
+
+// RemoteWriteOption describes PrometheusRemoteWriter option.
+type RemoteWriteOption func(*prom.RemoteWriteConfig)
+
+// WithRemoteWriteInterval sets snapshot and send interval.
+func WithRemoteWriteInterval(interval time.Duration) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.Interval = interval
+	}
+}
+
+// WithRemoteWriteTimeout sets timeout of each request.
+func WithRemoteWriteTimeout(timeout time.Duration) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.Timeout = timeout
+	}
+}
+
+// WithRemoteWriteGatherer sets gatherer to snapshot series from, eg registry specified in WithRegisterer option.
+// prometheus.DefaultGatherer is used by default.
+func WithRemoteWriteGatherer(gatherer prometheus.Gatherer) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.Gatherer = gatherer
+	}
+}
+
+// WithRemoteWriteLabels sets external labels to apply to all series, eg instance of the node. Series labels takes
+// precedence.
+func WithRemoteWriteLabels(labels map[string]string) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		if c.Labels == nil {
+			c.Labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			c.Labels[k] = v
+		}
+	}
+}
+
+// WithRemoteWriteHeader sets HTTP header to send with each request, eg authorization or tenant ID.
+func WithRemoteWriteHeader(name, value string) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		if c.Headers == nil {
+			c.Headers = make(map[string]string)
+		}
+		c.Headers[name] = value
+	}
+}
+
+// WithRemoteWriteBasicAuth sets credentials of HTTP basic authentication.
+func WithRemoteWriteBasicAuth(username, password string) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.User, c.Pass = username, password
+	}
+}
+
+// WithRemoteWriteClient sets HTTP client. http.DefaultClient is used by default.
+func WithRemoteWriteClient(client *http.Client) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.Client = client
+	}
+}
+
+// WithRemoteWriteBufferSize sets maximum amount of samples keeps in buffer till sending.
+func WithRemoteWriteBufferSize(size int) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.BufferSize = size
+	}
+}
+
+// WithRemoteWriteBatchSize sets maximum amount of samples in one request.
+func WithRemoteWriteBatchSize(size int) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.BatchSize = size
+	}
+}
+
+// WithRemoteWriteBackoff sets minimum and maximum delays between retries of failed send.
+func WithRemoteWriteBackoff(minDelay, maxDelay time.Duration) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.BackoffMin, c.BackoffMax = minDelay, maxDelay
+	}
+}
+
+// WithRemoteWriteErrorHandler sets callback to report errors of periodic sends and dropped samples.
+func WithRemoteWriteErrorHandler(fn func(err error)) RemoteWriteOption {
+	return func(c *prom.RemoteWriteConfig) {
+		c.OnError = fn
+	}
+}
+
+var _ = NewPrometheusRemoteWriter
+
+// NewPrometheusRemoteWriter makes new writer sends metrics to remote-write endpoint with given URL.
+func NewPrometheusRemoteWriter(url string, opts ...RemoteWriteOption) *PrometheusRemoteWriter {
+	var conf prom.RemoteWriteConfig
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	return &PrometheusRemoteWriter{w: prom.NewRemoteWriter(url, conf)}
+}
+
+// Flush snapshots actual state of series and sends buffered samples.
+//
+// If previous send failed, sending postpones until backoff delay expires, samples keeps in the buffer.
+func (m *PrometheusRemoteWriter) Flush() error {
+	return m.w.Flush()
+}
+
+// Close stops periodic sends and tries to send the final state of series.
+func (m *PrometheusRemoteWriter) Close() error {
+	return m.w.Close()
+}
@@ -0,0 +1,117 @@
+package cbyte
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPrometheusMetricsRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := []PrometheusOption{WithRegisterer(reg), WithNamespace("app"), WithSubsystem("svc"),
+		WithConstLabels(prometheus.Labels{"env": "test"})}
+	a := NewPrometheusMetricsWithOptions(opts...)
+	b := NewPrometheusMetricsWithOptions(opts...)
+	a.Alloc(64)
+	b.Alloc(64)
+
+	mfs := promGather(t, reg)
+	for _, name := range []string{"cbyte_alloc", "app_cbyte_alloc"} {
+		if _, ok := mfs[name]; ok {
+			t.Errorf("unexpected family %s", name)
+		}
+	}
+	mf, ok := mfs["app_svc_cbyte_alloc"]
+	if !ok {
+		t.Fatal("family app_svc_cbyte_alloc expected")
+	}
+	// Writers of the same registerer share series.
+	if ms := mf.GetMetric(); len(ms) != 1 || ms[0].GetCounter().GetValue() != 2 {
+		t.Errorf("app_svc_cbyte_alloc: single series with value 2 expected, got %v", ms)
+	} else if lbl := promLabels(ms[0]); len(lbl) != 1 || lbl["env"] != "test" {
+		t.Errorf("labels %v: expected env=test", lbl)
+	}
+	// Private registerer doesn't touch the default one.
+	if mfs, err := prometheus.DefaultGatherer.Gather(); err == nil {
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), "app_svc_") {
+				t.Errorf("family %s registered in default registerer", mf.GetName())
+			}
+		}
+	}
+
+	// The last closed writer unregisters collectors and releases registerer.
+	_ = a.Close()
+	if mfs := promGather(t, reg); len(mfs) == 0 {
+		t.Error("collectors of unclosed writer expected")
+	}
+	_ = b.Close()
+	_ = b.Close()
+	if mfs := promGather(t, reg); len(mfs) != 0 {
+		t.Errorf("no families expected after close, got %d", len(mfs))
+	}
+	promMux.Lock()
+	defer promMux.Unlock()
+	for k := range promIdx {
+		if k.reg == reg {
+			t.Error("closed registerer kept in index")
+		}
+	}
+}
+
+func TestPrometheusMetricsClose(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a := NewPrometheusMetricsWithOptions(WithRegisterer(reg))
+	b := NewPrometheusMetricsWithOptions(WithRegisterer(reg))
+	a.Alloc(64)
+	b.Alloc(128)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing one writer must not reset memory allocated through others.
+	if v := b.gauge("cbyte_mem"); v != 192 {
+		t.Fatalf("cbyte_mem after close: got %d, expected 192", v)
+	}
+	// Closed writer keeps applying events, so memory allocated before close is freed.
+	a.Free(64)
+	if v := b.gauge("cbyte_mem"); v != 128 {
+		t.Errorf("cbyte_mem after free by closed writer: got %d, expected 128", v)
+	}
+	b.Free(128)
+	if v := b.gauge("cbyte_mem"); v != 0 {
+		t.Errorf("cbyte_mem: got %d, expected 0", v)
+	}
+	_ = b.Close()
+}
+
+func BenchmarkPrometheusMetrics_Alloc(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions(WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Alloc(64)
+		m.Free(64)
+	}
+}
+
+func promGather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		r[mf.GetName()] = mf
+	}
+	return r
+}
+
+func promLabels(m *dto.Metric) map[string]string {
+	r := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		r[lp.GetName()] = lp.GetValue()
+	}
+	return r
+}
@@ -0,0 +1,91 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Metrics is a dependency-free Prometheus implementation of cbyte.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as cbyte.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	reg     *Registry
+	desc    *descs
+	id      string
+	created time.Time
+
+	alloc, grow, free, mem promreg.Counter
+}
+
+type descs struct {
+	alloc, grow, free, mem *promreg.Desc
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same registry share counters, ie constructor returns already registered writer.
+func NewMetrics(opts ...Option) *Metrics {
+	c := config{reg: DefaultRegistry}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	m := &Metrics{
+		reg:     c.reg,
+		desc:    newDescs(&c),
+		id:      "cbyte|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels),
+		created: time.Now(),
+	}
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, c.constLabels, labels...)
+	}
+	return &descs{
+		alloc: desc("cbyte_alloc", "Count of alloc calls."),
+		grow:  desc("cbyte_grow", "Count of realloc (grow) calls."),
+		free:  desc("cbyte_free", "Count of free calls."),
+		mem:   desc("cbyte_mem", "How many memory managed by cbyte."),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	g.Counter(d.alloc, &m.alloc, m.created)
+	g.Counter(d.grow, &m.grow, m.created)
+	g.Counter(d.free, &m.free, m.created)
+	g.Gauge(d.mem, &m.mem)
+}
+
+func (m *Metrics) Alloc(cap uint64) {
+	sh := promreg.Shard()
+	m.alloc.Add(sh, 1)
+	m.mem.Add(sh, int64(cap))
+}
+
+func (m *Metrics) Grow(capOld, cap uint64) {
+	sh := promreg.Shard()
+	m.grow.Add(sh, 1)
+	m.mem.Add(sh, int64(cap)-int64(capOld))
+}
+
+func (m *Metrics) Free(cap uint64) {
+	sh := promreg.Shard()
+	m.free.Add(sh, 1)
+	m.mem.Add(sh, -int64(cap))
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
@@ -0,0 +1,36 @@
+// Package promtext is a dependency-free Prometheus backend: writers keep metrics in atomics and Registry renders them
+// in Prometheus text format 0.0.4 or OpenMetrics 1.0 without client_golang.
+package promtext
+
+import (
+	"net/http"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+const (
+	// ContentTypeText is a content type of Prometheus text format 0.0.4.
+	ContentTypeText = promreg.ContentTypeText
+	// ContentTypeOpenMetrics is a content type of OpenMetrics 1.0 text format.
+	ContentTypeOpenMetrics = promreg.ContentTypeOpenMetrics
+)
+
+// Registry keeps writers and renders their metrics on scrape.
+//
+// Registry implements http.Handler: serves OpenMetrics if client accepts it (see Accept header) and Prometheus text
+// format otherwise. Registry type is common for promtext packages of all writers, so one registry may keep writers of
+// queues, caches, pools, etc.
+type Registry = promreg.Registry
+
+// DefaultRegistry is a registry used by writers by default. It's common for promtext packages of all writers.
+var DefaultRegistry = promreg.DefaultRegistry
+
+// NewRegistry makes new empty registry.
+func NewRegistry() *Registry {
+	return promreg.NewRegistry()
+}
+
+// Handler returns handler of DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
@@ -0,0 +1,55 @@
+package cbyte
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics()
+	m.Alloc(64)
+	m.Alloc(64)
+	m.Free(64)
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "Alloc", 2, uint64(64)) ||
+		!m.AssertValue(&ft, "cbyte_mem", 64) {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "Alloc", 1, uint64(64)) },
+			"Alloc[64]: expected 1 calls, got 2"},
+		// Argument of other type than in method signature never matches.
+		{func(t TestingT) bool { return m.AssertCount(t, "Alloc", 2, 64) },
+			"Alloc[64]: expected 2 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertValue(t, "cbyte_mem", 0) },
+			"cbyte_mem[]: expected 0, got 64"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cbyte
+
+import "github.com/koykov/metrics_writers/internal/statsd"
+
+// StatsDMetrics is a StatsD implementation of cbyte.MetricsWriter.
+//
+// Counters sends as is, gauges keeps locally and sends actual values.
+type StatsDMetrics struct {
+	c *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{c: c}
+	return m, nil
+}
+
+func (m StatsDMetrics) Alloc(cap uint64) {
+	m.c.Count("cbyte_alloc", 1)
+	m.c.GaugeAdd("cbyte_mem", int64(cap))
+}
+
+func (m StatsDMetrics) Grow(capOld, cap uint64) {
+	m.c.Count("cbyte_grow", 1)
+	m.c.GaugeAdd("cbyte_mem", int64(cap)-int64(capOld))
+}
+
+func (m StatsDMetrics) Free(cap uint64) {
+	m.c.Count("cbyte_free", 1)
+	m.c.GaugeAdd("cbyte_mem", -int64(cap))
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
@@ -0,0 +1,37 @@
+package cbyte
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsDMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	m, err := NewStatsDMetrics(conn.LocalAddr().String(), WithStatsDPrefix("app."),
+		WithStatsDFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = m.Close() }()
+
+	m.Alloc(64)
+	m.Free(64)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "app.cbyte_alloc:1|c\napp.cbyte_mem:64|g\napp.cbyte_free:1|c\napp.cbyte_mem:0|g"
+	if got := string(buf[:n]); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
@@ -0,0 +1,27 @@
+package cbyte
+
+// MetricsWriter describes cbyte.MetricsWriter interface.
+//
+// Declared locally to keep writers independent of upstream package version. All writers of the package implement it.
+type MetricsWriter interface {
+	// Alloc registers allocation of memory with given capacity.
+	Alloc(cap uint64)
+	// Grow registers growing of memory from capOld to cap.
+	Grow(capOld, cap uint64)
+	// Free registers release of memory with given capacity.
+	Free(cap uint64)
+}
+
+var (
+	_ MetricsWriter = (*PrometheusMetrics)(nil)
+	_ MetricsWriter = (*CollectorMetrics)(nil)
+	_ MetricsWriter = (*ExpvarMetrics)(nil)
+	_ MetricsWriter = (*OTelMetrics)(nil)
+	_ MetricsWriter = (*StatsDMetrics)(nil)
+	_ MetricsWriter = (*InfluxMetrics)(nil)
+	_ MetricsWriter = (*GraphiteMetrics)(nil)
+	_ MetricsWriter = (*MultiMetrics)(nil)
+	_ MetricsWriter = (*RecorderMetrics)(nil)
+	_ MetricsWriter = (*SlogMetrics)(nil)
+	_ MetricsWriter = (*SummaryLogMetrics)(nil)
+)
@@ -0,0 +1,384 @@
+package cbytebuf
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koykov/metrics_writers/cbytebuf/promtext"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var conformanceSeed = flag.Uint64("conformance.seed", 0, "seed of randomized conformance replay, 0 means default seeds")
+
+var conformanceSeeds = []uint64{1, 2, 3}
+
+// Writer under check. Gauge reads gauges of the writer the same way as RecorderMetrics.Gauge, events reads amount of
+// records written by log writers. Snapshot takes state of the writer before gauges read. All are optional.
+type conformanceTarget struct {
+	w        MetricsWriter
+	gauge    func(family string, labels ...string) int64
+	events   func() int64
+	snapshot func()
+}
+
+func TestConformance(t *testing.T) {
+	seeds := conformanceSeeds
+	if *conformanceSeed != 0 {
+		seeds = []uint64{*conformanceSeed}
+	}
+	// Log and summary writers use standard logger.
+	prev := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(prev)
+
+	for _, tc := range []struct {
+		name string
+		new  func(t *testing.T) conformanceTarget
+	}{
+		{"prometheus", func(*testing.T) conformanceTarget {
+			m := NewPrometheusMetricsWithOptions(WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"collector", func(t *testing.T) conformanceTarget {
+			m, err := NewCollectorMetrics(WithRegisterer(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"expvar", func(*testing.T) conformanceTarget {
+			// Expvar series are global, so the writer publishes private store to its own map.
+			s, mp := newAggrStore(), new(expvar.Map).Init()
+			s.hook = func(x *aggrSeries) { expvarPublish(mp, x, x.labels) }
+			m := &ExpvarMetrics{aggrMetrics: aggrMetrics{s: s}}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"recorder", func(*testing.T) conformanceTarget {
+			m := NewRecorderMetrics()
+			return conformanceTarget{w: m, gauge: m.Gauge}
+		}},
+		{"otel", func(*testing.T) conformanceTarget {
+			g := &otelValues{r: sdkmetric.NewManualReader()}
+			m := NewOTelMetrics(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(g.r))))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+		{"statsd", func(t *testing.T) conformanceTarget {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewStatsDMetrics(conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = m.Close()
+				_ = conn.Close()
+			})
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"influx", func(t *testing.T) conformanceTarget {
+			m := NewInfluxMetrics(io.Discard, WithInfluxInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"graphite", func(t *testing.T) conformanceTarget {
+			// Nothing listens the address, so series stay aggregated.
+			m := NewGraphiteMetrics("127.0.0.1:1", WithGraphiteInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"slog", func(*testing.T) conformanceTarget {
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := NewSlogMetrics(slog.New(h))
+			return conformanceTarget{w: m, events: h.count}
+		}},
+		{"summary", func(t *testing.T) conformanceTarget {
+			m := NewSummaryLogMetrics(time.Hour)
+			t.Cleanup(m.Stop)
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"multi", func(*testing.T) conformanceTarget {
+			pm := NewPrometheusMetricsWithOptions(WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: NewMultiMetrics(NewRecorderMetrics(), pm), gauge: pm.gauge}
+		}},
+		{"promtext", func(*testing.T) conformanceTarget {
+			g := &promtextValues{reg: promtext.NewRegistry()}
+			m := promtext.NewMetrics(promtext.WithRegistry(g.reg))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, seed := range seeds {
+				t.Run(strconv.FormatUint(seed, 10), func(t *testing.T) {
+					checkConformance(t, tc.new(t), seed)
+				})
+			}
+		})
+	}
+}
+
+const conformanceSteps = 10000
+
+// Replay scripted and randomized (using seed) event sequences derived from upstream cbytebuf pool
+// lifecycle into the writer and check gauges invariants after each event:
+//   - cbytebuf_pool and cbytebuf_pool_mem never become negative;
+//   - gauges values are equal to amount and total capacity of buffers in the replayed pool.
+//
+// Gauges checks on shadow RecorderMetrics receives the same events and on the writer if target has gauge reader.
+//
+// Note, sequences assumes that acquire from the empty pool makes new buffer and doesn't register PoolAcquire event.
+func checkConformance(t *testing.T, tg conformanceTarget, seed uint64) {
+	t.Helper()
+	c := &conformance{
+		conformanceTarget: tg,
+		rec:               NewRecorderMetrics(),
+		rnd:               rand.New(rand.NewPCG(seed, seed)),
+	}
+	c.script()
+	for i := 0; i < conformanceSteps && c.err == nil; i++ {
+		c.random()
+		c.check()
+	}
+	if c.err != nil {
+		t.Errorf("seed %d: %s", seed, c.err)
+	}
+}
+
+// State of replayed pool.
+type conformance struct {
+	conformanceTarget
+	rec *RecorderMetrics
+	rnd *rand.Rand
+	err error
+	// Last replayed event and amount of replayed events.
+	event string
+	n     int64
+
+	// Capacities of buffers in the pool and in use.
+	pool, busy []uint64
+}
+
+// Replay scripted sequences.
+func (c *conformance) script() {
+	steps := []func(){
+		// Acquire new buffers, release them and acquire again.
+		c.acquire, c.acquire, c.release, c.release, c.acquire, c.acquire, c.acquire,
+	}
+	for i := 0; i < len(steps) && c.err == nil; i++ {
+		steps[i]()
+		c.check()
+	}
+}
+
+// Replay random valid event.
+func (c *conformance) random() {
+	if c.rnd.IntN(2) == 0 {
+		c.acquire()
+	} else {
+		c.release()
+	}
+}
+
+func (c *conformance) acquire() {
+	if len(c.pool) == 0 {
+		c.busy = append(c.busy, uint64(64<<c.rnd.IntN(8)))
+		return
+	}
+	capacity := c.pool[len(c.pool)-1]
+	c.pool, c.busy = c.pool[:len(c.pool)-1], append(c.busy, capacity)
+	c.emit("PoolAcquire", func(w MetricsWriter) { w.PoolAcquire(capacity) })
+}
+
+func (c *conformance) release() {
+	if len(c.busy) == 0 {
+		return
+	}
+	i := c.rnd.IntN(len(c.busy))
+	capacity := c.busy[i]
+	c.busy[i] = c.busy[len(c.busy)-1]
+	c.busy, c.pool = c.busy[:len(c.busy)-1], append(c.pool, capacity)
+	c.emit("PoolRelease", func(w MetricsWriter) { w.PoolRelease(capacity) })
+}
+
+// Pass event to shadow recorder and to the writer.
+func (c *conformance) emit(event string, fn func(w MetricsWriter)) {
+	if c.err != nil {
+		return
+	}
+	c.event = event
+	c.n++
+	fn(c.rec)
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("event %s: writer panic: %v", event, r)
+		}
+	}()
+	fn(c.w)
+}
+
+func (c *conformance) check() {
+	if c.err != nil {
+		return
+	}
+	c.checkValues("recorder", c.rec.Gauge)
+	if c.snapshot != nil {
+		c.snapshot()
+	}
+	if c.conformanceTarget.gauge != nil {
+		c.checkValues("writer", c.conformanceTarget.gauge)
+	}
+	if c.events != nil && c.err == nil {
+		if n := c.events(); n != c.n {
+			c.err = fmt.Errorf("after %s: writer: %d records written, expected %d", c.event, n, c.n)
+		}
+	}
+}
+
+func (c *conformance) expect(src string, gauge func(family string, labels ...string) int64, family string,
+	value int64, labels ...string) {
+	if c.err != nil {
+		return
+	}
+	switch v := gauge(family, labels...); {
+	case v < 0:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v is negative: %d", c.event, src, family, labels, v)
+	case v != value:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v drifted: %d, expected %d", c.event, src, family, labels, v, value)
+	}
+}
+
+func (c *conformance) checkValues(src string, gauge func(family string, labels ...string) int64) {
+	var mem int64
+	for i := 0; i < len(c.pool); i++ {
+		mem += int64(c.pool[i])
+	}
+	c.expect(src, gauge, "cbytebuf_pool", int64(len(c.pool)))
+	c.expect(src, gauge, "cbytebuf_pool_mem", mem)
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m PrometheusMetrics) gauge(family string, _ ...string) int64 {
+	switch family {
+	case "cbytebuf_pool":
+		return promGaugeValue(m.vec.pool)
+	case "cbytebuf_pool_mem":
+		return promGaugeValue(m.vec.poolMem)
+	default:
+		return 0
+	}
+}
+
+func (m *CollectorMetrics) gauge(family string, _ ...string) int64 {
+	switch family {
+	case "cbytebuf_pool":
+		return m.pool.Load()
+	case "cbytebuf_pool_mem":
+		return m.poolMem.Load()
+	default:
+		return 0
+	}
+}
+
+func promGaugeValue(g prometheus.Gauge) int64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		return 0
+	}
+	return int64(math.Round(m.GetGauge().GetValue()))
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m aggrMetrics) gauge(family string, labels ...string) int64 {
+	if x := m.s.find(family, labels...); x != nil {
+		return x.load()
+	}
+	return 0
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m StatsDMetrics) gauge(family string, labels ...string) int64 {
+	return m.c.Gauge(family, labels...)
+}
+
+// Values of OTel writer collected by manual reader. Labels kv appends to labels of every series.
+type otelValues struct {
+	r  *sdkmetric.ManualReader
+	kv []string
+	rm metricdata.ResourceMetrics
+}
+
+func (g *otelValues) snapshot() {
+	g.rm = metricdata.ResourceMetrics{}
+	_ = g.r.Collect(context.Background(), &g.rm)
+}
+
+func (g *otelValues) value(family string, labels ...string) int64 {
+	data, _ := otelMetric(g.rm, family).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, append(g.kv[:len(g.kv):len(g.kv)], labels...)...) {
+			return p.Value
+		}
+	}
+	return 0
+}
+
+// Values of promtext writer parsed from text format rendered by registry. Labels kv prepends to labels of every
+// series.
+type promtextValues struct {
+	reg    *promtext.Registry
+	kv     []string
+	series map[string]int64
+}
+
+func (g *promtextValues) snapshot() {
+	var buf bytes.Buffer
+	_ = g.reg.WriteText(&buf)
+	g.series = make(map[string]int64)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if i := strings.LastIndexByte(line, ' '); i > 0 && !strings.HasPrefix(line, "#") {
+			f, _ := strconv.ParseFloat(line[i+1:], 64)
+			g.series[line[:i]] = int64(f)
+		}
+	}
+}
+
+func (g *promtextValues) value(family string, labels ...string) int64 {
+	// Text format sorts labels by name.
+	pairs := make([][2]string, 0, len(g.kv)/2+len(labels)/2)
+	for _, kv := range [][]string{g.kv, labels} {
+		for i := 0; i+1 < len(kv); i += 2 {
+			pairs = append(pairs, [2]string{kv[i], kv[i+1]})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	key := family
+	for i := range pairs {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		key += sep + pairs[i][0] + `="` + pairs[i][1] + `"`
+	}
+	if len(pairs) > 0 {
+		key += "}"
+	}
+	return g.series[key]
+}
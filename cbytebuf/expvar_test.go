@@ -0,0 +1,47 @@
+package cbytebuf
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.PoolRelease(64)
+	m.PoolAcquire(64)
+	m.PoolRelease(128)
+
+	var got any
+	if err := json.Unmarshal([]byte(expvar.Get("cbytebuf").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `{
+		"cbytebuf_acq": 1,
+		"cbytebuf_pool": 1,
+		"cbytebuf_pool_mem": 128,
+		"cbytebuf_rel": 2
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "cbytebuf_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
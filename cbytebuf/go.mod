@@ -1,5 +1,39 @@
 module github.com/koykov/metrics_writers/cbytebuf
 
-go 1.16
+go 1.22.0
 
-require github.com/prometheus/client_golang v1.14.0
+require (
+	github.com/koykov/metrics_writers/cbytebuf/promtext v0.1.0
+	github.com/prometheus/client_golang v1.15.1
+	github.com/prometheus/client_model v0.3.0
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/metric v1.35.0
+	go.opentelemetry.io/otel/sdk/metric v1.35.0
+)
+
+require github.com/golang/snappy v1.0.0 // indirect
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/go-logr/logr v1.4.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/koykov/metrics_writers/internal v0.1.0
+	github.com/koykov/metrics_writers/internal/prom v0.1.0
+	github.com/matttproud/golang_protobuf_extensions v1.0.4 // indirect
+	github.com/prometheus/common v0.42.0 // indirect
+	github.com/prometheus/procfs v0.9.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/sdk v1.35.0 // indirect
+	go.opentelemetry.io/otel/trace v1.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	google.golang.org/protobuf v1.30.0 // indirect
+)
+
+replace github.com/koykov/metrics_writers/internal => ../internal
+
+replace github.com/koykov/metrics_writers/internal/prom => ../internal/prom
+
+replace github.com/koykov/metrics_writers/cbytebuf/promtext => ./promtext
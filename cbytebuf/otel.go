@@ -0,0 +1,113 @@
+package cbytebuf
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of cbytebuf.MetricsWriter.
+type OTelMetrics struct {
+	ins  *otelInstruments
+	done *uint32
+}
+
+type otelInstruments struct {
+	acq     metric.Int64Counter
+	rel     metric.Int64Counter
+	pool    metric.Int64UpDownCounter
+	poolMem metric.Int64UpDownCounter
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/cbytebuf"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+	}
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.acq = otelCheck(meter.Int64Counter("cbytebuf_acq",
+		metric.WithDescription("Count of pool acquire.")))
+	ins.rel = otelCheck(meter.Int64Counter("cbytebuf_rel",
+		metric.WithDescription("Count of pool release.")))
+	ins.pool = otelCheck(meter.Int64UpDownCounter("cbytebuf_pool",
+		metric.WithDescription("Capacity of cbytebuf pool.")))
+	ins.poolMem = otelCheck(meter.Int64UpDownCounter("cbytebuf_pool_mem",
+		metric.WithDescription("Capacity of cbytebuf pool in bytes."),
+		metric.WithUnit("By")))
+	return ins
+}
+
+func (m OTelMetrics) PoolAcquire(cap uint64) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.acq.Add(ctx, 1)
+	m.ins.pool.Add(ctx, -1)
+	m.ins.poolMem.Add(ctx, -int64(cap))
+}
+
+func (m OTelMetrics) PoolRelease(cap uint64) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.rel.Add(ctx, 1)
+	m.ins.pool.Add(ctx, 1)
+	m.ins.poolMem.Add(ctx, int64(cap))
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports.
+func (m OTelMetrics) Close() error {
+	atomic.StoreUint32(m.done, 1)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
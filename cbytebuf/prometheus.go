@@ -1,53 +1,167 @@
 package cbytebuf
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collection of collectors registered in the same registry.
+type promVecs struct {
+	acq     *prometheus.CounterVec
+	rel     *prometheus.CounterVec
+	pool    prometheus.Gauge
+	poolMem prometheus.Gauge
+
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
+}
 
 var (
-	promCbytebufAcq     *prometheus.CounterVec
-	promCbytebufRel     *prometheus.CounterVec
-	promCbytebufPool    prometheus.Gauge
-	promCbytebufPoolMem prometheus.Gauge
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
 
-	_ = NewPrometheusMetrics
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
 )
 
-func init() {
-	promCbytebufAcq = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbytebuf_acq",
-		Help: "Count of pool acquire.",
-	}, []string{})
-	promCbytebufRel = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbytebuf_rel",
-		Help: "Count of pool release.",
-	}, []string{})
-	promCbytebufPool = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "cbytebuf_pool",
-		Help: "Capacity of cbytebuf pool.",
-	})
-	promCbytebufPoolMem = prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "cbytebuf_pool_mem",
-		Help: "Capacity of cbytebuf pool in bytes.",
-	})
-
-	prometheus.MustRegister(promCbytebufAcq, promCbytebufRel, promCbytebufPool, promCbytebufPoolMem)
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
+}
+
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
+
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.acq = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytebuf_acq",
+		Help:        "Count of pool acquire.",
+		ConstLabels: c.constLabels,
+	}, []string{})).(*prometheus.CounterVec)
+	v.rel = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytebuf_rel",
+		Help:        "Count of pool release.",
+		ConstLabels: c.constLabels,
+	}, []string{})).(*prometheus.CounterVec)
+	v.pool = v.register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytebuf_pool",
+		Help:        "Capacity of cbytebuf pool.",
+		ConstLabels: c.constLabels,
+	})).(prometheus.Gauge)
+	v.poolMem = v.register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytebuf_pool_mem",
+		Help:        "Capacity of cbytebuf pool in bytes.",
+		ConstLabels: c.constLabels,
+	})).(prometheus.Gauge)
+
+	return v
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
 }
 
 // PrometheusMetrics implement cbytebuf.MetricsWriter interface.
-type PrometheusMetrics struct{}
+type PrometheusMetrics struct {
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+}
 
 func NewPrometheusMetrics() *PrometheusMetrics {
-	m := &PrometheusMetrics{}
+	return NewPrometheusMetricsWithOptions()
+}
+
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+func NewPrometheusMetricsWithOptions(opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &PrometheusMetrics{
+		vec:  getPromVecs(&c),
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+	}
+	m.hnd.Store(newPromHandles(m.vec))
 	return m
 }
 
 func (m PrometheusMetrics) PoolAcquire(cap uint64) {
-	promCbytebufAcq.WithLabelValues().Add(1)
-	promCbytebufPool.Sub(1)
-	promCbytebufPoolMem.Sub(float64(cap))
+	m.handles().acq.Inc()
+	m.vec.pool.Sub(1)
+	m.vec.poolMem.Sub(float64(cap))
 }
 
 func (m PrometheusMetrics) PoolRelease(cap uint64) {
-	promCbytebufRel.WithLabelValues().Add(1)
-	promCbytebufPool.Add(1)
-	promCbytebufPoolMem.Add(float64(cap))
+	m.handles().rel.Inc()
+	m.vec.pool.Add(1)
+	m.vec.poolMem.Add(float64(cap))
+}
+
+// Close releases shared collectors, the last closed writer of the registerer unregisters them. Package has no named
+// components, so writer has no own series to remove.
+//
+// Writer keeps applying events after Close: memory allocated before Close may be freed later, so shared gauges must
+// follow it. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		putPromVecs(m.vec)
+	}
+	return nil
 }
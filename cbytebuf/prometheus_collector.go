@@ -0,0 +1,105 @@
+package cbytebuf
+
+import (
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of cbytebuf.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot pools. Metrics names and labels are the same as
+// PrometheusMetrics has, but both implementations can't be mixed in the same registerer.
+type CollectorMetrics struct {
+	reg  prometheus.Registerer
+	desc *collDescs
+
+	acq, rel, pool, poolMem promreg.Counter
+}
+
+type collDescs struct {
+	acq, rel, pool, poolMem *prometheus.Desc
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same registerer share counters, ie
+// constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &CollectorMetrics{
+		reg:  c.reg,
+		desc: newCollDescs(&c),
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, c.constLabels)
+	}
+	return &collDescs{
+		acq:     desc("cbytebuf_acq", "Count of pool acquire."),
+		rel:     desc("cbytebuf_rel", "Count of pool release."),
+		pool:    desc("cbytebuf_pool", "Capacity of cbytebuf pool."),
+		poolMem: desc("cbytebuf_pool_mem", "Capacity of cbytebuf pool in bytes."),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.acq, d.rel, d.pool, d.poolMem} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	gauge := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c.Load()), lvs...)
+	}
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	counter(d.acq, &m.acq)
+	counter(d.rel, &m.rel)
+	gauge(d.pool, &m.pool)
+	gauge(d.poolMem, &m.poolMem)
+}
+
+func (m *CollectorMetrics) PoolAcquire(cap uint64) {
+	sh := promreg.Shard()
+	m.acq.Add(sh, 1)
+	m.pool.Add(sh, -1)
+	m.poolMem.Add(sh, -int64(cap))
+}
+
+func (m *CollectorMetrics) PoolRelease(cap uint64) {
+	sh := promreg.Shard()
+	m.rel.Add(sh, 1)
+	m.pool.Add(sh, 1)
+	m.poolMem.Add(sh, int64(cap))
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
@@ -0,0 +1,25 @@
+package cbytebuf
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Counters bound once to avoid vectors locking on every event.
+type promHandles struct {
+	acq, rel prometheus.Counter
+}
+
+func newPromHandles(v *promVecs) *promHandles {
+	return &promHandles{
+		acq: v.acq.WithLabelValues(),
+		rel: v.rel.WithLabelValues(),
+	}
+}
+
+// Get handles bound to the writer.
+func (m PrometheusMetrics) handles() *promHandles {
+	for {
+		if h := m.hnd.Load(); h != nil {
+			return h
+		}
+		m.hnd.CompareAndSwap(nil, newPromHandles(m.vec))
+	}
+}
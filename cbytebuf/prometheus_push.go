@@ -0,0 +1,113 @@
+package cbytebuf
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/prom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// PrometheusPusher pushes metrics of Prometheus writers to Pushgateway.
+//
+// Designed for short-lived jobs exit before any scrape happens. Metrics pushes periodically and on Close, each push
+// replaces all metrics of the job and grouping key (see push.Pusher.Push). Push failed due to network error or 5xx
+// response retries with exponential backoff.
+type PrometheusPusher struct {
+	p *prom.Pusher
+}
+
+// PushOption describes PrometheusPusher option.
+type PushOption func(*prom.PushConfig)
+
+// WithPushInterval sets push interval.
+func WithPushInterval(interval time.Duration) PushOption {
+	return func(c *prom.PushConfig) {
+		c.Interval = interval
+	}
+}
+
+// WithPushGatherer sets gatherer to push metrics from, eg registry specified in WithRegisterer option.
+//
+// prometheus.DefaultGatherer is used by default, unless WithPushCollectors specified.
+func WithPushGatherer(gatherer prometheus.Gatherer) PushOption {
+	return func(c *prom.PushConfig) {
+		c.Gatherer = gatherer
+	}
+}
+
+// WithPushCollectors sets collectors to push, eg CollectorMetrics writers.
+func WithPushCollectors(collectors ...prometheus.Collector) PushOption {
+	return func(c *prom.PushConfig) {
+		c.Collectors = append(c.Collectors, collectors...)
+	}
+}
+
+// WithPushGrouping adds label to grouping key.
+func WithPushGrouping(name, value string) PushOption {
+	return func(c *prom.PushConfig) {
+		if c.Grouping == nil {
+			c.Grouping = make(map[string]string)
+		}
+		c.Grouping[name] = value
+	}
+}
+
+// WithPushBasicAuth sets credentials of HTTP basic authentication.
+func WithPushBasicAuth(username, password string) PushOption {
+	return func(c *prom.PushConfig) {
+		c.User, c.Pass = username, password
+	}
+}
+
+// WithPushClient sets HTTP client. http.DefaultClient is used by default.
+func WithPushClient(client push.HTTPDoer) PushOption {
+	return func(c *prom.PushConfig) {
+		c.Client = client
+	}
+}
+
+// WithPushRetry sets amount of retries of failed push and delay before the first retry. Each next delay doubles.
+//
+// Only network errors and 5xx responses are retried.
+func WithPushRetry(retries int, delay time.Duration) PushOption {
+	return func(c *prom.PushConfig) {
+		c.Retries, c.Delay = retries, delay
+	}
+}
+
+// WithPushCloseTimeout limits duration of the final push on Close, including retries. 5 seconds by default.
+func WithPushCloseTimeout(timeout time.Duration) PushOption {
+	return func(c *prom.PushConfig) {
+		c.CloseTimeout = timeout
+	}
+}
+
+// WithPushErrorHandler sets callback to report errors of periodic pushes.
+func WithPushErrorHandler(fn func(err error)) PushOption {
+	return func(c *prom.PushConfig) {
+		c.OnError = fn
+	}
+}
+
+var _ = NewPrometheusPusher
+
+// NewPrometheusPusher makes new pusher sends metrics to Pushgateway with given URL under given job name.
+func NewPrometheusPusher(url, job string, opts ...PushOption) *PrometheusPusher {
+	var conf prom.PushConfig
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	return &PrometheusPusher{p: prom.NewPusher(url, job, conf)}
+}
+
+// Push sends actual state of metrics, retrying on failure. Waiting between retries stops on Close.
+func (m *PrometheusPusher) Push() error {
+	return m.p.Push()
+}
+
+// Close stops periodic pushes and pushes the final state of metrics. Final push retries the same way as Push within
+// timeout specified by WithPushCloseTimeout.
+func (m *PrometheusPusher) Close() error {
+	return m.p.Close()
+}
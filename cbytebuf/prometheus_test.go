@@ -0,0 +1,117 @@
+package cbytebuf
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPrometheusMetricsRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := []PrometheusOption{WithRegisterer(reg), WithNamespace("app"), WithSubsystem("svc"),
+		WithConstLabels(prometheus.Labels{"env": "test"})}
+	a := NewPrometheusMetricsWithOptions(opts...)
+	b := NewPrometheusMetricsWithOptions(opts...)
+	a.PoolAcquire(64)
+	b.PoolAcquire(64)
+
+	mfs := promGather(t, reg)
+	for _, name := range []string{"cbytebuf_acq", "app_cbytebuf_acq"} {
+		if _, ok := mfs[name]; ok {
+			t.Errorf("unexpected family %s", name)
+		}
+	}
+	mf, ok := mfs["app_svc_cbytebuf_acq"]
+	if !ok {
+		t.Fatal("family app_svc_cbytebuf_acq expected")
+	}
+	// Writers of the same registerer share series.
+	if ms := mf.GetMetric(); len(ms) != 1 || ms[0].GetCounter().GetValue() != 2 {
+		t.Errorf("app_svc_cbytebuf_acq: single series with value 2 expected, got %v", ms)
+	} else if lbl := promLabels(ms[0]); len(lbl) != 1 || lbl["env"] != "test" {
+		t.Errorf("labels %v: expected env=test", lbl)
+	}
+	// Private registerer doesn't touch the default one.
+	if mfs, err := prometheus.DefaultGatherer.Gather(); err == nil {
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), "app_svc_") {
+				t.Errorf("family %s registered in default registerer", mf.GetName())
+			}
+		}
+	}
+
+	// The last closed writer unregisters collectors and releases registerer.
+	_ = a.Close()
+	if mfs := promGather(t, reg); len(mfs) == 0 {
+		t.Error("collectors of unclosed writer expected")
+	}
+	_ = b.Close()
+	_ = b.Close()
+	if mfs := promGather(t, reg); len(mfs) != 0 {
+		t.Errorf("no families expected after close, got %d", len(mfs))
+	}
+	promMux.Lock()
+	defer promMux.Unlock()
+	for k := range promIdx {
+		if k.reg == reg {
+			t.Error("closed registerer kept in index")
+		}
+	}
+}
+
+func TestPrometheusMetricsClose(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a := NewPrometheusMetricsWithOptions(WithRegisterer(reg))
+	b := NewPrometheusMetricsWithOptions(WithRegisterer(reg))
+	a.PoolRelease(64)
+	b.PoolRelease(128)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing one writer must not reset pool gauges maintained by others.
+	if v := b.gauge("cbytebuf_pool"); v != 2 {
+		t.Fatalf("cbytebuf_pool after close: got %d, expected 2", v)
+	}
+	// Closed writer keeps applying events, so buffers released before close are acquired.
+	a.PoolAcquire(64)
+	if v, mem := b.gauge("cbytebuf_pool"), b.gauge("cbytebuf_pool_mem"); v != 1 || mem != 128 {
+		t.Errorf("cbytebuf_pool after acquire by closed writer: got %d (%d bytes), expected 1 (128 bytes)", v, mem)
+	}
+	b.PoolAcquire(128)
+	if v, mem := b.gauge("cbytebuf_pool"), b.gauge("cbytebuf_pool_mem"); v != 0 || mem != 0 {
+		t.Errorf("cbytebuf_pool: got %d (%d bytes), expected 0", v, mem)
+	}
+	_ = b.Close()
+}
+
+func BenchmarkPrometheusMetrics_PoolAcquire(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions(WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.PoolAcquire(64)
+		m.PoolRelease(64)
+	}
+}
+
+func promGather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		r[mf.GetName()] = mf
+	}
+	return r
+}
+
+func promLabels(m *dto.Metric) map[string]string {
+	r := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		r[lp.GetName()] = lp.GetValue()
+	}
+	return r
+}
@@ -0,0 +1,87 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Metrics is a dependency-free Prometheus implementation of cbytebuf.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as cbytebuf.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	reg     *Registry
+	desc    *descs
+	id      string
+	created time.Time
+
+	acq, rel, pool, poolMem promreg.Counter
+}
+
+type descs struct {
+	acq, rel, pool, poolMem *promreg.Desc
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same registry share counters, ie constructor returns already registered writer.
+func NewMetrics(opts ...Option) *Metrics {
+	c := config{reg: DefaultRegistry}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	m := &Metrics{
+		reg:     c.reg,
+		desc:    newDescs(&c),
+		id:      "cbytebuf|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels),
+		created: time.Now(),
+	}
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, c.constLabels, labels...)
+	}
+	return &descs{
+		acq:     desc("cbytebuf_acq", "Count of pool acquire."),
+		rel:     desc("cbytebuf_rel", "Count of pool release."),
+		pool:    desc("cbytebuf_pool", "Capacity of cbytebuf pool."),
+		poolMem: desc("cbytebuf_pool_mem", "Capacity of cbytebuf pool in bytes."),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	g.Counter(d.acq, &m.acq, m.created)
+	g.Counter(d.rel, &m.rel, m.created)
+	g.Gauge(d.pool, &m.pool)
+	g.Gauge(d.poolMem, &m.poolMem)
+}
+
+func (m *Metrics) PoolAcquire(cap uint64) {
+	sh := promreg.Shard()
+	m.acq.Add(sh, 1)
+	m.pool.Add(sh, -1)
+	m.poolMem.Add(sh, -int64(cap))
+}
+
+func (m *Metrics) PoolRelease(cap uint64) {
+	sh := promreg.Shard()
+	m.rel.Add(sh, 1)
+	m.pool.Add(sh, 1)
+	m.poolMem.Add(sh, int64(cap))
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
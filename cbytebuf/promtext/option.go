@@ -0,0 +1,44 @@
+package promtext
+
+// Option describes writer option.
+type Option func(*config)
+
+type config struct {
+	reg         *Registry
+	namespace   string
+	subsystem   string
+	constLabels map[string]string
+}
+
+// WithRegistry sets registry to register writer in. DefaultRegistry is used by default.
+func WithRegistry(reg *Registry) Option {
+	return func(c *config) {
+		c.reg = reg
+	}
+}
+
+// WithNamespace sets namespace (prefix) of metrics names.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets subsystem of metrics names. Subsystem writes between namespace and name.
+func WithSubsystem(subsystem string) Option {
+	return func(c *config) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets labels to apply to all metrics of writer.
+func WithConstLabels(labels map[string]string) Option {
+	return func(c *config) {
+		if c.constLabels == nil {
+			c.constLabels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			c.constLabels[k] = v
+		}
+	}
+}
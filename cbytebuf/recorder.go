@@ -0,0 +1,154 @@
+package cbytebuf
+
+import (
+	"fmt"
+	"sync"
+)
+
+// RecorderMetrics is in-memory implementation of cbytebuf.MetricsWriter designed for unit tests.
+//
+// Records every call with its arguments and keeps counters, gauges the same way as aggregating writers do
+// (metric families and labels are the same as in PrometheusMetrics). Safe for concurrent use.
+//
+// Usage example:
+//
+//	m.AssertCount(t, "PoolAcquire", 1)
+//	n := m.Gauge("cbytebuf_pool")
+type RecorderMetrics struct {
+	aggrMetrics
+
+	mux sync.Mutex
+	buf []RecorderEvent
+}
+
+// RecorderEvent describes single recorded call.
+type RecorderEvent struct {
+	// Method name, eg "PoolAcquire".
+	Method string
+	// Arguments in order of method signature.
+	Args []any
+}
+
+// TestingT is a subset of testing.TB used by assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+var _ = NewRecorderMetrics
+
+func NewRecorderMetrics() *RecorderMetrics {
+	m := &RecorderMetrics{aggrMetrics: aggrMetrics{s: newAggrStore()}}
+	return m
+}
+
+func (m *RecorderMetrics) PoolAcquire(cap uint64) {
+	m.record("PoolAcquire", cap)
+	m.aggrMetrics.PoolAcquire(cap)
+}
+
+func (m *RecorderMetrics) PoolRelease(cap uint64) {
+	m.record("PoolRelease", cap)
+	m.aggrMetrics.PoolRelease(cap)
+}
+
+// Events returns copy of recorded events log.
+func (m *RecorderMetrics) Events() []RecorderEvent {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]RecorderEvent(nil), m.buf...)
+}
+
+// Count returns how many times method was called.
+//
+// Optional args filters events by leading arguments. Arguments compares using ==, so their types must be the same as
+// in method signature.
+func (m *RecorderMetrics) Count(method string, args ...any) (n int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i := 0; i < len(m.buf); i++ {
+		if e := &m.buf[i]; e.Method == method && e.match(args) {
+			n++
+		}
+	}
+	return
+}
+
+// Counter returns value of counter. Labels must be specified as key-value pairs, eg
+// m.Counter("cbytebuf_acq").
+func (m *RecorderMetrics) Counter(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrCounter {
+		return x.load()
+	}
+	return 0
+}
+
+// Gauge returns value of gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Gauge(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrGauge {
+		return x.load()
+	}
+	return 0
+}
+
+// Clear drops all recorded events and metrics.
+func (m *RecorderMetrics) Clear() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.buf = m.buf[:0]
+	m.s.reset()
+}
+
+// AssertCount checks if method was called exactly n times. Optional args filters events as in Count method.
+func (m *RecorderMetrics) AssertCount(t TestingT, method string, n int, args ...any) bool {
+	t.Helper()
+	if c := m.Count(method, args...); c != n {
+		t.Errorf("%s%s: expected %d calls, got %d", method, fmtArgs(args), n, c)
+		return false
+	}
+	return true
+}
+
+// AssertValue checks value of counter or gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) AssertValue(t TestingT, family string, value int64, labels ...string) bool {
+	t.Helper()
+	var v int64
+	if x := m.find(family, labels); x != nil && x.kind != aggrTimer {
+		v = x.load()
+	}
+	if v != value {
+		t.Errorf("%s%v: expected %d, got %d", family, labels, value, v)
+		return false
+	}
+	return true
+}
+
+func (m *RecorderMetrics) record(method string, args ...any) {
+	m.mux.Lock()
+	m.buf = append(m.buf, RecorderEvent{Method: method, Args: args})
+	m.mux.Unlock()
+}
+
+func (m *RecorderMetrics) find(family string, labels []string) *aggrSeries {
+	return m.s.find(family, labels...)
+}
+
+// Check if leading arguments of event are equal to args.
+func (e *RecorderEvent) match(args []any) bool {
+	if len(args) > len(e.Args) {
+		return false
+	}
+	for i := 0; i < len(args); i++ {
+		if e.Args[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func fmtArgs(args []any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return fmt.Sprint(args)
+}
@@ -0,0 +1,52 @@
+package cbytebuf
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics()
+	m.PoolRelease(64)
+	m.PoolRelease(64)
+	m.PoolAcquire(64)
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "PoolRelease", 2) ||
+		!m.AssertValue(&ft, "cbytebuf_pool", 1) {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "PoolAcquire", 2) },
+			"PoolAcquire: expected 2 calls, got 1"},
+		{func(t TestingT) bool { return m.AssertValue(t, "cbytebuf_pool_mem", 0) },
+			"cbytebuf_pool_mem[]: expected 0, got 64"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cbytebuf
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
+)
+
+// SlogMetrics is a log/slog implementation of cbytebuf.MetricsWriter.
+//
+// Each event writes as a separate record with message equal to method name (eg "PoolRelease") and arguments as
+// attributes.
+//
+// All events logs with slog.LevelDebug by default. Levels may be changed using options.
+type SlogMetrics struct {
+	l    *slog.Logger
+	conf slogConfig
+	lim  *loglimit.Limiter
+}
+
+// SlogOption describes SlogMetrics option.
+type SlogOption func(*slogConfig)
+
+type slogConfig struct {
+	level  slog.Level
+	levels map[string]slog.Level
+	limits []LogLimitOption
+}
+
+// WithSlogLevel sets level of events haven't own level.
+func WithSlogLevel(level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.level = level
+	}
+}
+
+// WithSlogEventLevel sets level of given event, eg WithSlogEventLevel("PoolRelease", slog.LevelInfo).
+func WithSlogEventLevel(event string, level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.levels[event] = level
+	}
+}
+
+// WithSlogLimits enables sampling and/or rate limiting of events.
+//
+// Reports about suppressed events writes with level of corresponding event.
+func WithSlogLimits(opts ...LogLimitOption) SlogOption {
+	return func(c *slogConfig) {
+		c.limits = append(c.limits, opts...)
+	}
+}
+
+var _ = NewSlogMetrics
+
+// NewSlogMetrics makes new writer logs events to logger. If logger is nil, slog.Default() will use.
+func NewSlogMetrics(logger *slog.Logger, opts ...SlogOption) *SlogMetrics {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &SlogMetrics{
+		l: logger,
+		conf: slogConfig{
+			level:  slog.LevelDebug,
+			levels: map[string]slog.Level{},
+		},
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	m.lim = newLogLimiter(m.conf.limits, m.suppressed)
+	return m
+}
+
+func (m SlogMetrics) PoolAcquire(cap uint64) {
+	m.log("PoolAcquire", slog.Uint64("cap", cap))
+}
+
+func (m SlogMetrics) PoolRelease(cap uint64) {
+	m.log("PoolRelease", slog.Uint64("cap", cap))
+}
+
+// Close stops reporting of suppressed events.
+func (m SlogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
+
+func (m SlogMetrics) log(event string, attrs ...slog.Attr) {
+	ctx, level := context.Background(), m.level(event)
+	if !m.l.Enabled(ctx, level) || !m.lim.Allow(event) {
+		return
+	}
+	m.l.LogAttrs(ctx, level, event, attrs...)
+}
+
+func (m SlogMetrics) suppressed(event string, n uint64) {
+	m.l.LogAttrs(context.Background(), m.level(event), "suppressed events",
+		slog.String("event", event), slog.Uint64("count", n))
+}
+
+func (m SlogMetrics) level(event string) slog.Level {
+	if level, ok := m.conf.levels[event]; ok {
+		return level
+	}
+	return m.conf.level
+}
@@ -0,0 +1,99 @@
+package cbytebuf
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Handler records handled records as "LEVEL message key=value ..." lines.
+type slogTestHandler struct {
+	level slog.Level
+	attrs []slog.Attr
+	mux   *sync.Mutex
+	buf   *[]string
+}
+
+func newSlogTestHandler(level slog.Level) *slogTestHandler {
+	return &slogTestHandler{level: level, mux: new(sync.Mutex), buf: new([]string)}
+}
+
+func (h *slogTestHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *slogTestHandler) Handle(_ context.Context, r slog.Record) error {
+	line := []string{r.Level.String(), r.Message}
+	for _, a := range h.attrs {
+		line = append(line, a.String())
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		line = append(line, a.String())
+		return true
+	})
+	h.mux.Lock()
+	*h.buf = append(*h.buf, strings.Join(line, " "))
+	h.mux.Unlock()
+	return nil
+}
+
+func (h *slogTestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cpy := *h
+	cpy.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return &cpy
+}
+
+func (h *slogTestHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// Amount of handled records.
+func (h *slogTestHandler) count() int64 {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return int64(len(*h.buf))
+}
+
+func (h *slogTestHandler) expect(t *testing.T, lines ...string) {
+	t.Helper()
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if got := strings.Join(*h.buf, "\n"); got != strings.Join(lines, "\n") {
+		t.Errorf("got records:\n%s\nexpected:\n%s", got, strings.Join(lines, "\n"))
+	}
+}
+
+func TestSlogMetrics(t *testing.T) {
+	t.Run("levels", func(t *testing.T) {
+		h := newSlogTestHandler(slog.LevelDebug)
+		m := NewSlogMetrics(slog.New(h))
+		m.PoolAcquire(64)
+		m.PoolRelease(64)
+		h.expect(t,
+			"DEBUG PoolAcquire cap=64",
+			"DEBUG PoolRelease cap=64",
+		)
+	})
+	t.Run("options", func(t *testing.T) {
+		h := newSlogTestHandler(slog.LevelInfo)
+		m := NewSlogMetrics(slog.New(h), WithSlogLevel(slog.LevelInfo),
+			WithSlogEventLevel("PoolAcquire", slog.LevelDebug))
+		// Event level is below handler's one, so it's skipped.
+		m.PoolAcquire(64)
+		m.PoolRelease(32)
+		h.expect(t, "INFO PoolRelease cap=32")
+	})
+	t.Run("limits", func(t *testing.T) {
+		h := newSlogTestHandler(slog.LevelDebug)
+		m := NewSlogMetrics(slog.New(h), WithSlogLimits(WithLogSampling(2)))
+		m.PoolAcquire(64)
+		m.PoolAcquire(64)
+		m.PoolAcquire(64)
+		// Close reports suppressed events.
+		_ = m.Close()
+		h.expect(t, "DEBUG PoolAcquire cap=64", "DEBUG PoolAcquire cap=64",
+			"DEBUG suppressed events event=PoolAcquire count=1")
+	})
+}
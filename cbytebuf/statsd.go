@@ -0,0 +1,43 @@
+package cbytebuf
+
+import "github.com/koykov/metrics_writers/internal/statsd"
+
+// StatsDMetrics is a StatsD implementation of cbytebuf.MetricsWriter.
+//
+// Counters sends as is, gauges keeps locally and sends actual values.
+type StatsDMetrics struct {
+	c *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{c: c}
+	return m, nil
+}
+
+func (m StatsDMetrics) PoolAcquire(cap uint64) {
+	m.c.Count("cbytebuf_acq", 1)
+	m.c.GaugeAdd("cbytebuf_pool", -1)
+	m.c.GaugeAdd("cbytebuf_pool_mem", -int64(cap))
+}
+
+func (m StatsDMetrics) PoolRelease(cap uint64) {
+	m.c.Count("cbytebuf_rel", 1)
+	m.c.GaugeAdd("cbytebuf_pool", 1)
+	m.c.GaugeAdd("cbytebuf_pool_mem", int64(cap))
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
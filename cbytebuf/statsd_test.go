@@ -0,0 +1,38 @@
+package cbytebuf
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsDMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	m, err := NewStatsDMetrics(conn.LocalAddr().String(), WithStatsDPrefix("app."),
+		WithStatsDFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = m.Close() }()
+
+	m.PoolRelease(64)
+	m.PoolAcquire(64)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "app.cbytebuf_rel:1|c\napp.cbytebuf_pool:1|g\napp.cbytebuf_pool_mem:64|g\napp.cbytebuf_acq:1|c\n" +
+		"app.cbytebuf_pool:0|g\napp.cbytebuf_pool_mem:0|g"
+	if got := string(buf[:n]); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
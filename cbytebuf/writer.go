@@ -0,0 +1,25 @@
+package cbytebuf
+
+// MetricsWriter describes cbytebuf.MetricsWriter interface.
+//
+// Declared locally to keep writers independent of upstream package version. All writers of the package implement it.
+type MetricsWriter interface {
+	// PoolAcquire registers acquiring of buffer with given capacity from the pool.
+	PoolAcquire(cap uint64)
+	// PoolRelease registers release of buffer with given capacity to the pool.
+	PoolRelease(cap uint64)
+}
+
+var (
+	_ MetricsWriter = (*PrometheusMetrics)(nil)
+	_ MetricsWriter = (*CollectorMetrics)(nil)
+	_ MetricsWriter = (*ExpvarMetrics)(nil)
+	_ MetricsWriter = (*OTelMetrics)(nil)
+	_ MetricsWriter = (*StatsDMetrics)(nil)
+	_ MetricsWriter = (*InfluxMetrics)(nil)
+	_ MetricsWriter = (*GraphiteMetrics)(nil)
+	_ MetricsWriter = (*MultiMetrics)(nil)
+	_ MetricsWriter = (*RecorderMetrics)(nil)
+	_ MetricsWriter = (*SlogMetrics)(nil)
+	_ MetricsWriter = (*SummaryLogMetrics)(nil)
+)
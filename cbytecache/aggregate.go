@@ -0,0 +1,244 @@
+package cbytecache
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type aggrKind uint8
+
+const (
+	aggrCounter aggrKind = iota
+	aggrGauge
+	aggrTimer
+)
+
+// In-memory storage of series aggregated between flushes.
+type aggrStore struct {
+	mux sync.RWMutex
+	idx map[string]*aggrSeries
+	// Optional callback of new series registration, calls under lock.
+	hook func(x *aggrSeries)
+}
+
+// Series of metric family with concrete labels values.
+//
+// Counters and gauges keeps in value, timers keeps cumulative count and sum (in nanoseconds) of observations and
+// peak (maximum) observation since last flush.
+type aggrSeries struct {
+	family string
+	kind   aggrKind
+	// Key-value pairs.
+	labels []string
+
+	value, count, sum, peak int64
+}
+
+func newAggrStore() *aggrStore {
+	return &aggrStore{idx: make(map[string]*aggrSeries)}
+}
+
+// Get existing or register new series. Labels must be specified as key-value pairs.
+func (s *aggrStore) get(family string, kind aggrKind, labels ...string) *aggrSeries {
+	key := aggrKey(family, labels)
+	s.mux.RLock()
+	x, ok := s.idx[key]
+	s.mux.RUnlock()
+	if ok {
+		return x
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if x, ok = s.idx[key]; ok {
+		return x
+	}
+	x = &aggrSeries{
+		family: family,
+		kind:   kind,
+		labels: append([]string(nil), labels...),
+	}
+	s.idx[key] = x
+	if s.hook != nil {
+		s.hook(x)
+	}
+	return x
+}
+
+// Get existing series or nil.
+func (s *aggrStore) find(family string, labels ...string) *aggrSeries {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.idx[aggrKey(family, labels)]
+}
+
+// Drop all series.
+func (s *aggrStore) reset() {
+	s.mux.Lock()
+	s.idx = make(map[string]*aggrSeries)
+	s.mux.Unlock()
+}
+
+// Walk over all series sorted by family and labels.
+func (s *aggrStore) each(fn func(x *aggrSeries)) {
+	s.mux.RLock()
+	buf := make([]*aggrSeries, 0, len(s.idx))
+	for _, x := range s.idx {
+		buf = append(buf, x)
+	}
+	s.mux.RUnlock()
+	sort.Slice(buf, func(i, j int) bool {
+		if buf[i].family != buf[j].family {
+			return buf[i].family < buf[j].family
+		}
+		return strings.Join(buf[i].labels, "\xff") < strings.Join(buf[j].labels, "\xff")
+	})
+	for _, x := range buf {
+		fn(x)
+	}
+}
+
+func aggrKey(family string, labels []string) string {
+	if len(labels) == 0 {
+		return family
+	}
+	return family + "\xff" + strings.Join(labels, "\xff")
+}
+
+func (x *aggrSeries) add(delta int64) {
+	atomic.AddInt64(&x.value, delta)
+}
+
+func (x *aggrSeries) set(value int64) {
+	atomic.StoreInt64(&x.value, value)
+}
+
+func (x *aggrSeries) observe(dur time.Duration) {
+	atomic.AddInt64(&x.count, 1)
+	atomic.AddInt64(&x.sum, int64(dur))
+	for {
+		cur := atomic.LoadInt64(&x.peak)
+		if int64(dur) <= cur || atomic.CompareAndSwapInt64(&x.peak, cur, int64(dur)) {
+			break
+		}
+	}
+}
+
+func (x *aggrSeries) load() int64 {
+	return atomic.LoadInt64(&x.value)
+}
+
+// Get timer's count and sum of observations and peak since the last reset.
+func (x *aggrSeries) loadTimer() (count int64, sum, peak time.Duration) {
+	count = atomic.LoadInt64(&x.count)
+	sum = time.Duration(atomic.LoadInt64(&x.sum))
+	peak = time.Duration(atomic.LoadInt64(&x.peak))
+	return
+}
+
+// Reset reported peak. Peak stays if greater observation came after reporting.
+func (x *aggrSeries) resetPeak(peak time.Duration) {
+	atomic.CompareAndSwapInt64(&x.peak, int64(peak), 0)
+}
+
+// Timer's peak reported by flush, resets after successful write only.
+type aggrPeak struct {
+	x    *aggrSeries
+	peak time.Duration
+}
+
+// Base of aggregating writers: registers events in the store.
+type aggrMetrics struct {
+	key string
+	s   *aggrStore
+}
+
+func (m aggrMetrics) Alloc(bucket string, size uint32) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheTotal).add(int64(size))
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheFree).add(int64(size))
+
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaTotal).add(1)
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaFree).add(1)
+	m.s.get("cbytecache_arena_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", arenaIOAlloc).add(1)
+}
+
+func (m aggrMetrics) Fill(bucket string, size uint32) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheUsed).add(int64(size))
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheFree).add(-int64(size))
+
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaUsed).add(1)
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaFree).add(-1)
+	m.s.get("cbytecache_arena_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", arenaIOFill).add(1)
+}
+
+func (m aggrMetrics) Reset(bucket string, size uint32) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheUsed).add(-int64(size))
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheFree).add(int64(size))
+
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaUsed).add(-1)
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaFree).add(1)
+	m.s.get("cbytecache_arena_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", arenaIOReset).add(1)
+}
+
+func (m aggrMetrics) Release(bucket string, size uint32) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheTotal).add(-int64(size))
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheFree).add(-int64(size))
+
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaTotal).add(-1)
+	m.s.get("cbytecache_arena", aggrGauge, "cache", m.key, "bucket", bucket, "type", arenaFree).add(-1)
+	m.s.get("cbytecache_arena_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", arenaIORelease).add(1)
+}
+
+func (m aggrMetrics) Set(bucket string, dur time.Duration) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheEntryTotal).add(1)
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOSet).add(1)
+	m.s.get("cbytecache_io_speed", aggrTimer, "cache", m.key, "bucket", bucket, "op", speedWrite).observe(dur)
+}
+
+func (m aggrMetrics) Del(bucket string) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheEntryDelete).add(1)
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIODel).add(1)
+}
+
+func (m aggrMetrics) Evict(bucket string, alive bool) {
+	m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheEntryTotal).add(-1)
+	if !alive {
+		m.s.get("cbytecache_size", aggrGauge, "cache", m.key, "bucket", bucket, "type", cacheEntryDelete).add(-1)
+	}
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOEvict).add(1)
+}
+
+func (m aggrMetrics) Miss(bucket string) {
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOMiss).add(1)
+}
+
+func (m aggrMetrics) Hit(bucket string, dur time.Duration) {
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOHit).add(1)
+	m.s.get("cbytecache_io_speed", aggrTimer, "cache", m.key, "bucket", bucket, "op", speedRead).observe(dur)
+}
+
+func (m aggrMetrics) Expire(bucket string) {
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOExpire).add(1)
+}
+
+func (m aggrMetrics) Corrupt(bucket string) {
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOCorrupt).add(1)
+}
+
+func (m aggrMetrics) Collision(bucket string) {
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIOCollision).add(1)
+}
+
+func (m aggrMetrics) NoSpace(bucket string) {
+	m.s.get("cbytecache_io", aggrCounter, "cache", m.key, "bucket", bucket, "op", cacheIONoSpace).add(1)
+}
+
+func (m aggrMetrics) Dump(bucket string) {
+	m.s.get("cbytecache_dump", aggrCounter, "cache", m.key, "bucket", bucket, "op", dumpIODump).add(1)
+}
+
+func (m aggrMetrics) Load(bucket string) {
+	m.s.get("cbytecache_dump", aggrCounter, "cache", m.key, "bucket", bucket, "op", dumpIOLoad).add(1)
+}
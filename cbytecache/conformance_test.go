@@ -0,0 +1,585 @@
+package cbytecache
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koykov/metrics_writers/cbytecache/promtext"
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var conformanceSeed = flag.Uint64("conformance.seed", 0, "seed of randomized conformance replay, 0 means default seeds")
+
+var conformanceSeeds = []uint64{1, 2, 3}
+
+// Writer under check. Gauge reads gauges of the writer the same way as RecorderMetrics.Gauge, events reads amount of
+// records written by log writers. Snapshot takes state of the writer before gauges read. All are optional.
+type conformanceTarget struct {
+	w        MetricsWriter
+	gauge    func(family string, labels ...string) int64
+	events   func() int64
+	snapshot func()
+}
+
+func TestConformance(t *testing.T) {
+	seeds := conformanceSeeds
+	if *conformanceSeed != 0 {
+		seeds = []uint64{*conformanceSeed}
+	}
+	// Log and summary writers use standard logger.
+	prev := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(prev)
+
+	for _, tc := range []struct {
+		name string
+		new  func(t *testing.T) conformanceTarget
+	}{
+		{"prometheus", func(*testing.T) conformanceTarget {
+			m := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"collector", func(t *testing.T) conformanceTarget {
+			m, err := NewCollectorMetrics("test", WithRegisterer(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"expvar", func(t *testing.T) conformanceTarget {
+			// Expvar variables are global, so name must be unique.
+			m, err := NewExpvarMetrics("conformance_" + strings.ReplaceAll(t.Name(), "/", "_"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"recorder", func(*testing.T) conformanceTarget {
+			m := NewRecorderMetrics("test")
+			return conformanceTarget{w: m, gauge: m.Gauge}
+		}},
+		{"otel", func(*testing.T) conformanceTarget {
+			g := &otelValues{r: sdkmetric.NewManualReader(), kv: []string{"cache", "test"}}
+			m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(g.r))))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+		{"statsd", func(t *testing.T) conformanceTarget {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewStatsDMetrics("test", conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = m.Close()
+				_ = conn.Close()
+			})
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"influx", func(t *testing.T) conformanceTarget {
+			m := NewInfluxMetrics("test", io.Discard, WithInfluxInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"graphite", func(t *testing.T) conformanceTarget {
+			// Nothing listens the address, so series stay aggregated.
+			m := NewGraphiteMetrics("test", "127.0.0.1:1", WithGraphiteInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"log", func(t *testing.T) conformanceTarget {
+			var lw conformanceLines
+			log.SetOutput(&lw)
+			t.Cleanup(func() { log.SetOutput(io.Discard) })
+			return conformanceTarget{w: NewLogMetrics("test"), events: lw.count}
+		}},
+		{"slog", func(*testing.T) conformanceTarget {
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := NewSlogMetrics("test", slog.New(h))
+			return conformanceTarget{w: m, events: h.count}
+		}},
+		{"summary", func(t *testing.T) conformanceTarget {
+			m := NewSummaryLogMetrics("test", time.Hour)
+			t.Cleanup(m.Stop)
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"rewrite", func(*testing.T) conformanceTarget {
+			rec := NewRecorderMetrics("test")
+			m := NewRewriteMetrics(rec, WithRewriteRules(RewritePrefix("tmp_", "tmp")), WithRewriteSanitize())
+			return conformanceTarget{w: m, gauge: rec.Gauge}
+		}},
+		{"multi", func(*testing.T) conformanceTarget {
+			pm := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: NewMultiMetrics(NewRecorderMetrics("test"), pm), gauge: pm.gauge}
+		}},
+		{"promtext", func(*testing.T) conformanceTarget {
+			g := &promtextValues{reg: promtext.NewRegistry(), kv: []string{"cache", "test"}}
+			m := promtext.NewMetrics("test", promtext.WithRegistry(g.reg))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, seed := range seeds {
+				t.Run(strconv.FormatUint(seed, 10), func(t *testing.T) {
+					checkConformance(t, tc.new(t), seed)
+				})
+			}
+		})
+	}
+}
+
+const (
+	// Each step checks gauges of all buckets, so the replay is shorter than in other packages.
+	conformanceSteps     = 5000
+	conformanceArenaSize = 1 << 16
+)
+
+var conformanceBuckets = []string{"0", "1", "2"}
+
+// Replay scripted and randomized (using seed) event sequences derived from upstream cbytecache state
+// machine into the writer and check gauges invariants after each event:
+//   - gauges never become negative;
+//   - total = used + free for both cbytecache_size and cbytecache_arena of each bucket;
+//   - deleted entries don't exceed total entries;
+//   - gauges values are equal to the state of the replayed cache.
+//
+// Gauges checks on shadow RecorderMetrics receives the same events and on the writer if target has gauge reader.
+func checkConformance(t *testing.T, tg conformanceTarget, seed uint64) {
+	t.Helper()
+	c := &conformance{
+		conformanceTarget: tg,
+		rec:               NewRecorderMetrics("conformance"),
+		rnd:               rand.New(rand.NewPCG(seed, seed)),
+		bkt:               make(map[string]*conformanceBucket, len(conformanceBuckets)),
+	}
+	for _, bucket := range conformanceBuckets {
+		c.bkt[bucket] = &conformanceBucket{}
+	}
+	c.script()
+	for i := 0; i < conformanceSteps && c.err == nil; i++ {
+		c.random()
+		c.check()
+	}
+	if c.err != nil {
+		t.Errorf("seed %d: %s", seed, c.err)
+	}
+}
+
+// State of replayed cache.
+type conformance struct {
+	conformanceTarget
+	rec *RecorderMetrics
+	rnd *rand.Rand
+	err error
+	// Last replayed event and amount of replayed events.
+	event string
+	n     int64
+
+	bkt map[string]*conformanceBucket
+}
+
+type conformanceBucket struct {
+	// Arenas sizes.
+	free, used []uint32
+	// Alive and deleted entries.
+	alive, deleted int64
+}
+
+// Replay scripted sequences.
+func (c *conformance) script() {
+	steps := []func(){
+		// Arenas lifecycle.
+		func() { c.alloc("0") }, func() { c.alloc("0") }, func() { c.fill("0") }, func() { c.fill("0") },
+		func() { c.reset("0") }, func() { c.release("0") },
+		// Entries lifecycle.
+		func() { c.set("1") }, func() { c.set("1") }, func() { c.set("1") }, func() { c.del("1") },
+		func() { c.evict("1", true) }, func() { c.evict("1", false) },
+		// Read events don't affect gauges.
+		func() { c.read("1") }, func() { c.read("1") }, func() { c.read("1") },
+	}
+	for i := 0; i < len(steps) && c.err == nil; i++ {
+		steps[i]()
+		c.check()
+	}
+}
+
+// Replay random valid event.
+func (c *conformance) random() {
+	bucket := conformanceBuckets[c.rnd.IntN(len(conformanceBuckets))]
+	switch c.rnd.IntN(9) {
+	case 0:
+		c.alloc(bucket)
+	case 1:
+		c.fill(bucket)
+	case 2:
+		c.reset(bucket)
+	case 3:
+		c.release(bucket)
+	case 4:
+		c.set(bucket)
+	case 5:
+		c.del(bucket)
+	case 6:
+		c.evict(bucket, c.rnd.IntN(2) == 0)
+	default:
+		c.read(bucket)
+	}
+}
+
+func (c *conformance) alloc(bucket string) {
+	b := c.bkt[bucket]
+	b.free = append(b.free, conformanceArenaSize)
+	c.emit("Alloc", func(w MetricsWriter) { w.Alloc(bucket, conformanceArenaSize) })
+}
+
+func (c *conformance) fill(bucket string) {
+	b := c.bkt[bucket]
+	if len(b.free) == 0 {
+		return
+	}
+	size := b.free[len(b.free)-1]
+	b.free, b.used = b.free[:len(b.free)-1], append(b.used, size)
+	c.emit("Fill", func(w MetricsWriter) { w.Fill(bucket, size) })
+}
+
+func (c *conformance) reset(bucket string) {
+	b := c.bkt[bucket]
+	if len(b.used) == 0 {
+		return
+	}
+	size := b.used[len(b.used)-1]
+	b.used, b.free = b.used[:len(b.used)-1], append(b.free, size)
+	c.emit("Reset", func(w MetricsWriter) { w.Reset(bucket, size) })
+}
+
+func (c *conformance) release(bucket string) {
+	// Only free (reset) arenas may be released.
+	b := c.bkt[bucket]
+	if len(b.free) == 0 {
+		return
+	}
+	size := b.free[len(b.free)-1]
+	b.free = b.free[:len(b.free)-1]
+	c.emit("Release", func(w MetricsWriter) { w.Release(bucket, size) })
+}
+
+func (c *conformance) set(bucket string) {
+	b := c.bkt[bucket]
+	if len(b.used) == 0 && len(b.free) == 0 {
+		c.emit("NoSpace", func(w MetricsWriter) { w.NoSpace(bucket) })
+		return
+	}
+	b.alive++
+	dur := time.Duration(c.rnd.IntN(1000)) * time.Microsecond
+	c.emit("Set", func(w MetricsWriter) { w.Set(bucket, dur) })
+}
+
+func (c *conformance) del(bucket string) {
+	// Deleted entry stays in the cache till eviction.
+	b := c.bkt[bucket]
+	if b.alive == 0 {
+		return
+	}
+	b.alive--
+	b.deleted++
+	c.emit("Del", func(w MetricsWriter) { w.Del(bucket) })
+}
+
+func (c *conformance) evict(bucket string, alive bool) {
+	b := c.bkt[bucket]
+	if alive {
+		if b.alive == 0 {
+			return
+		}
+		b.alive--
+	} else {
+		if b.deleted == 0 {
+			return
+		}
+		b.deleted--
+	}
+	c.emit("Evict", func(w MetricsWriter) { w.Evict(bucket, alive) })
+}
+
+func (c *conformance) read(bucket string) {
+	switch c.rnd.IntN(8) {
+	case 0:
+		c.emit("Miss", func(w MetricsWriter) { w.Miss(bucket) })
+	case 1:
+		dur := time.Duration(c.rnd.IntN(1000)) * time.Microsecond
+		c.emit("Hit", func(w MetricsWriter) { w.Hit(bucket, dur) })
+	case 2:
+		c.emit("Expire", func(w MetricsWriter) { w.Expire(bucket) })
+	case 3:
+		c.emit("Corrupt", func(w MetricsWriter) { w.Corrupt(bucket) })
+	case 4:
+		c.emit("Collision", func(w MetricsWriter) { w.Collision(bucket) })
+	case 5:
+		c.emit("NoSpace", func(w MetricsWriter) { w.NoSpace(bucket) })
+	case 6:
+		c.emit("Dump", func(w MetricsWriter) { w.Dump(bucket) })
+	default:
+		c.emit("Load", func(w MetricsWriter) { w.Load(bucket) })
+	}
+}
+
+// Pass event to shadow recorder and to the writer.
+func (c *conformance) emit(event string, fn func(w MetricsWriter)) {
+	if c.err != nil {
+		return
+	}
+	c.event = event
+	c.n++
+	fn(c.rec)
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("event %s: writer panic: %v", event, r)
+		}
+	}()
+	fn(c.w)
+}
+
+func (c *conformance) check() {
+	if c.err != nil {
+		return
+	}
+	c.checkValues("recorder", c.rec.Gauge)
+	if c.snapshot != nil {
+		c.snapshot()
+	}
+	if c.conformanceTarget.gauge != nil {
+		c.checkValues("writer", c.conformanceTarget.gauge)
+	}
+	if c.events != nil && c.err == nil {
+		if n := c.events(); n != c.n {
+			c.err = fmt.Errorf("after %s: writer: %d records written, expected %d", c.event, n, c.n)
+		}
+	}
+}
+
+func (c *conformance) expect(src string, gauge func(family string, labels ...string) int64, family string,
+	value int64, labels ...string) {
+	if c.err != nil {
+		return
+	}
+	switch v := gauge(family, labels...); {
+	case v < 0:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v is negative: %d", c.event, src, family, labels, v)
+	case v != value:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v drifted: %d, expected %d", c.event, src, family, labels, v, value)
+	}
+}
+
+func (c *conformance) checkValues(src string, gauge func(family string, labels ...string) int64) {
+	for _, bucket := range conformanceBuckets {
+		b := c.bkt[bucket]
+		var used, free int64
+		for _, size := range b.used {
+			used += int64(size)
+		}
+		for _, size := range b.free {
+			free += int64(size)
+		}
+		c.expect(src, gauge, "cbytecache_size", used+free, "bucket", bucket, "type", cacheTotal)
+		c.expect(src, gauge, "cbytecache_size", used, "bucket", bucket, "type", cacheUsed)
+		c.expect(src, gauge, "cbytecache_size", free, "bucket", bucket, "type", cacheFree)
+		c.expect(src, gauge, "cbytecache_arena", int64(len(b.used)+len(b.free)), "bucket", bucket, "type", arenaTotal)
+		c.expect(src, gauge, "cbytecache_arena", int64(len(b.used)), "bucket", bucket, "type", arenaUsed)
+		c.expect(src, gauge, "cbytecache_arena", int64(len(b.free)), "bucket", bucket, "type", arenaFree)
+		c.expect(src, gauge, "cbytecache_size", b.alive+b.deleted, "bucket", bucket, "type", cacheEntryTotal)
+		c.expect(src, gauge, "cbytecache_size", b.deleted, "bucket", bucket, "type", cacheEntryDelete)
+		if c.err != nil {
+			return
+		}
+		for _, family := range []string{"cbytecache_size", "cbytecache_arena"} {
+			total := gauge(family, "bucket", bucket, "type", cacheTotal)
+			used, free := gauge(family, "bucket", bucket, "type", cacheUsed), gauge(family, "bucket", bucket, "type", cacheFree)
+			if total != used+free {
+				c.err = fmt.Errorf("after %s: %s: %s of bucket %s: total %d != used %d + free %d",
+					c.event, src, family, bucket, total, used, free)
+				return
+			}
+		}
+		total := gauge("cbytecache_size", "bucket", bucket, "type", cacheEntryTotal)
+		if deleted := gauge("cbytecache_size", "bucket", bucket, "type", cacheEntryDelete); deleted > total {
+			c.err = fmt.Errorf("after %s: %s: bucket %s: deleted entries %d exceed total %d",
+				c.event, src, bucket, deleted, total)
+			return
+		}
+	}
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m PrometheusMetrics) gauge(family string, labels ...string) int64 {
+	var vec *prometheus.GaugeVec
+	switch family {
+	case "cbytecache_size":
+		vec = m.vec.size
+	case "cbytecache_arena":
+		vec = m.vec.arena
+	default:
+		return 0
+	}
+	lv := []string{m.key}
+	for i := 1; i < len(labels); i += 2 {
+		lv = append(lv, labels[i])
+	}
+	return promGaugeValue(vec.WithLabelValues(lv...))
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m *CollectorMetrics) gauge(family string, labels ...string) int64 {
+	if len(labels) < 4 {
+		return 0
+	}
+	h := m.bucket.get(labels[1])
+	var c *promreg.Counter
+	switch family {
+	case "cbytecache_size":
+		switch labels[3] {
+		case cacheTotal:
+			c = &h.sizeTotal
+		case cacheUsed:
+			c = &h.sizeUsed
+		case cacheFree:
+			c = &h.sizeFree
+		case cacheEntryTotal:
+			c = &h.entryTotal
+		case cacheEntryDelete:
+			c = &h.entryDelete
+		}
+	case "cbytecache_arena":
+		switch labels[3] {
+		case arenaTotal:
+			c = &h.arenaTotal
+		case arenaUsed:
+			c = &h.arenaUsed
+		case arenaFree:
+			c = &h.arenaFree
+		}
+	}
+	if c == nil {
+		return 0
+	}
+	return c.Load()
+}
+
+func promGaugeValue(g prometheus.Gauge) int64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		return 0
+	}
+	return int64(math.Round(m.GetGauge().GetValue()))
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m aggrMetrics) gauge(family string, labels ...string) int64 {
+	if x := m.s.find(family, append([]string{"cache", m.key}, labels...)...); x != nil {
+		return x.load()
+	}
+	return 0
+}
+
+// Counts lines written by standard logger.
+type conformanceLines struct {
+	n int64
+}
+
+func (w *conformanceLines) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.n, int64(bytes.Count(p, []byte{'\n'})))
+	return len(p), nil
+}
+
+func (w *conformanceLines) count() int64 {
+	return atomic.LoadInt64(&w.n)
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m StatsDMetrics) gauge(family string, labels ...string) int64 {
+	return m.c.Gauge(family, append([]string{"cache", m.key}, labels...)...)
+}
+
+// Values of OTel writer collected by manual reader. Labels kv appends to labels of every series.
+type otelValues struct {
+	r  *sdkmetric.ManualReader
+	kv []string
+	rm metricdata.ResourceMetrics
+}
+
+func (g *otelValues) snapshot() {
+	g.rm = metricdata.ResourceMetrics{}
+	_ = g.r.Collect(context.Background(), &g.rm)
+}
+
+func (g *otelValues) value(family string, labels ...string) int64 {
+	data, _ := otelMetric(g.rm, family).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, append(g.kv[:len(g.kv):len(g.kv)], labels...)...) {
+			return p.Value
+		}
+	}
+	return 0
+}
+
+// Values of promtext writer parsed from text format rendered by registry. Labels kv prepends to labels of every
+// series.
+type promtextValues struct {
+	reg    *promtext.Registry
+	kv     []string
+	series map[string]int64
+}
+
+func (g *promtextValues) snapshot() {
+	var buf bytes.Buffer
+	_ = g.reg.WriteText(&buf)
+	g.series = make(map[string]int64)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if i := strings.LastIndexByte(line, ' '); i > 0 && !strings.HasPrefix(line, "#") {
+			f, _ := strconv.ParseFloat(line[i+1:], 64)
+			g.series[line[:i]] = int64(f)
+		}
+	}
+}
+
+func (g *promtextValues) value(family string, labels ...string) int64 {
+	// Text format sorts labels by name.
+	pairs := make([][2]string, 0, len(g.kv)/2+len(labels)/2)
+	for _, kv := range [][]string{g.kv, labels} {
+		for i := 0; i+1 < len(kv); i += 2 {
+			pairs = append(pairs, [2]string{kv[i], kv[i+1]})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	key := family
+	for i := range pairs {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		key += sep + pairs[i][0] + `="` + pairs[i][1] + `"`
+	}
+	if len(pairs) > 0 {
+		key += "}"
+	}
+	return g.series[key]
+}
@@ -0,0 +1,93 @@
+package cbytecache
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics("expvar_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Alloc("0", 1024)
+	m.Fill("0", 512)
+	m.Set("0", 1500*time.Millisecond)
+	m.Hit("0", 500*time.Millisecond)
+	m.Miss("1")
+	m.Evict("0", true)
+
+	var root map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("cbytecache").String()), &root); err != nil {
+		t.Fatal(err)
+	}
+	got := root["expvar_test"]
+	const expected = `{
+		"cbytecache_arena": {
+			"0": {
+				"free": 0,
+				"total": 1,
+				"used": 1
+			}
+		},
+		"cbytecache_arena_io": {
+			"0": {
+				"alloc": 1,
+				"fill": 1
+			}
+		},
+		"cbytecache_io": {
+			"0": {
+				"evict": 1,
+				"hit": 1,
+				"set": 1
+			},
+			"1": {
+				"miss": 1
+			}
+		},
+		"cbytecache_io_speed": {
+			"0": {
+				"read": {
+					"count": 1,
+					"max": 0.5,
+					"sum": 0.5
+				},
+				"write": {
+					"count": 1,
+					"max": 1.5,
+					"sum": 1.5
+				}
+			}
+		},
+		"cbytecache_size": {
+			"0": {
+				"entry_total": 0,
+				"free": 512,
+				"total": 1024,
+				"used": 512
+			}
+		}
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "cbytecache_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name, "test"); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
@@ -0,0 +1,68 @@
+package cbytecache
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphiteMetrics(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	recv := make(chan string, 1024)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			recv <- line
+		}
+	}()
+
+	m := NewGraphiteMetrics("users", ln.Addr().String(), WithGraphitePrefix("app"), WithGraphiteInterval(time.Hour))
+	defer func() { _ = m.Close() }()
+	m.Hit("1.5 GB", time.Microsecond)
+	m.Hit("1.5 GB", time.Microsecond)
+	m.Miss("1.5 GB")
+	m.Alloc("1.5 GB", 1024)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]string{
+		"app.cbytecache.users.1_5_GB.hit":         "2",
+		"app.cbytecache.users.1_5_GB.miss":        "1",
+		"app.cbytecache.users.1_5_GB.arena.alloc": "1",
+		"app.cbytecache.users.1_5_GB.size.total":  "1024",
+	}
+	timeout := time.After(5 * time.Second)
+	for len(expect) > 0 {
+		select {
+		case line := <-recv:
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				t.Fatalf("malformed line %q", line)
+			}
+			if value, ok := expect[fields[0]]; ok {
+				if fields[1] != value {
+					t.Errorf("%s: got %s, expected %s", fields[0], fields[1], value)
+				}
+				delete(expect, fields[0])
+			}
+		case <-timeout:
+			t.Fatalf("paths not received: %v", expect)
+		}
+	}
+}
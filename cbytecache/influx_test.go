@@ -0,0 +1,48 @@
+package cbytecache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewInfluxMetrics("test\nq", &buf, WithInfluxInterval(time.Hour), WithInfluxTags(map[string]string{"host": "h1"}))
+	defer func() { _ = m.Close() }()
+
+	m.Set("b0", time.Second)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`cbytecache_size,bucket=b0,cache=test\ q,host=h1,type=entry_total value=1i `,
+		`cbytecache_io,bucket=b0,cache=test\ q,host=h1,op=set value=1i `,
+		`cbytecache_io_speed,bucket=b0,cache=test\ q,host=h1,op=write count=1i,sum=1,max=1 `,
+	} {
+		if !strings.Contains(out, "\n"+line) && !strings.HasPrefix(out, line) {
+			t.Errorf("line %q not found in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestInfluxEscape(t *testing.T) {
+	for _, tc := range []struct {
+		s, special, expect string
+	}{
+		{"size", ", ", "size"},
+		{"a,b c", ", ", `a\,b\ c`},
+		{"a=b", ", ", "a=b"},
+		{"a=b", ",= ", `a\=b`},
+		{`a\b`, ", ", `a\\b`},
+		// Line breaks escape as spaces.
+		{"queue\nsize\r", ", ", `queue\ size\ `},
+	} {
+		var buf bytes.Buffer
+		if influxEscape(&buf, tc.s, tc.special); buf.String() != tc.expect {
+			t.Errorf("%q: got %q, expected %q", tc.s, buf.String(), tc.expect)
+		}
+	}
+}
@@ -3,78 +3,143 @@ package cbytecache
 import (
 	"log"
 	"time"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
 )
 
 // LogMetrics is Log implementation of cbytecache.MetricsWriter.
 //
-// Don't use in production. Only for debug purposes.
+// Don't use in production without limits (see NewLogMetricsWithLimits). Only for debug purposes.
+//
+// Deprecated: use SlogMetrics instead.
 type LogMetrics struct {
 	key string
+	lim *loglimit.Limiter
 }
 
 func NewLogMetrics(key string) *LogMetrics {
-	m := &LogMetrics{key}
+	m := &LogMetrics{key: key}
+	return m
+}
+
+// NewLogMetricsWithLimits makes new writer with sampling and/or rate limiting of events.
+func NewLogMetricsWithLimits(key string, opts ...LogLimitOption) *LogMetrics {
+	m := &LogMetrics{key: key}
+	m.lim = newLogLimiter(opts, func(event string, n uint64) {
+		log.Printf("cbytecache %s: suppressed %d events of type %s\n", key, n, event)
+	})
 	return m
 }
 
 func (m LogMetrics) Alloc(bucket string, size uint32) {
+	if !m.lim.Allow("Alloc") {
+		return
+	}
 	log.Printf("cbytecache %s: alloc new arena with size %d in bucket %s\n", m.key, size, bucket)
 }
 
 func (m LogMetrics) Fill(bucket string, size uint32) {
+	if !m.lim.Allow("Fill") {
+		return
+	}
 	log.Printf("cbytecache %s: fill arena with size %d bytes of bucket %s\n", m.key, size, bucket)
 }
 
 func (m LogMetrics) Reset(bucket string, size uint32) {
+	if !m.lim.Allow("Reset") {
+		return
+	}
 	log.Printf("cbytecache %s: reset arena with size %d of bucket %s\n", m.key, size, bucket)
 }
 
 func (m LogMetrics) Release(bucket string, size uint32) {
+	if !m.lim.Allow("Release") {
+		return
+	}
 	log.Printf("cbytecache %s: release arena with size %d bytes of bucket %s\n", m.key, size, bucket)
 }
 
 func (m LogMetrics) Set(bucket string, dur time.Duration) {
+	if !m.lim.Allow("Set") {
+		return
+	}
 	log.Printf("cbytecache %s: set new entry to bucket %s took %s\n", m.key, bucket, dur)
 }
 
 func (m LogMetrics) Del(bucket string) {
+	if !m.lim.Allow("Del") {
+		return
+	}
 	log.Printf("cbytecache %s: delete entry from bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Evict(bucket string, _ bool) {
+	if !m.lim.Allow("Evict") {
+		return
+	}
 	log.Printf("cbytecache %s: evict entry from bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Miss(bucket string) {
+	if !m.lim.Allow("Miss") {
+		return
+	}
 	log.Printf("cbytecache %s: cache miss in bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Hit(bucket string, dur time.Duration) {
+	if !m.lim.Allow("Hit") {
+		return
+	}
 	log.Printf("cbytecache %s: cache hit in bucket %s took %s\n", m.key, bucket, dur)
 }
 
 func (m LogMetrics) Expire(bucket string) {
+	if !m.lim.Allow("Expire") {
+		return
+	}
 	log.Printf("cbytecache %s: hit expired entry in bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Corrupt(bucket string) {
+	if !m.lim.Allow("Corrupt") {
+		return
+	}
 	log.Printf("cbytecache %s: hit corrupted entry in bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Collision(bucket string) {
+	if !m.lim.Allow("Collision") {
+		return
+	}
 	log.Printf("cbytecache %s: keys collision in bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) NoSpace(bucket string) {
+	if !m.lim.Allow("NoSpace") {
+		return
+	}
 	log.Printf("cbytecache %s: no space in bucket %s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Dump(bucket string) {
+	if !m.lim.Allow("Dump") {
+		return
+	}
 	log.Printf("cbytecache %s: dump entry of bucket #%s\n", m.key, bucket)
 }
 
 func (m LogMetrics) Load(bucket string) {
+	if !m.lim.Allow("Load") {
+		return
+	}
 	log.Printf("cbytecache %s: load dumped entry to bucket #%s\n", m.key, bucket)
 }
 
-var _ = NewLogMetrics
+var _, _ = NewLogMetrics, NewLogMetricsWithLimits
+
+// Close stops reporting of suppressed events.
+func (m LogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
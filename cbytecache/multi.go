@@ -0,0 +1,147 @@
+package cbytecache
+
+import (
+	"io"
+	"time"
+)
+
+// MultiMetrics is fan-out implementation of cbytecache.MetricsWriter.
+//
+// Each event forwards to all child writers in order they were specified. Allows to use several writers at once, eg
+// Prometheus writer in production together with log writer for debug purposes.
+type MultiMetrics struct {
+	w    []MetricsWriter
+	conf multiConfig
+}
+
+// MultiOption describes MultiMetrics option.
+type MultiOption func(*multiConfig)
+
+type multiConfig struct {
+	isolate bool
+	onPanic func(w MetricsWriter, r any)
+}
+
+// WithMultiRecover enables panic isolation: panic in child writer recovers and doesn't affect the rest of writers.
+// Optional fn receives failed writer and recovered value.
+func WithMultiRecover(fn func(w MetricsWriter, r any)) MultiOption {
+	return func(c *multiConfig) {
+		c.isolate = true
+		c.onPanic = fn
+	}
+}
+
+var _, _ = NewMultiMetrics, NewMultiMetricsWithOptions
+
+// NewMultiMetrics makes new writer forwards events to given writers.
+func NewMultiMetrics(writers ...MetricsWriter) *MultiMetrics {
+	return NewMultiMetricsWithOptions(writers)
+}
+
+// NewMultiMetricsWithOptions makes new writer forwards events to given writers and applies given options to it.
+//
+// Nil writers skips.
+func NewMultiMetricsWithOptions(writers []MetricsWriter, opts ...MultiOption) *MultiMetrics {
+	m := &MultiMetrics{w: make([]MetricsWriter, 0, len(writers))}
+	for _, w := range writers {
+		if w != nil {
+			m.w = append(m.w, w)
+		}
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	return m
+}
+
+func (m MultiMetrics) Alloc(bucket string, size uint32) {
+	m.each(func(w MetricsWriter) { w.Alloc(bucket, size) })
+}
+
+func (m MultiMetrics) Fill(bucket string, size uint32) {
+	m.each(func(w MetricsWriter) { w.Fill(bucket, size) })
+}
+
+func (m MultiMetrics) Reset(bucket string, size uint32) {
+	m.each(func(w MetricsWriter) { w.Reset(bucket, size) })
+}
+
+func (m MultiMetrics) Release(bucket string, size uint32) {
+	m.each(func(w MetricsWriter) { w.Release(bucket, size) })
+}
+
+func (m MultiMetrics) Set(bucket string, dur time.Duration) {
+	m.each(func(w MetricsWriter) { w.Set(bucket, dur) })
+}
+
+func (m MultiMetrics) Del(bucket string) {
+	m.each(func(w MetricsWriter) { w.Del(bucket) })
+}
+
+func (m MultiMetrics) Evict(bucket string, alive bool) {
+	m.each(func(w MetricsWriter) { w.Evict(bucket, alive) })
+}
+
+func (m MultiMetrics) Miss(bucket string) {
+	m.each(func(w MetricsWriter) { w.Miss(bucket) })
+}
+
+func (m MultiMetrics) Hit(bucket string, dur time.Duration) {
+	m.each(func(w MetricsWriter) { w.Hit(bucket, dur) })
+}
+
+func (m MultiMetrics) Expire(bucket string) {
+	m.each(func(w MetricsWriter) { w.Expire(bucket) })
+}
+
+func (m MultiMetrics) Corrupt(bucket string) {
+	m.each(func(w MetricsWriter) { w.Corrupt(bucket) })
+}
+
+func (m MultiMetrics) Collision(bucket string) {
+	m.each(func(w MetricsWriter) { w.Collision(bucket) })
+}
+
+func (m MultiMetrics) NoSpace(bucket string) {
+	m.each(func(w MetricsWriter) { w.NoSpace(bucket) })
+}
+
+func (m MultiMetrics) Dump(bucket string) {
+	m.each(func(w MetricsWriter) { w.Dump(bucket) })
+}
+
+func (m MultiMetrics) Load(bucket string) {
+	m.each(func(w MetricsWriter) { w.Load(bucket) })
+}
+
+// Close closes all child writers implement io.Closer and returns the first occurred error.
+func (m MultiMetrics) Close() (err error) {
+	m.each(func(w MetricsWriter) {
+		if c, ok := w.(io.Closer); ok {
+			if err1 := c.Close(); err == nil {
+				err = err1
+			}
+		}
+	})
+	return
+}
+
+func (m MultiMetrics) each(fn func(w MetricsWriter)) {
+	for i := 0; i < len(m.w); i++ {
+		if m.conf.isolate {
+			m.safe(m.w[i], fn)
+		} else {
+			fn(m.w[i])
+		}
+	}
+}
+
+// Call fn and recover panic.
+func (m MultiMetrics) safe(w MetricsWriter, fn func(w MetricsWriter)) {
+	defer func() {
+		if r := recover(); r != nil && m.conf.onPanic != nil {
+			m.conf.onPanic(w, r)
+		}
+	}()
+	fn(w)
+}
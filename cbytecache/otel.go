@@ -0,0 +1,249 @@
+package cbytecache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of cbytecache.MetricsWriter.
+type OTelMetrics struct {
+	key  string
+	ins  *otelInstruments
+	done *uint32
+}
+
+type otelInstruments struct {
+	size, arena         metric.Int64UpDownCounter
+	io, arenaIO, dumpIO metric.Int64Counter
+	speed               metric.Float64Histogram
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/cbytecache"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(key string, opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		key:  key,
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+	}
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.size = otelCheck(meter.Int64UpDownCounter("cbytecache_size",
+		metric.WithDescription("Total, used and free cache (bucket) size in bytes.")))
+	ins.io = otelCheck(meter.Int64Counter("cbytecache_io",
+		metric.WithDescription("Count cache IO operations calls.")))
+
+	ins.arena = otelCheck(meter.Int64UpDownCounter("cbytecache_arena",
+		metric.WithDescription("Arenas count in cache (bucket).")))
+	ins.arenaIO = otelCheck(meter.Int64Counter("cbytecache_arena_io",
+		metric.WithDescription("Count arena IO operations calls.")))
+
+	ins.dumpIO = otelCheck(meter.Int64Counter("cbytecache_dump",
+		metric.WithDescription("Count dump IO operations calls.")))
+
+	ins.speed = otelCheck(meter.Float64Histogram("cbytecache_io_speed",
+		metric.WithDescription("Cache IO operations speed."),
+		metric.WithUnit("s")))
+
+	return ins
+}
+
+func (m OTelMetrics) Alloc(bucket string, size uint32) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, int64(size), m.attr(bucket, "type", cacheTotal))
+	m.ins.size.Add(ctx, int64(size), m.attr(bucket, "type", cacheFree))
+
+	m.ins.arena.Add(ctx, 1, m.attr(bucket, "type", arenaTotal))
+	m.ins.arena.Add(ctx, 1, m.attr(bucket, "type", arenaFree))
+	m.ins.arenaIO.Add(ctx, 1, m.attr(bucket, "op", arenaIOAlloc))
+}
+
+func (m OTelMetrics) Fill(bucket string, size uint32) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, int64(size), m.attr(bucket, "type", cacheUsed))
+	m.ins.size.Add(ctx, -int64(size), m.attr(bucket, "type", cacheFree))
+
+	m.ins.arena.Add(ctx, 1, m.attr(bucket, "type", arenaUsed))
+	m.ins.arena.Add(ctx, -1, m.attr(bucket, "type", arenaFree))
+	m.ins.arenaIO.Add(ctx, 1, m.attr(bucket, "op", arenaIOFill))
+}
+
+func (m OTelMetrics) Reset(bucket string, size uint32) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -int64(size), m.attr(bucket, "type", cacheUsed))
+	m.ins.size.Add(ctx, int64(size), m.attr(bucket, "type", cacheFree))
+
+	m.ins.arena.Add(ctx, -1, m.attr(bucket, "type", arenaUsed))
+	m.ins.arena.Add(ctx, 1, m.attr(bucket, "type", arenaFree))
+	m.ins.arenaIO.Add(ctx, 1, m.attr(bucket, "op", arenaIOReset))
+}
+
+func (m OTelMetrics) Release(bucket string, size uint32) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -int64(size), m.attr(bucket, "type", cacheTotal))
+	m.ins.size.Add(ctx, -int64(size), m.attr(bucket, "type", cacheFree))
+
+	m.ins.arena.Add(ctx, -1, m.attr(bucket, "type", arenaTotal))
+	m.ins.arena.Add(ctx, -1, m.attr(bucket, "type", arenaFree))
+	m.ins.arenaIO.Add(ctx, 1, m.attr(bucket, "op", arenaIORelease))
+}
+
+func (m OTelMetrics) Set(bucket string, dur time.Duration) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, 1, m.attr(bucket, "type", cacheEntryTotal))
+	m.ins.io.Add(ctx, 1, m.attr(bucket, "op", cacheIOSet))
+	m.ins.speed.Record(ctx, dur.Seconds(), m.attr(bucket, "op", speedWrite))
+}
+
+func (m OTelMetrics) Del(bucket string) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, 1, m.attr(bucket, "type", cacheEntryDelete))
+	m.ins.io.Add(ctx, 1, m.attr(bucket, "op", cacheIODel))
+}
+
+func (m OTelMetrics) Evict(bucket string, alive bool) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.size.Add(ctx, -1, m.attr(bucket, "type", cacheEntryTotal))
+	if !alive {
+		m.ins.size.Add(ctx, -1, m.attr(bucket, "type", cacheEntryDelete))
+	}
+	m.ins.io.Add(ctx, 1, m.attr(bucket, "op", cacheIOEvict))
+}
+
+func (m OTelMetrics) Miss(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.io.Add(context.Background(), 1, m.attr(bucket, "op", cacheIOMiss))
+}
+
+func (m OTelMetrics) Hit(bucket string, dur time.Duration) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.io.Add(ctx, 1, m.attr(bucket, "op", cacheIOHit))
+	m.ins.speed.Record(ctx, dur.Seconds(), m.attr(bucket, "op", speedRead))
+}
+
+func (m OTelMetrics) Expire(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.io.Add(context.Background(), 1, m.attr(bucket, "op", cacheIOExpire))
+}
+
+func (m OTelMetrics) Corrupt(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.io.Add(context.Background(), 1, m.attr(bucket, "op", cacheIOCorrupt))
+}
+
+func (m OTelMetrics) Collision(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.io.Add(context.Background(), 1, m.attr(bucket, "op", cacheIOCollision))
+}
+
+func (m OTelMetrics) NoSpace(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.io.Add(context.Background(), 1, m.attr(bucket, "op", cacheIONoSpace))
+}
+
+func (m OTelMetrics) Dump(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.dumpIO.Add(context.Background(), 1, m.attr(bucket, "op", dumpIODump))
+}
+
+func (m OTelMetrics) Load(bucket string) {
+	if m.closed() {
+		return
+	}
+	m.ins.dumpIO.Add(context.Background(), 1, m.attr(bucket, "op", dumpIOLoad))
+}
+
+func (m OTelMetrics) attr(bucket, k, v string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("cache", m.key), attribute.String("bucket", bucket), attribute.String(k, v))
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports.
+func (m OTelMetrics) Close() error {
+	atomic.StoreUint32(m.done, 1)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
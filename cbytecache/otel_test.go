@@ -0,0 +1,115 @@
+package cbytecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))))
+
+	m.Alloc("b0", 1024)
+	m.Alloc("b0", 1024)
+	m.Fill("b0", 1024)
+	m.Set("b0", 20*time.Microsecond)
+	m.Set("b0", 30*time.Microsecond)
+	m.Hit("b0", 10*time.Microsecond)
+	m.Miss("b0")
+	m.Del("b0")
+	m.Evict("b0", false)
+	m.Reset("b0", 1024)
+	m.Release("b0", 1024)
+	m.Dump("b0")
+
+	rm := otelCollect(t, r)
+	otelExpectSum(t, rm, "cbytecache_size", 1024, "cache", "test", "bucket", "b0", "type", "total")
+	otelExpectSum(t, rm, "cbytecache_size", 0, "cache", "test", "bucket", "b0", "type", "used")
+	otelExpectSum(t, rm, "cbytecache_size", 1024, "cache", "test", "bucket", "b0", "type", "free")
+	otelExpectSum(t, rm, "cbytecache_size", 1, "cache", "test", "bucket", "b0", "type", "entry_total")
+	otelExpectSum(t, rm, "cbytecache_size", 0, "cache", "test", "bucket", "b0", "type", "entry_delete")
+	otelExpectSum(t, rm, "cbytecache_arena", 1, "cache", "test", "bucket", "b0", "type", "total")
+	otelExpectSum(t, rm, "cbytecache_arena", 0, "cache", "test", "bucket", "b0", "type", "used")
+	otelExpectSum(t, rm, "cbytecache_arena", 1, "cache", "test", "bucket", "b0", "type", "free")
+	otelExpectSum(t, rm, "cbytecache_arena_io", 2, "cache", "test", "bucket", "b0", "op", "alloc")
+	otelExpectSum(t, rm, "cbytecache_io", 2, "cache", "test", "bucket", "b0", "op", "set")
+	otelExpectSum(t, rm, "cbytecache_io", 1, "cache", "test", "bucket", "b0", "op", "hit")
+	otelExpectSum(t, rm, "cbytecache_io", 1, "cache", "test", "bucket", "b0", "op", "miss")
+	otelExpectSum(t, rm, "cbytecache_dump", 1, "cache", "test", "bucket", "b0", "op", "dump")
+	otelExpectHist(t, rm, "cbytecache_io_speed", 2, 50e-6, "cache", "test", "bucket", "b0", "op", "write")
+	otelExpectHist(t, rm, "cbytecache_io_speed", 1, 10e-6, "cache", "test", "bucket", "b0", "op", "read")
+
+	t.Run("close", func(t *testing.T) {
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		m.Miss("b0")
+		otelExpectSum(t, otelCollect(t, r), "cbytecache_io", 1, "cache", "test", "bucket", "b0", "op", "miss")
+	})
+}
+
+func otelCollect(t *testing.T, r *sdkmetric.ManualReader) (rm metricdata.ResourceMetrics) {
+	t.Helper()
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// Find metric by name.
+func otelMetric(rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for _, mt := range sm.Metrics {
+			if mt.Name == name {
+				return mt
+			}
+		}
+	}
+	return metricdata.Metrics{}
+}
+
+// Check if attributes set consists of exactly given key-value pairs.
+func otelAttrsMatch(set attribute.Set, kv ...string) bool {
+	if set.Len() != len(kv)/2 {
+		return false
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if v, ok := set.Value(attribute.Key(kv[i])); !ok || v.AsString() != kv[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func otelExpectSum(t *testing.T, rm metricdata.ResourceMetrics, name string, value int64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Value != value {
+				t.Errorf("%s%v: got %d, expected %d", name, kv, p.Value, value)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
+
+func otelExpectHist(t *testing.T, rm metricdata.ResourceMetrics, name string, count uint64, sum float64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Histogram[float64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Count != count || p.Sum != sum {
+				t.Errorf("%s%v: got count %d sum %g, expected count %d sum %g", name, kv, p.Count, p.Sum, count, sum)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
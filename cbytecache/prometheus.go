@@ -1,7 +1,10 @@
 package cbytecache
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -42,150 +45,346 @@ const (
 // PrometheusMetrics is a Prometheus implementation of cbytecache.MetricsWriter.
 type PrometheusMetrics struct {
 	key  string
+	hist string
 	prec time.Duration
+	sec  bool
+	exm  func() prometheus.Labels
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+	lim  promLimit
 }
 
-var (
-	promSize, promArena             *prometheus.GaugeVec
-	promIO, promArenaIO, promDumpIO *prometheus.CounterVec
-	promSpeed                       *prometheus.HistogramVec
+// Collection of vectors registered in the same registry.
+type promVecs struct {
+	size, arena         *prometheus.GaugeVec
+	io, arenaIO, dumpIO *prometheus.CounterVec
+	speed               *promHistVec
 
-	_ = NewPrometheusMetrics
-)
+	labelOverflow *prometheus.CounterVec
 
-func init() {
-	promSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "cbytecache_size",
-		Help: "Total, used and free cache (bucket) size in bytes.",
-	}, []string{"cache", "bucket", "type"})
-	promIO = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbytecache_io",
-		Help: "Count cache IO operations calls.",
-	}, []string{"cache", "bucket", "op"})
-
-	promArena = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "cbytecache_arena",
-		Help: "Arenas count in cache (bucket).",
-	}, []string{"cache", "bucket", "type"})
-	promArenaIO = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbytecache_arena_io",
-		Help: "Count arena IO operations calls.",
-	}, []string{"cache", "bucket", "op"})
-
-	promDumpIO = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "cbytecache_dump",
-		Help: "Count dump IO operations calls.",
-	}, []string{"cache", "bucket", "op"})
-
-	speedBuckets := append(prometheus.DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000, 5000}...)
-	promSpeed = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "cbytecache_io_speed",
-		Help:    "Cache IO operations speed.",
-		Buckets: speedBuckets,
-	}, []string{"cache", "bucket", "op"})
-
-	prometheus.MustRegister(promSize, promIO, promDumpIO, promArena, promArenaIO, promSpeed)
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
 }
 
+var (
+	// Default buckets of timing metrics, in units of precision.
+	promBuckets = append(prometheus.DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000, 5000}...)
+
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
+
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
+)
+
 func NewPrometheusMetrics(key string) *PrometheusMetrics {
-	return NewPrometheusMetricsWP(key, time.Nanosecond)
+	return NewPrometheusMetricsWithOptions(key)
 }
 
+// NewPrometheusMetricsWP makes new writer observes durations in units of given precision.
+//
+// Kept for compatibility, use NewPrometheusMetricsWithOptions with WithPrecision or WithSeconds instead.
 func NewPrometheusMetricsWP(key string, precision time.Duration) *PrometheusMetrics {
-	if precision == 0 {
-		precision = time.Nanosecond
+	return NewPrometheusMetricsWithOptions(key, WithPrecision(precision))
+}
+
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+//
+// Timing options (precision, seconds, buckets and native histograms settings) belong to the cache key: writers of the
+// key observe durations with options of its first writer, options of the rest are ignored.
+func NewPrometheusMetricsWithOptions(key string, opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
 	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	vec := getPromVecs(&c)
+	t := vec.speed.adopt(key, &c)
 	m := &PrometheusMetrics{
 		key:  key,
-		prec: precision,
+		hist: t.hist,
+		prec: t.prec,
+		sec:  t.sec,
+		exm:  c.exemplar,
+		vec:  vec,
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+		lim:  promLimit{limit: c.labelLimit, logger: c.labelLogger},
 	}
+	m.hnd.Store(newPromHandles(m.vec, key, m.hist, m.lim))
 	return m
 }
 
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
+}
+
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
+
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.size = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_size",
+		Help:        "Total, used and free cache (bucket) size in bytes.",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "bucket", "type"})).(*prometheus.GaugeVec)
+	v.io = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_io",
+		Help:        "Count cache IO operations calls.",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "bucket", "op"})).(*prometheus.CounterVec)
+
+	v.arena = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_arena",
+		Help:        "Arenas count in cache (bucket).",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "bucket", "type"})).(*prometheus.GaugeVec)
+	v.arenaIO = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_arena_io",
+		Help:        "Count arena IO operations calls.",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "bucket", "op"})).(*prometheus.CounterVec)
+
+	v.dumpIO = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_dump",
+		Help:        "Count dump IO operations calls.",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "bucket", "op"})).(*prometheus.CounterVec)
+
+	v.speed = v.register(newPromHistVec(prometheus.HistogramOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_io_speed",
+		Help:        "Cache IO operations speed.",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "bucket", "op"})).(*promHistVec)
+
+	v.labelOverflow = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "cbytecache_label_overflow",
+		Help:        "How many events with label values over cardinality limit folded into __other__ value.",
+		ConstLabels: c.constLabels,
+	}, []string{"cache", "label"})).(*prometheus.CounterVec)
+
+	return v
+}
+
+// Convert duration to units of observation.
+func (m PrometheusMetrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+// Observe duration with exemplar if provider specified.
+func (m PrometheusMetrics) observe(o prometheus.Observer, dur time.Duration) {
+	v := m.units(dur)
+	if m.exm != nil {
+		if lbl := m.exm(); len(lbl) > 0 && promExemplarRunes(lbl) <= prometheus.ExemplarMaxRunes {
+			if eo, ok := o.(prometheus.ExemplarObserver); ok {
+				eo.ObserveWithExemplar(v, lbl)
+				return
+			}
+		}
+	}
+	o.Observe(v)
+}
+
+// Count runes of exemplar labels.
+func promExemplarRunes(lbl prometheus.Labels) (n int) {
+	for k, v := range lbl {
+		n += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	return
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
+}
+
 func (m PrometheusMetrics) Alloc(bucket string, size uint32) {
-	promSize.WithLabelValues(m.key, bucket, cacheTotal).Add(float64(size))
-	promSize.WithLabelValues(m.key, bucket, cacheFree).Add(float64(size))
+	h := m.handles().bucket.get(bucket)
+	h.sizeTotal.Add(float64(size))
+	h.sizeFree.Add(float64(size))
 
-	promArena.WithLabelValues(m.key, bucket, arenaTotal).Inc()
-	promArena.WithLabelValues(m.key, bucket, arenaFree).Inc()
-	promArenaIO.WithLabelValues(m.key, bucket, arenaIOAlloc).Inc()
+	h.arenaTotal.Inc()
+	h.arenaFree.Inc()
+	h.arenaAlloc.Inc()
 }
 
 func (m PrometheusMetrics) Fill(bucket string, size uint32) {
-	promSize.WithLabelValues(m.key, bucket, cacheUsed).Add(float64(size))
-	promSize.WithLabelValues(m.key, bucket, cacheFree).Add(-float64(size))
+	h := m.handles().bucket.get(bucket)
+	h.sizeUsed.Add(float64(size))
+	h.sizeFree.Sub(float64(size))
 
-	promArena.WithLabelValues(m.key, bucket, arenaUsed).Inc()
-	promArena.WithLabelValues(m.key, bucket, arenaFree).Dec()
-	promArenaIO.WithLabelValues(m.key, bucket, arenaIOFill).Inc()
+	h.arenaUsed.Inc()
+	h.arenaFree.Dec()
+	h.arenaFill.Inc()
 }
 
 func (m PrometheusMetrics) Reset(bucket string, size uint32) {
-	promSize.WithLabelValues(m.key, bucket, cacheUsed).Add(-float64(size))
-	promSize.WithLabelValues(m.key, bucket, cacheFree).Add(float64(size))
+	h := m.handles().bucket.get(bucket)
+	h.sizeUsed.Sub(float64(size))
+	h.sizeFree.Add(float64(size))
 
-	promArena.WithLabelValues(m.key, bucket, arenaUsed).Dec()
-	promArena.WithLabelValues(m.key, bucket, arenaFree).Inc()
-	promArenaIO.WithLabelValues(m.key, bucket, arenaIOReset).Inc()
+	h.arenaUsed.Dec()
+	h.arenaFree.Inc()
+	h.arenaReset.Inc()
 }
 
 func (m PrometheusMetrics) Release(bucket string, size uint32) {
-	promSize.WithLabelValues(m.key, bucket, cacheTotal).Add(-float64(size))
-	promSize.WithLabelValues(m.key, bucket, cacheFree).Add(-float64(size))
+	h := m.handles().bucket.get(bucket)
+	h.sizeTotal.Sub(float64(size))
+	h.sizeFree.Sub(float64(size))
 
-	promArena.WithLabelValues(m.key, bucket, arenaTotal).Dec()
-	promArena.WithLabelValues(m.key, bucket, arenaFree).Dec()
-	promArenaIO.WithLabelValues(m.key, bucket, arenaIORelease).Inc()
+	h.arenaTotal.Dec()
+	h.arenaFree.Dec()
+	h.arenaRelease.Inc()
 }
 
 func (m PrometheusMetrics) Set(bucket string, dur time.Duration) {
-	promSize.WithLabelValues(m.key, bucket, cacheEntryTotal).Inc()
-	promIO.WithLabelValues(m.key, bucket, cacheIOSet).Inc()
-	promSpeed.WithLabelValues(m.key, bucket, speedWrite).Observe(float64(dur.Nanoseconds() / int64(m.prec)))
+	h := m.handles().bucket.get(bucket)
+	h.entryTotal.Inc()
+	h.ioSet.Inc()
+	m.observe(h.speedWrite, dur)
 }
 
 func (m PrometheusMetrics) Del(bucket string) {
-	promSize.WithLabelValues(m.key, bucket, cacheEntryDelete).Inc()
-	promIO.WithLabelValues(m.key, bucket, cacheIODel).Inc()
+	h := m.handles().bucket.get(bucket)
+	h.entryDelete.Inc()
+	h.ioDel.Inc()
 }
 
 func (m PrometheusMetrics) Evict(bucket string, alive bool) {
-	promSize.WithLabelValues(m.key, bucket, cacheEntryTotal).Dec()
+	h := m.handles().bucket.get(bucket)
+	h.entryTotal.Dec()
 	if !alive {
-		promSize.WithLabelValues(m.key, bucket, cacheEntryDelete).Dec()
+		h.entryDelete.Dec()
 	}
-	promIO.WithLabelValues(m.key, bucket, cacheIOEvict).Inc()
+	h.ioEvict.Inc()
 }
 
 func (m PrometheusMetrics) Miss(bucket string) {
-	promIO.WithLabelValues(m.key, bucket, cacheIOMiss).Inc()
+	m.handles().bucket.get(bucket).ioMiss.Inc()
 }
 
 func (m PrometheusMetrics) Hit(bucket string, dur time.Duration) {
-	promIO.WithLabelValues(m.key, bucket, cacheIOHit).Inc()
-	promSpeed.WithLabelValues(m.key, bucket, speedRead).Observe(float64(dur.Nanoseconds() / int64(m.prec)))
+	h := m.handles().bucket.get(bucket)
+	h.ioHit.Inc()
+	m.observe(h.speedRead, dur)
 }
 
 func (m PrometheusMetrics) Expire(bucket string) {
-	promIO.WithLabelValues(m.key, bucket, cacheIOExpire).Inc()
+	m.handles().bucket.get(bucket).ioExpire.Inc()
 }
 
 func (m PrometheusMetrics) Corrupt(bucket string) {
-	promIO.WithLabelValues(m.key, bucket, cacheIOCorrupt).Inc()
+	m.handles().bucket.get(bucket).ioCorrupt.Inc()
 }
 
 func (m PrometheusMetrics) Collision(bucket string) {
-	promIO.WithLabelValues(m.key, bucket, cacheIOCollision).Inc()
+	m.handles().bucket.get(bucket).ioCollision.Inc()
 }
 
 func (m PrometheusMetrics) NoSpace(bucket string) {
-	promIO.WithLabelValues(m.key, bucket, cacheIONoSpace).Inc()
+	m.handles().bucket.get(bucket).ioNoSpace.Inc()
 }
 
 func (m PrometheusMetrics) Dump(bucket string) {
-	promDumpIO.WithLabelValues(m.key, bucket, dumpIODump).Inc()
+	m.handles().bucket.get(bucket).dump.Inc()
 }
 
 func (m PrometheusMetrics) Load(bucket string) {
-	promDumpIO.WithLabelValues(m.key, bucket, dumpIOLoad).Inc()
+	m.handles().bucket.get(bucket).load.Inc()
+}
+
+// Forget removes all series of the cache and all its buckets seen by the writer.
+//
+// Writer may be used further, but series will appear again on the next events. Note, writers of the same cache share
+// series, so forget them after all writers of the cache become unused.
+func (m PrometheusMetrics) Forget() {
+	match := prometheus.Labels{"cache": m.key}
+	m.vec.size.DeletePartialMatch(match)
+	m.vec.arena.DeletePartialMatch(match)
+	m.vec.io.DeletePartialMatch(match)
+	m.vec.arenaIO.DeletePartialMatch(match)
+	m.vec.dumpIO.DeletePartialMatch(match)
+	m.vec.speed.DeletePartialMatch(match)
+	m.vec.labelOverflow.DeletePartialMatch(match)
+	m.hnd.Store(nil)
+}
+
+// Close removes all series of the writer (see Forget) and releases shared collectors. The last closed writer of the
+// registerer unregisters them. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		m.Forget()
+		m.vec.speed.release(m.key)
+		putPromVecs(m.vec)
+	}
+	return nil
 }
@@ -0,0 +1,286 @@
+package cbytecache
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of cbytecache.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot caches. Metrics names and labels are the same as
+// PrometheusMetrics has, but cache key is a constant label of each writer's collector, so both implementations can't
+// be mixed in the same registerer. Options WithNativeHistograms, WithoutClassicBuckets and WithExemplars are ignored.
+type CollectorMetrics struct {
+	key  string
+	reg  prometheus.Registerer
+	prec time.Duration
+	sec  bool
+	desc *collDescs
+	lim  promLimit
+
+	bucket promCache[collBucket]
+
+	labelOverflow promreg.Counter
+}
+
+type collDescs struct {
+	size, io, arena, arenaIO, dumpIO, speed *prometheus.Desc
+	labelOverflow                           *prometheus.Desc
+}
+
+// Counters of cache bucket.
+type collBucket struct {
+	sizeTotal, sizeUsed, sizeFree, entryTotal, entryDelete,
+	arenaTotal, arenaUsed, arenaFree promreg.Counter
+
+	ioSet, ioEvict, ioMiss, ioHit, ioDel, ioExpire, ioCorrupt, ioCollision, ioNoSpace,
+	arenaAlloc, arenaRelease, arenaReset, arenaFill,
+	dump, load promreg.Counter
+
+	speedWrite, speedRead *promreg.Histogram
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same cache and registerer share
+// counters, ie constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(key string, opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	m := &CollectorMetrics{
+		key:  key,
+		reg:  c.reg,
+		prec: c.prec,
+		sec:  c.sec,
+		desc: newCollDescs(&c, key),
+		lim:  promLimit{limit: c.labelLimit, logger: c.labelLogger},
+	}
+	buckets := c.buckets()
+	m.bucket.bind = func(string) *collBucket {
+		return &collBucket{
+			speedWrite: promreg.NewHistogram(buckets),
+			speedRead:  promreg.NewHistogram(buckets),
+		}
+	}
+	if m.lim.limit > 0 {
+		overflow := m.lim.handler(func() { m.labelOverflow.Add(promreg.Shard(), 1) }, key)
+		m.bucket.limit, m.bucket.overflow = m.lim.limit, overflow
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig, key string) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		cl := prometheus.Labels{"cache": key}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, cl)
+	}
+	return &collDescs{
+		size:    desc("cbytecache_size", "Total, used and free cache (bucket) size in bytes.", "bucket", "type"),
+		io:      desc("cbytecache_io", "Count cache IO operations calls.", "bucket", "op"),
+		arena:   desc("cbytecache_arena", "Arenas count in cache (bucket).", "bucket", "type"),
+		arenaIO: desc("cbytecache_arena_io", "Count arena IO operations calls.", "bucket", "op"),
+		dumpIO:  desc("cbytecache_dump", "Count dump IO operations calls.", "bucket", "op"),
+		speed:   desc("cbytecache_io_speed", "Cache IO operations speed.", "bucket", "op"),
+		labelOverflow: desc("cbytecache_label_overflow",
+			"How many events with label values over cardinality limit folded into __other__ value.", "label"),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.size, d.io, d.arena, d.arenaIO, d.dumpIO, d.speed, d.labelOverflow} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	gauge := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c.Load()), lvs...)
+	}
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	m.bucket.each(func(bucket string, h *collBucket) {
+		gauge(d.size, &h.sizeTotal, bucket, cacheTotal)
+		gauge(d.size, &h.sizeUsed, bucket, cacheUsed)
+		gauge(d.size, &h.sizeFree, bucket, cacheFree)
+		gauge(d.size, &h.entryTotal, bucket, cacheEntryTotal)
+		gauge(d.size, &h.entryDelete, bucket, cacheEntryDelete)
+		gauge(d.arena, &h.arenaTotal, bucket, arenaTotal)
+		gauge(d.arena, &h.arenaUsed, bucket, arenaUsed)
+		gauge(d.arena, &h.arenaFree, bucket, arenaFree)
+
+		counter(d.io, &h.ioSet, bucket, cacheIOSet)
+		counter(d.io, &h.ioEvict, bucket, cacheIOEvict)
+		counter(d.io, &h.ioMiss, bucket, cacheIOMiss)
+		counter(d.io, &h.ioHit, bucket, cacheIOHit)
+		counter(d.io, &h.ioDel, bucket, cacheIODel)
+		counter(d.io, &h.ioExpire, bucket, cacheIOExpire)
+		counter(d.io, &h.ioCorrupt, bucket, cacheIOCorrupt)
+		counter(d.io, &h.ioCollision, bucket, cacheIOCollision)
+		counter(d.io, &h.ioNoSpace, bucket, cacheIONoSpace)
+		counter(d.arenaIO, &h.arenaAlloc, bucket, arenaIOAlloc)
+		counter(d.arenaIO, &h.arenaRelease, bucket, arenaIORelease)
+		counter(d.arenaIO, &h.arenaReset, bucket, arenaIOReset)
+		counter(d.arenaIO, &h.arenaFill, bucket, arenaIOFill)
+		counter(d.dumpIO, &h.dump, bucket, dumpIODump)
+		counter(d.dumpIO, &h.load, bucket, dumpIOLoad)
+
+		ch <- collHistogram(d.speed, h.speedWrite, m.units, bucket, speedWrite)
+		ch <- collHistogram(d.speed, h.speedRead, m.units, bucket, speedRead)
+	})
+	if m.lim.limit > 0 {
+		ch <- prometheus.MustNewConstMetric(d.labelOverflow, prometheus.CounterValue, float64(m.labelOverflow.Load()),
+			promLimitLabel)
+	}
+}
+
+// Convert duration to units of observation.
+func (m *CollectorMetrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+func (m *CollectorMetrics) Alloc(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.sizeTotal.Add(sh, int64(size))
+	h.sizeFree.Add(sh, int64(size))
+
+	h.arenaTotal.Add(sh, 1)
+	h.arenaFree.Add(sh, 1)
+	h.arenaAlloc.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Fill(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.sizeUsed.Add(sh, int64(size))
+	h.sizeFree.Add(sh, -int64(size))
+
+	h.arenaUsed.Add(sh, 1)
+	h.arenaFree.Add(sh, -1)
+	h.arenaFill.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Reset(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.sizeUsed.Add(sh, -int64(size))
+	h.sizeFree.Add(sh, int64(size))
+
+	h.arenaUsed.Add(sh, -1)
+	h.arenaFree.Add(sh, 1)
+	h.arenaReset.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Release(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.sizeTotal.Add(sh, -int64(size))
+	h.sizeFree.Add(sh, -int64(size))
+
+	h.arenaTotal.Add(sh, -1)
+	h.arenaFree.Add(sh, -1)
+	h.arenaRelease.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Set(bucket string, dur time.Duration) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.entryTotal.Add(sh, 1)
+	h.ioSet.Add(sh, 1)
+	h.speedWrite.Observe(sh, m.units(dur), dur)
+}
+
+func (m *CollectorMetrics) Del(bucket string) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.entryDelete.Add(sh, 1)
+	h.ioDel.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Evict(bucket string, alive bool) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.entryTotal.Add(sh, -1)
+	if !alive {
+		h.entryDelete.Add(sh, -1)
+	}
+	h.ioEvict.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Miss(bucket string) {
+	m.bucket.get(bucket).ioMiss.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) Hit(bucket string, dur time.Duration) {
+	sh, h := promreg.Shard(), m.bucket.get(bucket)
+	h.ioHit.Add(sh, 1)
+	h.speedRead.Observe(sh, m.units(dur), dur)
+}
+
+func (m *CollectorMetrics) Expire(bucket string) {
+	m.bucket.get(bucket).ioExpire.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) Corrupt(bucket string) {
+	m.bucket.get(bucket).ioCorrupt.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) Collision(bucket string) {
+	m.bucket.get(bucket).ioCollision.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) NoSpace(bucket string) {
+	m.bucket.get(bucket).ioNoSpace.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) Dump(bucket string) {
+	m.bucket.get(bucket).dump.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) Load(bucket string) {
+	m.bucket.get(bucket).load.Add(promreg.Shard(), 1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
+
+// Make constant histogram of sharded one.
+func collHistogram(desc *prometheus.Desc, h *promreg.Histogram, units func(time.Duration) float64,
+	lvs ...string) prometheus.Metric {
+	upper, cnt, sum := h.Load()
+	buckets := make(map[float64]uint64, len(upper))
+	for j := range upper {
+		buckets[upper[j]] = cnt[j]
+	}
+	return prometheus.MustNewConstHistogram(desc, cnt[len(upper)], units(sum), buckets, lvs...)
+}
@@ -0,0 +1,167 @@
+package cbytecache
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics bound to the cache key. Binds once to avoid labels hashing and vectors locking on every event.
+type promHandles struct {
+	bucket promCache[promBucketHandles]
+}
+
+// Metrics bound to the cache key and bucket.
+type promBucketHandles struct {
+	sizeTotal, sizeUsed, sizeFree, entryTotal, entryDelete,
+	arenaTotal, arenaUsed, arenaFree prometheus.Gauge
+
+	ioSet, ioEvict, ioMiss, ioHit, ioDel, ioExpire, ioCorrupt, ioCollision, ioNoSpace,
+	arenaAlloc, arenaRelease, arenaReset, arenaFill,
+	dump, load prometheus.Counter
+
+	speedWrite, speedRead prometheus.Observer
+}
+
+func newPromHandles(v *promVecs, key, hist string, lim promLimit) *promHandles {
+	h := &promHandles{}
+	h.bucket.bind = func(bucket string) *promBucketHandles {
+		return &promBucketHandles{
+			sizeTotal:   v.size.WithLabelValues(key, bucket, cacheTotal),
+			sizeUsed:    v.size.WithLabelValues(key, bucket, cacheUsed),
+			sizeFree:    v.size.WithLabelValues(key, bucket, cacheFree),
+			entryTotal:  v.size.WithLabelValues(key, bucket, cacheEntryTotal),
+			entryDelete: v.size.WithLabelValues(key, bucket, cacheEntryDelete),
+			arenaTotal:  v.arena.WithLabelValues(key, bucket, arenaTotal),
+			arenaUsed:   v.arena.WithLabelValues(key, bucket, arenaUsed),
+			arenaFree:   v.arena.WithLabelValues(key, bucket, arenaFree),
+
+			ioSet:        v.io.WithLabelValues(key, bucket, cacheIOSet),
+			ioEvict:      v.io.WithLabelValues(key, bucket, cacheIOEvict),
+			ioMiss:       v.io.WithLabelValues(key, bucket, cacheIOMiss),
+			ioHit:        v.io.WithLabelValues(key, bucket, cacheIOHit),
+			ioDel:        v.io.WithLabelValues(key, bucket, cacheIODel),
+			ioExpire:     v.io.WithLabelValues(key, bucket, cacheIOExpire),
+			ioCorrupt:    v.io.WithLabelValues(key, bucket, cacheIOCorrupt),
+			ioCollision:  v.io.WithLabelValues(key, bucket, cacheIOCollision),
+			ioNoSpace:    v.io.WithLabelValues(key, bucket, cacheIONoSpace),
+			arenaAlloc:   v.arenaIO.WithLabelValues(key, bucket, arenaIOAlloc),
+			arenaRelease: v.arenaIO.WithLabelValues(key, bucket, arenaIORelease),
+			arenaReset:   v.arenaIO.WithLabelValues(key, bucket, arenaIOReset),
+			arenaFill:    v.arenaIO.WithLabelValues(key, bucket, arenaIOFill),
+			dump:         v.dumpIO.WithLabelValues(key, bucket, dumpIODump),
+			load:         v.dumpIO.WithLabelValues(key, bucket, dumpIOLoad),
+
+			speedWrite: v.speed.vec(hist).WithLabelValues(key, bucket, speedWrite),
+			speedRead:  v.speed.vec(hist).WithLabelValues(key, bucket, speedRead),
+		}
+	}
+	if lim.limit > 0 {
+		overflow := lim.handler(v.labelOverflow.WithLabelValues(key, promLimitLabel).Inc, key)
+		h.bucket.limit, h.bucket.overflow = lim.limit, overflow
+	}
+	return h
+}
+
+// Get handles bound to the writer. Binds them again after Forget.
+func (m PrometheusMetrics) handles() *promHandles {
+	for {
+		if h := m.hnd.Load(); h != nil {
+			return h
+		}
+		m.hnd.CompareAndSwap(nil, newPromHandles(m.vec, m.key, m.hist, m.lim))
+	}
+}
+
+// Concurrent cache of metrics bound to values of dynamic label. Values are few (buckets, sub-queues, reasons), so
+// cache copies on write and reads without locks.
+type promCache[T any] struct {
+	mux  sync.Mutex
+	buf  atomic.Pointer[map[string]*T]
+	bind func(value string) *T
+
+	// Cardinality limit of values. Values over the limit folds into promOther.
+	limit    int
+	other    atomic.Pointer[T]
+	overflow func(value string)
+}
+
+func (c *promCache[T]) get(value string) *T {
+	if buf := c.buf.Load(); buf != nil {
+		if h, ok := (*buf)[value]; ok {
+			return h
+		}
+		if h := c.other.Load(); h != nil && len(*buf) >= c.limit {
+			c.overflow(value)
+			return h
+		}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var buf map[string]*T
+	if old := c.buf.Load(); old != nil {
+		if h, ok := (*old)[value]; ok {
+			return h
+		}
+		if c.limit > 0 && len(*old) >= c.limit {
+			h := c.other.Load()
+			if h == nil {
+				h = c.bind(promOther)
+				c.other.Store(h)
+			}
+			c.overflow(value)
+			return h
+		}
+		buf = make(map[string]*T, len(*old)+1)
+		for k, v := range *old {
+			buf[k] = v
+		}
+	} else {
+		buf = make(map[string]*T, 1)
+	}
+	h := c.bind(value)
+	buf[value] = h
+	c.buf.Store(&buf)
+	return h
+}
+
+func (c *promCache[T]) each(fn func(value string, h *T)) {
+	if buf := c.buf.Load(); buf != nil {
+		for value, h := range *buf {
+			fn(value, h)
+		}
+	}
+	if h := c.other.Load(); h != nil {
+		fn(promOther, h)
+	}
+}
+
+const (
+	// Value of dynamic label to fold values over cardinality limit.
+	promOther = "__other__"
+	// Name of dynamic label limited by WithLabelLimit.
+	promLimitLabel = "bucket"
+)
+
+// Cardinality limit of dynamic label.
+type promLimit struct {
+	limit  int
+	logger *slog.Logger
+}
+
+// Make handler of values over the limit: counts them using inc and logs the first one.
+func (l promLimit) handler(inc func(), key string) func(value string) {
+	var once sync.Once
+	return func(value string) {
+		inc()
+		if l.logger != nil {
+			once.Do(func() {
+				l.logger.Warn("label cardinality limit exceeded", "cache", key, "label", promLimitLabel,
+					"value", value, "limit", l.limit)
+			})
+		}
+	}
+}
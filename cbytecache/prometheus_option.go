@@ -0,0 +1,226 @@
+package cbytecache
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusOption describes PrometheusMetrics option.
+type PrometheusOption func(*promConfig)
+
+type promConfig struct {
+	reg  prometheus.Registerer
+	prec time.Duration
+	sec  bool
+	bkt  []float64
+
+	namespace, subsystem string
+	constLabels          prometheus.Labels
+
+	// Native histograms settings.
+	nhFactor     float64
+	nhMaxBuckets uint32
+	nhMinReset   time.Duration
+	noClassic    bool
+
+	// Cardinality limit of dynamic label.
+	labelLimit  int
+	labelLogger *slog.Logger
+
+	exemplar func() prometheus.Labels
+}
+
+// WithRegisterer sets registerer to use instead of prometheus.DefaultRegisterer.
+func WithRegisterer(reg prometheus.Registerer) PrometheusOption {
+	return func(c *promConfig) {
+		c.reg = reg
+	}
+}
+
+// WithPrecision sets precision (unit) of time measurements, eg time.Millisecond observes durations in milliseconds.
+//
+// Ignored if WithSeconds specified.
+func WithPrecision(precision time.Duration) PrometheusOption {
+	return func(c *promConfig) {
+		c.prec = precision
+	}
+}
+
+// WithNamespace sets namespace (prefix) of all metrics names.
+func WithNamespace(namespace string) PrometheusOption {
+	return func(c *promConfig) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets subsystem of all metrics names. Subsystem places between namespace and metric name.
+func WithSubsystem(subsystem string) PrometheusOption {
+	return func(c *promConfig) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets constant labels to apply to all metrics.
+//
+// Labels names must not intersect with variable labels of metrics.
+func WithConstLabels(labels prometheus.Labels) PrometheusOption {
+	return func(c *promConfig) {
+		if c.constLabels == nil {
+			c.constLabels = make(prometheus.Labels, len(labels))
+		}
+		for k, v := range labels {
+			c.constLabels[k] = v
+		}
+	}
+}
+
+// WithSeconds observes durations as float seconds following Prometheus conventions.
+//
+// If buckets aren't specified explicitly, prometheus.DefBuckets is used.
+func WithSeconds() PrometheusOption {
+	return func(c *promConfig) {
+		c.sec = true
+	}
+}
+
+// WithBuckets sets classic buckets of timing metrics. Buckets must be specified in units of time measurements (see
+// WithPrecision and WithSeconds) and sorted in increasing order.
+func WithBuckets(buckets ...float64) PrometheusOption {
+	return func(c *promConfig) {
+		c.bkt = append([]float64(nil), buckets...)
+	}
+}
+
+// WithLinearBuckets sets count classic buckets of timing metrics, each width wide, where the lowest one has an upper
+// bound of start.
+func WithLinearBuckets(start, width float64, count int) PrometheusOption {
+	return func(c *promConfig) {
+		c.bkt = prometheus.LinearBuckets(start, width, count)
+	}
+}
+
+// WithExponentialBuckets sets count classic buckets of timing metrics, where the lowest one has an upper bound of
+// start and each following one's upper bound is factor times the previous one's.
+func WithExponentialBuckets(start, factor float64, count int) PrometheusOption {
+	return func(c *promConfig) {
+		c.bkt = prometheus.ExponentialBuckets(start, factor, count)
+	}
+}
+
+// WithNativeHistograms enables Prometheus native (sparse) histograms for timing metrics.
+//
+// Param factor is a maximum ratio of neighbour buckets bounds (must be greater than 1, eg 1.1), maxBuckets limits
+// amount of populated buckets (zero means no limit) and minReset is a minimal interval between histogram resets if
+// buckets limit exceeded. Classic buckets keeps too for servers don't support native histograms, use
+// WithoutClassicBuckets to disable them.
+func WithNativeHistograms(factor float64, maxBuckets uint32, minReset time.Duration) PrometheusOption {
+	return func(c *promConfig) {
+		c.nhFactor, c.nhMaxBuckets, c.nhMinReset = factor, maxBuckets, minReset
+	}
+}
+
+// WithoutClassicBuckets disables classic buckets of timing metrics. Takes effect only with native histograms enabled.
+func WithoutClassicBuckets() PrometheusOption {
+	return func(c *promConfig) {
+		c.noClassic = true
+	}
+}
+
+// WithExemplars sets provider of exemplars labels (eg trace_id and span_id of current span) to attach to timing
+// metrics observations. Provider may return nil or empty labels to observe without exemplar. Labels exceed
+// prometheus.ExemplarMaxRunes are ignored.
+//
+// Exemplars are exposed only in OpenMetrics format, so enable it in handler, eg
+// promhttp.HandlerFor(reg, promhttp.HandlerOpts{EnableOpenMetrics: true}).
+func WithExemplars(provider func() prometheus.Labels) PrometheusOption {
+	return func(c *promConfig) {
+		c.exemplar = provider
+	}
+}
+
+// WithLabelLimit caps amount of distinct values of "bucket" label per writer. Events with values over the limit folds
+// into "__other__" value and counts in cbytecache_label_overflow metric. Zero means no limit.
+//
+// Applies to PrometheusMetrics and CollectorMetrics.
+func WithLabelLimit(limit int) PrometheusOption {
+	return func(c *promConfig) {
+		c.labelLimit = limit
+	}
+}
+
+// WithLabelLimitLogger sets logger to report the first overflow of label cardinality limit (see WithLabelLimit).
+func WithLabelLimitLogger(logger *slog.Logger) PrometheusOption {
+	return func(c *promConfig) {
+		c.labelLogger = logger
+	}
+}
+
+// Get classic buckets of timing metrics.
+func (c *promConfig) buckets() []float64 {
+	switch {
+	case c.noClassic && c.nhFactor > 1:
+		return nil
+	case len(c.bkt) > 0:
+		return c.bkt
+	case c.sec:
+		return prometheus.DefBuckets
+	default:
+		return promBuckets
+	}
+}
+
+// Build string representation of options affects metrics descriptions.
+func (c *promConfig) key() string {
+	var buf strings.Builder
+	buf.WriteString(c.namespace)
+	buf.WriteByte('|')
+	buf.WriteString(c.subsystem)
+	keys := make([]string, 0, len(c.constLabels))
+	for k := range c.constLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteByte('|')
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(c.constLabels[k])
+	}
+	return buf.String()
+}
+
+// Build string representation of options affects timing histograms: units, classic buckets and native histogram
+// settings.
+func (c *promConfig) histKey() string {
+	var buf strings.Builder
+	if c.sec {
+		buf.WriteString("u=s|")
+	} else {
+		buf.WriteString("u=")
+		buf.WriteString(c.prec.String())
+		buf.WriteByte('|')
+	}
+	buf.WriteString("b=")
+	for i, b := range c.buckets() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.FormatFloat(b, 'g', -1, 64))
+	}
+	if c.nhFactor > 1 {
+		buf.WriteString("|nh=")
+		buf.WriteString(strconv.FormatFloat(c.nhFactor, 'g', -1, 64))
+		buf.WriteByte(',')
+		buf.WriteString(strconv.FormatUint(uint64(c.nhMaxBuckets), 10))
+		buf.WriteByte(',')
+		buf.WriteString(c.nhMinReset.String())
+		buf.WriteByte(',')
+		buf.WriteString(strconv.FormatBool(c.noClassic))
+	}
+	return buf.String()
+}
@@ -0,0 +1,273 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Values of labels, the same as parent package uses.
+const (
+	cacheTotal       = "total"
+	cacheUsed        = "used"
+	cacheFree        = "free"
+	cacheEntryTotal  = "entry_total"
+	cacheEntryDelete = "entry_delete"
+
+	cacheIOSet       = "set"
+	cacheIOEvict     = "evict"
+	cacheIOMiss      = "miss"
+	cacheIOHit       = "hit"
+	cacheIODel       = "del"
+	cacheIOExpire    = "expire"
+	cacheIOCorrupt   = "corrupt"
+	cacheIOCollision = "collision"
+	cacheIONoSpace   = "no space"
+
+	speedWrite = "write"
+	speedRead  = "read"
+
+	arenaTotal = "total"
+	arenaUsed  = "used"
+	arenaFree  = "free"
+
+	arenaIOAlloc   = "alloc"
+	arenaIORelease = "release"
+	arenaIOReset   = "reset"
+	arenaIOFill    = "fill"
+
+	dumpIODump = "dump"
+	dumpIOLoad = "load"
+)
+
+// Metrics is a dependency-free Prometheus implementation of cbytecache.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as cbytecache.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	key     string
+	reg     *Registry
+	prec    time.Duration
+	sec     bool
+	desc    *descs
+	id      string
+	created time.Time
+
+	bucket promreg.Cache[bucketCounters]
+}
+
+type descs struct {
+	size, io, arena, arenaIO, dumpIO, speed *promreg.Desc
+}
+
+// Counters of cache bucket.
+type bucketCounters struct {
+	sizeTotal, sizeUsed, sizeFree, entryTotal, entryDelete,
+	arenaTotal, arenaUsed, arenaFree promreg.Counter
+
+	ioSet, ioEvict, ioMiss, ioHit, ioDel, ioExpire, ioCorrupt, ioCollision, ioNoSpace,
+	arenaAlloc, arenaRelease, arenaReset, arenaFill,
+	dump, load promreg.Counter
+
+	speedWrite, speedRead *promreg.Histogram
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same cache and registry share counters, ie constructor returns already registered writer.
+func NewMetrics(key string, opts ...Option) *Metrics {
+	c := config{
+		reg:  DefaultRegistry,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	m := &Metrics{
+		key:     key,
+		reg:     c.reg,
+		prec:    c.prec,
+		sec:     c.sec,
+		desc:    newDescs(&c, key),
+		id:      "cbytecache|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels) + "|" + key,
+		created: time.Now(),
+	}
+	buckets := c.buckets()
+	m.bucket.Bind = func(string) *bucketCounters {
+		return &bucketCounters{
+			speedWrite: promreg.NewHistogram(buckets),
+			speedRead:  promreg.NewHistogram(buckets),
+		}
+	}
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config, key string) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		cl := map[string]string{"cache": key}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, cl, labels...)
+	}
+	return &descs{
+		size:    desc("cbytecache_size", "Total, used and free cache (bucket) size in bytes.", "bucket", "type"),
+		io:      desc("cbytecache_io", "Count cache IO operations calls.", "bucket", "op"),
+		arena:   desc("cbytecache_arena", "Arenas count in cache (bucket).", "bucket", "type"),
+		arenaIO: desc("cbytecache_arena_io", "Count arena IO operations calls.", "bucket", "op"),
+		dumpIO:  desc("cbytecache_dump", "Count dump IO operations calls.", "bucket", "op"),
+		speed:   desc("cbytecache_io_speed", "Cache IO operations speed.", "bucket", "op"),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	m.bucket.Each(func(bucket string, h *bucketCounters) {
+		g.Gauge(d.size, &h.sizeTotal, bucket, cacheTotal)
+		g.Gauge(d.size, &h.sizeUsed, bucket, cacheUsed)
+		g.Gauge(d.size, &h.sizeFree, bucket, cacheFree)
+		g.Gauge(d.size, &h.entryTotal, bucket, cacheEntryTotal)
+		g.Gauge(d.size, &h.entryDelete, bucket, cacheEntryDelete)
+		g.Gauge(d.arena, &h.arenaTotal, bucket, arenaTotal)
+		g.Gauge(d.arena, &h.arenaUsed, bucket, arenaUsed)
+		g.Gauge(d.arena, &h.arenaFree, bucket, arenaFree)
+
+		g.Counter(d.io, &h.ioSet, m.created, bucket, cacheIOSet)
+		g.Counter(d.io, &h.ioEvict, m.created, bucket, cacheIOEvict)
+		g.Counter(d.io, &h.ioMiss, m.created, bucket, cacheIOMiss)
+		g.Counter(d.io, &h.ioHit, m.created, bucket, cacheIOHit)
+		g.Counter(d.io, &h.ioDel, m.created, bucket, cacheIODel)
+		g.Counter(d.io, &h.ioExpire, m.created, bucket, cacheIOExpire)
+		g.Counter(d.io, &h.ioCorrupt, m.created, bucket, cacheIOCorrupt)
+		g.Counter(d.io, &h.ioCollision, m.created, bucket, cacheIOCollision)
+		g.Counter(d.io, &h.ioNoSpace, m.created, bucket, cacheIONoSpace)
+		g.Counter(d.arenaIO, &h.arenaAlloc, m.created, bucket, arenaIOAlloc)
+		g.Counter(d.arenaIO, &h.arenaRelease, m.created, bucket, arenaIORelease)
+		g.Counter(d.arenaIO, &h.arenaReset, m.created, bucket, arenaIOReset)
+		g.Counter(d.arenaIO, &h.arenaFill, m.created, bucket, arenaIOFill)
+		g.Counter(d.dumpIO, &h.dump, m.created, bucket, dumpIODump)
+		g.Counter(d.dumpIO, &h.load, m.created, bucket, dumpIOLoad)
+
+		g.Histogram(d.speed, h.speedWrite, m.units, m.created, bucket, speedWrite)
+		g.Histogram(d.speed, h.speedRead, m.units, m.created, bucket, speedRead)
+	})
+}
+
+// Convert duration to units of observation.
+func (m *Metrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+func (m *Metrics) Alloc(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.sizeTotal.Add(sh, int64(size))
+	h.sizeFree.Add(sh, int64(size))
+
+	h.arenaTotal.Add(sh, 1)
+	h.arenaFree.Add(sh, 1)
+	h.arenaAlloc.Add(sh, 1)
+}
+
+func (m *Metrics) Fill(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.sizeUsed.Add(sh, int64(size))
+	h.sizeFree.Add(sh, -int64(size))
+
+	h.arenaUsed.Add(sh, 1)
+	h.arenaFree.Add(sh, -1)
+	h.arenaFill.Add(sh, 1)
+}
+
+func (m *Metrics) Reset(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.sizeUsed.Add(sh, -int64(size))
+	h.sizeFree.Add(sh, int64(size))
+
+	h.arenaUsed.Add(sh, -1)
+	h.arenaFree.Add(sh, 1)
+	h.arenaReset.Add(sh, 1)
+}
+
+func (m *Metrics) Release(bucket string, size uint32) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.sizeTotal.Add(sh, -int64(size))
+	h.sizeFree.Add(sh, -int64(size))
+
+	h.arenaTotal.Add(sh, -1)
+	h.arenaFree.Add(sh, -1)
+	h.arenaRelease.Add(sh, 1)
+}
+
+func (m *Metrics) Set(bucket string, dur time.Duration) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.entryTotal.Add(sh, 1)
+	h.ioSet.Add(sh, 1)
+	h.speedWrite.Observe(sh, m.units(dur), dur)
+}
+
+func (m *Metrics) Del(bucket string) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.entryDelete.Add(sh, 1)
+	h.ioDel.Add(sh, 1)
+}
+
+func (m *Metrics) Evict(bucket string, alive bool) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.entryTotal.Add(sh, -1)
+	if !alive {
+		h.entryDelete.Add(sh, -1)
+	}
+	h.ioEvict.Add(sh, 1)
+}
+
+func (m *Metrics) Miss(bucket string) {
+	m.bucket.Get(bucket).ioMiss.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) Hit(bucket string, dur time.Duration) {
+	sh, h := promreg.Shard(), m.bucket.Get(bucket)
+	h.ioHit.Add(sh, 1)
+	h.speedRead.Observe(sh, m.units(dur), dur)
+}
+
+func (m *Metrics) Expire(bucket string) {
+	m.bucket.Get(bucket).ioExpire.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) Corrupt(bucket string) {
+	m.bucket.Get(bucket).ioCorrupt.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) Collision(bucket string) {
+	m.bucket.Get(bucket).ioCollision.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) NoSpace(bucket string) {
+	m.bucket.Get(bucket).ioNoSpace.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) Dump(bucket string) {
+	m.bucket.Get(bucket).dump.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) Load(bucket string) {
+	m.bucket.Get(bucket).load.Add(promreg.Shard(), 1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
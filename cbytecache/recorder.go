@@ -0,0 +1,234 @@
+package cbytecache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RecorderMetrics is in-memory implementation of cbytecache.MetricsWriter designed for unit tests.
+//
+// Records every call with its arguments and keeps counters, gauges and timers the same way as aggregating writers do
+// (metric families and labels are the same as in PrometheusMetrics). Safe for concurrent use.
+//
+// Usage example:
+//
+//	m.AssertCount(t, "Collision", 2, "bucket0")
+//	n := m.Counter("cbytecache_io", "bucket", "bucket0", "op", "collision")
+type RecorderMetrics struct {
+	aggrMetrics
+
+	mux sync.Mutex
+	buf []RecorderEvent
+}
+
+// RecorderEvent describes single recorded call.
+type RecorderEvent struct {
+	// Method name, eg "Alloc".
+	Method string
+	// Arguments in order of method signature.
+	Args []any
+}
+
+// TestingT is a subset of testing.TB used by assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+var _ = NewRecorderMetrics
+
+func NewRecorderMetrics(key string) *RecorderMetrics {
+	m := &RecorderMetrics{
+		aggrMetrics: aggrMetrics{
+			key: key,
+			s:   newAggrStore(),
+		},
+	}
+	return m
+}
+
+func (m *RecorderMetrics) Alloc(bucket string, size uint32) {
+	m.record("Alloc", bucket, size)
+	m.aggrMetrics.Alloc(bucket, size)
+}
+
+func (m *RecorderMetrics) Fill(bucket string, size uint32) {
+	m.record("Fill", bucket, size)
+	m.aggrMetrics.Fill(bucket, size)
+}
+
+func (m *RecorderMetrics) Reset(bucket string, size uint32) {
+	m.record("Reset", bucket, size)
+	m.aggrMetrics.Reset(bucket, size)
+}
+
+func (m *RecorderMetrics) Release(bucket string, size uint32) {
+	m.record("Release", bucket, size)
+	m.aggrMetrics.Release(bucket, size)
+}
+
+func (m *RecorderMetrics) Set(bucket string, dur time.Duration) {
+	m.record("Set", bucket, dur)
+	m.aggrMetrics.Set(bucket, dur)
+}
+
+func (m *RecorderMetrics) Del(bucket string) {
+	m.record("Del", bucket)
+	m.aggrMetrics.Del(bucket)
+}
+
+func (m *RecorderMetrics) Evict(bucket string, alive bool) {
+	m.record("Evict", bucket, alive)
+	m.aggrMetrics.Evict(bucket, alive)
+}
+
+func (m *RecorderMetrics) Miss(bucket string) {
+	m.record("Miss", bucket)
+	m.aggrMetrics.Miss(bucket)
+}
+
+func (m *RecorderMetrics) Hit(bucket string, dur time.Duration) {
+	m.record("Hit", bucket, dur)
+	m.aggrMetrics.Hit(bucket, dur)
+}
+
+func (m *RecorderMetrics) Expire(bucket string) {
+	m.record("Expire", bucket)
+	m.aggrMetrics.Expire(bucket)
+}
+
+func (m *RecorderMetrics) Corrupt(bucket string) {
+	m.record("Corrupt", bucket)
+	m.aggrMetrics.Corrupt(bucket)
+}
+
+func (m *RecorderMetrics) Collision(bucket string) {
+	m.record("Collision", bucket)
+	m.aggrMetrics.Collision(bucket)
+}
+
+func (m *RecorderMetrics) NoSpace(bucket string) {
+	m.record("NoSpace", bucket)
+	m.aggrMetrics.NoSpace(bucket)
+}
+
+func (m *RecorderMetrics) Dump(bucket string) {
+	m.record("Dump", bucket)
+	m.aggrMetrics.Dump(bucket)
+}
+
+func (m *RecorderMetrics) Load(bucket string) {
+	m.record("Load", bucket)
+	m.aggrMetrics.Load(bucket)
+}
+
+// Events returns copy of recorded events log.
+func (m *RecorderMetrics) Events() []RecorderEvent {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]RecorderEvent(nil), m.buf...)
+}
+
+// Count returns how many times method was called.
+//
+// Optional args filters events by leading arguments. Arguments compares using ==, so their types must be the same as
+// in method signature.
+func (m *RecorderMetrics) Count(method string, args ...any) (n int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i := 0; i < len(m.buf); i++ {
+		if e := &m.buf[i]; e.Method == method && e.match(args) {
+			n++
+		}
+	}
+	return
+}
+
+// Counter returns value of counter. Labels must be specified as key-value pairs excluding cache key name, eg
+// m.Counter("cbytecache_io", "bucket", "bucket0", "op", "collision").
+func (m *RecorderMetrics) Counter(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrCounter {
+		return x.load()
+	}
+	return 0
+}
+
+// Gauge returns value of gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Gauge(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrGauge {
+		return x.load()
+	}
+	return 0
+}
+
+// Timer returns count and sum of timer observations. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Timer(family string, labels ...string) (count int64, sum time.Duration) {
+	if x := m.find(family, labels); x != nil && x.kind == aggrTimer {
+		count, sum = atomic.LoadInt64(&x.count), time.Duration(atomic.LoadInt64(&x.sum))
+	}
+	return
+}
+
+// Clear drops all recorded events and metrics.
+func (m *RecorderMetrics) Clear() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.buf = m.buf[:0]
+	m.s.reset()
+}
+
+// AssertCount checks if method was called exactly n times. Optional args filters events as in Count method.
+func (m *RecorderMetrics) AssertCount(t TestingT, method string, n int, args ...any) bool {
+	t.Helper()
+	if c := m.Count(method, args...); c != n {
+		t.Errorf("%s%s: expected %d calls, got %d", method, fmtArgs(args), n, c)
+		return false
+	}
+	return true
+}
+
+// AssertValue checks value of counter or gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) AssertValue(t TestingT, family string, value int64, labels ...string) bool {
+	t.Helper()
+	var v int64
+	if x := m.find(family, labels); x != nil && x.kind != aggrTimer {
+		v = x.load()
+	}
+	if v != value {
+		t.Errorf("%s%v: expected %d, got %d", family, labels, value, v)
+		return false
+	}
+	return true
+}
+
+func (m *RecorderMetrics) record(method string, args ...any) {
+	m.mux.Lock()
+	m.buf = append(m.buf, RecorderEvent{Method: method, Args: args})
+	m.mux.Unlock()
+}
+
+func (m *RecorderMetrics) find(family string, labels []string) *aggrSeries {
+	return m.s.find(family, append([]string{"cache", m.key}, labels...)...)
+}
+
+// Check if leading arguments of event are equal to args.
+func (e *RecorderEvent) match(args []any) bool {
+	if len(args) > len(e.Args) {
+		return false
+	}
+	for i := 0; i < len(args); i++ {
+		if e.Args[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func fmtArgs(args []any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return fmt.Sprint(args)
+}
@@ -0,0 +1,51 @@
+package cbytecache
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics("test")
+	m.Alloc("b0", 64)
+	m.Alloc("b0", 64)
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "Alloc", 2, "b0") ||
+		!m.AssertValue(&ft, "cbytecache_arena", 2, "bucket", "b0", "type", "total") {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "Alloc", 1, "b1") },
+			"Alloc[b1]: expected 1 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertValue(t, "cbytecache_size", 64, "bucket", "b0", "type", "total") },
+			"cbytecache_size[bucket b0 type total]: expected 64, got 128"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cbytecache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
+)
+
+// SlogMetrics is a log/slog implementation of cbytecache.MetricsWriter.
+//
+// Each event writes as a separate record with message equal to method name (eg "Load") and arguments as attributes.
+//
+// Events Corrupt, Collision and NoSpace logs with slog.LevelWarn, the rest with slog.LevelDebug. Levels may be changed
+// using options.
+type SlogMetrics struct {
+	l    *slog.Logger
+	conf slogConfig
+	lim  *loglimit.Limiter
+}
+
+// SlogOption describes SlogMetrics option.
+type SlogOption func(*slogConfig)
+
+type slogConfig struct {
+	level  slog.Level
+	levels map[string]slog.Level
+	limits []LogLimitOption
+}
+
+// WithSlogLevel sets level of events haven't own level.
+func WithSlogLevel(level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.level = level
+	}
+}
+
+// WithSlogEventLevel sets level of given event, eg WithSlogEventLevel("Load", slog.LevelInfo).
+func WithSlogEventLevel(event string, level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.levels[event] = level
+	}
+}
+
+// WithSlogLimits enables sampling and/or rate limiting of events.
+//
+// Reports about suppressed events writes with level of corresponding event.
+func WithSlogLimits(opts ...LogLimitOption) SlogOption {
+	return func(c *slogConfig) {
+		c.limits = append(c.limits, opts...)
+	}
+}
+
+var _ = NewSlogMetrics
+
+// NewSlogMetrics makes new writer logs events to logger. If logger is nil, slog.Default() will use.
+//
+// Records contain attribute "cache" with cache name.
+func NewSlogMetrics(name string, logger *slog.Logger, opts ...SlogOption) *SlogMetrics {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &SlogMetrics{
+		l: logger.With(slog.String("cache", name)),
+		conf: slogConfig{
+			level: slog.LevelDebug,
+			levels: map[string]slog.Level{
+				"Corrupt":   slog.LevelWarn,
+				"Collision": slog.LevelWarn,
+				"NoSpace":   slog.LevelWarn,
+			},
+		},
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	m.lim = newLogLimiter(m.conf.limits, m.suppressed)
+	return m
+}
+
+func (m SlogMetrics) Alloc(bucket string, size uint32) {
+	m.log("Alloc", slog.String("bucket", bucket), slog.Uint64("size", uint64(size)))
+}
+
+func (m SlogMetrics) Fill(bucket string, size uint32) {
+	m.log("Fill", slog.String("bucket", bucket), slog.Uint64("size", uint64(size)))
+}
+
+func (m SlogMetrics) Reset(bucket string, size uint32) {
+	m.log("Reset", slog.String("bucket", bucket), slog.Uint64("size", uint64(size)))
+}
+
+func (m SlogMetrics) Release(bucket string, size uint32) {
+	m.log("Release", slog.String("bucket", bucket), slog.Uint64("size", uint64(size)))
+}
+
+func (m SlogMetrics) Set(bucket string, dur time.Duration) {
+	m.log("Set", slog.String("bucket", bucket), slog.Duration("duration", dur))
+}
+
+func (m SlogMetrics) Del(bucket string) {
+	m.log("Del", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) Evict(bucket string, alive bool) {
+	m.log("Evict", slog.String("bucket", bucket), slog.Bool("alive", alive))
+}
+
+func (m SlogMetrics) Miss(bucket string) {
+	m.log("Miss", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) Hit(bucket string, dur time.Duration) {
+	m.log("Hit", slog.String("bucket", bucket), slog.Duration("duration", dur))
+}
+
+func (m SlogMetrics) Expire(bucket string) {
+	m.log("Expire", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) Corrupt(bucket string) {
+	m.log("Corrupt", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) Collision(bucket string) {
+	m.log("Collision", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) NoSpace(bucket string) {
+	m.log("NoSpace", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) Dump(bucket string) {
+	m.log("Dump", slog.String("bucket", bucket))
+}
+
+func (m SlogMetrics) Load(bucket string) {
+	m.log("Load", slog.String("bucket", bucket))
+}
+
+// Close stops reporting of suppressed events.
+func (m SlogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
+
+func (m SlogMetrics) log(event string, attrs ...slog.Attr) {
+	ctx, level := context.Background(), m.level(event)
+	if !m.l.Enabled(ctx, level) || !m.lim.Allow(event) {
+		return
+	}
+	m.l.LogAttrs(ctx, level, event, attrs...)
+}
+
+func (m SlogMetrics) suppressed(event string, n uint64) {
+	m.l.LogAttrs(context.Background(), m.level(event), "suppressed events",
+		slog.String("event", event), slog.Uint64("count", n))
+}
+
+func (m SlogMetrics) level(event string) slog.Level {
+	if level, ok := m.conf.levels[event]; ok {
+		return level
+	}
+	return m.conf.level
+}
@@ -0,0 +1,127 @@
+package cbytecache
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/statsd"
+)
+
+// StatsDMetrics is a StatsD implementation of cbytecache.MetricsWriter.
+//
+// Counters and timers sends as is, gauges keeps locally and sends actual values.
+type StatsDMetrics struct {
+	key string
+	c   *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(key, addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{
+		key: key,
+		c:   c,
+	}
+	return m, nil
+}
+
+func (m StatsDMetrics) Alloc(bucket string, size uint32) {
+	m.c.GaugeAdd("cbytecache_size", int64(size), "cache", m.key, "bucket", bucket, "type", cacheTotal)
+	m.c.GaugeAdd("cbytecache_size", int64(size), "cache", m.key, "bucket", bucket, "type", cacheFree)
+
+	m.c.GaugeAdd("cbytecache_arena", 1, "cache", m.key, "bucket", bucket, "type", arenaTotal)
+	m.c.GaugeAdd("cbytecache_arena", 1, "cache", m.key, "bucket", bucket, "type", arenaFree)
+	m.c.Count("cbytecache_arena_io", 1, "cache", m.key, "bucket", bucket, "op", arenaIOAlloc)
+}
+
+func (m StatsDMetrics) Fill(bucket string, size uint32) {
+	m.c.GaugeAdd("cbytecache_size", int64(size), "cache", m.key, "bucket", bucket, "type", cacheUsed)
+	m.c.GaugeAdd("cbytecache_size", -int64(size), "cache", m.key, "bucket", bucket, "type", cacheFree)
+
+	m.c.GaugeAdd("cbytecache_arena", 1, "cache", m.key, "bucket", bucket, "type", arenaUsed)
+	m.c.GaugeAdd("cbytecache_arena", -1, "cache", m.key, "bucket", bucket, "type", arenaFree)
+	m.c.Count("cbytecache_arena_io", 1, "cache", m.key, "bucket", bucket, "op", arenaIOFill)
+}
+
+func (m StatsDMetrics) Reset(bucket string, size uint32) {
+	m.c.GaugeAdd("cbytecache_size", -int64(size), "cache", m.key, "bucket", bucket, "type", cacheUsed)
+	m.c.GaugeAdd("cbytecache_size", int64(size), "cache", m.key, "bucket", bucket, "type", cacheFree)
+
+	m.c.GaugeAdd("cbytecache_arena", -1, "cache", m.key, "bucket", bucket, "type", arenaUsed)
+	m.c.GaugeAdd("cbytecache_arena", 1, "cache", m.key, "bucket", bucket, "type", arenaFree)
+	m.c.Count("cbytecache_arena_io", 1, "cache", m.key, "bucket", bucket, "op", arenaIOReset)
+}
+
+func (m StatsDMetrics) Release(bucket string, size uint32) {
+	m.c.GaugeAdd("cbytecache_size", -int64(size), "cache", m.key, "bucket", bucket, "type", cacheTotal)
+	m.c.GaugeAdd("cbytecache_size", -int64(size), "cache", m.key, "bucket", bucket, "type", cacheFree)
+
+	m.c.GaugeAdd("cbytecache_arena", -1, "cache", m.key, "bucket", bucket, "type", arenaTotal)
+	m.c.GaugeAdd("cbytecache_arena", -1, "cache", m.key, "bucket", bucket, "type", arenaFree)
+	m.c.Count("cbytecache_arena_io", 1, "cache", m.key, "bucket", bucket, "op", arenaIORelease)
+}
+
+func (m StatsDMetrics) Set(bucket string, dur time.Duration) {
+	m.c.GaugeAdd("cbytecache_size", 1, "cache", m.key, "bucket", bucket, "type", cacheEntryTotal)
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOSet)
+	m.c.Timing("cbytecache_io_speed", dur, "cache", m.key, "bucket", bucket, "op", speedWrite)
+}
+
+func (m StatsDMetrics) Del(bucket string) {
+	m.c.GaugeAdd("cbytecache_size", 1, "cache", m.key, "bucket", bucket, "type", cacheEntryDelete)
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIODel)
+}
+
+func (m StatsDMetrics) Evict(bucket string, alive bool) {
+	m.c.GaugeAdd("cbytecache_size", -1, "cache", m.key, "bucket", bucket, "type", cacheEntryTotal)
+	if !alive {
+		m.c.GaugeAdd("cbytecache_size", -1, "cache", m.key, "bucket", bucket, "type", cacheEntryDelete)
+	}
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOEvict)
+}
+
+func (m StatsDMetrics) Miss(bucket string) {
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOMiss)
+}
+
+func (m StatsDMetrics) Hit(bucket string, dur time.Duration) {
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOHit)
+	m.c.Timing("cbytecache_io_speed", dur, "cache", m.key, "bucket", bucket, "op", speedRead)
+}
+
+func (m StatsDMetrics) Expire(bucket string) {
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOExpire)
+}
+
+func (m StatsDMetrics) Corrupt(bucket string) {
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOCorrupt)
+}
+
+func (m StatsDMetrics) Collision(bucket string) {
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIOCollision)
+}
+
+func (m StatsDMetrics) NoSpace(bucket string) {
+	m.c.Count("cbytecache_io", 1, "cache", m.key, "bucket", bucket, "op", cacheIONoSpace)
+}
+
+func (m StatsDMetrics) Dump(bucket string) {
+	m.c.Count("cbytecache_dump", 1, "cache", m.key, "bucket", bucket, "op", dumpIODump)
+}
+
+func (m StatsDMetrics) Load(bucket string) {
+	m.c.Count("cbytecache_dump", 1, "cache", m.key, "bucket", bucket, "op", dumpIOLoad)
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
@@ -0,0 +1,42 @@
+package cbytecache
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/statsd"
+)
+
+// StatsDOption describes StatsDMetrics option.
+type StatsDOption func(*statsd.Config)
+
+// WithStatsDPrefix sets prefix of all metrics names.
+func WithStatsDPrefix(prefix string) StatsDOption {
+	return func(c *statsd.Config) {
+		c.Prefix = prefix
+	}
+}
+
+// WithDogStatsDTags enables DogStatsD tags extension.
+//
+// Labels writes as tags instead of metric name suffixes. Optional const tags in "key:value" format appends to every
+// metric.
+func WithDogStatsDTags(constTags ...string) StatsDOption {
+	return func(c *statsd.Config) {
+		c.Tags = true
+		c.ConstTags = append(c.ConstTags, constTags...)
+	}
+}
+
+// WithStatsDMTU sets maximum size of UDP packet. Metrics batches into packets up to that size.
+func WithStatsDMTU(mtu int) StatsDOption {
+	return func(c *statsd.Config) {
+		c.MTU = mtu
+	}
+}
+
+// WithStatsDFlushInterval sets how often not completely filled packets will send.
+func WithStatsDFlushInterval(interval time.Duration) StatsDOption {
+	return func(c *statsd.Config) {
+		c.Interval = interval
+	}
+}
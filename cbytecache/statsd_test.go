@@ -0,0 +1,37 @@
+package cbytecache
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsDMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	m, err := NewStatsDMetrics("test", conn.LocalAddr().String(), WithStatsDPrefix("app."),
+		WithStatsDFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = m.Close() }()
+
+	m.Set("b0", time.Millisecond)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "app.cbytecache_size.test.b0.entry_total:1|g\napp.cbytecache_io.test.b0.set:1|c\n" +
+		"app.cbytecache_io_speed.test.b0.write:1|ms"
+	if got := string(buf[:n]); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
@@ -0,0 +1,170 @@
+package cbytecache
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const summaryDefaultInterval = 10 * time.Second
+
+// SummaryLogMetrics is a summary log implementation of cbytecache.MetricsWriter.
+//
+// Instead of line per event aggregates events and periodically logs compact digest of the last interval: counters
+// deltas and rates, actual values of gauges and timers stats (count, mean and max). Counters without changes omits. Digest looks like:
+//
+//	cbytecache foo in last 10s: io(bucket0,hit) 1200 (120.0/s), size(bucket0,used) 4096, ...
+type SummaryLogMetrics struct {
+	aggrMetrics
+	interval time.Duration
+
+	mux   sync.Mutex
+	lastT time.Time
+	last  map[*aggrSeries]summaryLast
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+type summaryLast struct {
+	value, count int64
+	sum          time.Duration
+}
+
+var _ = NewSummaryLogMetrics
+
+// NewSummaryLogMetrics makes new writer logs digest every interval (10 seconds by default).
+func NewSummaryLogMetrics(key string, interval time.Duration) *SummaryLogMetrics {
+	m := &SummaryLogMetrics{
+		aggrMetrics: aggrMetrics{
+			key: key,
+			s:   newAggrStore(),
+		},
+		interval: interval,
+		lastT:    time.Now(),
+		last:     make(map[*aggrSeries]summaryLast),
+		done:     make(chan struct{}),
+	}
+	if m.interval <= 0 {
+		m.interval = summaryDefaultInterval
+	}
+	m.wg.Add(1)
+	go m.loop()
+	return m
+}
+
+// Close stops background ticker and logs digest of the rest of events.
+func (m *SummaryLogMetrics) Close() error {
+	m.once.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		log.Println(m.digest(time.Now()))
+	})
+	return nil
+}
+
+// Stop is an alias of Close.
+func (m *SummaryLogMetrics) Stop() {
+	_ = m.Close()
+}
+
+func (m *SummaryLogMetrics) loop() {
+	defer m.wg.Done()
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			log.Println(m.digest(now))
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// Build digest of events since previous call.
+func (m *SummaryLogMetrics) digest(now time.Time) string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	elapsed := now.Sub(m.lastT)
+	m.lastT = now
+
+	var buf strings.Builder
+	buf.WriteString("cbytecache ")
+	buf.WriteString(m.key)
+	buf.WriteString(" in last ")
+	buf.WriteString(elapsed.Round(time.Millisecond).String())
+	buf.WriteByte(':')
+	var n int
+	m.s.each(func(x *aggrSeries) {
+		prev := m.last[x]
+		switch x.kind {
+		case aggrCounter:
+			value := x.load()
+			m.last[x] = summaryLast{value: value}
+			delta := value - prev.value
+			if delta == 0 {
+				return
+			}
+			summarySep(&buf, &n)
+			summaryName(&buf, x)
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatInt(delta, 10))
+			buf.WriteString(" (")
+			buf.WriteString(strconv.FormatFloat(float64(delta)/elapsed.Seconds(), 'f', 1, 64))
+			buf.WriteString("/s)")
+		case aggrGauge:
+			summarySep(&buf, &n)
+			summaryName(&buf, x)
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatInt(x.load(), 10))
+		case aggrTimer:
+			count, sum, peak := x.loadTimer()
+			x.resetPeak(peak)
+			m.last[x] = summaryLast{count: count, sum: sum}
+			dc := count - prev.count
+			if dc == 0 {
+				return
+			}
+			summarySep(&buf, &n)
+			summaryName(&buf, x)
+			buf.WriteByte(' ')
+			buf.WriteString(strconv.FormatInt(dc, 10))
+			buf.WriteString("x mean ")
+			buf.WriteString(((sum - prev.sum) / time.Duration(dc)).String())
+			buf.WriteString(" max ")
+			buf.WriteString(peak.String())
+		}
+	})
+	if n == 0 {
+		buf.WriteString(" no events")
+	}
+	return buf.String()
+}
+
+func summarySep(buf *strings.Builder, n *int) {
+	if *n > 0 {
+		buf.WriteByte(',')
+	}
+	buf.WriteByte(' ')
+	*n++
+}
+
+// Write short name of series: family without package prefix and label values except cache name, eg io(bucket0,hit).
+func summaryName(buf *strings.Builder, x *aggrSeries) {
+	buf.WriteString(strings.TrimPrefix(x.family, "cbytecache_"))
+	for i := 3; i < len(x.labels); i += 2 {
+		if i == 3 {
+			buf.WriteByte('(')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(x.labels[i])
+	}
+	if len(x.labels) > 2 {
+		buf.WriteByte(')')
+	}
+}
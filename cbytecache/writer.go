@@ -0,0 +1,55 @@
+package cbytecache
+
+import "time"
+
+// MetricsWriter describes cbytecache.MetricsWriter interface.
+//
+// Declared locally to keep writers independent of upstream package version. All writers of the package implement it.
+type MetricsWriter interface {
+	// Alloc registers allocation of new arena in the bucket.
+	Alloc(bucket string, size uint32)
+	// Fill registers how many bytes of arena filled.
+	Fill(bucket string, size uint32)
+	// Reset registers reset of arena.
+	Reset(bucket string, size uint32)
+	// Release registers release of arena memory.
+	Release(bucket string, size uint32)
+	// Set registers how many time set of new entry took.
+	Set(bucket string, dur time.Duration)
+	// Del registers entry removal.
+	Del(bucket string)
+	// Evict registers entry eviction. Param alive indicates if entry wasn't expired yet.
+	Evict(bucket string, alive bool)
+	// Miss registers cache miss.
+	Miss(bucket string)
+	// Hit registers cache hit and how many time read took.
+	Hit(bucket string, dur time.Duration)
+	// Expire registers read of expired entry.
+	Expire(bucket string)
+	// Corrupt registers read of corrupted entry.
+	Corrupt(bucket string)
+	// Collision registers keys collision.
+	Collision(bucket string)
+	// NoSpace registers set fail due to lack of space.
+	NoSpace(bucket string)
+	// Dump registers entry dump.
+	Dump(bucket string)
+	// Load registers entry load from dump.
+	Load(bucket string)
+}
+
+var (
+	_ MetricsWriter = (*PrometheusMetrics)(nil)
+	_ MetricsWriter = (*CollectorMetrics)(nil)
+	_ MetricsWriter = (*ExpvarMetrics)(nil)
+	_ MetricsWriter = (*RewriteMetrics)(nil)
+	_ MetricsWriter = (*OTelMetrics)(nil)
+	_ MetricsWriter = (*StatsDMetrics)(nil)
+	_ MetricsWriter = (*InfluxMetrics)(nil)
+	_ MetricsWriter = (*GraphiteMetrics)(nil)
+	_ MetricsWriter = (*LogMetrics)(nil)
+	_ MetricsWriter = (*MultiMetrics)(nil)
+	_ MetricsWriter = (*RecorderMetrics)(nil)
+	_ MetricsWriter = (*SlogMetrics)(nil)
+	_ MetricsWriter = (*SummaryLogMetrics)(nil)
+)
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Grafana dashboard model, only fields used by generator.
+type dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description,omitempty"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	Editable      bool       `json:"editable"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Version       int        `json:"version"`
+	Refresh       string     `json:"refresh"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []*panel   `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label,omitempty"`
+	Type       string      `json:"type"`
+	Datasource *datasource `json:"datasource,omitempty"`
+	Query      any         `json:"query"`
+	Definition string      `json:"definition,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	IncludeAll bool        `json:"includeAll"`
+	Multi      bool        `json:"multi"`
+	Sort       int         `json:"sort,omitempty"`
+	Current    struct{}    `json:"current"`
+}
+
+type variableQuery struct {
+	Query string `json:"query"`
+	RefID string `json:"refId"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	GridPos     gridPos      `json:"gridPos"`
+	Datasource  *datasource  `json:"datasource,omitempty"`
+	Targets     []target     `json:"targets,omitempty"`
+	FieldConfig *fieldConfig `json:"fieldConfig,omitempty"`
+	Options     *options     `json:"options,omitempty"`
+	Collapsed   *bool        `json:"collapsed,omitempty"`
+	Panels      []*panel     `json:"panels,omitempty"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+type fieldConfig struct {
+	Defaults  fieldDefaults `json:"defaults"`
+	Overrides []struct{}    `json:"overrides"`
+}
+
+type fieldDefaults struct {
+	Unit   string      `json:"unit"`
+	Min    *float64    `json:"min,omitempty"`
+	Max    *float64    `json:"max,omitempty"`
+	Custom fieldCustom `json:"custom"`
+}
+
+type fieldCustom struct {
+	FillOpacity int      `json:"fillOpacity"`
+	Stacking    stacking `json:"stacking"`
+}
+
+type stacking struct {
+	Mode string `json:"mode"`
+}
+
+type options struct {
+	Legend  legend  `json:"legend"`
+	Tooltip tooltip `json:"tooltip"`
+}
+
+type legend struct {
+	DisplayMode string   `json:"displayMode"`
+	Placement   string   `json:"placement"`
+	Calcs       []string `json:"calcs"`
+}
+
+type tooltip struct {
+	Mode string `json:"mode"`
+	Sort string `json:"sort"`
+}
+
+var promDatasource = &datasource{Type: "prometheus", UID: "${datasource}"}
+
+// Builder of dashboard: names metrics, builds selectors and lays out panels.
+type builder struct {
+	conf *config
+	// Name label of package, eg "queue", empty if package has no name.
+	nameLabel string
+	// Name variable is registered, so selectors may refer it.
+	nameVar bool
+
+	d    *dashboard
+	x, y int
+}
+
+func newBuilder(c *config, p *pkg) *builder {
+	b := &builder{
+		conf:      c,
+		nameLabel: p.nameLabel,
+		d: &dashboard{
+			UID:           c.uid(p.name),
+			Title:         c.title(p.name),
+			Description:   p.desc,
+			Tags:          []string{"metrics_writers", p.name},
+			Timezone:      "browser",
+			Editable:      true,
+			SchemaVersion: 39,
+			Version:       1,
+			Refresh:       "30s",
+			Time:          timeRange{From: "now-6h", To: "now"},
+			Panels:        []*panel{},
+		},
+	}
+	b.d.Templating.List = append(b.d.Templating.List, variable{
+		Name:  "datasource",
+		Label: "Data source",
+		Type:  "datasource",
+		Query: "prometheus",
+	})
+	if len(p.nameLabel) > 0 {
+		q := "label_values(" + b.metric(p.nameMetric) + b.selector() + ", " + p.nameLabel + ")"
+		b.d.Templating.List = append(b.d.Templating.List, variable{
+			Name:       p.nameLabel,
+			Label:      strings.ToUpper(p.nameLabel[:1]) + p.nameLabel[1:],
+			Type:       "query",
+			Datasource: promDatasource,
+			Query:      variableQuery{Query: q, RefID: "PrometheusVariableQueryEditor-VariableQuery"},
+			Definition: q,
+			Refresh:    2,
+			IncludeAll: true,
+			Multi:      true,
+			Sort:       1,
+		})
+		b.nameVar = true
+	}
+	return b
+}
+
+// Get fully qualified name of metric family.
+func (b *builder) metric(name string) string {
+	var parts []string
+	for _, s := range []string{b.conf.namespace, b.conf.subsystem, name} {
+		if len(s) > 0 {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// Build selector of series: name variable, constant labels and given extra matchers.
+func (b *builder) selector(matchers ...string) string {
+	var m []string
+	if b.nameVar {
+		m = append(m, b.nameLabel+`=~"$`+b.nameLabel+`"`)
+	}
+	keys := make([]string, 0, len(b.conf.labels))
+	for k := range b.conf.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m = append(m, k+`="`+escape(b.conf.labels[k])+`"`)
+	}
+	m = append(m, matchers...)
+	if len(m) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(m, ",") + "}"
+}
+
+// Build series expression: metric with selector.
+func (b *builder) series(name string, matchers ...string) string {
+	return b.metric(name) + b.selector(matchers...)
+}
+
+// Build rate expression of counter.
+func (b *builder) rate(name string, matchers ...string) string {
+	return "rate(" + b.series(name, matchers...) + "[$__rate_interval])"
+}
+
+// Build quantile expression of histogram aggregated by given labels.
+func (b *builder) quantile(q, name, by string) string {
+	return "histogram_quantile(" + q + ", sum by (" + by + ", le) (" + b.rate(name+"_bucket") + "))"
+}
+
+// Add row to the dashboard.
+func (b *builder) row(title string) {
+	if b.x > 0 {
+		b.x, b.y = 0, b.y+8
+	}
+	collapsed := false
+	b.d.Panels = append(b.d.Panels, &panel{
+		ID:        len(b.d.Panels) + 1,
+		Type:      "row",
+		Title:     title,
+		GridPos:   gridPos{H: 1, W: 24, X: 0, Y: b.y},
+		Collapsed: &collapsed,
+	})
+	b.y++
+}
+
+// Panel kinds.
+const (
+	panelLines = iota
+	panelStacked
+	panelRatio
+)
+
+// Add time series panel. Targets specifies as expression-legend pairs.
+func (b *builder) panel(title, desc, unit string, kind int, targets ...string) {
+	p := &panel{
+		ID:          len(b.d.Panels) + 1,
+		Type:        "timeseries",
+		Title:       title,
+		Description: desc,
+		GridPos:     gridPos{H: 8, W: 12, X: b.x, Y: b.y},
+		Datasource:  promDatasource,
+		FieldConfig: &fieldConfig{
+			Defaults:  fieldDefaults{Unit: unit, Custom: fieldCustom{FillOpacity: 10, Stacking: stacking{Mode: "none"}}},
+			Overrides: []struct{}{},
+		},
+		Options: &options{
+			Legend:  legend{DisplayMode: "table", Placement: "bottom", Calcs: []string{"mean", "max", "lastNotNull"}},
+			Tooltip: tooltip{Mode: "multi", Sort: "desc"},
+		},
+	}
+	switch kind {
+	case panelStacked:
+		p.FieldConfig.Defaults.Custom = fieldCustom{FillOpacity: 60, Stacking: stacking{Mode: "normal"}}
+	case panelRatio:
+		lo, hi := 0.0, 1.0
+		p.FieldConfig.Defaults.Min, p.FieldConfig.Defaults.Max = &lo, &hi
+	}
+	for i := 0; i+1 < len(targets); i += 2 {
+		p.Targets = append(p.Targets, target{
+			RefID:        string(rune('A' + i/2)),
+			Expr:         targets[i],
+			LegendFormat: targets[i+1],
+		})
+	}
+	b.d.Panels = append(b.d.Panels, p)
+	if b.x += 12; b.x >= 24 {
+		b.x, b.y = 0, b.y+8
+	}
+}
+
+// Escape label value for PromQL string literal.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,151 @@
+// Command dashboard generates Grafana dashboards of metrics written by Prometheus writers of metrics_writers
+// packages.
+//
+// Dashboards are ready to import and parametrized by namespace, subsystem and constant labels used in writers
+// options. Usage:
+//
+//	dashboard -pkg queue -namespace app -label env=prod > queue.json
+//	dashboard -out dashboards -time-unit s
+//
+// Dashboards of several packages writes to "dashboards" directory unless -out specified.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output directory of several dashboards if -out isn't specified.
+const defaultOut = "dashboards"
+
+type config struct {
+	pkg       string
+	namespace string
+	subsystem string
+	labels    map[string]string
+	timeUnit  string
+	out       string
+}
+
+// Build UID of package dashboard, unique for namespace and subsystem.
+func (c *config) uid(pkg string) string {
+	uid := "mw"
+	for _, s := range []string{c.namespace, c.subsystem, pkg} {
+		if len(s) > 0 {
+			uid += "-" + s
+		}
+	}
+	if len(uid) > 40 {
+		uid = uid[:40]
+	}
+	return uid
+}
+
+func (c *config) title(pkg string) string {
+	if len(c.namespace) > 0 {
+		return c.namespace + " / " + pkg
+	}
+	return pkg
+}
+
+// Flag of repeatable constant labels.
+type labelsFlag map[string]string
+
+func (f labelsFlag) String() string {
+	var buf []string
+	for k, v := range f {
+		buf = append(buf, k+"="+v)
+	}
+	return strings.Join(buf, ",")
+}
+
+func (f labelsFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || len(k) == 0 {
+		return fmt.Errorf("label must be specified as name=value")
+	}
+	f[k] = v
+	return nil
+}
+
+// Grafana units of time by writers precision.
+var timeUnits = map[string]string{
+	"ns": "ns",
+	"us": "µs",
+	"ms": "ms",
+	"s":  "s",
+}
+
+func main() {
+	c := config{labels: make(map[string]string)}
+	flag.StringVar(&c.pkg, "pkg", "all", "package to generate dashboard for: "+pkgNames()+" or all")
+	flag.StringVar(&c.namespace, "namespace", "", "namespace of metrics (see WithNamespace option)")
+	flag.StringVar(&c.subsystem, "subsystem", "", "subsystem of metrics (see WithSubsystem option)")
+	flag.Var(labelsFlag(c.labels), "label", "constant label as name=value (see WithConstLabels option), may be repeated")
+	flag.StringVar(&c.timeUnit, "time-unit", "ns", "units of timing metrics (see WithPrecision and WithSeconds options): ns, us, ms or s")
+	flag.StringVar(&c.out, "out", "", "output directory, dashboards writes to <pkg>.json files; "+
+		"if empty, single dashboard writes to stdout and several ones to "+defaultOut+" directory")
+	flag.Parse()
+
+	if err := run(&c, os.Stdout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(c *config, stdout io.Writer) error {
+	unit, ok := timeUnits[c.timeUnit]
+	if !ok {
+		return fmt.Errorf("unknown time unit %q", c.timeUnit)
+	}
+	c.timeUnit = unit
+
+	var list []*pkg
+	for _, p := range pkgs {
+		if c.pkg == "all" || c.pkg == p.name {
+			list = append(list, p)
+		}
+	}
+	switch {
+	case len(list) == 0:
+		return fmt.Errorf("unknown package %q", c.pkg)
+	case len(list) > 1 && len(c.out) == 0:
+		c.out = defaultOut
+	}
+	if len(c.out) > 0 {
+		if err := os.MkdirAll(c.out, 0755); err != nil {
+			return err
+		}
+	}
+	for _, p := range list {
+		b := newBuilder(c, p)
+		p.build(b)
+		body, err := json.MarshalIndent(b.d, "", "  ")
+		if err != nil {
+			return err
+		}
+		body = append(body, '\n')
+		if len(c.out) == 0 {
+			_, err = stdout.Write(body)
+		} else {
+			err = os.WriteFile(filepath.Join(c.out, p.name+".json"), body, 0644)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pkgNames() string {
+	names := make([]string, 0, len(pkgs))
+	for _, p := range pkgs {
+		names = append(names, p.name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("stdout", func(t *testing.T) {
+		c := config{pkg: "queue", namespace: "app", labels: map[string]string{"env": "prod"}, timeUnit: "s"}
+		var buf bytes.Buffer
+		if err := run(&c, &buf); err != nil {
+			t.Fatal(err)
+		}
+		var d dashboard
+		if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.UID != "mw-app-queue" || d.Title != "app / queue" {
+			t.Errorf("got uid %q and title %q", d.UID, d.Title)
+		}
+		var targets int
+		walkPanels(d.Panels, func(p *panel) {
+			for _, tg := range p.Targets {
+				targets++
+				if !strings.Contains(tg.Expr, "app_queue_") || !strings.Contains(tg.Expr, `env="prod"`) ||
+					!strings.Contains(tg.Expr, `queue=~"$queue"`) {
+					t.Errorf("panel %q: unexpected expression %s", p.Title, tg.Expr)
+				}
+			}
+			if p.Title == "Worker wait" && p.FieldConfig.Defaults.Unit != "s" {
+				t.Errorf("got time unit %q, expected s", p.FieldConfig.Defaults.Unit)
+			}
+		})
+		if targets == 0 {
+			t.Error("dashboard has no queries")
+		}
+	})
+	t.Run("out", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "out")
+		c := config{pkg: "all", timeUnit: "ns", out: dir}
+		var buf bytes.Buffer
+		if err := run(&c, &buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() > 0 {
+			t.Error("nothing must be written to stdout")
+		}
+		testDashboardFiles(t, dir)
+	})
+	t.Run("default out", func(t *testing.T) {
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		if err = os.Chdir(dir); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = os.Chdir(wd) })
+		c := config{pkg: "all", timeUnit: "ns"}
+		var buf bytes.Buffer
+		if err = run(&c, &buf); err != nil {
+			t.Fatal(err)
+		}
+		testDashboardFiles(t, filepath.Join(dir, defaultOut))
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, c := range []config{
+			{pkg: "unknown", timeUnit: "ns"},
+			{pkg: "queue", timeUnit: "h"},
+		} {
+			var buf bytes.Buffer
+			if err := run(&c, &buf); err == nil {
+				t.Errorf("pkg %q, time unit %q: error expected", c.pkg, c.timeUnit)
+			}
+		}
+	})
+}
+
+func TestLabelsFlag(t *testing.T) {
+	f := labelsFlag{}
+	for _, s := range []string{"env=prod", "dc=", "env=dev"} {
+		if err := f.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expect := (labelsFlag{"env": "dev", "dc": ""}); !reflect.DeepEqual(f, expect) {
+		t.Errorf("got %v, expected %v", f, expect)
+	}
+	for _, s := range []string{"env", "=prod"} {
+		if err := f.Set(s); err == nil {
+			t.Errorf("%q: error expected", s)
+		}
+	}
+}
+
+func TestConfigUID(t *testing.T) {
+	c := config{namespace: strings.Repeat("n", 50)}
+	if uid := c.uid("queue"); len(uid) != 40 {
+		t.Errorf("got uid of length %d, expected 40", len(uid))
+	}
+	c = config{subsystem: "sub"}
+	if uid := c.uid("cbyte"); uid != "mw-sub-cbyte" {
+		t.Errorf("got uid %q", uid)
+	}
+}
+
+// Check directory contains valid dashboards of all packages.
+func testDashboardFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files, expect []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+		body, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var d dashboard
+		if err = json.Unmarshal(body, &d); err != nil {
+			t.Errorf("%s: %v", e.Name(), err)
+		}
+	}
+	for _, p := range pkgs {
+		expect = append(expect, p.name+".json")
+	}
+	sort.Strings(expect)
+	if !reflect.DeepEqual(files, expect) {
+		t.Errorf("got files %v, expected %v", files, expect)
+	}
+}
+
+func walkPanels(panels []*panel, fn func(p *panel)) {
+	for _, p := range panels {
+		fn(p)
+		walkPanels(p.Panels, fn)
+	}
+}
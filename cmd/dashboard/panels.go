@@ -0,0 +1,207 @@
+package main
+
+// Description of writer package: metrics and dashboard layout.
+type pkg struct {
+	name string
+	desc string
+	// Name label and metric to collect its values from.
+	nameLabel, nameMetric string
+	build                 func(b *builder)
+}
+
+var pkgs = []*pkg{
+	{
+		name:       "queue",
+		desc:       "Metrics of github.com/koykov/queue written by metrics_writers/queue.",
+		nameLabel:  "queue",
+		nameMetric: "queue_size",
+		build:      buildQueue,
+	},
+	{
+		name:       "cbytecache",
+		desc:       "Metrics of github.com/koykov/cbytecache written by metrics_writers/cbytecache.",
+		nameLabel:  "cache",
+		nameMetric: "cbytecache_size",
+		build:      buildCbytecache,
+	},
+	{
+		name:       "batch_query",
+		desc:       "Metrics of github.com/koykov/batch_query written by metrics_writers/batch_query.",
+		nameLabel:  "query",
+		nameMetric: "batch_query_size",
+		build:      buildBatchQuery,
+	},
+	{
+		name:       "dlqdump",
+		desc:       "Metrics of github.com/koykov/dlqdump written by metrics_writers/dlqdump.",
+		nameLabel:  "queue",
+		nameMetric: "dlqdump_size_in",
+		build:      buildDlqdump,
+	},
+	{
+		name:       "laborpool",
+		desc:       "Metrics of github.com/koykov/laborpool written by metrics_writers/laborpool.",
+		nameLabel:  "pool",
+		nameMetric: "laborpool_size",
+		build:      buildLaborpool,
+	},
+	{
+		name:  "cbyte",
+		desc:  "Metrics of github.com/koykov/cbyte written by metrics_writers/cbyte.",
+		build: buildCbyte,
+	},
+	{
+		name:  "cbytebuf",
+		desc:  "Metrics of github.com/koykov/cbytebuf written by metrics_writers/cbytebuf.",
+		build: buildCbytebuf,
+	},
+}
+
+func buildQueue(b *builder) {
+	b.row("Throughput")
+	b.panel("Throughput", "Items put to and pulled from the queue per second.", "ops", panelLines,
+		"sum by (queue) ("+b.rate("queue_in")+")", "{{queue}} in",
+		"sum by (queue) ("+b.rate("queue_out")+")", "{{queue}} out")
+	b.panel("Queue size", "Actual amount of items in the queue.", "short", panelLines,
+		"sum by (queue) ("+b.series("queue_size")+")", "{{queue}}")
+	b.row("Losses")
+	b.panel("Leak rate", "Items dropped due to queue is full, by leak direction.", "ops", panelLines,
+		"sum by (queue, dir) ("+b.rate("queue_leak")+")", "{{queue}} {{dir}}")
+	b.panel("Deadline, retry and lost rate", "Items skipped due to deadline, retried and lost on force close.", "ops",
+		panelLines,
+		"sum by (queue) ("+b.rate("queue_deadline")+")", "{{queue}} deadline",
+		"sum by (queue) ("+b.rate("queue_retry")+")", "{{queue}} retry",
+		"sum by (queue) ("+b.rate("queue_lost")+")", "{{queue}} lost")
+	b.row("Workers")
+	b.panel("Worker states", "Amount of active, sleeping and idle (stopped) workers.", "short", panelStacked,
+		"sum by (queue) ("+b.series("queue_workers_active")+")", "{{queue}} active",
+		"sum by (queue) ("+b.series("queue_workers_sleep")+")", "{{queue}} sleep",
+		"sum by (queue) ("+b.series("queue_workers_idle")+")", "{{queue}} idle")
+	b.panel("Worker wait", "Quantiles of delay of delayed execution.", b.conf.timeUnit, panelLines,
+		b.quantile("0.5", "queue_wait", "queue"), "{{queue}} p50",
+		b.quantile("0.99", "queue_wait", "queue"), "{{queue}} p99")
+	b.row("Sub-queues")
+	b.panel("Sub-queue size", "Actual amount of items in sub-queues.", "short", panelStacked,
+		"sum by (queue, subq) ("+b.series("queue_subq_size")+")", "{{queue}} {{subq}}")
+	b.panel("Sub-queue throughput", "Items put to and pulled from sub-queues per second.", "ops", panelLines,
+		"sum by (queue, subq) ("+b.rate("queue_subq_in")+")", "{{queue}} {{subq}} in",
+		"sum by (queue, subq) ("+b.rate("queue_subq_out")+")", "{{queue}} {{subq}} out")
+	b.panel("Sub-queue leak rate", "Items dropped due to sub-queue is full.", "ops", panelLines,
+		"sum by (queue, subq) ("+b.rate("queue_subq_leak")+")", "{{queue}} {{subq}}")
+	b.overflow("queue")
+}
+
+func buildCbytecache(b *builder) {
+	b.row("Hits")
+	b.panel("Hit ratio per bucket", "Share of hits among hits and misses.", "percentunit", panelRatio,
+		"sum by (cache, bucket) ("+b.rate("cbytecache_io", `op="hit"`)+") / sum by (cache, bucket) ("+
+			b.rate("cbytecache_io", `op=~"hit|miss"`)+")", "{{cache}} {{bucket}}")
+	b.panel("IO rate", "Cache operations per second.", "ops", panelLines,
+		"sum by (cache, op) ("+b.rate("cbytecache_io")+")", "{{cache}} {{op}}")
+	b.panel("IO speed", "Quantiles of duration of set (write) and hit (read) operations.", b.conf.timeUnit,
+		panelLines,
+		b.quantile("0.5", "cbytecache_io_speed", "cache, op"), "{{cache}} {{op}} p50",
+		b.quantile("0.99", "cbytecache_io_speed", "cache, op"), "{{cache}} {{op}} p99")
+	b.panel("Problems rate", "Expired, corrupted, collided and not fitted entries per second.", "ops", panelLines,
+		"sum by (cache, op) ("+b.rate("cbytecache_io", `op=~"expire|corrupt|collision|no space"`)+")",
+		"{{cache}} {{op}}")
+	b.row("Memory")
+	b.panel("Memory", "Total, used and free memory of cache.", "bytes", panelLines,
+		"sum by (cache, type) ("+b.series("cbytecache_size", `type=~"total|used|free"`)+")", "{{cache}} {{type}}")
+	b.panel("Entries", "Amount of entries and deleted entries. Note, these are counts, not bytes.", "short",
+		panelLines,
+		"sum by (cache, type) ("+b.series("cbytecache_size", `type=~"entry_total|entry_delete"`)+")",
+		"{{cache}} {{type}}")
+	b.panel("Arena occupancy per bucket", "Share of used arenas.", "percentunit", panelRatio,
+		"sum by (cache, bucket) ("+b.series("cbytecache_arena", `type="used"`)+") / sum by (cache, bucket) ("+
+			b.series("cbytecache_arena", `type="total"`)+")", "{{cache}} {{bucket}}")
+	b.panel("Arenas", "Amount of used and free arenas.", "short", panelStacked,
+		"sum by (cache, type) ("+b.series("cbytecache_arena", `type=~"used|free"`)+")", "{{cache}} {{type}}")
+	b.panel("Arena IO rate", "Arena operations per second.", "ops", panelLines,
+		"sum by (cache, op) ("+b.rate("cbytecache_arena_io")+")", "{{cache}} {{op}}")
+	b.panel("Dump rate", "Entries dumped and loaded per second.", "ops", panelLines,
+		"sum by (cache, op) ("+b.rate("cbytecache_dump")+")", "{{cache}} {{op}}")
+	b.overflow("cbytecache")
+}
+
+func buildBatchQuery(b *builder) {
+	b.row("Throughput")
+	b.panel("Throughput", "Single keys and batches processed per second.", "ops", panelLines,
+		"sum by (query, entity) ("+b.rate("batch_query_io", `type="in"`)+")", "{{query}} {{entity}}")
+	b.panel("Outcomes", "Results of processing per second.", "ops", panelLines,
+		"sum by (query, entity, type) ("+b.rate("batch_query_io", `type!="in"`)+")", "{{query}} {{entity}} {{type}}")
+	b.panel("Success ratio", "Share of successfully processed entities.", "percentunit", panelRatio,
+		"sum by (query, entity) ("+b.rate("batch_query_io", `type="success"`)+") / sum by (query, entity) ("+
+			b.rate("batch_query_io", `type="in"`)+")", "{{query}} {{entity}}")
+	b.panel("Timing", "Quantiles of processing duration.", b.conf.timeUnit, panelLines,
+		b.quantile("0.5", "batch_query_timing", "query, entity"), "{{query}} {{entity}} p50",
+		b.quantile("0.99", "batch_query_timing", "query, entity"), "{{query}} {{entity}} p99")
+	b.row("Buffer")
+	b.panel("In flight", "Amount of single keys, batches and buffered keys in flight.", "short", panelLines,
+		"sum by (query, entity) ("+b.series("batch_query_size")+")", "{{query}} {{entity}}")
+	b.panel("Buffer flushes", "Buffer flushes per second by reason.", "ops", panelLines,
+		"sum by (query, reason) ("+b.rate("batch_query_bufio")+")", "{{query}} {{reason}}")
+	b.overflow("batch_query")
+}
+
+func buildDlqdump(b *builder) {
+	b.row("Backlog")
+	b.panel("Backlog", "Items dumped, but not restored yet.", "short", panelLines,
+		"sum by (queue) ("+b.series("dlqdump_size_in")+") - sum by (queue) ("+b.series("dlqdump_size_out")+")",
+		"{{queue}}")
+	b.panel("Backlog bytes", "Bytes dumped, but not restored yet.", "bytes", panelLines,
+		"sum by (queue) ("+b.series("dlqdump_bytes_in")+") - sum by (queue) ("+b.series("dlqdump_bytes_out")+")",
+		"{{queue}}")
+	b.row("Throughput")
+	b.panel("Dump and restore rate", "Items dumped and restored per second.", "ops", panelLines,
+		"sum by (queue) ("+b.rate("dlqdump_size_in")+")", "{{queue}} dump",
+		"sum by (queue) ("+b.rate("dlqdump_size_out")+")", "{{queue}} restore")
+	b.panel("Flush rate", "Bytes flushed per second by reason.", "Bps", panelLines,
+		"sum by (queue, reason) ("+b.rate("dlqdump_bytes_flush")+")", "{{queue}} {{reason}}")
+	b.panel("Failures", "Errors per second by reason.", "ops", panelLines,
+		"sum by (queue, reason) ("+b.rate("dlqdump_fail")+")", "{{queue}} {{reason}}")
+	b.overflow("dlqdump")
+}
+
+func buildLaborpool(b *builder) {
+	b.row("Workers")
+	b.panel("Idle workers", "Workers waiting for hire.", "short", panelLines,
+		"sum by (pool) ("+b.series("laborpool_size")+")", "{{pool}}")
+	b.panel("Hire, fire and retire rate", "Workers hired, fired and retired per second.", "ops", panelLines,
+		"sum by (pool) ("+b.rate("laborpool_hire")+")", "{{pool}} hire",
+		"sum by (pool) ("+b.rate("laborpool_fire")+")", "{{pool}} fire",
+		"sum by (pool) ("+b.rate("laborpool_retire")+")", "{{pool}} retire")
+}
+
+func buildCbyte(b *builder) {
+	b.row("Memory")
+	b.panel("Managed memory", "Memory allocated by cbyte.", "bytes", panelLines,
+		"sum("+b.series("cbyte_mem")+")", "memory")
+	b.panel("Live allocations", "Allocations not freed yet.", "short", panelLines,
+		"sum("+b.series("cbyte_alloc")+") - sum("+b.series("cbyte_free")+")", "allocations")
+	b.panel("Calls rate", "Alloc, grow and free calls per second.", "ops", panelLines,
+		"sum("+b.rate("cbyte_alloc")+")", "alloc",
+		"sum("+b.rate("cbyte_grow")+")", "grow",
+		"sum("+b.rate("cbyte_free")+")", "free")
+}
+
+func buildCbytebuf(b *builder) {
+	b.row("Pool")
+	b.panel("Pool size", "Amount of buffers in pool.", "short", panelLines,
+		"sum("+b.series("cbytebuf_pool")+")", "buffers")
+	b.panel("Pool memory", "Capacity of buffers in pool.", "bytes", panelLines,
+		"sum("+b.series("cbytebuf_pool_mem")+")", "memory")
+	b.panel("Buffers in use", "Buffers acquired, but not released yet.", "short", panelLines,
+		"sum("+b.series("cbytebuf_acq")+") - sum("+b.series("cbytebuf_rel")+")", "buffers")
+	b.panel("Acquire and release rate", "Pool calls per second.", "ops", panelLines,
+		"sum("+b.rate("cbytebuf_acq")+")", "acquire",
+		"sum("+b.rate("cbytebuf_rel")+")", "release")
+}
+
+// Add panel of label cardinality overflow (see WithLabelLimit option).
+func (b *builder) overflow(prefix string) {
+	b.row("Cardinality")
+	b.panel("Label overflow", "Events with label values folded into __other__ due to cardinality limit.", "ops",
+		panelLines,
+		"sum by ("+b.nameLabel+", label) ("+b.rate(prefix+"_label_overflow")+")", "{{"+b.nameLabel+"}} {{label}}")
+}
@@ -0,0 +1,3 @@
+module github.com/koykov/metrics_writers/cmd
+
+go 1.22.0
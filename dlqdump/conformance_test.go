@@ -0,0 +1,531 @@
+package dlqdump
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koykov/metrics_writers/dlqdump/promtext"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var conformanceSeed = flag.Uint64("conformance.seed", 0, "seed of randomized conformance replay, 0 means default seeds")
+
+var conformanceSeeds = []uint64{1, 2, 3}
+
+// Writer under check. Counter reads counters of the writer the same way as RecorderMetrics.Counter, events reads
+// amount of records written by log writers. Snapshot takes state of the writer before counters read. Final flushes the
+// writer after replay and returns reader of its counters for the last check. All are optional.
+type conformanceTarget struct {
+	w        MetricsWriter
+	counter  func(family string, labels ...string) int64
+	events   func() int64
+	snapshot func()
+	final    func() func(family string, labels ...string) int64
+}
+
+func TestConformance(t *testing.T) {
+	seeds := conformanceSeeds
+	if *conformanceSeed != 0 {
+		seeds = []uint64{*conformanceSeed}
+	}
+	// Log and summary writers use standard logger.
+	prev := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(prev)
+
+	for _, tc := range []struct {
+		name string
+		new  func(t *testing.T) conformanceTarget
+	}{
+		{"prometheus", func(*testing.T) conformanceTarget {
+			m := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: m, counter: m.counter}
+		}},
+		{"collector", func(t *testing.T) conformanceTarget {
+			m, err := NewCollectorMetrics("test", WithRegisterer(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, counter: m.counter}
+		}},
+		{"expvar", func(t *testing.T) conformanceTarget {
+			// Expvar variables are global, so name must be unique.
+			m, err := NewExpvarMetrics("conformance_" + strings.ReplaceAll(t.Name(), "/", "_"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, counter: m.counter}
+		}},
+		{"recorder", func(*testing.T) conformanceTarget {
+			m := NewRecorderMetrics("test")
+			return conformanceTarget{w: m, counter: m.Counter}
+		}},
+		{"otel", func(*testing.T) conformanceTarget {
+			g := &otelValues{r: sdkmetric.NewManualReader(), kv: []string{"queue", "test"}}
+			m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(g.r))))
+			return conformanceTarget{w: m, counter: g.value, snapshot: g.snapshot}
+		}},
+		{"statsd", func(t *testing.T) conformanceTarget {
+			a := newConformanceAgent(t)
+			m, err := NewStatsDMetrics("test", a.conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = m.Close() })
+			// StatsD counters are deltas, so the agent sums them and checks after the replay only.
+			return conformanceTarget{w: m, final: func() func(family string, labels ...string) int64 {
+				_ = m.Close()
+				a.wait()
+				return a.counter
+			}}
+		}},
+		{"influx", func(t *testing.T) conformanceTarget {
+			m := NewInfluxMetrics("test", io.Discard, WithInfluxInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, counter: m.counter}
+		}},
+		{"graphite", func(t *testing.T) conformanceTarget {
+			// Nothing listens the address, so series stay aggregated.
+			m := NewGraphiteMetrics("test", "127.0.0.1:1", WithGraphiteInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, counter: m.counter}
+		}},
+		{"log", func(t *testing.T) conformanceTarget {
+			var lw conformanceLines
+			log.SetOutput(&lw)
+			t.Cleanup(func() { log.SetOutput(io.Discard) })
+			return conformanceTarget{w: NewLogMetrics("test"), events: lw.count}
+		}},
+		{"slog", func(*testing.T) conformanceTarget {
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := NewSlogMetrics("test", slog.New(h))
+			return conformanceTarget{w: m, events: h.count}
+		}},
+		{"summary", func(t *testing.T) conformanceTarget {
+			m := NewSummaryLogMetrics("test", time.Hour)
+			t.Cleanup(m.Stop)
+			return conformanceTarget{w: m, counter: m.counter}
+		}},
+		{"rewrite", func(*testing.T) conformanceTarget {
+			rec := NewRecorderMetrics("test")
+			m := NewRewriteMetrics(rec, WithRewriteRules(RewritePrefix("tmp_", "tmp")), WithRewriteSanitize())
+			return conformanceTarget{w: m, counter: rec.Counter}
+		}},
+		{"multi", func(*testing.T) conformanceTarget {
+			pm := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: NewMultiMetrics(NewRecorderMetrics("test"), pm), counter: pm.counter}
+		}},
+		{"promtext", func(*testing.T) conformanceTarget {
+			g := &promtextValues{reg: promtext.NewRegistry(), kv: []string{"queue", "test"}}
+			m := promtext.NewMetrics("test", promtext.WithRegistry(g.reg))
+			return conformanceTarget{w: m, counter: g.value, snapshot: g.snapshot}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, seed := range seeds {
+				t.Run(strconv.FormatUint(seed, 10), func(t *testing.T) {
+					checkConformance(t, tc.new(t), seed)
+				})
+			}
+		})
+	}
+}
+
+const conformanceSteps = 10000
+
+var conformanceReasons = []string{"size", "interval", "close"}
+
+// Replay scripted and randomized (using seed) event sequences derived from upstream dlqdump state
+// machine into the writer and check counters invariants after each event:
+//   - restored items and bytes don't exceed dumped ones;
+//   - counters values are equal to the state of the replayed dump.
+//
+// Counters checks on shadow RecorderMetrics receives the same events and on the writer if target has counter reader.
+func checkConformance(t *testing.T, tg conformanceTarget, seed uint64) {
+	t.Helper()
+	c := &conformance{
+		conformanceTarget: tg,
+		rec:               NewRecorderMetrics("conformance"),
+		rnd:               rand.New(rand.NewPCG(seed, seed)),
+		flushed:           make(map[string]int64, len(conformanceReasons)),
+	}
+	c.script()
+	for i := 0; i < conformanceSteps && c.err == nil; i++ {
+		c.random()
+		c.check()
+	}
+	if c.err == nil && c.final != nil {
+		c.event = "final"
+		c.checkValues("writer", c.final())
+	}
+	if c.err != nil {
+		t.Errorf("seed %d: %s", seed, c.err)
+	}
+}
+
+// State of replayed dump.
+type conformance struct {
+	conformanceTarget
+	rec *RecorderMetrics
+	rnd *rand.Rand
+	err error
+	// Last replayed event and amount of replayed events.
+	event string
+	n     int64
+
+	// Sizes of flushed items waiting for restore.
+	dumped []int
+	// Counters.
+	sizeIn, sizeOut, bytesIn, bytesOut, fail int64
+	flushed                                  map[string]int64
+}
+
+// Replay scripted sequences.
+func (c *conformance) script() {
+	steps := []func(){
+		// Dump items, flush and restore them.
+		c.dump, c.dump, c.flush, c.restore, c.restore,
+		// Restore from empty dump fails.
+		c.restore,
+	}
+	for i := 0; i < len(steps) && c.err == nil; i++ {
+		steps[i]()
+		c.check()
+	}
+}
+
+// Replay random valid event.
+func (c *conformance) random() {
+	switch c.rnd.IntN(4) {
+	case 0, 1:
+		c.dump()
+	case 2:
+		c.flush()
+	default:
+		c.restore()
+	}
+}
+
+func (c *conformance) dump() {
+	size := 1 + c.rnd.IntN(1024)
+	c.dumped = append(c.dumped, size)
+	c.sizeIn++
+	c.bytesIn += int64(size)
+	c.emit("Dump", func(w MetricsWriter) { w.Dump(size) })
+}
+
+func (c *conformance) flush() {
+	var size int
+	for i := 0; i < len(c.dumped); i++ {
+		size += c.dumped[i]
+	}
+	reason := conformanceReasons[c.rnd.IntN(len(conformanceReasons))]
+	c.flushed[reason] += int64(size)
+	c.emit("Flush", func(w MetricsWriter) { w.Flush(reason, size) })
+}
+
+func (c *conformance) restore() {
+	if len(c.dumped) == 0 {
+		c.fail++
+		c.emit("Fail", func(w MetricsWriter) { w.Fail("eof") })
+		return
+	}
+	size := c.dumped[0]
+	c.dumped = c.dumped[1:]
+	c.sizeOut++
+	c.bytesOut += int64(size)
+	c.emit("Restore", func(w MetricsWriter) { w.Restore(size) })
+}
+
+// Pass event to shadow recorder and to the writer.
+func (c *conformance) emit(event string, fn func(w MetricsWriter)) {
+	if c.err != nil {
+		return
+	}
+	c.event = event
+	c.n++
+	fn(c.rec)
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("event %s: writer panic: %v", event, r)
+		}
+	}()
+	fn(c.w)
+}
+
+func (c *conformance) check() {
+	if c.err != nil {
+		return
+	}
+	c.checkValues("recorder", c.rec.Counter)
+	if c.snapshot != nil {
+		c.snapshot()
+	}
+	if c.conformanceTarget.counter != nil {
+		c.checkValues("writer", c.conformanceTarget.counter)
+	}
+	if c.events != nil && c.err == nil {
+		if n := c.events(); n != c.n {
+			c.err = fmt.Errorf("after %s: writer: %d records written, expected %d", c.event, n, c.n)
+		}
+	}
+}
+
+func (c *conformance) expect(src string, counter func(family string, labels ...string) int64, family string,
+	value int64, labels ...string) {
+	if c.err != nil {
+		return
+	}
+	switch v := counter(family, labels...); {
+	case v < 0:
+		c.err = fmt.Errorf("after %s: %s: counter %s%v is negative: %d", c.event, src, family, labels, v)
+	case v != value:
+		c.err = fmt.Errorf("after %s: %s: counter %s%v drifted: %d, expected %d", c.event, src, family, labels, v, value)
+	}
+}
+
+func (c *conformance) checkValues(src string, counter func(family string, labels ...string) int64) {
+	c.expect(src, counter, "dlqdump_size_in", c.sizeIn)
+	c.expect(src, counter, "dlqdump_size_out", c.sizeOut)
+	c.expect(src, counter, "dlqdump_bytes_in", c.bytesIn)
+	c.expect(src, counter, "dlqdump_bytes_out", c.bytesOut)
+	c.expect(src, counter, "dlqdump_fail", c.fail, "reason", "eof")
+	for _, reason := range conformanceReasons {
+		c.expect(src, counter, "dlqdump_bytes_flush", c.flushed[reason], "reason", reason)
+	}
+	if c.err != nil {
+		return
+	}
+	if in, out := counter("dlqdump_size_in"), counter("dlqdump_size_out"); out > in {
+		c.err = fmt.Errorf("after %s: %s: restored items %d exceed dumped %d", c.event, src, out, in)
+		return
+	}
+	if in, out := counter("dlqdump_bytes_in"), counter("dlqdump_bytes_out"); out > in {
+		c.err = fmt.Errorf("after %s: %s: restored bytes %d exceed dumped %d", c.event, src, out, in)
+	}
+}
+
+// Get value of counter. Labels specifies the same way as in RecorderMetrics.Counter method.
+func (m PrometheusMetrics) counter(family string, labels ...string) int64 {
+	var vec *prometheus.CounterVec
+	switch family {
+	case "dlqdump_size_in":
+		vec = m.vec.sizeIncome
+	case "dlqdump_size_out":
+		vec = m.vec.sizeOutcome
+	case "dlqdump_bytes_in":
+		vec = m.vec.bytesIncome
+	case "dlqdump_bytes_out":
+		vec = m.vec.bytesOutcome
+	case "dlqdump_bytes_flush":
+		vec = m.vec.bytesFlush
+	case "dlqdump_fail":
+		vec = m.vec.fail
+	default:
+		return 0
+	}
+	lv := []string{m.name}
+	for i := 1; i < len(labels); i += 2 {
+		lv = append(lv, labels[i])
+	}
+	return promCounterValue(vec.WithLabelValues(lv...))
+}
+
+// Get value of counter. Labels specifies the same way as in RecorderMetrics.Counter method.
+func (m *CollectorMetrics) counter(family string, labels ...string) int64 {
+	switch family {
+	case "dlqdump_size_in":
+		return m.sizeIncome.Load()
+	case "dlqdump_size_out":
+		return m.sizeOutcome.Load()
+	case "dlqdump_bytes_in":
+		return m.bytesIncome.Load()
+	case "dlqdump_bytes_out":
+		return m.bytesOutcome.Load()
+	case "dlqdump_bytes_flush":
+		if len(labels) > 1 {
+			return m.flush.get(labels[1]).Load()
+		}
+	case "dlqdump_fail":
+		if len(labels) > 1 {
+			return m.fail.get(labels[1]).Load()
+		}
+	}
+	return 0
+}
+
+func promCounterValue(c prometheus.Counter) int64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		return 0
+	}
+	return int64(math.Round(m.GetCounter().GetValue()))
+}
+
+// Get value of counter. Labels specifies the same way as in RecorderMetrics.Counter method.
+func (m aggrMetrics) counter(family string, labels ...string) int64 {
+	if x := m.s.find(family, append([]string{"queue", m.name}, labels...)...); x != nil {
+		return x.load()
+	}
+	return 0
+}
+
+// Counts lines written by standard logger.
+type conformanceLines struct {
+	n int64
+}
+
+func (w *conformanceLines) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.n, int64(bytes.Count(p, []byte{'\n'})))
+	return len(p), nil
+}
+
+func (w *conformanceLines) count() int64 {
+	return atomic.LoadInt64(&w.n)
+}
+
+// Local StatsD agent sums received counters.
+type conformanceAgent struct {
+	conn net.PacketConn
+	mux  sync.Mutex
+	sum  map[string]int64
+	last time.Time
+}
+
+func newConformanceAgent(t *testing.T) *conformanceAgent {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replay sends bursts of packets, default buffer may drop some of them.
+	_ = conn.(*net.UDPConn).SetReadBuffer(16 << 20)
+	a := &conformanceAgent{conn: conn, sum: make(map[string]int64), last: time.Now()}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			a.mux.Lock()
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				name, value, ok := strings.Cut(strings.TrimSuffix(line, "|c"), ":")
+				if v, err := strconv.ParseInt(value, 10, 64); ok && err == nil {
+					a.sum[name] += v
+				}
+			}
+			a.last = time.Now()
+			a.mux.Unlock()
+		}
+	}()
+	return a
+}
+
+// Wait till packets stop coming.
+func (a *conformanceAgent) wait() {
+	for {
+		time.Sleep(50 * time.Millisecond)
+		a.mux.Lock()
+		idle := time.Since(a.last) > 200*time.Millisecond
+		a.mux.Unlock()
+		if idle {
+			return
+		}
+	}
+}
+
+// Get value of counter. Labels specifies the same way as in RecorderMetrics.Counter method.
+func (a *conformanceAgent) counter(family string, labels ...string) int64 {
+	path := family + ".test"
+	for i := 1; i < len(labels); i += 2 {
+		path += "." + labels[i]
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.sum[path]
+}
+
+// Values of OTel writer collected by manual reader. Labels kv appends to labels of every series.
+type otelValues struct {
+	r  *sdkmetric.ManualReader
+	kv []string
+	rm metricdata.ResourceMetrics
+}
+
+func (g *otelValues) snapshot() {
+	g.rm = metricdata.ResourceMetrics{}
+	_ = g.r.Collect(context.Background(), &g.rm)
+}
+
+func (g *otelValues) value(family string, labels ...string) int64 {
+	data, _ := otelMetric(g.rm, family).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, append(g.kv[:len(g.kv):len(g.kv)], labels...)...) {
+			return p.Value
+		}
+	}
+	return 0
+}
+
+// Values of promtext writer parsed from text format rendered by registry. Labels kv prepends to labels of every
+// series.
+type promtextValues struct {
+	reg    *promtext.Registry
+	kv     []string
+	series map[string]int64
+}
+
+func (g *promtextValues) snapshot() {
+	var buf bytes.Buffer
+	_ = g.reg.WriteText(&buf)
+	g.series = make(map[string]int64)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if i := strings.LastIndexByte(line, ' '); i > 0 && !strings.HasPrefix(line, "#") {
+			f, _ := strconv.ParseFloat(line[i+1:], 64)
+			g.series[line[:i]] = int64(f)
+		}
+	}
+}
+
+func (g *promtextValues) value(family string, labels ...string) int64 {
+	// Text format sorts labels by name.
+	pairs := make([][2]string, 0, len(g.kv)/2+len(labels)/2)
+	for _, kv := range [][]string{g.kv, labels} {
+		for i := 0; i+1 < len(kv); i += 2 {
+			pairs = append(pairs, [2]string{kv[i], kv[i+1]})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	key := family
+	for i := range pairs {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		key += sep + pairs[i][0] + `="` + pairs[i][1] + `"`
+	}
+	if len(pairs) > 0 {
+		key += "}"
+	}
+	return g.series[key]
+}
@@ -0,0 +1,56 @@
+package dlqdump
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics("expvar_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Dump(100)
+	m.Dump(20)
+	m.Flush("timer", 100)
+	m.Restore(50)
+	m.Fail("eof")
+
+	var root map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("dlqdump").String()), &root); err != nil {
+		t.Fatal(err)
+	}
+	got := root["expvar_test"]
+	const expected = `{
+		"dlqdump_bytes_flush": {
+			"timer": 100
+		},
+		"dlqdump_bytes_in": 120,
+		"dlqdump_bytes_out": 50,
+		"dlqdump_fail": {
+			"eof": 1
+		},
+		"dlqdump_size_in": 2,
+		"dlqdump_size_out": 1
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "dlqdump_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name, "test"); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
@@ -0,0 +1,336 @@
+package dlqdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	graphiteDefaultInterval   = 10 * time.Second
+	graphiteDefaultTimeout    = 5 * time.Second
+	graphiteDefaultBackoffMin = 100 * time.Millisecond
+	graphiteDefaultBackoffMax = 30 * time.Second
+)
+
+// GraphiteMetrics is Graphite implementation of dlqdump.MetricsWriter.
+//
+// Events aggregates in memory and periodically flushes to carbon over TCP using plaintext (default) or pickle
+// protocol. Counters sends as increments since previous successful flush, gauges sends as is. If connection fails,
+// writer reconnects with exponential backoff and keeps aggregating until the next successful flush.
+type GraphiteMetrics struct {
+	aggrMetrics
+	addr string
+	conf graphiteConfig
+
+	mux     sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+	buf     bytes.Buffer
+	// Counters/timers values sent last time.
+	last map[*aggrSeries]graphiteLast
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+type graphiteLast struct {
+	value, count int64
+	sum          time.Duration
+}
+
+type graphitePoint struct {
+	path  string
+	value float64
+}
+
+// GraphiteOption describes GraphiteMetrics option.
+type GraphiteOption func(*graphiteConfig)
+
+type graphiteConfig struct {
+	prefix     string
+	interval   time.Duration
+	timeout    time.Duration
+	pickle     bool
+	bmin, bmax time.Duration
+}
+
+// WithGraphitePrefix sets prefix of all metrics paths.
+func WithGraphitePrefix(prefix string) GraphiteOption {
+	return func(c *graphiteConfig) {
+		c.prefix = strings.TrimSuffix(prefix, ".")
+	}
+}
+
+// WithGraphiteInterval sets flush interval.
+func WithGraphiteInterval(interval time.Duration) GraphiteOption {
+	return func(c *graphiteConfig) {
+		c.interval = interval
+	}
+}
+
+// WithGraphiteTimeout sets dial and write timeout.
+func WithGraphiteTimeout(timeout time.Duration) GraphiteOption {
+	return func(c *graphiteConfig) {
+		c.timeout = timeout
+	}
+}
+
+// WithGraphitePickle enables pickle protocol instead of plaintext.
+func WithGraphitePickle() GraphiteOption {
+	return func(c *graphiteConfig) {
+		c.pickle = true
+	}
+}
+
+// WithGraphiteBackoff sets minimum and maximum delays between reconnect attempts.
+func WithGraphiteBackoff(minDelay, maxDelay time.Duration) GraphiteOption {
+	return func(c *graphiteConfig) {
+		c.bmin, c.bmax = minDelay, maxDelay
+	}
+}
+
+var _ = NewGraphiteMetrics
+
+// NewGraphiteMetrics makes new writer sends metrics to carbon listening given TCP address.
+//
+// Connection establishes lazily on the first flush.
+func NewGraphiteMetrics(name, addr string, opts ...GraphiteOption) *GraphiteMetrics {
+	m := &GraphiteMetrics{
+		aggrMetrics: aggrMetrics{
+			name: name,
+			s:    newAggrStore(),
+		},
+		addr: addr,
+		conf: graphiteConfig{
+			interval: graphiteDefaultInterval,
+			timeout:  graphiteDefaultTimeout,
+			bmin:     graphiteDefaultBackoffMin,
+			bmax:     graphiteDefaultBackoffMax,
+		},
+		last: make(map[*aggrSeries]graphiteLast),
+		done: make(chan struct{}),
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	if m.conf.interval <= 0 {
+		m.conf.interval = graphiteDefaultInterval
+	}
+	if m.conf.bmin <= 0 {
+		m.conf.bmin = graphiteDefaultBackoffMin
+	}
+	if m.conf.bmax < m.conf.bmin {
+		m.conf.bmax = m.conf.bmin
+	}
+	m.wg.Add(1)
+	go m.loop()
+	return m
+}
+
+// Sync sends actual state of all series.
+//
+// If connection isn't available (or reconnect delay isn't expired yet) data stays aggregated till the next flush.
+func (m *GraphiteMetrics) Sync() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	now := time.Now()
+	if m.conn == nil {
+		if now.Before(m.retryAt) {
+			return nil
+		}
+		conn, err := net.DialTimeout("tcp", m.addr, m.conf.timeout)
+		if err != nil {
+			m.fail(now)
+			return err
+		}
+		m.conn = conn
+	}
+
+	var (
+		points []graphitePoint
+		peaks  []aggrPeak
+		last   = make(map[*aggrSeries]graphiteLast)
+	)
+	m.s.each(func(x *aggrSeries) {
+		path := m.path(x)
+		prev := m.last[x]
+		switch x.kind {
+		case aggrCounter:
+			value := x.load()
+			points = append(points, graphitePoint{path, float64(value - prev.value)})
+			last[x] = graphiteLast{value: value}
+		case aggrGauge:
+			points = append(points, graphitePoint{path, float64(x.load())})
+		case aggrTimer:
+			count, sum, peak := x.loadTimer()
+			var mean float64
+			if dc := count - prev.count; dc > 0 {
+				mean = (sum - prev.sum).Seconds() / float64(dc)
+			}
+			points = append(points,
+				graphitePoint{path + ".count", float64(count - prev.count)},
+				graphitePoint{path + ".mean", mean},
+				graphitePoint{path + ".max", peak.Seconds()},
+			)
+			peaks = append(peaks, aggrPeak{x, peak})
+			last[x] = graphiteLast{count: count, sum: sum}
+		}
+	})
+	if len(points) == 0 {
+		return nil
+	}
+
+	m.buf.Reset()
+	if m.conf.pickle {
+		graphitePickle(&m.buf, points, now.Unix())
+	} else {
+		graphitePlain(&m.buf, points, now.Unix())
+	}
+	_ = m.conn.SetWriteDeadline(now.Add(m.conf.timeout))
+	if _, err := m.conn.Write(m.buf.Bytes()); err != nil {
+		_ = m.conn.Close()
+		m.conn = nil
+		m.fail(now)
+		return err
+	}
+	m.last = last
+	for _, p := range peaks {
+		p.x.resetPeak(p.peak)
+	}
+	m.backoff = 0
+	return nil
+}
+
+// Close stops background flushing, sends the rest of data and closes connection.
+func (m *GraphiteMetrics) Close() (err error) {
+	m.once.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		m.mux.Lock()
+		m.retryAt = time.Time{}
+		m.mux.Unlock()
+		err = m.Sync()
+		m.mux.Lock()
+		if m.conn != nil {
+			if err1 := m.conn.Close(); err == nil {
+				err = err1
+			}
+			m.conn = nil
+		}
+		m.mux.Unlock()
+	})
+	return
+}
+
+// Register failed attempt and schedule next one.
+func (m *GraphiteMetrics) fail(now time.Time) {
+	if m.backoff == 0 {
+		m.backoff = m.conf.bmin
+	} else if m.backoff *= 2; m.backoff > m.conf.bmax {
+		m.backoff = m.conf.bmax
+	}
+	m.retryAt = now.Add(m.backoff)
+}
+
+func (m *GraphiteMetrics) loop() {
+	defer m.wg.Done()
+	t := time.NewTicker(m.conf.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			_ = m.Sync()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *GraphiteMetrics) path(x *aggrSeries) string {
+	var buf strings.Builder
+	if len(m.conf.prefix) > 0 {
+		buf.WriteString(m.conf.prefix)
+		buf.WriteByte('.')
+	}
+	for i, seg := range graphitePath(x) {
+		if i > 0 {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(seg)
+	}
+	return buf.String()
+}
+
+func graphitePlain(buf *bytes.Buffer, points []graphitePoint, ts int64) {
+	for _, p := range points {
+		buf.WriteString(p.path)
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(p.value, 'f', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(ts, 10))
+		buf.WriteByte('\n')
+	}
+}
+
+// Encode points as pickled list of (path, (timestamp, value)) tuples prefixed with payload length.
+func graphitePickle(buf *bytes.Buffer, points []graphitePoint, ts int64) {
+	var b [8]byte
+	buf.Write(b[:4]) // reserve space for header
+	buf.WriteString("\x80\x02]q\x00(")
+	for _, p := range points {
+		buf.WriteByte('X')
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(p.path)))
+		buf.Write(b[:4])
+		buf.WriteString(p.path)
+		buf.WriteByte('G')
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(float64(ts)))
+		buf.Write(b[:])
+		buf.WriteByte('G')
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(p.value))
+		buf.Write(b[:])
+		buf.WriteString("\x86\x86")
+	}
+	buf.WriteString("e.")
+	binary.BigEndian.PutUint32(buf.Bytes()[:4], uint32(buf.Len()-4))
+}
+
+// Make label value safe to use as path segment.
+func graphiteSanitize(s string) string {
+	if len(s) == 0 {
+		return "_"
+	}
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' {
+			continue
+		}
+		if buf == nil {
+			buf = []byte(s)
+		}
+		buf[i] = '_'
+	}
+	if buf == nil {
+		return s
+	}
+	return string(buf)
+}
+
+// Build path segments of series, eg dlqdump.<name>.bytes.flush.<reason>.
+func graphitePath(x *aggrSeries) []string {
+	// First label is always queue name.
+	r := []string{"dlqdump", graphiteSanitize(x.labels[1])}
+	r = append(r, strings.Split(strings.TrimPrefix(x.family, "dlqdump_"), "_")...)
+	if len(x.labels) > 2 {
+		r = append(r, graphiteSanitize(x.labels[3]))
+	}
+	return r
+}
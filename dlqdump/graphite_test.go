@@ -0,0 +1,141 @@
+package dlqdump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Local carbon stand-in: accepts connections and collects received plaintext lines or pickle payloads.
+type graphiteTestServer struct {
+	ln   net.Listener
+	recv chan string
+}
+
+func newGraphiteTestServer(t *testing.T, addr string, pickle bool) *graphiteTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &graphiteTestServer{ln: ln, recv: make(chan string, 1024)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, pickle)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *graphiteTestServer) serve(conn net.Conn, pickle bool) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		if pickle {
+			var hdr [4]byte
+			if _, err := io.ReadFull(r, hdr[:]); err != nil {
+				return
+			}
+			payload := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.recv <- string(payload)
+			continue
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		s.recv <- strings.TrimSuffix(line, "\n")
+	}
+}
+
+// Wait for lines with given paths and return their values.
+func (s *graphiteTestServer) expect(t *testing.T, paths ...string) map[string]string {
+	t.Helper()
+	r := make(map[string]string)
+	timeout := time.After(5 * time.Second)
+	for len(r) < len(paths) {
+		select {
+		case line := <-s.recv:
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				t.Fatalf("malformed line %q", line)
+			}
+			for _, p := range paths {
+				if fields[0] == p {
+					r[p] = fields[1]
+				}
+			}
+		case <-timeout:
+			t.Fatalf("paths not received, got %v of %v", r, paths)
+		}
+	}
+	return r
+}
+
+func TestGraphiteMetrics(t *testing.T) {
+	srv := newGraphiteTestServer(t, "127.0.0.1:0", false)
+	m := NewGraphiteMetrics("q.1 x", srv.ln.Addr().String(), WithGraphitePrefix("app."),
+		WithGraphiteInterval(time.Hour))
+	defer func() { _ = m.Close() }()
+
+	m.Dump(10)
+	m.Dump(10)
+	m.Fail("io err")
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	v := srv.expect(t, "app.dlqdump.q_1_x.size.in", "app.dlqdump.q_1_x.bytes.in", "app.dlqdump.q_1_x.fail.io_err")
+	for path, value := range map[string]string{
+		"app.dlqdump.q_1_x.size.in":     "2",
+		"app.dlqdump.q_1_x.bytes.in":    "20",
+		"app.dlqdump.q_1_x.fail.io_err": "1",
+	} {
+		if v[path] != value {
+			t.Errorf("%s: got %s, expected %s", path, v[path], value)
+		}
+	}
+
+	t.Run("delta", func(t *testing.T) {
+		// Counters sends as increments since previous flush, gauges as is.
+		m.Dump(5)
+		if err := m.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		v := srv.expect(t, "app.dlqdump.q_1_x.size.in", "app.dlqdump.q_1_x.bytes.in")
+		if v["app.dlqdump.q_1_x.size.in"] != "1" || v["app.dlqdump.q_1_x.bytes.in"] != "5" {
+			t.Errorf("unexpected values %v", v)
+		}
+	})
+}
+
+func TestGraphiteMetricsPickle(t *testing.T) {
+	srv := newGraphiteTestServer(t, "127.0.0.1:0", true)
+	m := NewGraphiteMetrics("q.1 x", srv.ln.Addr().String(), WithGraphitePickle(), WithGraphiteInterval(time.Hour))
+	defer func() { _ = m.Close() }()
+
+	m.Dump(10)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-srv.recv:
+		if !strings.HasPrefix(payload, "\x80\x02]") || !strings.HasSuffix(payload, "e.") ||
+			!strings.Contains(payload, "dlqdump.q_1_x.size.in") {
+			t.Errorf("malformed pickle payload %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("payload not received")
+	}
+}
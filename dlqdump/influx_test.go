@@ -0,0 +1,49 @@
+package dlqdump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewInfluxMetrics("test\nq", &buf, WithInfluxInterval(time.Hour), WithInfluxTags(map[string]string{"host": "h1"}))
+	defer func() { _ = m.Close() }()
+
+	m.Dump(10)
+	m.Fail("io")
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`dlqdump_bytes_in,host=h1,queue=test\ q value=10i `,
+		`dlqdump_size_in,host=h1,queue=test\ q value=1i `,
+		`dlqdump_fail,host=h1,queue=test\ q,reason=io value=1i `,
+	} {
+		if !strings.Contains(out, "\n"+line) && !strings.HasPrefix(out, line) {
+			t.Errorf("line %q not found in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestInfluxEscape(t *testing.T) {
+	for _, tc := range []struct {
+		s, special, expect string
+	}{
+		{"size", ", ", "size"},
+		{"a,b c", ", ", `a\,b\ c`},
+		{"a=b", ", ", "a=b"},
+		{"a=b", ",= ", `a\=b`},
+		{`a\b`, ", ", `a\\b`},
+		// Line breaks escape as spaces.
+		{"queue\nsize\r", ", ", `queue\ size\ `},
+	} {
+		var buf bytes.Buffer
+		if influxEscape(&buf, tc.s, tc.special); buf.String() != tc.expect {
+			t.Errorf("%q: got %q, expected %q", tc.s, buf.String(), tc.expect)
+		}
+	}
+}
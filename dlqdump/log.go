@@ -2,34 +2,66 @@ package dlqdump
 
 import (
 	"log"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
 )
 
 // LogMetrics is Log implementation of dlqdump.MetricsWriter.
 //
-// Don't use in production. Only for debug purposes.
+// Don't use in production without limits (see NewLogMetricsWithLimits). Only for debug purposes.
+//
+// Deprecated: use SlogMetrics instead.
 type LogMetrics struct {
 	name string
+	lim  *loglimit.Limiter
 }
 
-var _ = NewLogMetrics
+var _, _ = NewLogMetrics, NewLogMetricsWithLimits
 
 func NewLogMetrics(name string) *LogMetrics {
-	m := &LogMetrics{name}
+	m := &LogMetrics{name: name}
+	return m
+}
+
+// NewLogMetricsWithLimits makes new writer with sampling and/or rate limiting of events.
+func NewLogMetricsWithLimits(name string, opts ...LogLimitOption) *LogMetrics {
+	m := &LogMetrics{name: name}
+	m.lim = newLogLimiter(opts, func(event string, n uint64) {
+		log.Printf("queue %s: suppressed %d events of type %s\n", name, n, event)
+	})
 	return m
 }
 
 func (m LogMetrics) Dump(size int) {
+	if !m.lim.Allow("Dump") {
+		return
+	}
 	log.Printf("queue %s: %d bytes come to the queue\n", m.name, size)
 }
 
 func (m LogMetrics) Flush(reason string, size int) {
+	if !m.lim.Allow("Flush") {
+		return
+	}
 	log.Printf("queue %s: flush %d bytes due to reason %s\n", m.name, size, reason)
 }
 
 func (m LogMetrics) Restore(size int) {
+	if !m.lim.Allow("Restore") {
+		return
+	}
 	log.Printf("queue %s: %d bytes restored from dump\n", m.name, size)
 }
 
 func (m LogMetrics) Fail(reason string) {
+	if !m.lim.Allow("Fail") {
+		return
+	}
 	log.Printf("queue %s: restore failed with reason '%s'\n", m.name, reason)
 }
+
+// Close stops reporting of suppressed events.
+func (m LogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
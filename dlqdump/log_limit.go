@@ -0,0 +1,57 @@
+package dlqdump
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
+)
+
+// LogLimitOption describes sampling or rate limiting option of log writers.
+//
+// Limits applies to each event type separately, so noisy events (eg Dump) don't suppress rare ones. Suppressed
+// events counts and periodically reports in a line like "suppressed N events of type X".
+type LogLimitOption func(*loglimit.Config)
+
+// WithLogSampling logs only every n-th event of each type.
+func WithLogSampling(n uint64) LogLimitOption {
+	return func(c *loglimit.Config) {
+		c.Every = n
+	}
+}
+
+// WithLogEventSampling logs events of given type with probability p (0..1).
+func WithLogEventSampling(event string, p float64) LogLimitOption {
+	return func(c *loglimit.Config) {
+		if c.Probs == nil {
+			c.Probs = make(map[string]float64)
+		}
+		c.Probs[event] = p
+	}
+}
+
+// WithLogRateLimit limits logging of each event type using token bucket: rate events per second with bursts up to
+// burst events.
+func WithLogRateLimit(rate float64, burst int) LogLimitOption {
+	return func(c *loglimit.Config) {
+		c.Rate, c.Burst = rate, float64(burst)
+	}
+}
+
+// WithLogSuppressReport sets how often suppressed events reports. One minute by default.
+func WithLogSuppressReport(interval time.Duration) LogLimitOption {
+	return func(c *loglimit.Config) {
+		c.Interval = interval
+	}
+}
+
+// Make limiter of log events. No options means no limits.
+func newLogLimiter(opts []LogLimitOption, report func(event string, n uint64)) *loglimit.Limiter {
+	if len(opts) == 0 {
+		return nil
+	}
+	var conf loglimit.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	return loglimit.New(conf, report)
+}
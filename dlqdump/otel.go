@@ -0,0 +1,140 @@
+package dlqdump
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of dlqdump.MetricsWriter.
+type OTelMetrics struct {
+	name string
+	attr metric.MeasurementOption
+	ins  *otelInstruments
+	done *uint32
+}
+
+type otelInstruments struct {
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome, bytesFlush,
+	fail metric.Int64Counter
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/dlqdump"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(name string, opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		name: name,
+		attr: metric.WithAttributeSet(attribute.NewSet(attribute.String("queue", name))),
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+	}
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.sizeIncome = otelCheck(meter.Int64Counter("dlqdump_size_in",
+		metric.WithDescription("Actual queue size.")))
+	ins.sizeOutcome = otelCheck(meter.Int64Counter("dlqdump_size_out",
+		metric.WithDescription("Actual queue size.")))
+
+	ins.bytesIncome = otelCheck(meter.Int64Counter("dlqdump_bytes_in",
+		metric.WithDescription("How many bytes comes to the queue."),
+		metric.WithUnit("By")))
+	ins.bytesOutcome = otelCheck(meter.Int64Counter("dlqdump_bytes_out",
+		metric.WithDescription("How many bytes comes to the queue."),
+		metric.WithUnit("By")))
+	ins.bytesFlush = otelCheck(meter.Int64Counter("dlqdump_bytes_flush",
+		metric.WithDescription("How many bytes flushes from the queue."),
+		metric.WithUnit("By")))
+	ins.fail = otelCheck(meter.Int64Counter("dlqdump_fail",
+		metric.WithDescription("Error counters with various reasons.")))
+
+	return ins
+}
+
+func (m OTelMetrics) Dump(size int) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.bytesIncome.Add(ctx, int64(size), m.attr)
+	m.ins.sizeIncome.Add(ctx, 1, m.attr)
+}
+
+func (m OTelMetrics) Flush(reason string, size int) {
+	if m.closed() {
+		return
+	}
+	m.ins.bytesFlush.Add(context.Background(), int64(size), m.reasonAttr(reason))
+}
+
+func (m OTelMetrics) Restore(size int) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.bytesOutcome.Add(ctx, int64(size), m.attr)
+	m.ins.sizeOutcome.Add(ctx, 1, m.attr)
+}
+
+func (m OTelMetrics) Fail(reason string) {
+	if m.closed() {
+		return
+	}
+	m.ins.fail.Add(context.Background(), 1, m.reasonAttr(reason))
+}
+
+func (m OTelMetrics) reasonAttr(reason string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("queue", m.name), attribute.String("reason", reason))
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports.
+func (m OTelMetrics) Close() error {
+	atomic.StoreUint32(m.done, 1)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
@@ -0,0 +1,98 @@
+package dlqdump
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))))
+
+	m.Dump(100)
+	m.Dump(50)
+	m.Flush("size", 150)
+	m.Restore(100)
+	m.Fail("io")
+
+	rm := otelCollect(t, r)
+	otelExpectSum(t, rm, "dlqdump_size_in", 2, "queue", "test")
+	otelExpectSum(t, rm, "dlqdump_bytes_in", 150, "queue", "test")
+	otelExpectSum(t, rm, "dlqdump_size_out", 1, "queue", "test")
+	otelExpectSum(t, rm, "dlqdump_bytes_out", 100, "queue", "test")
+	otelExpectSum(t, rm, "dlqdump_bytes_flush", 150, "queue", "test", "reason", "size")
+	otelExpectSum(t, rm, "dlqdump_fail", 1, "queue", "test", "reason", "io")
+
+	t.Run("close", func(t *testing.T) {
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		m.Dump(100)
+		otelExpectSum(t, otelCollect(t, r), "dlqdump_size_in", 2, "queue", "test")
+	})
+}
+
+func otelCollect(t *testing.T, r *sdkmetric.ManualReader) (rm metricdata.ResourceMetrics) {
+	t.Helper()
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// Find metric by name.
+func otelMetric(rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for _, mt := range sm.Metrics {
+			if mt.Name == name {
+				return mt
+			}
+		}
+	}
+	return metricdata.Metrics{}
+}
+
+// Check if attributes set consists of exactly given key-value pairs.
+func otelAttrsMatch(set attribute.Set, kv ...string) bool {
+	if set.Len() != len(kv)/2 {
+		return false
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if v, ok := set.Value(attribute.Key(kv[i])); !ok || v.AsString() != kv[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func otelExpectSum(t *testing.T, rm metricdata.ResourceMetrics, name string, value int64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Value != value {
+				t.Errorf("%s%v: got %d, expected %d", name, kv, p.Value, value)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
+
+func otelExpectHist(t *testing.T, rm metricdata.ResourceMetrics, name string, count uint64, sum float64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Histogram[float64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Count != count || p.Sum != sum {
+				t.Errorf("%s%v: got count %d sum %g, expected count %d sum %g", name, kv, p.Count, p.Sum, count, sum)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
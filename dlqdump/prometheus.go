@@ -1,6 +1,8 @@
 package dlqdump
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -10,74 +12,218 @@ import (
 type PrometheusMetrics struct {
 	name string
 	prec time.Duration
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+	lim  promLimit
 }
 
-var (
-	promSizeIncome, promSizeOutcome, promBytesIncome, promBytesOutcome, promBytesFlush,
-	promFail *prometheus.CounterVec
+// Collection of vectors registered in the same registry.
+type promVecs struct {
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome, bytesFlush,
+	fail *prometheus.CounterVec
 
-	_ = NewPrometheusMetrics
-)
+	labelOverflow *prometheus.CounterVec
 
-func init() {
-	promSizeIncome = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "dlqdump_size_in",
-		Help: "Actual queue size.",
-	}, []string{"queue"})
-	promSizeOutcome = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "dlqdump_size_out",
-		Help: "Actual queue size.",
-	}, []string{"queue"})
-
-	promBytesIncome = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "dlqdump_bytes_in",
-		Help: "How many bytes comes to the queue.",
-	}, []string{"queue"})
-	promBytesOutcome = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "dlqdump_bytes_out",
-		Help: "How many bytes comes to the queue.",
-	}, []string{"queue"})
-	promBytesFlush = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "dlqdump_bytes_flush",
-		Help: "How many bytes flushes from the queue.",
-	}, []string{"queue", "reason"})
-	promFail = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "dlqdump_fail",
-		Help: "Error counters with various reasons.",
-	}, []string{"queue", "reason"})
-
-	prometheus.MustRegister(promSizeIncome, promSizeOutcome, promBytesIncome, promBytesOutcome, promBytesFlush, promFail)
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
 }
 
+var (
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
+
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
+)
+
 func NewPrometheusMetrics(name string) *PrometheusMetrics {
-	return NewPrometheusMetricsWP(name, time.Nanosecond)
+	return NewPrometheusMetricsWithOptions(name)
 }
 
 func NewPrometheusMetricsWP(name string, precision time.Duration) *PrometheusMetrics {
-	if precision == 0 {
-		precision = time.Nanosecond
+	return NewPrometheusMetricsWithOptions(name, WithPrecision(precision))
+}
+
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+func NewPrometheusMetricsWithOptions(name string, opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
 	}
 	m := &PrometheusMetrics{
 		name: name,
-		prec: precision,
+		prec: c.prec,
+		vec:  getPromVecs(&c),
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+		lim:  promLimit{limit: c.labelLimit, logger: c.labelLogger},
 	}
+	m.hnd.Store(newPromHandles(m.vec, name, m.lim))
 	return m
 }
 
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
+}
+
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
+
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.sizeIncome = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_size_in",
+		Help:        "Actual queue size.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.sizeOutcome = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_size_out",
+		Help:        "Actual queue size.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+
+	v.bytesIncome = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_bytes_in",
+		Help:        "How many bytes comes to the queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.bytesOutcome = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_bytes_out",
+		Help:        "How many bytes comes to the queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.bytesFlush = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_bytes_flush",
+		Help:        "How many bytes flushes from the queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "reason"})).(*prometheus.CounterVec)
+	v.fail = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_fail",
+		Help:        "Error counters with various reasons.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "reason"})).(*prometheus.CounterVec)
+
+	v.labelOverflow = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "dlqdump_label_overflow",
+		Help:        "How many events with label values over cardinality limit folded into __other__ value.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "label"})).(*prometheus.CounterVec)
+
+	return v
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
+}
+
 func (m PrometheusMetrics) Dump(size int) {
-	promBytesIncome.WithLabelValues(m.name).Add(float64(size))
-	promSizeIncome.WithLabelValues(m.name).Inc()
+	h := m.handles()
+	h.bytesIncome.Add(float64(size))
+	h.sizeIncome.Inc()
 }
 
 func (m PrometheusMetrics) Flush(reason string, size int) {
-	promBytesFlush.WithLabelValues(m.name, reason).Add(float64(size))
+	m.handles().flush.get(reason).Add(float64(size))
 }
 
 func (m PrometheusMetrics) Restore(size int) {
-	promBytesOutcome.WithLabelValues(m.name).Add(float64(size))
-	promSizeOutcome.WithLabelValues(m.name).Inc()
+	h := m.handles()
+	h.bytesOutcome.Add(float64(size))
+	h.sizeOutcome.Inc()
 }
 
 func (m PrometheusMetrics) Fail(reason string) {
-	promFail.WithLabelValues(m.name, reason).Inc()
+	m.handles().fail.get(reason).Inc()
+}
+
+// Forget removes all series of the queue and all flush/fail reasons seen by the writer.
+//
+// Writer may be used further, but series will appear again on the next events. Note, writers of the same queue share
+// series, so forget them after all writers of the queue become unused.
+func (m PrometheusMetrics) Forget() {
+	match := prometheus.Labels{"queue": m.name}
+	m.vec.sizeIncome.DeletePartialMatch(match)
+	m.vec.sizeOutcome.DeletePartialMatch(match)
+	m.vec.bytesIncome.DeletePartialMatch(match)
+	m.vec.bytesOutcome.DeletePartialMatch(match)
+	m.vec.bytesFlush.DeletePartialMatch(match)
+	m.vec.fail.DeletePartialMatch(match)
+	m.vec.labelOverflow.DeletePartialMatch(match)
+	m.hnd.Store(nil)
+}
+
+// Close removes all series of the writer (see Forget) and releases shared collectors. The last closed writer of the
+// registerer unregisters them. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		m.Forget()
+		putPromVecs(m.vec)
+	}
+	return nil
 }
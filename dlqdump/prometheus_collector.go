@@ -0,0 +1,146 @@
+package dlqdump
+
+import (
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of dlqdump.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot queues. Metrics names and labels are the same as
+// PrometheusMetrics has, but queue name is a constant label of each writer's collector, so both implementations can't
+// be mixed in the same registerer.
+type CollectorMetrics struct {
+	name string
+	reg  prometheus.Registerer
+	desc *collDescs
+	lim  promLimit
+
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome promreg.Counter
+
+	flush, fail promCache[promreg.Counter]
+
+	labelOverflow promreg.Counter
+}
+
+type collDescs struct {
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome, bytesFlush, fail *prometheus.Desc
+	labelOverflow                                                        *prometheus.Desc
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same queue and registerer share
+// counters, ie constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(name string, opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &CollectorMetrics{
+		name: name,
+		reg:  c.reg,
+		desc: newCollDescs(&c, name),
+		lim:  promLimit{limit: c.labelLimit, logger: c.labelLogger},
+	}
+	bind := func(string) *promreg.Counter {
+		return &promreg.Counter{}
+	}
+	m.flush.bind, m.fail.bind = bind, bind
+	if m.lim.limit > 0 {
+		overflow := m.lim.handler(func() { m.labelOverflow.Add(promreg.Shard(), 1) }, name)
+		m.flush.limit, m.flush.overflow = m.lim.limit, overflow
+		m.fail.limit, m.fail.overflow = m.lim.limit, overflow
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig, name string) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		cl := prometheus.Labels{"queue": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, cl)
+	}
+	return &collDescs{
+		sizeIncome:   desc("dlqdump_size_in", "Actual queue size."),
+		sizeOutcome:  desc("dlqdump_size_out", "Actual queue size."),
+		bytesIncome:  desc("dlqdump_bytes_in", "How many bytes comes to the queue."),
+		bytesOutcome: desc("dlqdump_bytes_out", "How many bytes comes to the queue."),
+		bytesFlush:   desc("dlqdump_bytes_flush", "How many bytes flushes from the queue.", "reason"),
+		fail:         desc("dlqdump_fail", "Error counters with various reasons.", "reason"),
+		labelOverflow: desc("dlqdump_label_overflow",
+			"How many events with label values over cardinality limit folded into __other__ value.", "label"),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.sizeIncome, d.sizeOutcome, d.bytesIncome, d.bytesOutcome, d.bytesFlush,
+		d.fail, d.labelOverflow} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	counter(d.sizeIncome, &m.sizeIncome)
+	counter(d.sizeOutcome, &m.sizeOutcome)
+	counter(d.bytesIncome, &m.bytesIncome)
+	counter(d.bytesOutcome, &m.bytesOutcome)
+	m.flush.each(func(reason string, c *promreg.Counter) {
+		counter(d.bytesFlush, c, reason)
+	})
+	m.fail.each(func(reason string, c *promreg.Counter) {
+		counter(d.fail, c, reason)
+	})
+	if m.lim.limit > 0 {
+		ch <- prometheus.MustNewConstMetric(d.labelOverflow, prometheus.CounterValue, float64(m.labelOverflow.Load()),
+			promLimitLabel)
+	}
+}
+
+func (m *CollectorMetrics) Dump(size int) {
+	sh := promreg.Shard()
+	m.bytesIncome.Add(sh, int64(size))
+	m.sizeIncome.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Flush(reason string, size int) {
+	m.flush.get(reason).Add(promreg.Shard(), int64(size))
+}
+
+func (m *CollectorMetrics) Restore(size int) {
+	sh := promreg.Shard()
+	m.bytesOutcome.Add(sh, int64(size))
+	m.sizeOutcome.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Fail(reason string) {
+	m.fail.get(reason).Add(promreg.Shard(), 1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
@@ -0,0 +1,143 @@
+package dlqdump
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics bound to the queue name. Binds once to avoid labels hashing and vectors locking on every event.
+type promHandles struct {
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome prometheus.Counter
+
+	flush, fail promCache[promCounter]
+}
+
+// Counter bound to the queue name and reason.
+type promCounter struct {
+	prometheus.Counter
+}
+
+func newPromHandles(v *promVecs, name string, lim promLimit) *promHandles {
+	h := &promHandles{
+		sizeIncome:   v.sizeIncome.WithLabelValues(name),
+		sizeOutcome:  v.sizeOutcome.WithLabelValues(name),
+		bytesIncome:  v.bytesIncome.WithLabelValues(name),
+		bytesOutcome: v.bytesOutcome.WithLabelValues(name),
+	}
+	h.flush.bind = func(reason string) *promCounter {
+		return &promCounter{v.bytesFlush.WithLabelValues(name, reason)}
+	}
+	h.fail.bind = func(reason string) *promCounter {
+		return &promCounter{v.fail.WithLabelValues(name, reason)}
+	}
+	if lim.limit > 0 {
+		overflow := lim.handler(v.labelOverflow.WithLabelValues(name, promLimitLabel).Inc, name)
+		h.flush.limit, h.flush.overflow = lim.limit, overflow
+		h.fail.limit, h.fail.overflow = lim.limit, overflow
+	}
+	return h
+}
+
+// Get handles bound to the writer. Binds them again after Forget.
+func (m PrometheusMetrics) handles() *promHandles {
+	for {
+		if h := m.hnd.Load(); h != nil {
+			return h
+		}
+		m.hnd.CompareAndSwap(nil, newPromHandles(m.vec, m.name, m.lim))
+	}
+}
+
+// Concurrent cache of metrics bound to values of dynamic label. Values are few (buckets, sub-queues, reasons), so
+// cache copies on write and reads without locks.
+type promCache[T any] struct {
+	mux  sync.Mutex
+	buf  atomic.Pointer[map[string]*T]
+	bind func(value string) *T
+
+	// Cardinality limit of values. Values over the limit folds into promOther.
+	limit    int
+	other    atomic.Pointer[T]
+	overflow func(value string)
+}
+
+func (c *promCache[T]) get(value string) *T {
+	if buf := c.buf.Load(); buf != nil {
+		if h, ok := (*buf)[value]; ok {
+			return h
+		}
+		if h := c.other.Load(); h != nil && len(*buf) >= c.limit {
+			c.overflow(value)
+			return h
+		}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var buf map[string]*T
+	if old := c.buf.Load(); old != nil {
+		if h, ok := (*old)[value]; ok {
+			return h
+		}
+		if c.limit > 0 && len(*old) >= c.limit {
+			h := c.other.Load()
+			if h == nil {
+				h = c.bind(promOther)
+				c.other.Store(h)
+			}
+			c.overflow(value)
+			return h
+		}
+		buf = make(map[string]*T, len(*old)+1)
+		for k, v := range *old {
+			buf[k] = v
+		}
+	} else {
+		buf = make(map[string]*T, 1)
+	}
+	h := c.bind(value)
+	buf[value] = h
+	c.buf.Store(&buf)
+	return h
+}
+
+func (c *promCache[T]) each(fn func(value string, h *T)) {
+	if buf := c.buf.Load(); buf != nil {
+		for value, h := range *buf {
+			fn(value, h)
+		}
+	}
+	if h := c.other.Load(); h != nil {
+		fn(promOther, h)
+	}
+}
+
+const (
+	// Value of dynamic label to fold values over cardinality limit.
+	promOther = "__other__"
+	// Name of dynamic label limited by WithLabelLimit.
+	promLimitLabel = "reason"
+)
+
+// Cardinality limit of dynamic label.
+type promLimit struct {
+	limit  int
+	logger *slog.Logger
+}
+
+// Make handler of values over the limit: counts them using inc and logs the first one.
+func (l promLimit) handler(inc func(), name string) func(value string) {
+	var once sync.Once
+	return func(value string) {
+		inc()
+		if l.logger != nil {
+			once.Do(func() {
+				l.logger.Warn("label cardinality limit exceeded", "queue", name, "label", promLimitLabel,
+					"value", value, "limit", l.limit)
+			})
+		}
+	}
+}
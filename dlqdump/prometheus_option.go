@@ -0,0 +1,104 @@
+package dlqdump
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusOption describes PrometheusMetrics option.
+type PrometheusOption func(*promConfig)
+
+type promConfig struct {
+	reg  prometheus.Registerer
+	prec time.Duration
+
+	namespace, subsystem string
+	constLabels          prometheus.Labels
+
+	// Cardinality limit of dynamic label.
+	labelLimit  int
+	labelLogger *slog.Logger
+}
+
+// WithRegisterer sets registerer to use instead of prometheus.DefaultRegisterer.
+func WithRegisterer(reg prometheus.Registerer) PrometheusOption {
+	return func(c *promConfig) {
+		c.reg = reg
+	}
+}
+
+// WithPrecision sets precision of time measurements.
+func WithPrecision(precision time.Duration) PrometheusOption {
+	return func(c *promConfig) {
+		c.prec = precision
+	}
+}
+
+// WithNamespace sets namespace (prefix) of all metrics names.
+func WithNamespace(namespace string) PrometheusOption {
+	return func(c *promConfig) {
+		c.namespace = namespace
+	}
+}
+
+// WithSubsystem sets subsystem of all metrics names. Subsystem places between namespace and metric name.
+func WithSubsystem(subsystem string) PrometheusOption {
+	return func(c *promConfig) {
+		c.subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets constant labels to apply to all metrics.
+//
+// Labels names must not intersect with variable labels of metrics.
+func WithConstLabels(labels prometheus.Labels) PrometheusOption {
+	return func(c *promConfig) {
+		if c.constLabels == nil {
+			c.constLabels = make(prometheus.Labels, len(labels))
+		}
+		for k, v := range labels {
+			c.constLabels[k] = v
+		}
+	}
+}
+
+// WithLabelLimit caps amount of distinct values of "reason" label per writer. Events with values over the limit folds
+// into "__other__" value and counts in dlqdump_label_overflow metric. Zero means no limit.
+//
+// Applies to PrometheusMetrics and CollectorMetrics.
+func WithLabelLimit(limit int) PrometheusOption {
+	return func(c *promConfig) {
+		c.labelLimit = limit
+	}
+}
+
+// WithLabelLimitLogger sets logger to report the first overflow of label cardinality limit (see WithLabelLimit).
+func WithLabelLimitLogger(logger *slog.Logger) PrometheusOption {
+	return func(c *promConfig) {
+		c.labelLogger = logger
+	}
+}
+
+// Build string representation of options affects metrics descriptions.
+func (c *promConfig) key() string {
+	var buf strings.Builder
+	buf.WriteString(c.namespace)
+	buf.WriteByte('|')
+	buf.WriteString(c.subsystem)
+	keys := make([]string, 0, len(c.constLabels))
+	for k := range c.constLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		buf.WriteByte('|')
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(c.constLabels[k])
+	}
+	return buf.String()
+}
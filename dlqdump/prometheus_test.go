@@ -0,0 +1,155 @@
+package dlqdump
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPrometheusMetricsRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := []PrometheusOption{WithRegisterer(reg), WithNamespace("app"), WithSubsystem("svc"),
+		WithConstLabels(prometheus.Labels{"env": "test"})}
+	a := NewPrometheusMetricsWithOptions("a", opts...)
+	b := NewPrometheusMetricsWithOptions("b", opts...)
+	a.Dump(1)
+	b.Dump(1)
+	b.Dump(1)
+
+	mfs := promGather(t, reg)
+	for _, name := range []string{"dlqdump_size_in", "app_dlqdump_size_in"} {
+		if _, ok := mfs[name]; ok {
+			t.Errorf("unexpected family %s", name)
+		}
+	}
+	mf, ok := mfs["app_svc_dlqdump_size_in"]
+	if !ok {
+		t.Fatal("family app_svc_dlqdump_size_in expected")
+	}
+	got := make(map[string]float64)
+	for _, m := range mf.GetMetric() {
+		lbl := promLabels(m)
+		if lbl["env"] != "test" {
+			t.Errorf("labels %v: expected env=test", lbl)
+		}
+		got[lbl["queue"]] += m.GetCounter().GetValue()
+	}
+	if len(got) != 2 || got["a"] != 1 || got["b"] != 2 {
+		t.Errorf("app_svc_dlqdump_size_in: got %v, expected a=1 b=2", got)
+	}
+	// Private registerer doesn't touch the default one.
+	if mfs, err := prometheus.DefaultGatherer.Gather(); err == nil {
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), "app_svc_") {
+				t.Errorf("family %s registered in default registerer", mf.GetName())
+			}
+		}
+	}
+
+	// The last closed writer unregisters collectors and releases registerer.
+	_ = a.Close()
+	if mfs := promGather(t, reg); len(mfs) == 0 {
+		t.Error("collectors of unclosed writer expected")
+	}
+	_ = b.Close()
+	_ = b.Close()
+	if mfs := promGather(t, reg); len(mfs) != 0 {
+		t.Errorf("no families expected after close, got %d", len(mfs))
+	}
+	promMux.Lock()
+	defer promMux.Unlock()
+	for k := range promIdx {
+		if k.reg == reg {
+			t.Error("closed registerer kept in index")
+		}
+	}
+}
+
+func BenchmarkPrometheusMetrics_Dump(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Dump(64)
+	}
+}
+
+func BenchmarkPrometheusMetrics_Fail(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Fail("io")
+	}
+}
+
+func TestPrometheusMetricsLabelLimit(t *testing.T) {
+	type writer interface {
+		Fail(reason string)
+	}
+	for _, tc := range []struct {
+		name string
+		make func(t *testing.T, opts ...PrometheusOption) writer
+	}{
+		{"prometheus", func(t *testing.T, opts ...PrometheusOption) writer {
+			return NewPrometheusMetricsWithOptions("test", opts...)
+		}},
+		{"collector", func(t *testing.T, opts ...PrometheusOption) writer {
+			m, err := NewCollectorMetrics("test", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := tc.make(t, WithRegisterer(reg), WithLabelLimit(2), WithLabelLimitLogger(slog.New(h)))
+			for _, v := range []string{"a", "b", "c", "d", "a", "c"} {
+				m.Fail(v)
+			}
+			mfs := promGather(t, reg)
+			got := make(map[string]float64)
+			for _, mt := range mfs["dlqdump_fail"].GetMetric() {
+				got[promLabels(mt)["reason"]] = mt.GetCounter().GetValue()
+			}
+			if want := map[string]float64{"a": 2, "b": 1, promOther: 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("reason values: got %v, expected %v", got, want)
+			}
+			var overflow []string
+			for _, mt := range mfs["dlqdump_label_overflow"].GetMetric() {
+				overflow = append(overflow, fmt.Sprintf("%v %v", promLabels(mt), mt.GetCounter().GetValue()))
+			}
+			if want := []string{"map[label:reason queue:test] 3"}; !reflect.DeepEqual(overflow, want) {
+				t.Errorf("dlqdump_label_overflow: got %q, expected %q", overflow, want)
+			}
+			// Only the first overflow is logged.
+			h.expect(t, "WARN label cardinality limit exceeded queue=test label=reason value=c limit=2")
+		})
+	}
+}
+
+func promGather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		r[mf.GetName()] = mf
+	}
+	return r
+}
+
+func promLabels(m *dto.Metric) map[string]string {
+	r := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		r[lp.GetName()] = lp.GetValue()
+	}
+	return r
+}
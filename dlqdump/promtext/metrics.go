@@ -0,0 +1,113 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Metrics is a dependency-free Prometheus implementation of dlqdump.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as dlqdump.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	name    string
+	reg     *Registry
+	desc    *descs
+	id      string
+	created time.Time
+
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome promreg.Counter
+
+	flush, fail promreg.Cache[promreg.Counter]
+}
+
+type descs struct {
+	sizeIncome, sizeOutcome, bytesIncome, bytesOutcome, bytesFlush, fail *promreg.Desc
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same queue and registry share counters, ie constructor returns already registered writer.
+func NewMetrics(name string, opts ...Option) *Metrics {
+	c := config{reg: DefaultRegistry}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	m := &Metrics{
+		name:    name,
+		reg:     c.reg,
+		desc:    newDescs(&c, name),
+		id:      "dlqdump|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels) + "|" + name,
+		created: time.Now(),
+	}
+	bind := func(string) *promreg.Counter {
+		return &promreg.Counter{}
+	}
+	m.flush.Bind, m.fail.Bind = bind, bind
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config, name string) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		cl := map[string]string{"queue": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, cl, labels...)
+	}
+	return &descs{
+		sizeIncome:   desc("dlqdump_size_in", "Actual queue size."),
+		sizeOutcome:  desc("dlqdump_size_out", "Actual queue size."),
+		bytesIncome:  desc("dlqdump_bytes_in", "How many bytes comes to the queue."),
+		bytesOutcome: desc("dlqdump_bytes_out", "How many bytes comes to the queue."),
+		bytesFlush:   desc("dlqdump_bytes_flush", "How many bytes flushes from the queue.", "reason"),
+		fail:         desc("dlqdump_fail", "Error counters with various reasons.", "reason"),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	g.Counter(d.sizeIncome, &m.sizeIncome, m.created)
+	g.Counter(d.sizeOutcome, &m.sizeOutcome, m.created)
+	g.Counter(d.bytesIncome, &m.bytesIncome, m.created)
+	g.Counter(d.bytesOutcome, &m.bytesOutcome, m.created)
+	m.flush.Each(func(reason string, c *promreg.Counter) {
+		g.Counter(d.bytesFlush, c, m.created, reason)
+	})
+	m.fail.Each(func(reason string, c *promreg.Counter) {
+		g.Counter(d.fail, c, m.created, reason)
+	})
+}
+
+func (m *Metrics) Dump(size int) {
+	sh := promreg.Shard()
+	m.bytesIncome.Add(sh, int64(size))
+	m.sizeIncome.Add(sh, 1)
+}
+
+func (m *Metrics) Flush(reason string, size int) {
+	m.flush.Get(reason).Add(promreg.Shard(), int64(size))
+}
+
+func (m *Metrics) Restore(size int) {
+	sh := promreg.Shard()
+	m.bytesOutcome.Add(sh, int64(size))
+	m.sizeOutcome.Add(sh, 1)
+}
+
+func (m *Metrics) Fail(reason string) {
+	m.fail.Get(reason).Add(promreg.Shard(), 1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
@@ -0,0 +1,52 @@
+package dlqdump
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics("test")
+	m.Dump(10)
+	m.Dump(10)
+	m.Fail("io")
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "Fail", 1, "io") ||
+		!m.AssertValue(&ft, "dlqdump_bytes_in", 20) {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "Fail", 2, "io") },
+			"Fail[io]: expected 2 calls, got 1"},
+		{func(t TestingT) bool { return m.AssertValue(t, "dlqdump_fail", 2, "reason", "io") },
+			"dlqdump_fail[reason io]: expected 2, got 1"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package dlqdump
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RewriteMetrics is a wrapper of MetricsWriter that rewrites values of "reason" label before they reach wrapped writer.
+//
+// Designed to reduce cardinality of label values contain IDs or error text (eg "user:18237" -> "user") and to make
+// them safe for path based backends like Graphite or StatsD.
+type RewriteMetrics struct {
+	w     MetricsWriter
+	rules []RewriteRule
+	san   bool
+	cache *rewriteCache
+}
+
+// RewriteRule describes rewrite rule of label value.
+//
+// Rule returns new value and true if it matches given value. Any func with the same signature may be used as a rule,
+// eg user callback.
+type RewriteRule func(value string) (string, bool)
+
+// RewriteOption describes RewriteMetrics option.
+type RewriteOption func(*RewriteMetrics)
+
+// WithRewriteRules adds rules to the writer. Rules apply in order they were specified, the first matched rule wins.
+func WithRewriteRules(rules ...RewriteRule) RewriteOption {
+	return func(m *RewriteMetrics) {
+		m.rules = append(m.rules, rules...)
+	}
+}
+
+// WithRewriteSanitize enables sanitization of rewritten values: all characters except letters, digits, '_' and '-'
+// replace with '_'. Thus, values become safe for Graphite and StatsD paths.
+func WithRewriteSanitize() RewriteOption {
+	return func(m *RewriteMetrics) {
+		m.san = true
+	}
+}
+
+// RewriteRegexp makes rule replaces all matches of regular expression pattern with replacement.
+//
+// Replacement may contain submatches references, see regexp.Regexp.ReplaceAllString. Panics if pattern is invalid.
+func RewriteRegexp(pattern, replacement string) RewriteRule {
+	re := regexp.MustCompile(pattern)
+	return func(value string) (string, bool) {
+		if !re.MatchString(value) {
+			return value, false
+		}
+		return re.ReplaceAllString(value, replacement), true
+	}
+}
+
+// RewritePrefix makes rule replaces entire value starts with prefix with replacement.
+func RewritePrefix(prefix, replacement string) RewriteRule {
+	return func(value string) (string, bool) {
+		if !strings.HasPrefix(value, prefix) {
+			return value, false
+		}
+		return replacement, true
+	}
+}
+
+var _ = NewRewriteMetrics
+
+// NewRewriteMetrics makes new writer rewrites label values using given options and forwards events to w.
+func NewRewriteMetrics(w MetricsWriter, opts ...RewriteOption) *RewriteMetrics {
+	m := &RewriteMetrics{w: w, cache: &rewriteCache{}}
+	for _, fn := range opts {
+		fn(m)
+	}
+	return m
+}
+
+func (m RewriteMetrics) Dump(size int) {
+	m.w.Dump(size)
+}
+
+func (m RewriteMetrics) Flush(reason string, size int) {
+	m.w.Flush(m.rewrite(reason), size)
+}
+
+func (m RewriteMetrics) Restore(size int) {
+	m.w.Restore(size)
+}
+
+func (m RewriteMetrics) Fail(reason string) {
+	m.w.Fail(m.rewrite(reason))
+}
+
+// Close closes wrapped writer if it implements io.Closer.
+func (m RewriteMetrics) Close() error {
+	if c, ok := m.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Rewrite value using rules and sanitize it.
+func (m RewriteMetrics) rewrite(value string) string {
+	if len(m.rules) == 0 && !m.san {
+		return value
+	}
+	if r, ok := m.cache.get(value); ok {
+		return r
+	}
+	r := value
+	for _, rule := range m.rules {
+		if v, ok := rule(value); ok {
+			r = v
+			break
+		}
+	}
+	if m.san {
+		r = rewriteSanitize(r)
+	}
+	m.cache.set(value, r)
+	return r
+}
+
+// Replace characters illegal in Graphite/StatsD paths with '_'.
+func rewriteSanitize(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, value)
+}
+
+// Max amount of cached values. Values over the limit rewrite on each event.
+const rewriteCacheSize = 1024
+
+// Concurrent cache of rewritten values. Reads without locks, copies on write.
+type rewriteCache struct {
+	mux sync.Mutex
+	buf atomic.Pointer[map[string]string]
+}
+
+func (c *rewriteCache) get(value string) (string, bool) {
+	if buf := c.buf.Load(); buf != nil {
+		r, ok := (*buf)[value]
+		return r, ok
+	}
+	return "", false
+}
+
+func (c *rewriteCache) set(value, r string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var buf map[string]string
+	if old := c.buf.Load(); old != nil {
+		if _, ok := (*old)[value]; ok || len(*old) >= rewriteCacheSize {
+			return
+		}
+		buf = make(map[string]string, len(*old)+1)
+		for k, v := range *old {
+			buf[k] = v
+		}
+	} else {
+		buf = make(map[string]string, 1)
+	}
+	buf[value] = r
+	c.buf.Store(&buf)
+}
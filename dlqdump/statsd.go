@@ -0,0 +1,51 @@
+package dlqdump
+
+import "github.com/koykov/metrics_writers/internal/statsd"
+
+// StatsDMetrics is a StatsD implementation of dlqdump.MetricsWriter.
+type StatsDMetrics struct {
+	name string
+	c    *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(name, addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{
+		name: name,
+		c:    c,
+	}
+	return m, nil
+}
+
+func (m StatsDMetrics) Dump(size int) {
+	m.c.Count("dlqdump_bytes_in", int64(size), "queue", m.name)
+	m.c.Count("dlqdump_size_in", 1, "queue", m.name)
+}
+
+func (m StatsDMetrics) Flush(reason string, size int) {
+	m.c.Count("dlqdump_bytes_flush", int64(size), "queue", m.name, "reason", reason)
+}
+
+func (m StatsDMetrics) Restore(size int) {
+	m.c.Count("dlqdump_bytes_out", int64(size), "queue", m.name)
+	m.c.Count("dlqdump_size_out", 1, "queue", m.name)
+}
+
+func (m StatsDMetrics) Fail(reason string) {
+	m.c.Count("dlqdump_fail", 1, "queue", m.name, "reason", reason)
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
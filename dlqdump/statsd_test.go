@@ -0,0 +1,37 @@
+package dlqdump
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsDMetrics(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	m, err := NewStatsDMetrics("test", conn.LocalAddr().String(), WithStatsDPrefix("app."),
+		WithStatsDFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = m.Close() }()
+
+	m.Dump(10)
+	m.Fail("io")
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "app.dlqdump_bytes_in.test:10|c\napp.dlqdump_size_in.test:1|c\napp.dlqdump_fail.test.io:1|c"
+	if got := string(buf[:n]); got != expect {
+		t.Errorf("got %q, expected %q", got, expect)
+	}
+}
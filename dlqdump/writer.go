@@ -0,0 +1,31 @@
+package dlqdump
+
+// MetricsWriter describes dlqdump.MetricsWriter interface.
+//
+// Declared locally to keep writers independent of upstream package version. All writers of the package implement it.
+type MetricsWriter interface {
+	// Dump registers income of size bytes to the dump.
+	Dump(size int)
+	// Flush registers flush of size bytes due to reason.
+	Flush(reason string, size int)
+	// Restore registers restore of size bytes from the dump.
+	Restore(size int)
+	// Fail registers restore fail due to reason.
+	Fail(reason string)
+}
+
+var (
+	_ MetricsWriter = (*PrometheusMetrics)(nil)
+	_ MetricsWriter = (*CollectorMetrics)(nil)
+	_ MetricsWriter = (*ExpvarMetrics)(nil)
+	_ MetricsWriter = (*RewriteMetrics)(nil)
+	_ MetricsWriter = (*OTelMetrics)(nil)
+	_ MetricsWriter = (*StatsDMetrics)(nil)
+	_ MetricsWriter = (*InfluxMetrics)(nil)
+	_ MetricsWriter = (*GraphiteMetrics)(nil)
+	_ MetricsWriter = (*LogMetrics)(nil)
+	_ MetricsWriter = (*MultiMetrics)(nil)
+	_ MetricsWriter = (*RecorderMetrics)(nil)
+	_ MetricsWriter = (*SlogMetrics)(nil)
+	_ MetricsWriter = (*SummaryLogMetrics)(nil)
+)
@@ -0,0 +1,144 @@
+// Package loglimit is a sampling and rate limiting of log events shared by log writers of all packages.
+package loglimit
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultReportInterval = time.Minute
+
+// Config describes limiter settings.
+type Config struct {
+	// Log only every n-th event of each type.
+	Every uint64
+	// Log events of given type with probability p (0..1).
+	Probs map[string]float64
+	// Token bucket: rate events per second with bursts up to burst events.
+	Rate, Burst float64
+	// How often suppressed events reports.
+	Interval time.Duration
+}
+
+// Limiter of log events. Nil limiter allows all events.
+//
+// Limits applies to each event type separately. Suppressed events counts and periodically reports via report func.
+type Limiter struct {
+	conf   Config
+	report func(event string, n uint64)
+	now    func() time.Time
+
+	mux sync.Mutex
+	idx map[string]*state
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+type state struct {
+	seen, suppressed uint64
+	tokens           float64
+	last             time.Time
+}
+
+// New makes limiter and starts reporting of suppressed events.
+func New(conf Config, report func(event string, n uint64)) *Limiter {
+	l := &Limiter{
+		conf:   conf,
+		report: report,
+		now:    time.Now,
+		idx:    make(map[string]*state),
+		done:   make(chan struct{}),
+	}
+	if l.conf.Interval <= 0 {
+		l.conf.Interval = DefaultReportInterval
+	}
+	if l.conf.Rate > 0 && l.conf.Burst < 1 {
+		l.conf.Burst = 1
+	}
+	l.wg.Add(1)
+	go l.loop()
+	return l
+}
+
+// Allow checks if event may be logged.
+func (l *Limiter) Allow(event string) bool {
+	if l == nil {
+		return true
+	}
+	now := l.now()
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	st, ok := l.idx[event]
+	if !ok {
+		st = &state{tokens: l.conf.Burst, last: now}
+		l.idx[event] = st
+	}
+	st.seen++
+	ok = l.conf.Every <= 1 || (st.seen-1)%l.conf.Every == 0
+	if p, has := l.conf.Probs[event]; ok && has {
+		ok = rand.Float64() < p
+	}
+	if ok && l.conf.Rate > 0 {
+		st.tokens = math.Min(l.conf.Burst, st.tokens+now.Sub(st.last).Seconds()*l.conf.Rate)
+		st.last = now
+		if ok = st.tokens >= 1; ok {
+			st.tokens--
+		}
+	}
+	if !ok {
+		st.suppressed++
+	}
+	return ok
+}
+
+// Report suppressed events and reset their counters.
+func (l *Limiter) flush() {
+	type rec struct {
+		event string
+		n     uint64
+	}
+	var buf []rec
+	l.mux.Lock()
+	for event, st := range l.idx {
+		if st.suppressed > 0 {
+			buf = append(buf, rec{event, st.suppressed})
+			st.suppressed = 0
+		}
+	}
+	l.mux.Unlock()
+	sort.Slice(buf, func(i, j int) bool { return buf[i].event < buf[j].event })
+	for i := 0; i < len(buf); i++ {
+		l.report(buf[i].event, buf[i].n)
+	}
+}
+
+func (l *Limiter) loop() {
+	defer l.wg.Done()
+	t := time.NewTicker(l.conf.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			l.flush()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Close stops reporting and reports the rest of suppressed events.
+func (l *Limiter) Close() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		close(l.done)
+		l.wg.Wait()
+		l.flush()
+	})
+}
@@ -0,0 +1,156 @@
+package loglimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Collects reports of suppressed events.
+type testReport struct {
+	mux sync.Mutex
+	buf map[string]uint64
+}
+
+func (r *testReport) report(event string, n uint64) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.buf == nil {
+		r.buf = make(map[string]uint64)
+	}
+	r.buf[event] += n
+}
+
+func (r *testReport) get(event string) uint64 {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.buf[event]
+}
+
+// Makes limiter with manual clock.
+func newTestLimiter(conf Config, r *testReport) (*Limiter, *time.Time) {
+	conf.Interval = time.Hour
+	l := New(conf, r.report)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter(t *testing.T) {
+	t.Run("nil", func(t *testing.T) {
+		var l *Limiter
+		for i := 0; i < 10; i++ {
+			if !l.Allow("put") {
+				t.Fatal("nil limiter must allow all events")
+			}
+		}
+		l.Close()
+	})
+	t.Run("sampling", func(t *testing.T) {
+		var r testReport
+		l, _ := newTestLimiter(Config{Every: 3}, &r)
+		var n int
+		for i := 0; i < 9; i++ {
+			if l.Allow("put") {
+				n++
+			}
+		}
+		if !l.Allow("leak") {
+			t.Error("first event of other type must be allowed")
+		}
+		l.Close()
+		if n != 3 {
+			t.Errorf("allowed %d events, want 3", n)
+		}
+		if got := r.get("put"); got != 6 {
+			t.Errorf("suppressed %d events, want 6", got)
+		}
+	})
+	t.Run("probability", func(t *testing.T) {
+		var r testReport
+		l, _ := newTestLimiter(Config{Probs: map[string]float64{"put": 0}}, &r)
+		for i := 0; i < 5; i++ {
+			if l.Allow("put") {
+				t.Fatal("event with zero probability allowed")
+			}
+			if !l.Allow("pull") {
+				t.Fatal("event without probability suppressed")
+			}
+		}
+		l.Close()
+		if got := r.get("put"); got != 5 {
+			t.Errorf("suppressed %d events, want 5", got)
+		}
+	})
+	t.Run("rate", func(t *testing.T) {
+		var r testReport
+		l, now := newTestLimiter(Config{Rate: 2, Burst: 3}, &r)
+		allow := func(want int) {
+			t.Helper()
+			var n int
+			for i := 0; i < 10; i++ {
+				if l.Allow("put") {
+					n++
+				}
+			}
+			if n != want {
+				t.Errorf("allowed %d events, want %d", n, want)
+			}
+		}
+		// Burst, then bucket is empty.
+		allow(3)
+		// Half a second refills one token.
+		*now = now.Add(500 * time.Millisecond)
+		allow(1)
+		// Refill is capped by burst.
+		*now = now.Add(time.Hour)
+		allow(3)
+		l.Close()
+		if got := r.get("put"); got != 30-7 {
+			t.Errorf("suppressed %d events, want %d", got, 30-7)
+		}
+	})
+	t.Run("zero burst", func(t *testing.T) {
+		var r testReport
+		l, _ := newTestLimiter(Config{Rate: 1}, &r)
+		if !l.Allow("put") || l.Allow("put") {
+			t.Error("rate without burst must allow one event")
+		}
+		l.Close()
+	})
+	t.Run("report", func(t *testing.T) {
+		var r testReport
+		l := New(Config{Every: 2, Interval: 10 * time.Millisecond}, r.report)
+		for i := 0; i < 4; i++ {
+			l.Allow("put")
+			l.Allow("leak")
+		}
+		// Periodic report without close.
+		deadline := time.Now().Add(5 * time.Second)
+		for (r.get("put") < 2 || r.get("leak") < 2) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if r.get("put") != 2 || r.get("leak") != 2 {
+			t.Errorf("reported put=%d leak=%d, want 2 both", r.get("put"), r.get("leak"))
+		}
+		// Counters reset after report.
+		l.Close()
+		if r.get("put") != 2 || r.get("leak") != 2 {
+			t.Errorf("reported put=%d leak=%d after close, want 2 both", r.get("put"), r.get("leak"))
+		}
+	})
+	t.Run("close", func(t *testing.T) {
+		var r testReport
+		l, _ := newTestLimiter(Config{Every: 2}, &r)
+		l.Allow("put")
+		l.Allow("put")
+		if got := r.get("put"); got != 0 {
+			t.Errorf("reported %d events before interval", got)
+		}
+		l.Close()
+		l.Close()
+		if got := r.get("put"); got != 1 {
+			t.Errorf("reported %d events on close, want 1", got)
+		}
+	})
+}
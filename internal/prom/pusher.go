@@ -0,0 +1,173 @@
+// Package prom contains Prometheus delivery helpers shared by writers of all packages: Pushgateway pusher and
+// remote-write sender.
+package prom
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const (
+	pushDefaultInterval = 10 * time.Second
+	pushDefaultDelay    = 100 * time.Millisecond
+	pushDefaultClose    = 5 * time.Second
+)
+
+// PushConfig describes Pusher settings.
+type PushConfig struct {
+	Interval   time.Duration
+	Gatherer   prometheus.Gatherer
+	Collectors []prometheus.Collector
+	Grouping   map[string]string
+	User, Pass string
+	Client     push.HTTPDoer
+	// Amount of retries of failed push and delay before the first retry. Each next delay doubles.
+	Retries int
+	Delay   time.Duration
+	// Time limit of the final push on Close, including retries.
+	CloseTimeout time.Duration
+	OnError      func(err error)
+}
+
+// Pusher pushes metrics to Pushgateway periodically and on Close.
+type Pusher struct {
+	p    *push.Pusher
+	conf PushConfig
+	doer pushDoer
+
+	mux  sync.Mutex
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewPusher makes new pusher sends metrics to Pushgateway with given URL under given job name.
+func NewPusher(url, job string, conf PushConfig) *Pusher {
+	if conf.Interval <= 0 {
+		conf.Interval = pushDefaultInterval
+	}
+	if conf.Delay <= 0 {
+		conf.Delay = pushDefaultDelay
+	}
+	if conf.CloseTimeout <= 0 {
+		conf.CloseTimeout = pushDefaultClose
+	}
+	if conf.Gatherer == nil && len(conf.Collectors) == 0 {
+		conf.Gatherer = prometheus.DefaultGatherer
+	}
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+	m := &Pusher{
+		conf: conf,
+		doer: pushDoer{client: conf.Client},
+		done: make(chan struct{}),
+	}
+
+	m.p = push.New(url, job).Client(&m.doer)
+	if conf.Gatherer != nil {
+		m.p.Gatherer(conf.Gatherer)
+	}
+	for _, c := range conf.Collectors {
+		m.p.Collector(c)
+	}
+	for name, value := range conf.Grouping {
+		m.p.Grouping(name, value)
+	}
+	if len(conf.User) > 0 {
+		m.p.BasicAuth(conf.User, conf.Pass)
+	}
+
+	m.wg.Add(1)
+	go m.loop()
+	return m
+}
+
+// Push sends actual state of metrics. Failed push retries on network errors and 5xx responses only, waiting between
+// retries stops on Close.
+func (m *Pusher) Push() error {
+	return m.retry(context.Background(), m.done)
+}
+
+// Push with exponential backoff until success, unrecoverable error, retries limit, stop signal or context is done.
+func (m *Pusher) retry(ctx context.Context, stop <-chan struct{}) (err error) {
+	delay := m.conf.Delay
+	for i := 0; ; i++ {
+		var retry bool
+		if retry, err = m.push(ctx); err == nil || !retry || i >= m.conf.Retries {
+			return
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-stop:
+			t.Stop()
+			return
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+		delay *= 2
+	}
+}
+
+// Make one push attempt. Returns retry flag if error is recoverable.
+func (m *Pusher) push(ctx context.Context) (retry bool, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.doer.status = -1
+	if err = m.p.PushContext(ctx); err != nil {
+		// Status -1 means the request wasn't sent, 0 - network error.
+		retry = m.doer.status == 0 || m.doer.status >= 500
+	}
+	return
+}
+
+// Close stops periodic pushes and pushes the final state of metrics. Final push retries the same way as Push, but all
+// attempts are limited by CloseTimeout.
+func (m *Pusher) Close() (err error) {
+	m.once.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		ctx, cancel := context.WithTimeout(context.Background(), m.conf.CloseTimeout)
+		defer cancel()
+		err = m.retry(ctx, nil)
+	})
+	return
+}
+
+func (m *Pusher) loop() {
+	defer m.wg.Done()
+	t := time.NewTicker(m.conf.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := m.Push(); err != nil && m.conf.OnError != nil {
+				m.conf.OnError(err)
+			}
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// HTTP client keeps status of the last response to decide if failed push may be retried.
+type pushDoer struct {
+	client push.HTTPDoer
+	status int
+}
+
+func (d *pushDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	d.status = 0
+	if err == nil {
+		d.status = resp.StatusCode
+	}
+	return resp, err
+}
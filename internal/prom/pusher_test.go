@@ -0,0 +1,181 @@
+package prom
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Pushgateway stand-in: responds with statuses from the list (the last one repeats) and keeps received requests.
+type testPushgateway struct {
+	*httptest.Server
+	mux    sync.Mutex
+	status []int
+	reqs   []testPushRequest
+	calls  int32
+}
+
+type testPushRequest struct {
+	method, path, user, body string
+}
+
+func newTestPushgateway(t *testing.T, status ...int) *testPushgateway {
+	g := &testPushgateway{status: status}
+	g.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		user, _, _ := r.BasicAuth()
+		g.mux.Lock()
+		g.reqs = append(g.reqs, testPushRequest{method: r.Method, path: r.URL.Path, user: user, body: string(body)})
+		code := http.StatusOK
+		if n := len(g.status); n > 0 {
+			code = g.status[min(len(g.reqs)-1, n-1)]
+		}
+		g.mux.Unlock()
+		atomic.AddInt32(&g.calls, 1)
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(g.Close)
+	return g
+}
+
+func (g *testPushgateway) requests() []testPushRequest {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return append([]testPushRequest(nil), g.reqs...)
+}
+
+func newTestGatherer() prometheus.Gatherer {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "queue_in", Help: "How many items comes to the queue."})
+	reg.MustRegister(c)
+	c.Add(3)
+	return reg
+}
+
+func TestPusher(t *testing.T) {
+	g := newTestPushgateway(t)
+	m := NewPusher(g.URL, "batch", PushConfig{
+		Interval: time.Hour,
+		Gatherer: newTestGatherer(),
+		Grouping: map[string]string{"instance": "node1"},
+		User:     "user",
+		Pass:     "pass",
+	})
+	if err := m.Push(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reqs := g.requests()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, expected push and final push on close", len(reqs))
+	}
+	r := reqs[0]
+	if r.method != http.MethodPut || r.path != "/metrics/job/batch/instance/node1" || r.user != "user" {
+		t.Errorf("unexpected request %s %s by %q", r.method, r.path, r.user)
+	}
+	if !strings.Contains(r.body, "queue_in") {
+		t.Error("metric not pushed")
+	}
+}
+
+func TestPusherRetry(t *testing.T) {
+	t.Run("5xx", func(t *testing.T) {
+		g := newTestPushgateway(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+		m := NewPusher(g.URL, "batch", PushConfig{Interval: time.Hour, Gatherer: newTestGatherer(), Retries: 3,
+			Delay: time.Millisecond})
+		defer func() { _ = m.Close() }()
+		if err := m.Push(); err != nil {
+			t.Fatal(err)
+		}
+		if n := atomic.LoadInt32(&g.calls); n != 3 {
+			t.Errorf("got %d attempts, expected 3", n)
+		}
+	})
+	t.Run("4xx", func(t *testing.T) {
+		g := newTestPushgateway(t, http.StatusBadRequest)
+		m := NewPusher(g.URL, "batch", PushConfig{Interval: time.Hour, Gatherer: newTestGatherer(), Retries: 3,
+			Delay: time.Millisecond})
+		defer func() { _ = m.Close() }()
+		if err := m.Push(); err == nil {
+			t.Fatal("error expected")
+		}
+		if n := atomic.LoadInt32(&g.calls); n != 1 {
+			t.Errorf("got %d attempts, client errors must not be retried", n)
+		}
+	})
+	t.Run("network", func(t *testing.T) {
+		g := newTestPushgateway(t)
+		url := g.URL
+		g.Close()
+		var attempts int32
+		m := NewPusher(url, "batch", PushConfig{Interval: time.Hour, Gatherer: newTestGatherer(), Retries: 2,
+			Delay: time.Millisecond, Client: testDoerFunc(func(r *http.Request) (*http.Response, error) {
+				atomic.AddInt32(&attempts, 1)
+				return http.DefaultClient.Do(r)
+			})})
+		defer func() { _ = m.Close() }()
+		if err := m.Push(); err == nil {
+			t.Fatal("error expected")
+		}
+		if attempts != 3 {
+			t.Errorf("got %d attempts, expected 3", attempts)
+		}
+	})
+}
+
+func TestPusherClose(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		g := newTestPushgateway(t, http.StatusServiceUnavailable)
+		errs := make(chan error, 1)
+		m := NewPusher(g.URL, "batch", PushConfig{Interval: time.Millisecond, Gatherer: newTestGatherer(), Retries: 10,
+			Delay: time.Hour, CloseTimeout: 50 * time.Millisecond, OnError: func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			}})
+		// Wait till periodic push fails and starts waiting for retry.
+		for atomic.LoadInt32(&g.calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		closed := make(chan error)
+		go func() { closed <- m.Close() }()
+		select {
+		case err := <-closed:
+			if err == nil {
+				t.Error("error of final push expected")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("close waits for backoff after timeout")
+		}
+		if err := <-errs; err == nil {
+			t.Error("error of periodic push expected")
+		}
+	})
+	t.Run("retry", func(t *testing.T) {
+		g := newTestPushgateway(t, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+		m := NewPusher(g.URL, "batch", PushConfig{Interval: time.Hour, Gatherer: newTestGatherer(), Retries: 3,
+			Delay: time.Millisecond})
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if n := atomic.LoadInt32(&g.calls); n != 3 {
+			t.Errorf("got %d attempts of final push, expected 3", n)
+		}
+	})
+}
+
+type testDoerFunc func(r *http.Request) (*http.Response, error)
+
+func (fn testDoerFunc) Do(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
@@ -0,0 +1,380 @@
+package prom
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	remoteWriteDefaultInterval   = 15 * time.Second
+	remoteWriteDefaultTimeout    = 10 * time.Second
+	remoteWriteDefaultBufferSize = 100000
+	remoteWriteDefaultBatchSize  = 2000
+	remoteWriteDefaultBackoffMin = 100 * time.Millisecond
+	remoteWriteDefaultBackoffMax = 30 * time.Second
+)
+
+// RemoteWriter sends metrics of Prometheus writers to remote storage (Prometheus, VictoriaMetrics, Mimir, ...)
+// using remote-write 1.0 protocol.
+//
+// Designed for nodes can't be scraped. Writer periodically snapshots all series of gatherer and buffers their samples.
+// Buffer sends in batches (snappy-compressed protobuf WriteRequest per batch). If storage is unavailable, samples keeps
+// in the buffer and sending retries with exponential backoff. Buffer is bounded, the oldest samples drops on overflow.
+type RemoteWriter struct {
+	url  string
+	conf RemoteWriteConfig
+
+	mux     sync.Mutex
+	buf     []remoteWriteSeries
+	backoff time.Duration
+	retryAt time.Time
+	req     bytes.Buffer
+	enc     []byte
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// Sample of series.
+type remoteWriteSeries struct {
+	// Name-value pairs sorted by name.
+	labels []string
+	value  float64
+	ts     int64
+}
+
+// RemoteWriteConfig describes RemoteWriter settings.
+type RemoteWriteConfig struct {
+	Interval time.Duration
+	// Timeout of each request.
+	Timeout  time.Duration
+	Gatherer prometheus.Gatherer
+	// External labels to apply to all series. Series labels takes precedence.
+	Labels     map[string]string
+	Headers    map[string]string
+	User, Pass string
+	Client     *http.Client
+	// Maximum amount of samples keeps in buffer till sending.
+	BufferSize int
+	// Maximum amount of samples in one request.
+	BatchSize int
+	// Minimum and maximum delays between retries of failed send.
+	BackoffMin, BackoffMax time.Duration
+	OnError                func(err error)
+}
+
+// NewRemoteWriter makes new writer sends metrics to remote-write endpoint with given URL.
+func NewRemoteWriter(url string, conf RemoteWriteConfig) *RemoteWriter {
+	m := &RemoteWriter{
+		url:  url,
+		conf: conf,
+		done: make(chan struct{}),
+	}
+	if m.conf.Interval <= 0 {
+		m.conf.Interval = remoteWriteDefaultInterval
+	}
+	if m.conf.Timeout <= 0 {
+		m.conf.Timeout = remoteWriteDefaultTimeout
+	}
+	if m.conf.Gatherer == nil {
+		m.conf.Gatherer = prometheus.DefaultGatherer
+	}
+	if m.conf.Client == nil {
+		m.conf.Client = http.DefaultClient
+	}
+	if m.conf.BufferSize <= 0 {
+		m.conf.BufferSize = remoteWriteDefaultBufferSize
+	}
+	if m.conf.BatchSize <= 0 {
+		m.conf.BatchSize = remoteWriteDefaultBatchSize
+	}
+	if m.conf.BackoffMin <= 0 {
+		m.conf.BackoffMin = remoteWriteDefaultBackoffMin
+	}
+	if m.conf.BackoffMax < m.conf.BackoffMin {
+		m.conf.BackoffMax = m.conf.BackoffMin
+	}
+	m.wg.Add(1)
+	go m.loop()
+	return m
+}
+
+// Flush snapshots actual state of series and sends buffered samples.
+//
+// If previous send failed, sending postpones until backoff delay expires, samples keeps in the buffer.
+func (m *RemoteWriter) Flush() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if err := m.snapshot(); err != nil {
+		return err
+	}
+	return m.send()
+}
+
+// Close stops periodic sends and tries to send the final state of series.
+func (m *RemoteWriter) Close() (err error) {
+	m.once.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		m.mux.Lock()
+		m.retryAt = time.Time{}
+		m.mux.Unlock()
+		err = m.Flush()
+	})
+	return
+}
+
+func (m *RemoteWriter) loop() {
+	defer m.wg.Done()
+	t := time.NewTicker(m.conf.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := m.Flush(); err != nil {
+				m.report(err)
+			}
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *RemoteWriter) report(err error) {
+	if m.conf.OnError != nil {
+		m.conf.OnError(err)
+	}
+}
+
+// Gather series and append their samples to buffer.
+func (m *RemoteWriter) snapshot() error {
+	mfs, err := m.conf.Gatherer.Gather()
+	if err != nil && len(mfs) == 0 {
+		return err
+	}
+	ts := time.Now().UnixMilli()
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, mt := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				m.add(name, mt, ts, mt.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				m.add(name, mt, ts, mt.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				m.add(name, mt, ts, mt.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := mt.GetHistogram()
+				inf := false
+				for _, b := range h.GetBucket() {
+					inf = inf || math.IsInf(b.GetUpperBound(), 1)
+					m.add(name+"_bucket", mt, ts, float64(b.GetCumulativeCount()), "le", remoteWriteFloat(b.GetUpperBound()))
+				}
+				if !inf {
+					m.add(name+"_bucket", mt, ts, float64(h.GetSampleCount()), "le", "+Inf")
+				}
+				m.add(name+"_sum", mt, ts, h.GetSampleSum())
+				m.add(name+"_count", mt, ts, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := mt.GetSummary()
+				for _, q := range s.GetQuantile() {
+					m.add(name, mt, ts, q.GetValue(), "quantile", remoteWriteFloat(q.GetQuantile()))
+				}
+				m.add(name+"_sum", mt, ts, s.GetSampleSum())
+				m.add(name+"_count", mt, ts, float64(s.GetSampleCount()))
+			}
+		}
+	}
+	if n := len(m.buf) - m.conf.BufferSize; n > 0 {
+		m.buf = append(m.buf[:0], m.buf[n:]...)
+		m.report(fmt.Errorf("remote write: buffer overflow, %d samples dropped", n))
+	}
+	return err
+}
+
+// Append sample to buffer. Extra labels must be specified as name-value pairs.
+func (m *RemoteWriter) add(name string, mt *dto.Metric, ts int64, value float64, extra ...string) {
+	lp := mt.GetLabel()
+	labels := make([]string, 0, 2*(len(lp)+len(m.conf.Labels)+1)+len(extra))
+	labels = append(labels, "__name__", name)
+	for _, l := range lp {
+		labels = append(labels, l.GetName(), l.GetValue())
+	}
+	labels = append(labels, extra...)
+	for k, v := range m.conf.Labels {
+		dup := false
+		for i := 0; i < len(labels); i += 2 {
+			if dup = labels[i] == k; dup {
+				break
+			}
+		}
+		if !dup {
+			labels = append(labels, k, v)
+		}
+	}
+	sort.Sort(remoteWriteLabels(labels))
+	m.buf = append(m.buf, remoteWriteSeries{labels: labels, value: value, ts: ts})
+}
+
+// Send buffered samples by batches. Sent and rejected by storage samples removes from buffer.
+func (m *RemoteWriter) send() error {
+	if len(m.buf) == 0 || time.Now().Before(m.retryAt) {
+		return nil
+	}
+	for len(m.buf) > 0 {
+		n := len(m.buf)
+		if n > m.conf.BatchSize {
+			n = m.conf.BatchSize
+		}
+		retry, err := m.post(m.buf[:n])
+		if err != nil && retry {
+			if m.backoff == 0 {
+				m.backoff = m.conf.BackoffMin
+			} else if m.backoff *= 2; m.backoff > m.conf.BackoffMax {
+				m.backoff = m.conf.BackoffMax
+			}
+			m.retryAt = time.Now().Add(m.backoff)
+			return err
+		}
+		m.buf = append(m.buf[:0], m.buf[n:]...)
+		if err != nil {
+			return err
+		}
+		m.backoff, m.retryAt = 0, time.Time{}
+	}
+	return nil
+}
+
+// Send batch of samples. Returns retry flag if error is recoverable (network errors, 5xx and 429 responses).
+func (m *RemoteWriter) post(batch []remoteWriteSeries) (retry bool, err error) {
+	m.req.Reset()
+	for i := range batch {
+		remoteWriteEncode(&m.req, &batch[i])
+	}
+	m.enc = snappy.Encode(m.enc[:cap(m.enc)], m.req.Bytes())
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.conf.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(m.enc))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "metrics_writers")
+	for k, v := range m.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	if len(m.conf.User) > 0 {
+		req.SetBasicAuth(m.conf.User, m.conf.Pass)
+	}
+	resp, err := m.conf.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("remote write: server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// Encode sample as TimeSeries field of WriteRequest message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func remoteWriteEncode(buf *bytes.Buffer, x *remoteWriteSeries) {
+	var size int
+	for i := 0; i < len(x.labels); i += 2 {
+		size += remoteWriteFieldLen(remoteWriteLabelLen(x.labels[i], x.labels[i+1]))
+	}
+	slen := 9 + 1 + remoteWriteVarintLen(uint64(x.ts))
+	size += remoteWriteFieldLen(slen)
+
+	remoteWriteTag(buf, 1, 2)
+	remoteWriteVarint(buf, uint64(size))
+	for i := 0; i < len(x.labels); i += 2 {
+		name, value := x.labels[i], x.labels[i+1]
+		remoteWriteTag(buf, 1, 2)
+		remoteWriteVarint(buf, uint64(remoteWriteLabelLen(name, value)))
+		remoteWriteString(buf, 1, name)
+		remoteWriteString(buf, 2, value)
+	}
+	remoteWriteTag(buf, 2, 2)
+	remoteWriteVarint(buf, uint64(slen))
+	remoteWriteTag(buf, 1, 1)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(x.value))
+	buf.Write(b[:])
+	remoteWriteTag(buf, 2, 0)
+	remoteWriteVarint(buf, uint64(x.ts))
+}
+
+func remoteWriteLabelLen(name, value string) int {
+	return remoteWriteFieldLen(len(name)) + remoteWriteFieldLen(len(value))
+}
+
+// Get size of length-delimited field with payload of given size.
+func remoteWriteFieldLen(n int) int {
+	return 1 + remoteWriteVarintLen(uint64(n)) + n
+}
+
+func remoteWriteTag(buf *bytes.Buffer, field, wire int) {
+	buf.WriteByte(byte(field<<3 | wire))
+}
+
+func remoteWriteString(buf *bytes.Buffer, field int, s string) {
+	remoteWriteTag(buf, field, 2)
+	remoteWriteVarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func remoteWriteVarint(buf *bytes.Buffer, x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+func remoteWriteVarintLen(x uint64) int {
+	n := 1
+	for ; x >= 0x80; x >>= 7 {
+		n++
+	}
+	return n
+}
+
+func remoteWriteFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Name-value pairs sortable by name.
+type remoteWriteLabels []string
+
+func (l remoteWriteLabels) Len() int           { return len(l) / 2 }
+func (l remoteWriteLabels) Less(i, j int) bool { return l[2*i] < l[2*j] }
+func (l remoteWriteLabels) Swap(i, j int) {
+	l[2*i], l[2*j] = l[2*j], l[2*i]
+	l[2*i+1], l[2*j+1] = l[2*j+1], l[2*i+1]
+}
@@ -0,0 +1,259 @@
+package prom
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Remote-write receiver stand-in: decodes WriteRequest and keeps received samples as `{name="value",...} value`
+// strings with labels in order of receiving.
+type testReceiver struct {
+	*httptest.Server
+	mux     sync.Mutex
+	status  int
+	reqs    int
+	samples []string
+	err     error
+}
+
+func newTestReceiver(t *testing.T) *testReceiver {
+	r := &testReceiver{status: http.StatusNoContent}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.reqs++
+		if r.status/100 != 2 {
+			w.WriteHeader(r.status)
+			return
+		}
+		if err := r.receive(req); err != nil {
+			r.err = err
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *testReceiver) setStatus(status int) {
+	r.mux.Lock()
+	r.status = status
+	r.mux.Unlock()
+}
+
+// Get received samples and amount of requests and reset them.
+func (r *testReceiver) received(t *testing.T) ([]string, int) {
+	t.Helper()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	samples, reqs := r.samples, r.reqs
+	r.samples, r.reqs = nil, 0
+	return samples, reqs
+}
+
+func (r *testReceiver) receive(req *http.Request) error {
+	for name, value := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if v := req.Header.Get(name); v != value {
+			return fmt.Errorf("header %s: got %q, expected %q", name, v, value)
+		}
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	msg, err := snappy.Decode(nil, body)
+	if err != nil {
+		return err
+	}
+	// message WriteRequest { repeated TimeSeries timeseries = 1; }
+	return testDecode(msg, func(num protowire.Number, _ protowire.Type, b []byte, _ uint64) error {
+		if num != 1 {
+			return fmt.Errorf("unexpected field %d of WriteRequest", num)
+		}
+		return r.receiveSeries(b)
+	})
+}
+
+// message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+func (r *testReceiver) receiveSeries(msg []byte) error {
+	var labels, values []string
+	err := testDecode(msg, func(num protowire.Number, _ protowire.Type, b []byte, _ uint64) error {
+		switch num {
+		case 1:
+			// message Label { string name = 1; string value = 2; }
+			var name, value string
+			err := testDecode(b, func(num protowire.Number, _ protowire.Type, b []byte, _ uint64) error {
+				if num == 1 {
+					name = string(b)
+				} else {
+					value = string(b)
+				}
+				return nil
+			})
+			labels = append(labels, name+"="+strconv.Quote(value))
+			return err
+		case 2:
+			// message Sample { double value = 1; int64 timestamp = 2; }
+			var value float64
+			var ts int64
+			err := testDecode(b, func(num protowire.Number, _ protowire.Type, _ []byte, v uint64) error {
+				if num == 1 {
+					value = math.Float64frombits(v)
+				} else {
+					ts = int64(v)
+				}
+				return nil
+			})
+			if ts <= 0 {
+				return fmt.Errorf("sample without timestamp")
+			}
+			values = append(values, strconv.FormatFloat(value, 'g', -1, 64))
+			return err
+		}
+		return fmt.Errorf("unexpected field %d of TimeSeries", num)
+	})
+	for _, v := range values {
+		r.samples = append(r.samples, "{"+strings.Join(labels, ",")+"} "+v)
+	}
+	return err
+}
+
+// Iterate over fields of protobuf message. Length-delimited fields passes as bytes, others as numbers.
+func testDecode(msg []byte, fn func(num protowire.Number, typ protowire.Type, b []byte, v uint64) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		var (
+			b []byte
+			v uint64
+		)
+		switch typ {
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(msg)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(msg)
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(msg)
+		default:
+			return fmt.Errorf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if err := fn(num, typ, b, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRemoteWriter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "queue_in", Help: "How many items comes to the queue."},
+		[]string{"queue"})
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "queue_wait", Help: "Wait.", Buckets: []float64{1}})
+	reg.MustRegister(c, h)
+	c.WithLabelValues("q1").Add(3)
+	h.Observe(.5)
+	h.Observe(2)
+
+	r := newTestReceiver(t)
+	errs := make(chan error, 16)
+	m := NewRemoteWriter(r.URL, RemoteWriteConfig{
+		Interval:   time.Hour,
+		Gatherer:   reg,
+		Labels:     map[string]string{"instance": "node1", "queue": "none"},
+		BatchSize:  2,
+		BackoffMin: time.Hour,
+		OnError:    func(err error) { errs <- err },
+	})
+	defer func() { _ = m.Close() }()
+
+	// Series labels take precedence over external ones.
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	samples, reqs := r.received(t)
+	expect := []string{
+		`{__name__="queue_in",instance="node1",queue="q1"} 3`,
+		`{__name__="queue_wait_bucket",instance="node1",le="1",queue="none"} 1`,
+		`{__name__="queue_wait_bucket",instance="node1",le="+Inf",queue="none"} 2`,
+		`{__name__="queue_wait_sum",instance="node1",queue="none"} 2.5`,
+		`{__name__="queue_wait_count",instance="node1",queue="none"} 2`,
+	}
+	if strings.Join(samples, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("got:\n%s\nexpected:\n%s", strings.Join(samples, "\n"), strings.Join(expect, "\n"))
+	}
+	if reqs != 3 {
+		t.Errorf("got %d requests, expected 3 batches", reqs)
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		// Samples of failed send keep in buffer till backoff expires.
+		r.setStatus(http.StatusServiceUnavailable)
+		if err := m.Flush(); err == nil {
+			t.Fatal("error expected")
+		}
+		r.setStatus(http.StatusNoContent)
+		if err := m.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if _, reqs := r.received(t); reqs != 1 {
+			t.Errorf("got %d requests, send must be postponed by backoff", reqs)
+		}
+		// Close ignores backoff and sends everything.
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if samples, _ := r.received(t); len(samples) != 3*len(expect) {
+			t.Errorf("got %d samples, expected %d", len(samples), 3*len(expect))
+		}
+	})
+}
+
+func TestRemoteWriterReject(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_size", Help: "Actual queue size."})
+	reg.MustRegister(g)
+
+	r := newTestReceiver(t)
+	r.setStatus(http.StatusBadRequest)
+	m := NewRemoteWriter(r.URL, RemoteWriteConfig{Interval: time.Hour, Gatherer: reg})
+	defer func() { _ = m.Close() }()
+	if err := m.Flush(); err == nil {
+		t.Fatal("error expected")
+	}
+	// Rejected samples drops without backoff.
+	r.setStatus(http.StatusNoContent)
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if samples, _ := r.received(t); len(samples) != 1 {
+		t.Errorf("got %v, expected only the sample of the last snapshot", samples)
+	}
+}
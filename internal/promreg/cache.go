@@ -0,0 +1,52 @@
+package promreg
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Cache is a concurrent cache of counters bound to values of dynamic label. Values are few (buckets, sub-queues,
+// reasons), so cache copies on write and reads without locks.
+type Cache[T any] struct {
+	mux sync.Mutex
+	buf atomic.Pointer[map[string]*T]
+	// Bind makes counters of new value.
+	Bind func(value string) *T
+}
+
+// Get returns counters bound to value.
+func (c *Cache[T]) Get(value string) *T {
+	if buf := c.buf.Load(); buf != nil {
+		if h, ok := (*buf)[value]; ok {
+			return h
+		}
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var buf map[string]*T
+	if old := c.buf.Load(); old != nil {
+		if h, ok := (*old)[value]; ok {
+			return h
+		}
+		buf = make(map[string]*T, len(*old)+1)
+		for k, v := range *old {
+			buf[k] = v
+		}
+	} else {
+		buf = make(map[string]*T, 1)
+	}
+	h := c.Bind(value)
+	buf[value] = h
+	c.buf.Store(&buf)
+	return h
+}
+
+// Each calls fn for all bound values.
+func (c *Cache[T]) Each(fn func(value string, h *T)) {
+	if buf := c.buf.Load(); buf != nil {
+		for value, h := range *buf {
+			fn(value, h)
+		}
+	}
+}
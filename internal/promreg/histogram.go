@@ -0,0 +1,72 @@
+package promreg
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// DefBuckets are default Prometheus buckets, in seconds.
+	DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// PrecBuckets are default buckets of timing metrics, in units of precision.
+	PrecBuckets = append(DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000,
+		5000}...)
+)
+
+// Histogram is a sharded atomic histogram of durations.
+type Histogram struct {
+	upper []float64
+	shard [shards]struct {
+		// Counts of buckets (non-cumulative) plus +Inf bucket.
+		cnt []uint64
+		// Sum of observations in nanoseconds.
+		sum int64
+		_   [32]byte // cache line padding
+	}
+}
+
+// NewHistogram makes new histogram with given upper bounds of buckets.
+func NewHistogram(upper []float64) *Histogram {
+	h := &Histogram{upper: upper}
+	for i := 0; i < shards; i++ {
+		h.shard[i].cnt = make([]uint64, len(upper)+1)
+	}
+	return h
+}
+
+// Observe observes duration using given shard (see Shard). Param value is a duration in units of buckets.
+func (h *Histogram) Observe(shard uint32, value float64, dur time.Duration) {
+	s := &h.shard[shard]
+	atomic.AddUint64(&s.cnt[sort.SearchFloat64s(h.upper, value)], 1)
+	atomic.AddInt64(&s.sum, int64(dur))
+}
+
+// Load returns upper bounds of buckets, cumulative counts of buckets plus +Inf bucket (total count) and sum of
+// observations.
+func (h *Histogram) Load() (upper []float64, cnt []uint64, sum time.Duration) {
+	cnt = make([]uint64, len(h.upper)+1)
+	for i := 0; i < shards; i++ {
+		s := &h.shard[i]
+		for j := range s.cnt {
+			cnt[j] += atomic.LoadUint64(&s.cnt[j])
+		}
+		sum += time.Duration(atomic.LoadInt64(&s.sum))
+	}
+	for j := 1; j < len(cnt); j++ {
+		cnt[j] += cnt[j-1]
+	}
+	return h.upper, cnt, sum
+}
+
+// Histogram reports histogram. Param units converts sum of observations to units of buckets.
+func (g *Gatherer) Histogram(d *Desc, h *Histogram, units func(time.Duration) float64, created time.Time,
+	lvs ...string) {
+	upper, cnt, sum := h.Load()
+	g.add(d, typeHistogram, sample{
+		value:   units(sum),
+		created: created,
+		upper:   upper,
+		cnt:     cnt,
+	}, lvs)
+}
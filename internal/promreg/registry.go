@@ -0,0 +1,383 @@
+// Package promreg is a dependency-free registry of promtext writers of all packages: writers keep metrics in atomics
+// and Registry renders them in Prometheus text format 0.0.4 or OpenMetrics 1.0 without client_golang.
+package promreg
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ContentTypeText is a content type of Prometheus text format 0.0.4.
+	ContentTypeText = "text/plain; version=0.0.4; charset=utf-8"
+	// ContentTypeOpenMetrics is a content type of OpenMetrics 1.0 text format.
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Registry keeps writers and renders their metrics on scrape.
+//
+// Registry implements http.Handler: serves OpenMetrics if client accepts it (see Accept header) and Prometheus text
+// format otherwise.
+type Registry struct {
+	mux sync.RWMutex
+	idx map[string]Collector
+}
+
+// Collector is a writer that reports its metrics on scrape.
+type Collector interface {
+	Collect(g *Gatherer)
+}
+
+// DefaultRegistry is a registry used by writers by default.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry makes new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{idx: make(map[string]Collector)}
+}
+
+// Handler returns handler of DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// Register registers collector under given key. Returns already registered collector if key is busy.
+func (r *Registry) Register(key string, c Collector) Collector {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if c1, ok := r.idx[key]; ok {
+		return c1
+	}
+	r.idx[key] = c
+	return c
+}
+
+// Unregister removes collector registered under given key.
+func (r *Registry) Unregister(key string) {
+	r.mux.Lock()
+	delete(r.idx, key)
+	r.mux.Unlock()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	om := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+	if om {
+		w.Header().Set("Content-Type", ContentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", ContentTypeText)
+	}
+	_ = r.write(w, om)
+}
+
+// WriteText writes all metrics to w in Prometheus text format 0.0.4.
+func (r *Registry) WriteText(w io.Writer) error {
+	return r.write(w, false)
+}
+
+// WriteOpenMetrics writes all metrics to w in OpenMetrics 1.0 text format.
+func (r *Registry) WriteOpenMetrics(w io.Writer) error {
+	return r.write(w, true)
+}
+
+func (r *Registry) write(w io.Writer, om bool) error {
+	g := &Gatherer{idx: make(map[string]*family)}
+	r.mux.RLock()
+	for _, c := range r.idx {
+		c.Collect(g)
+	}
+	r.mux.RUnlock()
+
+	fams := make([]*family, 0, len(g.idx))
+	for _, f := range g.idx {
+		sort.Slice(f.samples, func(i, j int) bool {
+			return labelsLess(f.samples[i].labels, f.samples[j].labels)
+		})
+		fams = append(fams, f)
+	}
+	sort.Slice(fams, func(i, j int) bool { return fams[i].desc.name < fams[j].desc.name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fams {
+		writeFamily(bw, f, om)
+	}
+	if om {
+		_, _ = bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+type metricType uint8
+
+const (
+	typeCounter metricType = iota
+	typeGauge
+	typeHistogram
+)
+
+// Desc is a description of metric: name, help and labels.
+type Desc struct {
+	name, help string
+	// Constant labels, sorted by name.
+	constLabels []label
+	labels      []string
+}
+
+type label struct {
+	name, value string
+}
+
+// NewDesc makes new description. Param name must be fully qualified (see BuildFQName).
+func NewDesc(name, help string, constLabels map[string]string, labels ...string) *Desc {
+	d := &Desc{name: name, help: help, labels: labels}
+	for k, v := range constLabels {
+		d.constLabels = append(d.constLabels, label{k, v})
+	}
+	sort.Slice(d.constLabels, func(i, j int) bool { return d.constLabels[i].name < d.constLabels[j].name })
+	return d
+}
+
+// BuildFQName builds fully qualified name of metric, the same way as prometheus.BuildFQName does.
+func BuildFQName(namespace, subsystem, name string) string {
+	var parts []string
+	for _, s := range []string{namespace, subsystem, name} {
+		if len(s) > 0 {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// LabelsKey builds key of constant labels.
+func LabelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(labels[k])
+		buf.WriteByte(',')
+	}
+	return buf.String()
+}
+
+// Metrics family collected on scrape.
+type family struct {
+	desc    *Desc
+	typ     metricType
+	samples []sample
+}
+
+type sample struct {
+	// All labels of sample sorted by name.
+	labels  []label
+	value   float64
+	created time.Time
+
+	// Histogram's cumulative counts of buckets and their upper bounds.
+	upper []float64
+	cnt   []uint64
+}
+
+// Gatherer collects samples, creates on each scrape.
+type Gatherer struct {
+	idx map[string]*family
+}
+
+func (g *Gatherer) add(d *Desc, typ metricType, s sample, lvs []string) {
+	f, ok := g.idx[d.name]
+	if !ok {
+		f = &family{desc: d, typ: typ}
+		g.idx[d.name] = f
+	}
+	s.labels = make([]label, 0, len(d.constLabels)+len(lvs))
+	s.labels = append(s.labels, d.constLabels...)
+	for i := 0; i < len(lvs) && i < len(d.labels); i++ {
+		s.labels = append(s.labels, label{d.labels[i], lvs[i]})
+	}
+	sort.Slice(s.labels, func(i, j int) bool { return s.labels[i].name < s.labels[j].name })
+	f.samples = append(f.samples, s)
+}
+
+// Gauge reports gauge value.
+func (g *Gatherer) Gauge(d *Desc, c *Counter, lvs ...string) {
+	g.add(d, typeGauge, sample{value: float64(c.Load())}, lvs)
+}
+
+// Counter reports counter value and its creation time.
+func (g *Gatherer) Counter(d *Desc, c *Counter, created time.Time, lvs ...string) {
+	g.add(d, typeCounter, sample{value: float64(c.Load()), created: created}, lvs)
+}
+
+func writeFamily(w *bufio.Writer, f *family, om bool) {
+	name := f.desc.name
+	if om && f.typ == typeCounter {
+		// OpenMetrics counter family can't have _total suffix, it belongs to sample.
+		name = strings.TrimSuffix(name, "_total")
+	}
+	w.WriteString("# HELP ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	writeEscaped(w, f.desc.help, om)
+	w.WriteString("\n# TYPE ")
+	w.WriteString(name)
+	switch f.typ {
+	case typeCounter:
+		w.WriteString(" counter\n")
+	case typeGauge:
+		w.WriteString(" gauge\n")
+	case typeHistogram:
+		w.WriteString(" histogram\n")
+	}
+	for i := range f.samples {
+		s := &f.samples[i]
+		switch f.typ {
+		case typeCounter:
+			if om {
+				writeSample(w, name, "_total", s.labels, "", 0, s.value)
+				writeSample(w, name, "_created", s.labels, "", 0, createdValue(s.created))
+			} else {
+				writeSample(w, name, "", s.labels, "", 0, s.value)
+			}
+		case typeGauge:
+			writeSample(w, name, "", s.labels, "", 0, s.value)
+		case typeHistogram:
+			for j, upper := range s.upper {
+				writeSample(w, name, "_bucket", s.labels, "le", upper, float64(s.cnt[j]))
+			}
+			total := s.cnt[len(s.cnt)-1]
+			writeSample(w, name, "_bucket", s.labels, "le", math.Inf(1), float64(total))
+			if om {
+				writeSample(w, name, "_count", s.labels, "", 0, float64(total))
+				writeSample(w, name, "_sum", s.labels, "", 0, s.value)
+				writeSample(w, name, "_created", s.labels, "", 0, createdValue(s.created))
+			} else {
+				writeSample(w, name, "_sum", s.labels, "", 0, s.value)
+				writeSample(w, name, "_count", s.labels, "", 0, float64(total))
+			}
+		}
+	}
+}
+
+// Write sample line. Optional label le writes the last.
+func writeSample(w *bufio.Writer, name, suffix string, labels []label, le string, upper, value float64) {
+	w.WriteString(name)
+	w.WriteString(suffix)
+	if len(labels) > 0 || len(le) > 0 {
+		w.WriteByte('{')
+		for i := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(labels[i].name)
+			w.WriteString(`="`)
+			writeEscaped(w, labels[i].value, true)
+			w.WriteByte('"')
+		}
+		if len(le) > 0 {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(le)
+			w.WriteString(`="`)
+			writeFloat(w, upper)
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	writeFloat(w, value)
+	w.WriteByte('\n')
+}
+
+// Escape backslash and new line, and double quote if quote flag is set.
+func writeEscaped(w *bufio.Writer, s string, quote bool) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			w.WriteString(`\\`)
+		case c == '\n':
+			w.WriteString(`\n`)
+		case c == '"' && quote:
+			w.WriteString(`\"`)
+		default:
+			w.WriteByte(c)
+		}
+	}
+}
+
+func writeFloat(w *bufio.Writer, f float64) {
+	switch {
+	case math.IsInf(f, 1):
+		w.WriteString("+Inf")
+	case math.IsInf(f, -1):
+		w.WriteString("-Inf")
+	case math.IsNaN(f):
+		w.WriteString("NaN")
+	default:
+		var buf [32]byte
+		w.Write(strconv.AppendFloat(buf[:0], f, 'g', -1, 64))
+	}
+}
+
+// Get value of _created sample: unix time in seconds.
+func createdValue(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func labelsLess(a, b []label) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].value != b[i].value {
+			return a[i].value < b[i].value
+		}
+	}
+	return len(a) < len(b)
+}
+
+// Amount of shards of atomic counters. Concurrent updates spreads over shards to avoid cache line contention.
+const shards = 8
+
+// Counter is a sharded atomic counter. Value calculates on scrape as a sum of shards.
+type Counter [shards]struct {
+	v int64
+	_ [56]byte // cache line padding
+}
+
+// Add adds delta to counter using given shard (see Shard).
+func (c *Counter) Add(shard uint32, delta int64) {
+	atomic.AddInt64(&c[shard].v, delta)
+}
+
+// Set sets counter value. Isn't atomic regarding concurrent updates, so use only for rare events like setup.
+func (c *Counter) Set(value int64) {
+	for i := 1; i < shards; i++ {
+		value -= atomic.LoadInt64(&c[i].v)
+	}
+	atomic.StoreInt64(&c[0].v, value)
+}
+
+// Load returns counter value.
+func (c *Counter) Load() (n int64) {
+	for i := 0; i < shards; i++ {
+		n += atomic.LoadInt64(&c[i].v)
+	}
+	return
+}
+
+// Shard returns random shard index. Cheap per-P generator is used, so doesn't contend. Event should take shard once
+// for all its counters.
+func Shard() uint32 {
+	return rand.Uint32() % shards
+}
@@ -0,0 +1,121 @@
+package promreg
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCollector struct {
+	size, in *Desc
+	wait     *Desc
+	cSize    Counter
+	cIn      Counter
+	hWait    *Histogram
+	created  time.Time
+}
+
+func (c *testCollector) Collect(g *Gatherer) {
+	g.Gauge(c.size, &c.cSize)
+	g.Counter(c.in, &c.cIn, c.created)
+	g.Histogram(c.wait, c.hWait, func(d time.Duration) float64 { return d.Seconds() }, c.created)
+}
+
+func newTestCollector() *testCollector {
+	cl := map[string]string{"queue": "q\"1"}
+	return &testCollector{
+		size:    NewDesc(BuildFQName("app", "", "queue_size"), "Actual queue size.", cl),
+		in:      NewDesc(BuildFQName("app", "", "queue_in_total"), "How many items comes to the queue.", cl),
+		wait:    NewDesc(BuildFQName("app", "", "queue_wait"), "Wait\ntime.", cl),
+		hWait:   NewHistogram([]float64{.1, 1}),
+		created: time.Unix(1700000000, 500000000),
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := newTestCollector()
+	if c1 := r.Register("q1", c); c1 != c {
+		t.Fatal("collector expected")
+	}
+	// Busy key returns registered collector.
+	if c1 := r.Register("q1", newTestCollector()); c1 != c {
+		t.Error("registered collector expected")
+	}
+	c.cSize.Add(Shard(), 3)
+	c.cSize.Add(Shard(), -1)
+	c.cIn.Add(Shard(), 3)
+	c.hWait.Observe(Shard(), .5, 500*time.Millisecond)
+	c.hWait.Observe(Shard(), 2, 2*time.Second)
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := r.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+		expect := `# HELP app_queue_in_total How many items comes to the queue.
+# TYPE app_queue_in_total counter
+app_queue_in_total{queue="q\"1"} 3
+# HELP app_queue_size Actual queue size.
+# TYPE app_queue_size gauge
+app_queue_size{queue="q\"1"} 2
+# HELP app_queue_wait Wait\ntime.
+# TYPE app_queue_wait histogram
+app_queue_wait_bucket{queue="q\"1",le="0.1"} 0
+app_queue_wait_bucket{queue="q\"1",le="1"} 1
+app_queue_wait_bucket{queue="q\"1",le="+Inf"} 2
+app_queue_wait_sum{queue="q\"1"} 2.5
+app_queue_wait_count{queue="q\"1"} 2
+`
+		if buf.String() != expect {
+			t.Errorf("got:\n%s\nexpected:\n%s", buf.String(), expect)
+		}
+	})
+	t.Run("openmetrics", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if ct := w.Header().Get("Content-Type"); ct != ContentTypeOpenMetrics {
+			t.Errorf("content type %q", ct)
+		}
+		out := w.Body.String()
+		for _, line := range []string{
+			"# TYPE app_queue_in counter\n",
+			`app_queue_in_total{queue="q\"1"} 3` + "\n",
+			`app_queue_in_created{queue="q\"1"} 1.7000000005e+09` + "\n",
+			`app_queue_wait_count{queue="q\"1"} 2` + "\n",
+		} {
+			if !strings.Contains(out, line) {
+				t.Errorf("line %q not found in output:\n%s", line, out)
+			}
+		}
+		if !strings.HasSuffix(out, "# EOF\n") {
+			t.Error("EOF marker expected")
+		}
+	})
+	t.Run("unregister", func(t *testing.T) {
+		r.Unregister("q1")
+		var buf bytes.Buffer
+		if err := r.WriteText(&buf); err != nil || buf.Len() > 0 {
+			t.Errorf("empty output expected, got %q, %v", buf.String(), err)
+		}
+	})
+}
+
+func TestCache(t *testing.T) {
+	var c Cache[Counter]
+	c.Bind = func(string) *Counter { return &Counter{} }
+	a := c.Get("a")
+	if c.Get("a") != a || c.Get("b") == a {
+		t.Error("counters must be bound to values")
+	}
+	var n int
+	c.Each(func(string, *Counter) { n++ })
+	if n != 2 {
+		t.Errorf("got %d values, expected 2", n)
+	}
+}
@@ -0,0 +1,255 @@
+// Package statsd is a StatsD client with client-side batching shared by StatsD writers of all packages.
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMTU   = 1432
+	DefaultFlush = 100 * time.Millisecond
+)
+
+// Config describes client settings.
+type Config struct {
+	// Prefix of all metrics names.
+	Prefix string
+	// Write labels as DogStatsD tags instead of metric name suffixes.
+	Tags bool
+	// Const tags in "key:value" format appends to every metric, works with Tags only.
+	ConstTags []string
+	// Maximum size of UDP packet. Metrics batches into packets up to that size.
+	MTU int
+	// How often not completely filled packets will send.
+	Interval time.Duration
+}
+
+// Client sends metrics to StatsD agent over UDP.
+type Client struct {
+	conf Config
+	conn net.Conn
+
+	mux    sync.Mutex
+	buf    []byte
+	closed bool
+	// Current values of gauges. StatsD gauges may be only set, so client keeps them locally.
+	gauges map[string]int64
+
+	done chan struct{}
+	once sync.Once
+}
+
+// New makes new client sends metrics to given UDP address.
+func New(addr string, conf Config) (*Client, error) {
+	if conf.MTU <= 0 {
+		conf.MTU = DefaultMTU
+	}
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultFlush
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conf:   conf,
+		conn:   conn,
+		buf:    make([]byte, 0, conf.MTU),
+		gauges: make(map[string]int64),
+		done:   make(chan struct{}),
+	}
+	go c.loop()
+	return c, nil
+}
+
+// Count sends counter increment. Labels must be specified as key-value pairs.
+func (c *Client) Count(name string, value int64, labels ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return
+	}
+	off := c.openLF(name, labels)
+	c.buf = strconv.AppendInt(c.buf, value, 10)
+	c.closeLF(off, "c", labels)
+}
+
+// GaugeAdd adds delta to locally stored gauge and sends actual value.
+func (c *Client) GaugeAdd(name string, delta int64, labels ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return
+	}
+	key := gaugeKey(name, labels)
+	value := c.gauges[key] + delta
+	c.gauges[key] = value
+	c.gaugeLF(name, value, labels)
+}
+
+// GaugeSet sets gauge value.
+func (c *Client) GaugeSet(name string, value int64, labels ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return
+	}
+	c.gauges[gaugeKey(name, labels)] = value
+	c.gaugeLF(name, value, labels)
+}
+
+// Gauge returns locally stored gauge value.
+func (c *Client) Gauge(name string, labels ...string) int64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.gauges[gaugeKey(name, labels)]
+}
+
+// Send absolute gauge value. Signed value means relative change in StatsD protocol, so negative value must be sent
+// as reset to zero followed by decrement.
+func (c *Client) gaugeLF(name string, value int64, labels []string) {
+	if value < 0 {
+		off := c.openLF(name, labels)
+		c.buf = append(c.buf, '0')
+		c.closeLF(off, "g", labels)
+	}
+	off := c.openLF(name, labels)
+	c.buf = strconv.AppendInt(c.buf, value, 10)
+	c.closeLF(off, "g", labels)
+}
+
+// Timing sends duration in milliseconds.
+func (c *Client) Timing(name string, dur time.Duration, labels ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return
+	}
+	off := c.openLF(name, labels)
+	c.buf = strconv.AppendFloat(c.buf, float64(dur)/float64(time.Millisecond), 'f', -1, 64)
+	c.closeLF(off, "ms", labels)
+}
+
+// Start new line in the buffer: writes name and labels as path segments. Value must be appended right after that.
+// Returns offset of the line.
+func (c *Client) openLF(name string, labels []string) int {
+	off := len(c.buf)
+	if off > 0 {
+		c.buf = append(c.buf, '\n')
+	}
+	c.buf = append(c.buf, c.conf.Prefix...)
+	c.buf = append(c.buf, name...)
+	if !c.conf.Tags {
+		for i := 1; i < len(labels); i += 2 {
+			c.buf = append(c.buf, '.')
+			c.buf = appendSanitized(c.buf, labels[i], true)
+		}
+	}
+	c.buf = append(c.buf, ':')
+	return off
+}
+
+// Finish the line started at offset off: writes type and tags and sends the packet if it's full.
+func (c *Client) closeLF(off int, typ string, labels []string) {
+	c.buf = append(c.buf, '|')
+	c.buf = append(c.buf, typ...)
+	if c.conf.Tags && len(labels)+len(c.conf.ConstTags) > 0 {
+		c.buf = append(c.buf, "|#"...)
+		for i := 0; i < len(c.conf.ConstTags); i++ {
+			if i > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			c.buf = append(c.buf, c.conf.ConstTags[i]...)
+		}
+		for i := 1; i < len(labels); i += 2 {
+			if i > 1 || len(c.conf.ConstTags) > 0 {
+				c.buf = append(c.buf, ',')
+			}
+			c.buf = appendSanitized(c.buf, labels[i-1], false)
+			c.buf = append(c.buf, ':')
+			c.buf = appendSanitized(c.buf, labels[i], false)
+		}
+	}
+	if len(c.buf) > c.conf.MTU && off > 0 {
+		// Packet overflow, so send previous lines and keep only the last one.
+		_, _ = c.conn.Write(c.buf[:off])
+		n := copy(c.buf, c.buf[off+1:])
+		c.buf = c.buf[:n]
+	}
+	if len(c.buf) >= c.conf.MTU {
+		c.flushLF()
+	}
+}
+
+// Flush sends buffered metrics.
+func (c *Client) Flush() {
+	c.mux.Lock()
+	c.flushLF()
+	c.mux.Unlock()
+}
+
+func (c *Client) flushLF() {
+	if len(c.buf) == 0 {
+		return
+	}
+	_, _ = c.conn.Write(c.buf)
+	c.buf = c.buf[:0]
+}
+
+func (c *Client) loop() {
+	t := time.NewTicker(c.conf.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.Flush()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Close flushes buffered metrics and closes connection. All further metrics are dropped.
+func (c *Client) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.done)
+		c.mux.Lock()
+		c.flushLF()
+		c.closed = true
+		c.gauges = nil
+		c.mux.Unlock()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func gaugeKey(name string, labels []string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	return name + "|" + strings.Join(labels, "|")
+}
+
+// Replace characters having special meaning in StatsD protocol.
+// Dots are also replaced in path segments.
+func appendSanitized(dst []byte, s string, path bool) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ':', '|', '@', '#', ',', ' ', '\t', '\n', '\r':
+			dst = append(dst, '_')
+		case '.':
+			if path {
+				c = '_'
+			}
+			dst = append(dst, c)
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
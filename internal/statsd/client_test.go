@@ -0,0 +1,235 @@
+package statsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Local agent stand-in: collects received packets.
+type testAgent struct {
+	conn net.PacketConn
+	recv chan string
+}
+
+func newTestAgent(t *testing.T) *testAgent {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &testAgent{conn: conn, recv: make(chan string, 1<<16)}
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			a.recv <- string(buf[:n])
+		}
+	}()
+	t.Cleanup(func() { _ = conn.Close() })
+	return a
+}
+
+// Wait for count lines.
+func (a *testAgent) lines(t *testing.T, count int) []string {
+	t.Helper()
+	var r []string
+	timeout := time.After(5 * time.Second)
+	for len(r) < count {
+		select {
+		case p := <-a.recv:
+			r = append(r, strings.Split(p, "\n")...)
+		case <-timeout:
+			t.Fatalf("got %d lines of %d: %v", len(r), count, r)
+		}
+	}
+	return r
+}
+
+func TestClient(t *testing.T) {
+	a := newTestAgent(t)
+	c, err := New(a.conn.LocalAddr().String(), Config{Prefix: "app.", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	c.Count("queue_in", 2, "queue", "q.1")
+	c.GaugeSet("queue_size", 5, "queue", "q.1")
+	c.GaugeAdd("queue_size", -2, "queue", "q.1")
+	c.Timing("queue_wait", 1500*time.Microsecond, "queue", "q.1")
+	c.Flush()
+	expect := []string{
+		"app.queue_in.q_1:2|c",
+		"app.queue_size.q_1:5|g",
+		"app.queue_size.q_1:3|g",
+		"app.queue_wait.q_1:1.5|ms",
+	}
+	if r := a.lines(t, len(expect)); strings.Join(r, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("got %q, expected %q", r, expect)
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		// Signed value is a relative change, so negative gauge must be reset first.
+		c.GaugeAdd("queue_size", -5, "queue", "q.1")
+		c.GaugeSet("queue_lag", -1)
+		c.Flush()
+		expect := []string{
+			"app.queue_size.q_1:0|g",
+			"app.queue_size.q_1:-2|g",
+			"app.queue_lag:0|g",
+			"app.queue_lag:-1|g",
+		}
+		if r := a.lines(t, len(expect)); strings.Join(r, "\n") != strings.Join(expect, "\n") {
+			t.Errorf("got %q, expected %q", r, expect)
+		}
+		if v := c.Gauge("queue_size", "queue", "q.1"); v != -2 {
+			t.Errorf("gauge %d, expected -2", v)
+		}
+		if v := c.Gauge("queue_lag"); v != -1 {
+			t.Errorf("gauge %d, expected -1", v)
+		}
+	})
+	t.Run("close", func(t *testing.T) {
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		c.Count("queue_in", 1)
+		c.GaugeAdd("queue_size", 1)
+		c.Flush()
+		select {
+		case p := <-a.recv:
+			t.Errorf("unexpected packet %q after close", p)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestClientAllocs(t *testing.T) {
+	a := newTestAgent(t)
+	c, err := New(a.conn.LocalAddr().String(), Config{Prefix: "app.", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// Lines must be encoded right into the buffer.
+	if n := testing.AllocsPerRun(100, func() {
+		c.Count("queue_in", 1, "queue", "q")
+		c.Timing("queue_wait", time.Millisecond, "queue", "q")
+	}); n != 0 {
+		t.Errorf("got %v allocs per run, expected 0", n)
+	}
+}
+
+func TestClientTags(t *testing.T) {
+	a := newTestAgent(t)
+	c, err := New(a.conn.LocalAddr().String(), Config{Tags: true, ConstTags: []string{"env:dev"}, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	c.Count("queue_leak", 1, "queue", "q:1", "dir", "front")
+	c.Flush()
+	if r := a.lines(t, 1); r[0] != "queue_leak:1|c|#env:dev,queue:q_1,dir:front" {
+		t.Errorf("got %q", r[0])
+	}
+}
+
+func TestAppendSanitized(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		path, tags string
+	}{
+		{"plain-value_1", "plain-value_1", "plain-value_1"},
+		{"a.b", "a_b", "a.b"},
+		{"k:v|c@0.5#t,x", "k_v_c_0_5_t_x", "k_v_c_0.5_t_x"},
+		{"a b\tc\r\nd", "a_b_c__d", "a_b_c__d"},
+	} {
+		if got := string(appendSanitized([]byte("x."), tc.in, true)); got != "x."+tc.path {
+			t.Errorf("path %q: got %q, expected %q", tc.in, got, "x."+tc.path)
+		}
+		if got := string(appendSanitized(nil, tc.in, false)); got != tc.tags {
+			t.Errorf("tag %q: got %q, expected %q", tc.in, got, tc.tags)
+		}
+	}
+}
+
+func TestClientMTU(t *testing.T) {
+	a := newTestAgent(t)
+	c, err := New(a.conn.LocalAddr().String(), Config{MTU: 32, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	for i := 0; i < 4; i++ {
+		c.Count("counter_"+strconv.Itoa(i), 1)
+	}
+	c.Flush()
+	// Each packet must fit into MTU and contain whole lines.
+	var lines int
+	timeout := time.After(5 * time.Second)
+	for lines < 4 {
+		select {
+		case p := <-a.recv:
+			if len(p) > 32 {
+				t.Errorf("packet %q exceeds MTU", p)
+			}
+			for _, line := range strings.Split(p, "\n") {
+				if !strings.HasSuffix(line, ":1|c") {
+					t.Errorf("broken line %q", line)
+				}
+				lines++
+			}
+		case <-timeout:
+			t.Fatalf("got %d lines of 4", lines)
+		}
+	}
+}
+
+func TestClientGaugeOrder(t *testing.T) {
+	a := newTestAgent(t)
+	c, err := New(a.conn.LocalAddr().String(), Config{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	const workers, events = 4, 100
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < events; j++ {
+				c.GaugeAdd("queue_size", 1)
+				c.GaugeAdd("queue_size", -1)
+			}
+		}()
+	}
+	wg.Wait()
+	c.Flush()
+	// Values must be sent in order of changes, so each one differs from previous by one.
+	var prev int64
+	for i, line := range a.lines(t, 2*workers*events) {
+		v, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(line, "queue_size:"), "|g"), 10, 64)
+		if err != nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		if d := v - prev; d != 1 && d != -1 {
+			t.Fatalf("line %d: value %d after %d", i, v, prev)
+		}
+		prev = v
+	}
+	if prev != 0 {
+		t.Errorf("final value %d, expected 0", prev)
+	}
+}
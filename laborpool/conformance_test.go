@@ -0,0 +1,412 @@
+package laborpool
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koykov/metrics_writers/laborpool/promtext"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var conformanceSeed = flag.Uint64("conformance.seed", 0, "seed of randomized conformance replay, 0 means default seeds")
+
+var conformanceSeeds = []uint64{1, 2, 3}
+
+// Writer under check. Gauge reads gauges of the writer the same way as RecorderMetrics.Gauge, events reads amount of
+// records written by log writers. Snapshot takes state of the writer before gauges read. All are optional.
+type conformanceTarget struct {
+	w        MetricsWriter
+	gauge    func(family string, labels ...string) int64
+	events   func() int64
+	snapshot func()
+}
+
+func TestConformance(t *testing.T) {
+	seeds := conformanceSeeds
+	if *conformanceSeed != 0 {
+		seeds = []uint64{*conformanceSeed}
+	}
+	// Log and summary writers use standard logger.
+	prev := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(prev)
+
+	for _, tc := range []struct {
+		name string
+		new  func(t *testing.T) conformanceTarget
+	}{
+		{"prometheus", func(*testing.T) conformanceTarget {
+			m := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"collector", func(t *testing.T) conformanceTarget {
+			m, err := NewCollectorMetrics("test", WithRegisterer(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"expvar", func(t *testing.T) conformanceTarget {
+			// Expvar variables are global, so name must be unique.
+			m, err := NewExpvarMetrics("conformance_" + strings.ReplaceAll(t.Name(), "/", "_"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"recorder", func(*testing.T) conformanceTarget {
+			m := NewRecorderMetrics("test")
+			return conformanceTarget{w: m, gauge: m.Gauge}
+		}},
+		{"otel", func(*testing.T) conformanceTarget {
+			g := &otelValues{r: sdkmetric.NewManualReader(), kv: []string{"pool", "test"}}
+			m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(g.r))))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+		{"statsd", func(t *testing.T) conformanceTarget {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewStatsDMetrics("test", conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = m.Close()
+				_ = conn.Close()
+			})
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"influx", func(t *testing.T) conformanceTarget {
+			m := NewInfluxMetrics("test", io.Discard, WithInfluxInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"graphite", func(t *testing.T) conformanceTarget {
+			// Nothing listens the address, so series stay aggregated.
+			m := NewGraphiteMetrics("test", "127.0.0.1:1", WithGraphiteInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"log", func(t *testing.T) conformanceTarget {
+			var lw conformanceLines
+			log.SetOutput(&lw)
+			t.Cleanup(func() { log.SetOutput(io.Discard) })
+			return conformanceTarget{w: NewLogMetrics("test"), events: lw.count}
+		}},
+		{"slog", func(*testing.T) conformanceTarget {
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := NewSlogMetrics("test", slog.New(h))
+			return conformanceTarget{w: m, events: h.count}
+		}},
+		{"summary", func(t *testing.T) conformanceTarget {
+			m := NewSummaryLogMetrics("test", time.Hour)
+			t.Cleanup(m.Stop)
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"multi", func(*testing.T) conformanceTarget {
+			pm := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: NewMultiMetrics(NewRecorderMetrics("test"), pm), gauge: pm.gauge}
+		}},
+		{"promtext", func(*testing.T) conformanceTarget {
+			g := &promtextValues{reg: promtext.NewRegistry(), kv: []string{"pool", "test"}}
+			m := promtext.NewMetrics("test", promtext.WithRegistry(g.reg))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, seed := range seeds {
+				t.Run(strconv.FormatUint(seed, 10), func(t *testing.T) {
+					checkConformance(t, tc.new(t), seed)
+				})
+			}
+		})
+	}
+}
+
+const conformanceSteps = 10000
+
+// Replay scripted and randomized (using seed) event sequences derived from upstream laborpool state
+// machine into the writer and check gauges invariants after each event:
+//   - laborpool_size never becomes negative;
+//   - gauge value is equal to amount of idle workers in the replayed pool.
+//
+// Gauge checks on shadow RecorderMetrics receives the same events and on the writer if target has gauge reader.
+func checkConformance(t *testing.T, tg conformanceTarget, seed uint64) {
+	t.Helper()
+	c := &conformance{
+		conformanceTarget: tg,
+		rec:               NewRecorderMetrics("conformance"),
+		rnd:               rand.New(rand.NewPCG(seed, seed)),
+	}
+	c.script()
+	for i := 0; i < conformanceSteps && c.err == nil; i++ {
+		c.random()
+		c.check()
+	}
+	if c.err != nil {
+		t.Errorf("seed %d: %s", seed, c.err)
+	}
+}
+
+// State of replayed pool.
+type conformance struct {
+	conformanceTarget
+	rec *RecorderMetrics
+	rnd *rand.Rand
+	err error
+	// Last replayed event and amount of replayed events.
+	event string
+	n     int64
+
+	// Idle workers in the pool and hired workers.
+	idle, busy int64
+}
+
+// Replay scripted sequences.
+func (c *conformance) script() {
+	steps := []func(){
+		// Hire new workers from the empty pool.
+		c.hire, c.hire, c.hire,
+		// Return workers to the pool and hire them again.
+		c.fire, c.fire, c.hire, c.retire, c.hire,
+	}
+	for i := 0; i < len(steps) && c.err == nil; i++ {
+		steps[i]()
+		c.check()
+	}
+}
+
+// Replay random valid event.
+func (c *conformance) random() {
+	switch c.rnd.IntN(3) {
+	case 0:
+		c.hire()
+	case 1:
+		c.fire()
+	default:
+		c.retire()
+	}
+}
+
+func (c *conformance) hire() {
+	c.busy++
+	if c.idle == 0 {
+		// Pool is empty, so new worker makes.
+		c.emit("Hire", func(w MetricsWriter) { w.Hire(true) })
+		return
+	}
+	c.idle--
+	c.emit("Hire", func(w MetricsWriter) { w.Hire(false) })
+}
+
+func (c *conformance) fire() {
+	if c.busy == 0 {
+		return
+	}
+	c.busy--
+	c.idle++
+	c.emit("Fire", func(w MetricsWriter) { w.Fire() })
+}
+
+// Worker drops instead of return to the pool.
+func (c *conformance) retire() {
+	if c.busy == 0 {
+		return
+	}
+	c.busy--
+	c.emit("Retire", func(w MetricsWriter) { w.Retire() })
+}
+
+// Pass event to shadow recorder and to the writer.
+func (c *conformance) emit(event string, fn func(w MetricsWriter)) {
+	if c.err != nil {
+		return
+	}
+	c.event = event
+	c.n++
+	fn(c.rec)
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("event %s: writer panic: %v", event, r)
+		}
+	}()
+	fn(c.w)
+}
+
+func (c *conformance) check() {
+	if c.err != nil {
+		return
+	}
+	c.checkValues("recorder", c.rec.Gauge)
+	if c.snapshot != nil {
+		c.snapshot()
+	}
+	if c.conformanceTarget.gauge != nil {
+		c.checkValues("writer", c.conformanceTarget.gauge)
+	}
+	if c.events != nil && c.err == nil {
+		if n := c.events(); n != c.n {
+			c.err = fmt.Errorf("after %s: writer: %d records written, expected %d", c.event, n, c.n)
+		}
+	}
+}
+
+func (c *conformance) expect(src string, gauge func(family string, labels ...string) int64, family string,
+	value int64, labels ...string) {
+	if c.err != nil {
+		return
+	}
+	switch v := gauge(family, labels...); {
+	case v < 0:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v is negative: %d", c.event, src, family, labels, v)
+	case v != value:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v drifted: %d, expected %d", c.event, src, family, labels, v, value)
+	}
+}
+
+func (c *conformance) checkValues(src string, gauge func(family string, labels ...string) int64) {
+	c.expect(src, gauge, "laborpool_size", c.idle)
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m PrometheusMetrics) gauge(family string, labels ...string) int64 {
+	var vec *prometheus.GaugeVec
+	switch family {
+	case "laborpool_size":
+		vec = m.vec.size
+	default:
+		return 0
+	}
+	lv := []string{m.name}
+	for i := 1; i < len(labels); i += 2 {
+		lv = append(lv, labels[i])
+	}
+	return promGaugeValue(vec.WithLabelValues(lv...))
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m *CollectorMetrics) gauge(family string, _ ...string) int64 {
+	if family != "laborpool_size" {
+		return 0
+	}
+	return m.size.Load()
+}
+
+func promGaugeValue(g prometheus.Gauge) int64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		return 0
+	}
+	return int64(math.Round(m.GetGauge().GetValue()))
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m aggrMetrics) gauge(family string, labels ...string) int64 {
+	if x := m.s.find(family, append([]string{"pool", m.name}, labels...)...); x != nil {
+		return x.load()
+	}
+	return 0
+}
+
+// Counts lines written by standard logger.
+type conformanceLines struct {
+	n int64
+}
+
+func (w *conformanceLines) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.n, int64(bytes.Count(p, []byte{'\n'})))
+	return len(p), nil
+}
+
+func (w *conformanceLines) count() int64 {
+	return atomic.LoadInt64(&w.n)
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m StatsDMetrics) gauge(family string, labels ...string) int64 {
+	return m.c.Gauge(family, append([]string{"pool", m.name}, labels...)...)
+}
+
+// Values of OTel writer collected by manual reader. Labels kv appends to labels of every series.
+type otelValues struct {
+	r  *sdkmetric.ManualReader
+	kv []string
+	rm metricdata.ResourceMetrics
+}
+
+func (g *otelValues) snapshot() {
+	g.rm = metricdata.ResourceMetrics{}
+	_ = g.r.Collect(context.Background(), &g.rm)
+}
+
+func (g *otelValues) value(family string, labels ...string) int64 {
+	data, _ := otelMetric(g.rm, family).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, append(g.kv[:len(g.kv):len(g.kv)], labels...)...) {
+			return p.Value
+		}
+	}
+	return 0
+}
+
+// Values of promtext writer parsed from text format rendered by registry. Labels kv prepends to labels of every
+// series.
+type promtextValues struct {
+	reg    *promtext.Registry
+	kv     []string
+	series map[string]int64
+}
+
+func (g *promtextValues) snapshot() {
+	var buf bytes.Buffer
+	_ = g.reg.WriteText(&buf)
+	g.series = make(map[string]int64)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if i := strings.LastIndexByte(line, ' '); i > 0 && !strings.HasPrefix(line, "#") {
+			f, _ := strconv.ParseFloat(line[i+1:], 64)
+			g.series[line[:i]] = int64(f)
+		}
+	}
+}
+
+func (g *promtextValues) value(family string, labels ...string) int64 {
+	// Text format sorts labels by name.
+	pairs := make([][2]string, 0, len(g.kv)/2+len(labels)/2)
+	for _, kv := range [][]string{g.kv, labels} {
+		for i := 0; i+1 < len(kv); i += 2 {
+			pairs = append(pairs, [2]string{kv[i], kv[i+1]})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	key := family
+	for i := range pairs {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		key += sep + pairs[i][0] + `="` + pairs[i][1] + `"`
+	}
+	if len(pairs) > 0 {
+		key += "}"
+	}
+	return g.series[key]
+}
@@ -0,0 +1,49 @@
+package laborpool
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics("expvar_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Hire(false)
+	m.Hire(true)
+	m.Fire()
+	m.Retire()
+
+	var root map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("laborpool").String()), &root); err != nil {
+		t.Fatal(err)
+	}
+	got := root["expvar_test"]
+	const expected = `{
+		"laborpool_fire": 1,
+		"laborpool_hire": 2,
+		"laborpool_retire": 1,
+		"laborpool_size": 0
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "laborpool_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name, "test"); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
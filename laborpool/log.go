@@ -2,23 +2,40 @@ package laborpool
 
 import (
 	"log"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
 )
 
 // LogMetrics is Log implementation of laborpool.MetricsWriter.
 //
-// Don't use in production. Only for debug purposes.
+// Don't use in production without limits (see NewLogMetricsWithLimits). Only for debug purposes.
+//
+// Deprecated: use SlogMetrics instead.
 type LogMetrics struct {
 	name string
+	lim  *loglimit.Limiter
 }
 
-var _ = NewLogMetrics
+var _, _ = NewLogMetrics, NewLogMetricsWithLimits
 
 func NewLogMetrics(name string) *LogMetrics {
-	m := &LogMetrics{name}
+	m := &LogMetrics{name: name}
+	return m
+}
+
+// NewLogMetricsWithLimits makes new writer with sampling and/or rate limiting of events.
+func NewLogMetricsWithLimits(name string, opts ...LogLimitOption) *LogMetrics {
+	m := &LogMetrics{name: name}
+	m.lim = newLogLimiter(opts, func(event string, n uint64) {
+		log.Printf("pool %s: suppressed %d events of type %s\n", name, n, event)
+	})
 	return m
 }
 
 func (m LogMetrics) Hire(unknown bool) {
+	if !m.lim.Allow("Hire") {
+		return
+	}
 	if unknown {
 		log.Printf("pool %s: new worker hired\n", m.name)
 	} else {
@@ -27,9 +44,21 @@ func (m LogMetrics) Hire(unknown bool) {
 }
 
 func (m LogMetrics) Fire() {
+	if !m.lim.Allow("Fire") {
+		return
+	}
 	log.Printf("pool %s: worker fired\n", m.name)
 }
 
 func (m LogMetrics) Retire() {
+	if !m.lim.Allow("Retire") {
+		return
+	}
 	log.Printf("pool %s: worker retired\n", m.name)
 }
+
+// Close stops reporting of suppressed events.
+func (m LogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
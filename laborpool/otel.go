@@ -0,0 +1,122 @@
+package laborpool
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of laborpool.MetricsWriter.
+type OTelMetrics struct {
+	name string
+	attr metric.MeasurementOption
+	ins  *otelInstruments
+	done *uint32
+}
+
+type otelInstruments struct {
+	size               metric.Int64UpDownCounter
+	hire, fire, retire metric.Int64Counter
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/laborpool"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(name string, opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		name: name,
+		attr: metric.WithAttributeSet(attribute.NewSet(attribute.String("pool", name))),
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+	}
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.size = otelCheck(meter.Int64UpDownCounter("laborpool_size",
+		metric.WithDescription("Indicates how many workers idle waiting for hire.")))
+	ins.hire = otelCheck(meter.Int64Counter("laborpool_hire",
+		metric.WithDescription("How many workers hired.")))
+	ins.fire = otelCheck(meter.Int64Counter("laborpool_fire",
+		metric.WithDescription("How many workers fired.")))
+	ins.retire = otelCheck(meter.Int64Counter("laborpool_retire",
+		metric.WithDescription("How many workers retired.")))
+	return ins
+}
+
+func (m OTelMetrics) Hire(unknown bool) {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.hire.Add(ctx, 1, m.attr)
+	if !unknown {
+		m.ins.size.Add(ctx, -1, m.attr)
+	}
+}
+
+func (m OTelMetrics) Fire() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.fire.Add(ctx, 1, m.attr)
+	m.ins.size.Add(ctx, 1, m.attr)
+}
+
+func (m OTelMetrics) Retire() {
+	if m.closed() {
+		return
+	}
+	m.ins.retire.Add(context.Background(), 1, m.attr)
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports.
+func (m OTelMetrics) Close() error {
+	atomic.StoreUint32(m.done, 1)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
@@ -1,59 +1,184 @@
 package laborpool
 
 import (
+	"sync"
+	"sync/atomic"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // PrometheusMetrics is a Prometheus implementation of queue.MetricsWriter.
 type PrometheusMetrics struct {
 	name string
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+}
+
+// Collection of vectors registered in the same registry.
+type promVecs struct {
+	size               *prometheus.GaugeVec
+	hire, fire, retire *prometheus.CounterVec
+
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
 }
 
 var (
-	promSize                       *prometheus.GaugeVec
-	promHire, promFire, promRetire *prometheus.CounterVec
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
 
-	_ = NewPrometheusMetrics
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
 )
 
-func init() {
-	promSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "laborpool_size",
-		Help: "Indicates how many workers idle waiting for hire.",
-	}, []string{"pool"})
-	promHire = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "laborpool_hire",
-		Help: "How many workers hired.",
-	}, []string{"pool"})
-	promFire = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "laborpool_fire",
-		Help: "How many workers fired.",
-	}, []string{"pool"})
-	promRetire = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "laborpool_retire",
-		Help: "How many workers retired.",
-	}, []string{"pool"})
-
-	prometheus.MustRegister(promSize, promHire, promFire, promRetire)
+func NewPrometheusMetrics(name string) *PrometheusMetrics {
+	return NewPrometheusMetricsWithOptions(name)
 }
 
-func NewPrometheusMetrics(name string) *PrometheusMetrics {
-	m := &PrometheusMetrics{name: name}
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+func NewPrometheusMetricsWithOptions(name string, opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &PrometheusMetrics{
+		name: name,
+		vec:  getPromVecs(&c),
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+	}
+	m.hnd.Store(newPromHandles(m.vec, name))
 	return m
 }
 
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
+}
+
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
+
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.size = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "laborpool_size",
+		Help:        "Indicates how many workers idle waiting for hire.",
+		ConstLabels: c.constLabels,
+	}, []string{"pool"})).(*prometheus.GaugeVec)
+	v.hire = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "laborpool_hire",
+		Help:        "How many workers hired.",
+		ConstLabels: c.constLabels,
+	}, []string{"pool"})).(*prometheus.CounterVec)
+	v.fire = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "laborpool_fire",
+		Help:        "How many workers fired.",
+		ConstLabels: c.constLabels,
+	}, []string{"pool"})).(*prometheus.CounterVec)
+	v.retire = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "laborpool_retire",
+		Help:        "How many workers retired.",
+		ConstLabels: c.constLabels,
+	}, []string{"pool"})).(*prometheus.CounterVec)
+
+	return v
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
+}
+
 func (m PrometheusMetrics) Hire(unknown bool) {
-	promHire.WithLabelValues(m.name).Inc()
+	h := m.handles()
+	h.hire.Inc()
 	if !unknown {
-		promSize.WithLabelValues(m.name).Dec()
+		h.size.Dec()
 	}
 }
 
 func (m PrometheusMetrics) Fire() {
-	promFire.WithLabelValues(m.name).Inc()
-	promSize.WithLabelValues(m.name).Inc()
+	h := m.handles()
+	h.fire.Inc()
+	h.size.Inc()
 }
 
 func (m PrometheusMetrics) Retire() {
-	promRetire.WithLabelValues(m.name).Inc()
+	m.handles().retire.Inc()
+}
+
+// Forget removes all series of the pool seen by the writer.
+//
+// Writer may be used further, but series will appear again on the next events. Note, writers of the same pool share
+// series, so forget them after all writers of the pool become unused.
+func (m PrometheusMetrics) Forget() {
+	match := prometheus.Labels{"pool": m.name}
+	m.vec.size.DeletePartialMatch(match)
+	m.vec.hire.DeletePartialMatch(match)
+	m.vec.fire.DeletePartialMatch(match)
+	m.vec.retire.DeletePartialMatch(match)
+	m.hnd.Store(nil)
+}
+
+// Close removes all series of the writer (see Forget) and releases shared collectors. The last closed writer of the
+// registerer unregisters them. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		m.Forget()
+		putPromVecs(m.vec)
+	}
+	return nil
 }
@@ -0,0 +1,116 @@
+package laborpool
+
+import (
+	"github.com/koykov/metrics_writers/internal/promreg"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of laborpool.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot pools. Metrics names and labels are the same as
+// PrometheusMetrics has, but pool name is a constant label of each writer's collector, so both implementations can't
+// be mixed in the same registerer.
+type CollectorMetrics struct {
+	name string
+	reg  prometheus.Registerer
+	desc *collDescs
+
+	size, hire, fire, retire promreg.Counter
+}
+
+type collDescs struct {
+	size, hire, fire, retire *prometheus.Desc
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same pool and registerer share
+// counters, ie constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(name string, opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{reg: prometheus.DefaultRegisterer}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	m := &CollectorMetrics{
+		name: name,
+		reg:  c.reg,
+		desc: newCollDescs(&c, name),
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig, name string) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		cl := prometheus.Labels{"pool": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, cl)
+	}
+	return &collDescs{
+		size:   desc("laborpool_size", "Indicates how many workers idle waiting for hire."),
+		hire:   desc("laborpool_hire", "How many workers hired."),
+		fire:   desc("laborpool_fire", "How many workers fired."),
+		retire: desc("laborpool_retire", "How many workers retired."),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.size, d.hire, d.fire, d.retire} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	gauge := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c.Load()), lvs...)
+	}
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	gauge(d.size, &m.size)
+	counter(d.hire, &m.hire)
+	counter(d.fire, &m.fire)
+	counter(d.retire, &m.retire)
+}
+
+func (m *CollectorMetrics) Hire(unknown bool) {
+	sh := promreg.Shard()
+	m.hire.Add(sh, 1)
+	if !unknown {
+		m.size.Add(sh, -1)
+	}
+}
+
+func (m *CollectorMetrics) Fire() {
+	sh := promreg.Shard()
+	m.fire.Add(sh, 1)
+	m.size.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) Retire() {
+	m.retire.Add(promreg.Shard(), 1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
@@ -0,0 +1,28 @@
+package laborpool
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics bound to the pool name. Binds once to avoid labels hashing and vectors locking on every event.
+type promHandles struct {
+	size               prometheus.Gauge
+	hire, fire, retire prometheus.Counter
+}
+
+func newPromHandles(v *promVecs, name string) *promHandles {
+	return &promHandles{
+		size:   v.size.WithLabelValues(name),
+		hire:   v.hire.WithLabelValues(name),
+		fire:   v.fire.WithLabelValues(name),
+		retire: v.retire.WithLabelValues(name),
+	}
+}
+
+// Get handles bound to the writer. Binds them again after Forget.
+func (m PrometheusMetrics) handles() *promHandles {
+	for {
+		if h := m.hnd.Load(); h != nil {
+			return h
+		}
+		m.hnd.CompareAndSwap(nil, newPromHandles(m.vec, m.name))
+	}
+}
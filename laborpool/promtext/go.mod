@@ -0,0 +1,7 @@
+module github.com/koykov/metrics_writers/laborpool/promtext
+
+go 1.22.0
+
+require github.com/koykov/metrics_writers/internal v0.1.0
+
+replace github.com/koykov/metrics_writers/internal => ../../internal
@@ -0,0 +1,97 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+)
+
+// Metrics is a dependency-free Prometheus implementation of laborpool.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as laborpool.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	name    string
+	reg     *Registry
+	desc    *descs
+	id      string
+	created time.Time
+
+	size, hire, fire, retire promreg.Counter
+}
+
+type descs struct {
+	size, hire, fire, retire *promreg.Desc
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same pool and registry share counters, ie constructor returns already registered writer.
+func NewMetrics(name string, opts ...Option) *Metrics {
+	c := config{reg: DefaultRegistry}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	m := &Metrics{
+		name:    name,
+		reg:     c.reg,
+		desc:    newDescs(&c, name),
+		id:      "laborpool|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels) + "|" + name,
+		created: time.Now(),
+	}
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config, name string) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		cl := map[string]string{"pool": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, cl, labels...)
+	}
+	return &descs{
+		size:   desc("laborpool_size", "Indicates how many workers idle waiting for hire."),
+		hire:   desc("laborpool_hire", "How many workers hired."),
+		fire:   desc("laborpool_fire", "How many workers fired."),
+		retire: desc("laborpool_retire", "How many workers retired."),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	g.Gauge(d.size, &m.size)
+	g.Counter(d.hire, &m.hire, m.created)
+	g.Counter(d.fire, &m.fire, m.created)
+	g.Counter(d.retire, &m.retire, m.created)
+}
+
+func (m *Metrics) Hire(unknown bool) {
+	sh := promreg.Shard()
+	m.hire.Add(sh, 1)
+	if !unknown {
+		m.size.Add(sh, -1)
+	}
+}
+
+func (m *Metrics) Fire() {
+	sh := promreg.Shard()
+	m.fire.Add(sh, 1)
+	m.size.Add(sh, 1)
+}
+
+func (m *Metrics) Retire() {
+	m.retire.Add(promreg.Shard(), 1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
@@ -0,0 +1,52 @@
+package laborpool
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics("test")
+	m.Fire()
+	m.Fire()
+	m.Hire(false)
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "Hire", 1, false) ||
+		!m.AssertValue(&ft, "laborpool_size", 1) {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "Hire", 1, true) },
+			"Hire[true]: expected 1 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertValue(t, "laborpool_fire", 1) },
+			"laborpool_fire[]: expected 1, got 2"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
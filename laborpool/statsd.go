@@ -0,0 +1,51 @@
+package laborpool
+
+import "github.com/koykov/metrics_writers/internal/statsd"
+
+// StatsDMetrics is a StatsD implementation of laborpool.MetricsWriter.
+//
+// Counters sends as is, gauges keeps locally and sends actual values.
+type StatsDMetrics struct {
+	name string
+	c    *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(name, addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{
+		name: name,
+		c:    c,
+	}
+	return m, nil
+}
+
+func (m StatsDMetrics) Hire(unknown bool) {
+	m.c.Count("laborpool_hire", 1, "pool", m.name)
+	if !unknown {
+		m.c.GaugeAdd("laborpool_size", -1, "pool", m.name)
+	}
+}
+
+func (m StatsDMetrics) Fire() {
+	m.c.Count("laborpool_fire", 1, "pool", m.name)
+	m.c.GaugeAdd("laborpool_size", 1, "pool", m.name)
+}
+
+func (m StatsDMetrics) Retire() {
+	m.c.Count("laborpool_retire", 1, "pool", m.name)
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
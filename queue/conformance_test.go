@@ -0,0 +1,667 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koykov/metrics_writers/queue/promtext"
+	q "github.com/koykov/queue"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var conformanceSeed = flag.Uint64("conformance.seed", 0, "seed of randomized conformance replay, 0 means default seeds")
+
+const (
+	conformanceSteps    = 10000
+	conformanceWorkers  = 8
+	conformanceCapacity = 16
+)
+
+var (
+	conformanceSeeds = []uint64{1, 2, 3}
+	conformanceSubqs = []string{"high", "low"}
+)
+
+// Writer under check. Gauge reads gauges of the writer the same way as RecorderMetrics.Gauge, events reads amount of
+// records written by log writers. Snapshot takes state of the writer before gauges read. All are optional.
+type conformanceTarget struct {
+	w        MetricsWriter
+	gauge    func(family string, labels ...string) int64
+	events   func() int64
+	snapshot func()
+}
+
+func TestConformance(t *testing.T) {
+	seeds := conformanceSeeds
+	if *conformanceSeed != 0 {
+		seeds = []uint64{*conformanceSeed}
+	}
+	// Log and summary writers use standard logger.
+	prev := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(prev)
+
+	for _, tc := range []struct {
+		name string
+		new  func(t *testing.T) conformanceTarget
+	}{
+		{"prometheus", func(*testing.T) conformanceTarget {
+			m := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"collector", func(t *testing.T) conformanceTarget {
+			m, err := NewCollectorMetrics("test", WithRegisterer(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"expvar", func(t *testing.T) conformanceTarget {
+			// Expvar variables are global, so name must be unique.
+			m, err := NewExpvarMetrics("conformance_" + strings.ReplaceAll(t.Name(), "/", "_"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"recorder", func(*testing.T) conformanceTarget {
+			m := NewRecorderMetrics("test")
+			return conformanceTarget{w: m, gauge: m.Gauge}
+		}},
+		{"otel", func(*testing.T) conformanceTarget {
+			g := &otelValues{r: sdkmetric.NewManualReader(), kv: []string{"queue", "test"}}
+			m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(g.r))))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+		{"statsd", func(t *testing.T) conformanceTarget {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewStatsDMetrics("test", conn.LocalAddr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() {
+				_ = m.Close()
+				_ = conn.Close()
+			})
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"influx", func(t *testing.T) conformanceTarget {
+			m := NewInfluxMetrics("test", io.Discard, WithInfluxInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"graphite", func(t *testing.T) conformanceTarget {
+			// Nothing listens the address, so series stay aggregated.
+			m := NewGraphiteMetrics("test", "127.0.0.1:1", WithGraphiteInterval(time.Hour))
+			t.Cleanup(func() { _ = m.Close() })
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"log", func(t *testing.T) conformanceTarget {
+			var lw conformanceLines
+			log.SetOutput(&lw)
+			t.Cleanup(func() { log.SetOutput(io.Discard) })
+			return conformanceTarget{w: NewLogMetrics("test"), events: lw.count}
+		}},
+		{"slog", func(*testing.T) conformanceTarget {
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := NewSlogMetrics("test", slog.New(h))
+			return conformanceTarget{w: m, events: func() int64 { return h.count() }}
+		}},
+		{"summary", func(t *testing.T) conformanceTarget {
+			m := NewSummaryLogMetrics("test", time.Hour)
+			t.Cleanup(m.Stop)
+			return conformanceTarget{w: m, gauge: m.gauge}
+		}},
+		{"rewrite", func(*testing.T) conformanceTarget {
+			rec := NewRecorderMetrics("test")
+			m := NewRewriteMetrics(rec, WithRewriteRules(RewritePrefix("tmp_", "tmp")), WithRewriteSanitize())
+			return conformanceTarget{w: m, gauge: rec.Gauge}
+		}},
+		{"multi", func(*testing.T) conformanceTarget {
+			pm := NewPrometheusMetricsWithOptions("test", WithRegisterer(prometheus.NewRegistry()))
+			return conformanceTarget{w: NewMultiMetrics(NewRecorderMetrics("test"), pm), gauge: pm.gauge}
+		}},
+		{"promtext", func(*testing.T) conformanceTarget {
+			g := &promtextValues{reg: promtext.NewRegistry(), kv: []string{"queue", "test"}}
+			m := promtext.NewMetrics("test", promtext.WithRegistry(g.reg))
+			return conformanceTarget{w: m, gauge: g.value, snapshot: g.snapshot}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, seed := range seeds {
+				t.Run(strconv.FormatUint(seed, 10), func(t *testing.T) {
+					checkConformance(t, tc.new(t), seed)
+				})
+			}
+		})
+	}
+}
+
+// Replay scripted and randomized (using seed) event sequences derived from upstream queue state machine into the
+// writer and check gauges invariants after each event:
+//   - workers gauges never become negative;
+//   - queue_workers_active + queue_workers_sleep + queue_workers_idle is equal to amount of workers;
+//   - gauges values are equal to the state of the replayed queue.
+//
+// Sequences contain item fail with Config.FailToDLQ: upstream worker (koykov/queue v1.1.4, worker.go) registers it as
+// QueuePull followed by QueueLeak, so the item decrements queue_size twice. Writers can't tell that leak from the leak
+// of queued item, thus queue_size is expected to lag behind the actual size by the number of such fails and may
+// become negative. This is an upstream issue, the harness replays it as is.
+//
+// Gauges checks on shadow RecorderMetrics receives the same events and on the writer if target has gauge reader.
+func checkConformance(t *testing.T, tg conformanceTarget, seed uint64) {
+	t.Helper()
+	c := &conformance{
+		conformanceTarget: tg,
+		rec:               NewRecorderMetrics("conformance"),
+		rnd:               rand.New(rand.NewPCG(seed, seed)),
+		workers:           make([]q.WorkerStatus, conformanceWorkers),
+		subq:              make(map[string]int64, len(conformanceSubqs)),
+	}
+	c.script()
+	for i := 0; i < conformanceSteps && c.err == nil; i++ {
+		c.random()
+		c.check()
+	}
+	if c.err != nil {
+		t.Errorf("seed %d: %s", seed, c.err)
+	}
+}
+
+// State of replayed queue.
+type conformance struct {
+	conformanceTarget
+	rec *RecorderMetrics
+	rnd *rand.Rand
+	err error
+	// Last replayed event and amount of replayed events.
+	event string
+	n     int64
+
+	workers []q.WorkerStatus
+	size    int64
+	subq    map[string]int64
+	// Expected value of queue_size gauge: the same as size, but lags behind after fails with Config.FailToDLQ.
+	gauge int64
+}
+
+// Replay scripted sequences.
+func (c *conformance) script() {
+	// Queue init: all workers puts to sleep and then setup resets gauges. Gauges are consistent only after setup.
+	for i := range c.workers {
+		c.emit("WorkerSleep", func(w MetricsWriter) { w.WorkerSleep(uint32(i)) })
+	}
+	c.setup()
+	c.check()
+
+	steps := []func(){
+		// Start workers.
+		func() { c.init(0) },
+		func() { c.init(1) },
+		// Regular put/pull.
+		c.put, c.put, func() { c.pull(0, false) }, func() { c.pull(1, true) },
+		// Fail with Config.FailToDLQ of the last item: queue_size becomes negative.
+		func() { c.fail(0, q.LeakDirectionRear) },
+		// Fill the queue and leak in both directions.
+		func() {
+			for c.size < conformanceCapacity {
+				c.put()
+			}
+		},
+		func() { c.leak(q.LeakDirectionRear) },
+		func() { c.leak(q.LeakDirectionFront) },
+		c.deadline,
+		// Fail with Config.FailToDLQ of an item from the full queue.
+		func() { c.fail(1, q.LeakDirectionFront) },
+		// Worker sleeps and wakes up.
+		func() { c.sleep(1) }, func() { c.wakeup(1) }, func() { c.sleep(1) }, func() { c.stop(1) },
+		// Sub-queues.
+		func() { c.subqPut("high") }, func() { c.subqPut("low") }, func() { c.subqPull("high") },
+		func() { c.subqLeak("low") },
+		// Schedule changes.
+		c.setup,
+		// Force close drains the queue.
+		c.close,
+	}
+	for i := 0; i < len(steps) && c.err == nil; i++ {
+		steps[i]()
+		c.check()
+	}
+}
+
+// Replay random valid event.
+func (c *conformance) random() {
+	idx := c.rnd.IntN(len(c.workers))
+	switch c.rnd.IntN(15) {
+	case 0, 1, 2:
+		c.put()
+	case 3, 4, 5:
+		if c.rnd.IntN(8) == 0 {
+			c.fail(idx, q.LeakDirection(c.rnd.IntN(2)))
+		} else {
+			c.pull(idx, c.rnd.IntN(4) == 0)
+		}
+	case 6:
+		c.leak(q.LeakDirection(c.rnd.IntN(2)))
+	case 7:
+		c.deadline()
+	case 8:
+		c.init(idx)
+	case 9:
+		c.sleep(idx)
+	case 10:
+		c.wakeup(idx)
+	case 11:
+		if c.rnd.IntN(2) == 0 {
+			c.stop(idx)
+		} else {
+			c.forceStop(idx)
+		}
+	case 12:
+		subq := conformanceSubqs[c.rnd.IntN(len(conformanceSubqs))]
+		switch c.rnd.IntN(3) {
+		case 0:
+			c.subqPut(subq)
+		case 1:
+			c.subqPull(subq)
+		default:
+			c.subqLeak(subq)
+		}
+	case 13:
+		c.setup()
+	case 14:
+		if c.rnd.IntN(50) == 0 {
+			c.close()
+		}
+	}
+}
+
+func (c *conformance) setup() {
+	active, sleep, idle := c.count()
+	c.emit("WorkerSetup", func(w MetricsWriter) { w.WorkerSetup(uint(active), uint(sleep), uint(idle)) })
+}
+
+func (c *conformance) init(idx int) {
+	if c.workers[idx] != q.WorkerStatusIdle {
+		return
+	}
+	c.workers[idx] = q.WorkerStatusActive
+	c.emit("WorkerInit", func(w MetricsWriter) { w.WorkerInit(uint32(idx)) })
+}
+
+func (c *conformance) sleep(idx int) {
+	if c.workers[idx] != q.WorkerStatusActive {
+		return
+	}
+	c.workers[idx] = q.WorkerStatusSleep
+	c.emit("WorkerSleep", func(w MetricsWriter) { w.WorkerSleep(uint32(idx)) })
+}
+
+func (c *conformance) wakeup(idx int) {
+	if c.workers[idx] != q.WorkerStatusSleep {
+		return
+	}
+	c.workers[idx] = q.WorkerStatusActive
+	c.emit("WorkerWakeup", func(w MetricsWriter) { w.WorkerWakeup(uint32(idx)) })
+}
+
+func (c *conformance) stop(idx int) {
+	// Only slept workers may be stopped regularly.
+	if c.workers[idx] != q.WorkerStatusSleep {
+		return
+	}
+	c.workers[idx] = q.WorkerStatusIdle
+	c.emit("WorkerStop", func(w MetricsWriter) { w.WorkerStop(uint32(idx), false, q.WorkerStatusSleep) })
+}
+
+func (c *conformance) forceStop(idx int) {
+	status := c.workers[idx]
+	if status == q.WorkerStatusIdle {
+		return
+	}
+	c.workers[idx] = q.WorkerStatusIdle
+	c.emit("WorkerStop", func(w MetricsWriter) { w.WorkerStop(uint32(idx), true, status) })
+}
+
+func (c *conformance) put() {
+	if c.size >= conformanceCapacity {
+		return
+	}
+	c.size++
+	c.gauge++
+	c.emit("QueuePut", func(w MetricsWriter) { w.QueuePut() })
+}
+
+// Put to the full queue in leaky mode.
+func (c *conformance) leak(dir q.LeakDirection) {
+	if dir == q.LeakDirectionFront && c.size == 0 {
+		return
+	}
+	// Income item counts as put, leaked item (new one or from the front) removes from the queue.
+	c.gauge++
+	c.emit("QueuePut", func(w MetricsWriter) { w.QueuePut() })
+	c.gauge--
+	c.emit("QueueLeak", func(w MetricsWriter) { w.QueueLeak(dir) })
+}
+
+func (c *conformance) pull(idx int, retry bool) {
+	if c.size == 0 || c.workers[idx] != q.WorkerStatusActive {
+		return
+	}
+	c.size--
+	c.gauge--
+	c.emit("QueuePull", func(w MetricsWriter) { w.QueuePull() })
+	c.wait(idx)
+	if retry {
+		// Failed item returns to the queue.
+		c.size++
+		c.gauge++
+		c.emit("QueueRetry", func(w MetricsWriter) { w.QueueRetry() })
+		c.emit("QueuePut", func(w MetricsWriter) { w.QueuePut() })
+	}
+}
+
+// Pull item, which fails with Config.FailToDLQ and leaks to DLQ.
+func (c *conformance) fail(idx int, dir q.LeakDirection) {
+	if c.size == 0 || c.workers[idx] != q.WorkerStatusActive {
+		return
+	}
+	c.size--
+	c.gauge--
+	c.emit("QueuePull", func(w MetricsWriter) { w.QueuePull() })
+	c.wait(idx)
+	// Item is already out of the queue, but upstream registers the leak and writers decrement queue_size again.
+	c.gauge--
+	c.emit("QueueLeak", func(w MetricsWriter) { w.QueueLeak(dir) })
+}
+
+// Optional wait of delayed execution.
+func (c *conformance) wait(idx int) {
+	if c.rnd.IntN(2) == 0 {
+		delay := time.Duration(c.rnd.IntN(1000)) * time.Microsecond
+		c.emit("WorkerWait", func(w MetricsWriter) { w.WorkerWait(uint32(idx), delay) })
+	}
+}
+
+func (c *conformance) deadline() {
+	if c.size == 0 {
+		return
+	}
+	c.size--
+	c.gauge--
+	c.emit("QueueDeadline", func(w MetricsWriter) { w.QueueDeadline() })
+}
+
+// Force close: stop all workers and throw the rest of items to DLQ or trash.
+func (c *conformance) close() {
+	for i := range c.workers {
+		c.forceStop(i)
+	}
+	for ; c.size > 0; c.size-- {
+		c.gauge--
+		if c.rnd.IntN(2) == 0 {
+			c.emit("QueueLeak", func(w MetricsWriter) { w.QueueLeak(q.LeakDirectionFront) })
+		} else {
+			c.emit("QueueLost", func(w MetricsWriter) { w.QueueLost() })
+		}
+	}
+}
+
+func (c *conformance) subqPut(subq string) {
+	c.subq[subq]++
+	c.emit("SubqPut", func(w MetricsWriter) { w.SubqPut(subq) })
+}
+
+func (c *conformance) subqPull(subq string) {
+	if c.subq[subq] == 0 {
+		return
+	}
+	c.subq[subq]--
+	c.emit("SubqPull", func(w MetricsWriter) { w.SubqPull(subq) })
+}
+
+func (c *conformance) subqLeak(subq string) {
+	if c.subq[subq] == 0 {
+		return
+	}
+	c.subq[subq]--
+	c.emit("SubqLeak", func(w MetricsWriter) { w.SubqLeak(subq) })
+}
+
+func (c *conformance) count() (active, sleep, idle int64) {
+	for _, status := range c.workers {
+		switch status {
+		case q.WorkerStatusActive:
+			active++
+		case q.WorkerStatusSleep:
+			sleep++
+		default:
+			idle++
+		}
+	}
+	return
+}
+
+// Pass event to shadow recorder and to the writer.
+func (c *conformance) emit(event string, fn func(w MetricsWriter)) {
+	if c.err != nil {
+		return
+	}
+	c.event = event
+	c.n++
+	fn(c.rec)
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("event %s: writer panic: %v", event, r)
+		}
+	}()
+	fn(c.w)
+}
+
+func (c *conformance) check() {
+	if c.err != nil {
+		return
+	}
+	c.checkValues("recorder", c.rec.Gauge)
+	if c.snapshot != nil {
+		c.snapshot()
+	}
+	if c.conformanceTarget.gauge != nil {
+		c.checkValues("writer", c.conformanceTarget.gauge)
+	}
+	if c.events != nil && c.err == nil {
+		if n := c.events(); n != c.n {
+			c.err = fmt.Errorf("after %s: writer: %d records written, expected %d", c.event, n, c.n)
+		}
+	}
+}
+
+func (c *conformance) checkValues(src string, gauge func(family string, labels ...string) int64) {
+	active, sleep, idle := c.count()
+	active = c.expect(src, gauge, "queue_workers_active", active)
+	sleep = c.expect(src, gauge, "queue_workers_sleep", sleep)
+	idle = c.expect(src, gauge, "queue_workers_idle", idle)
+	if sum := active + sleep + idle; c.err == nil && sum != int64(len(c.workers)) {
+		c.err = fmt.Errorf("after %s: %s: active+sleep+idle workers is %d, expected %d", c.event, src, sum, len(c.workers))
+	}
+	c.expect(src, gauge, "queue_size", c.gauge)
+	for _, subq := range conformanceSubqs {
+		c.expect(src, gauge, "queue_subq_size", c.subq[subq], "subq", subq)
+	}
+}
+
+// Check gauge value and return it.
+func (c *conformance) expect(src string, gauge func(family string, labels ...string) int64, family string,
+	value int64, labels ...string) int64 {
+	if c.err != nil {
+		return value
+	}
+	switch v := gauge(family, labels...); {
+	case v < 0 && value >= 0:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v is negative: %d", c.event, src, family, labels, v)
+		return v
+	case v != value:
+		c.err = fmt.Errorf("after %s: %s: gauge %s%v drifted: %d, expected %d", c.event, src, family, labels, v, value)
+		return v
+	}
+	return value
+}
+
+// Counts lines written by standard logger.
+type conformanceLines struct {
+	n int64
+}
+
+func (w *conformanceLines) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.n, int64(bytes.Count(p, []byte{'\n'})))
+	return len(p), nil
+}
+
+func (w *conformanceLines) count() int64 {
+	return atomic.LoadInt64(&w.n)
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m PrometheusMetrics) gauge(family string, labels ...string) int64 {
+	var vec *prometheus.GaugeVec
+	switch family {
+	case "queue_workers_idle":
+		vec = m.vec.workerIdle
+	case "queue_workers_active":
+		vec = m.vec.workerActive
+	case "queue_workers_sleep":
+		vec = m.vec.workerSleep
+	case "queue_size":
+		vec = m.vec.queueSize
+	case "queue_subq_size":
+		vec = m.vec.subqSize
+	default:
+		return 0
+	}
+	lv := []string{m.name}
+	for i := 1; i < len(labels); i += 2 {
+		lv = append(lv, labels[i])
+	}
+	var mt dto.Metric
+	if err := vec.WithLabelValues(lv...).Write(&mt); err != nil {
+		return 0
+	}
+	return int64(math.Round(mt.GetGauge().GetValue()))
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m *CollectorMetrics) gauge(family string, labels ...string) int64 {
+	switch family {
+	case "queue_workers_idle":
+		return m.workerIdle.Load()
+	case "queue_workers_active":
+		return m.workerActive.Load()
+	case "queue_workers_sleep":
+		return m.workerSleep.Load()
+	case "queue_size":
+		return m.queueSize.Load()
+	case "queue_subq_size":
+		if len(labels) > 1 {
+			return m.subq.get(labels[1]).size.Load()
+		}
+	}
+	return 0
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m aggrMetrics) gauge(family string, labels ...string) int64 {
+	if x := m.s.find(family, append([]string{"queue", m.name}, labels...)...); x != nil {
+		return x.load()
+	}
+	return 0
+}
+
+// Get value of gauge. Labels specifies the same way as in RecorderMetrics.Gauge method.
+func (m StatsDMetrics) gauge(family string, labels ...string) int64 {
+	return m.c.Gauge(family, append([]string{"queue", m.name}, labels...)...)
+}
+
+// Values of OTel writer collected by manual reader. Labels kv appends to labels of every series.
+type otelValues struct {
+	r  *sdkmetric.ManualReader
+	kv []string
+	rm metricdata.ResourceMetrics
+}
+
+func (g *otelValues) snapshot() {
+	g.rm = metricdata.ResourceMetrics{}
+	_ = g.r.Collect(context.Background(), &g.rm)
+}
+
+func (g *otelValues) value(family string, labels ...string) int64 {
+	data, _ := otelMetric(g.rm, family).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, append(g.kv[:len(g.kv):len(g.kv)], labels...)...) {
+			return p.Value
+		}
+	}
+	return 0
+}
+
+// Values of promtext writer parsed from text format rendered by registry. Labels kv prepends to labels of every
+// series.
+type promtextValues struct {
+	reg    *promtext.Registry
+	kv     []string
+	series map[string]int64
+}
+
+func (g *promtextValues) snapshot() {
+	var buf bytes.Buffer
+	_ = g.reg.WriteText(&buf)
+	g.series = make(map[string]int64)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if i := strings.LastIndexByte(line, ' '); i > 0 && !strings.HasPrefix(line, "#") {
+			f, _ := strconv.ParseFloat(line[i+1:], 64)
+			g.series[line[:i]] = int64(f)
+		}
+	}
+}
+
+func (g *promtextValues) value(family string, labels ...string) int64 {
+	// Text format sorts labels by name.
+	pairs := make([][2]string, 0, len(g.kv)/2+len(labels)/2)
+	for _, kv := range [][]string{g.kv, labels} {
+		for i := 0; i+1 < len(kv); i += 2 {
+			pairs = append(pairs, [2]string{kv[i], kv[i+1]})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	key := family
+	for i := range pairs {
+		sep := ","
+		if i == 0 {
+			sep = "{"
+		}
+		key += sep + pairs[i][0] + `="` + pairs[i][1] + `"`
+	}
+	if len(pairs) > 0 {
+		key += "}"
+	}
+	return g.series[key]
+}
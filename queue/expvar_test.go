@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"encoding/json"
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	m, err := NewExpvarMetrics("expvar_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.QueuePut()
+	m.QueuePut()
+	m.QueuePull()
+	m.QueueLeak(q.LeakDirectionFront)
+	m.WorkerWait(0, 1500*time.Millisecond)
+	m.SubqPut("a")
+
+	var root map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get("queue").String()), &root); err != nil {
+		t.Fatal(err)
+	}
+	got := root["expvar_test"]
+	const expected = `{
+		"queue_in": 2,
+		"queue_leak": {
+			"front": 1
+		},
+		"queue_out": 1,
+		"queue_size": 0,
+		"queue_subq_in": {
+			"a": 1
+		},
+		"queue_subq_size": {
+			"a": 1
+		},
+		"queue_wait": {
+			"count": 1,
+			"max": 1.5,
+			"sum": 1.5
+		}
+	}`
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		b, _ := json.Marshal(got)
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+}
+
+func TestExpvarMetricsConflict(t *testing.T) {
+	const name = "queue_expvar_conflict"
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return 0 }))
+	}
+	if _, err := expvarGetStore(name, "test"); err == nil {
+		t.Error("error on name published as non-map expected")
+	}
+}
@@ -0,0 +1,213 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// Local carbon stand-in: accepts connections and collects received plaintext lines or pickle payloads.
+type graphiteTestServer struct {
+	ln   net.Listener
+	recv chan string
+}
+
+func newGraphiteTestServer(t *testing.T, addr string, pickle bool) *graphiteTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &graphiteTestServer{ln: ln, recv: make(chan string, 1024)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, pickle)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *graphiteTestServer) serve(conn net.Conn, pickle bool) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		if pickle {
+			var hdr [4]byte
+			if _, err := io.ReadFull(r, hdr[:]); err != nil {
+				return
+			}
+			payload := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.recv <- string(payload)
+			continue
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		s.recv <- strings.TrimSuffix(line, "\n")
+	}
+}
+
+// Wait for lines with given paths and return their values.
+func (s *graphiteTestServer) expect(t *testing.T, paths ...string) map[string]string {
+	t.Helper()
+	r := make(map[string]string)
+	timeout := time.After(5 * time.Second)
+	for len(r) < len(paths) {
+		select {
+		case line := <-s.recv:
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				t.Fatalf("malformed line %q", line)
+			}
+			for _, p := range paths {
+				if fields[0] == p {
+					r[p] = fields[1]
+				}
+			}
+		case <-timeout:
+			t.Fatalf("paths not received, got %v of %v", r, paths)
+		}
+	}
+	return r
+}
+
+func TestGraphiteMetrics(t *testing.T) {
+	srv := newGraphiteTestServer(t, "127.0.0.1:0", false)
+	m := NewGraphiteMetrics("q.1 x", srv.ln.Addr().String(), WithGraphitePrefix("app."),
+		WithGraphiteInterval(time.Hour))
+	defer func() { _ = m.Close() }()
+
+	m.QueuePut()
+	m.QueuePut()
+	m.QueuePut()
+	m.QueueLeak(q.LeakDirectionFront)
+	m.SubqPut("hi/lo")
+	m.WorkerWait(0, 2*time.Second)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	v := srv.expect(t, "app.queue.q_1_x.in", "app.queue.q_1_x.size", "app.queue.q_1_x.leak.front",
+		"app.queue.q_1_x.subq.hi_lo.in", "app.queue.q_1_x.wait.count", "app.queue.q_1_x.wait.max")
+	for path, value := range map[string]string{
+		"app.queue.q_1_x.in":         "3",
+		"app.queue.q_1_x.size":       "2",
+		"app.queue.q_1_x.leak.front": "1",
+		"app.queue.q_1_x.wait.max":   "2",
+	} {
+		if v[path] != value {
+			t.Errorf("%s: got %s, expected %s", path, v[path], value)
+		}
+	}
+
+	t.Run("delta", func(t *testing.T) {
+		// Counters sends as increments since previous flush, gauges as is.
+		m.QueuePut()
+		if err := m.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		v := srv.expect(t, "app.queue.q_1_x.in", "app.queue.q_1_x.size")
+		if v["app.queue.q_1_x.in"] != "1" || v["app.queue.q_1_x.size"] != "3" {
+			t.Errorf("unexpected values %v", v)
+		}
+	})
+}
+
+func TestGraphiteMetricsPickle(t *testing.T) {
+	srv := newGraphiteTestServer(t, "127.0.0.1:0", true)
+	m := NewGraphiteMetrics("test", srv.ln.Addr().String(), WithGraphitePickle(), WithGraphiteInterval(time.Hour))
+	defer func() { _ = m.Close() }()
+
+	m.QueuePut()
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-srv.recv:
+		if !strings.HasPrefix(payload, "\x80\x02]") || !strings.HasSuffix(payload, "e.") ||
+			!strings.Contains(payload, "queue.test.in") {
+			t.Errorf("malformed pickle payload %q", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("payload not received")
+	}
+}
+
+func TestGraphiteMetricsReconnect(t *testing.T) {
+	// Reserve free address and keep it closed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	m := NewGraphiteMetrics("test", addr, WithGraphiteInterval(time.Hour),
+		WithGraphiteBackoff(50*time.Millisecond, time.Second))
+	defer func() { _ = m.Close() }()
+
+	m.QueuePut()
+	m.WorkerWait(0, 3*time.Second)
+	if err := m.Sync(); err == nil {
+		t.Fatal("dial error expected")
+	}
+	// Next attempt is postponed by backoff.
+	if err := m.Sync(); err != nil {
+		t.Fatalf("attempt within backoff must be skipped, got %s", err)
+	}
+
+	srv := newGraphiteTestServer(t, addr, false)
+	time.Sleep(60 * time.Millisecond)
+	m.QueuePut()
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// Data aggregated while carbon was unavailable must be delivered.
+	v := srv.expect(t, "queue.test.in", "queue.test.wait.max")
+	if v["queue.test.in"] != "2" || v["queue.test.wait.max"] != "3" {
+		t.Errorf("unexpected values %v", v)
+	}
+}
+
+func TestGraphiteMetricsWriteFail(t *testing.T) {
+	srv := newGraphiteTestServer(t, "127.0.0.1:0", false)
+	m := NewGraphiteMetrics("test", srv.ln.Addr().String(), WithGraphiteInterval(time.Hour),
+		WithGraphiteBackoff(time.Millisecond, time.Millisecond))
+	defer func() { _ = m.Close() }()
+
+	m.WorkerWait(0, 3*time.Second)
+	// Inject connection that fails on write.
+	c1, c2 := net.Pipe()
+	_ = c2.Close()
+	m.mux.Lock()
+	m.conn = c1
+	m.mux.Unlock()
+	if err := m.Sync(); err == nil {
+		t.Fatal("write error expected")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	m.WorkerWait(0, time.Second)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// Peak of failed flush must survive.
+	v := srv.expect(t, "queue.test.wait.count", "queue.test.wait.max")
+	if v["queue.test.wait.count"] != "2" || v["queue.test.wait.max"] != "3" {
+		t.Errorf("unexpected values %v", v)
+	}
+}
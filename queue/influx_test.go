@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// Writer fails while err is set.
+type influxTestWriter struct {
+	bytes.Buffer
+	err error
+}
+
+func (w *influxTestWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestInfluxMetrics(t *testing.T) {
+	w := &influxTestWriter{}
+	m := NewInfluxMetrics("test q", w, WithInfluxInterval(time.Hour), WithInfluxTags(map[string]string{"host": "h1"}))
+	defer func() { _ = m.Close() }()
+
+	m.QueuePut()
+	m.QueuePut()
+	m.QueueLeak(q.LeakDirectionFront)
+	m.WorkerWait(0, 3*time.Second)
+	m.WorkerWait(0, time.Second)
+	if err := m.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	out := w.String()
+	for _, line := range []string{
+		`queue_in,host=h1,queue=test\ q value=2i `,
+		`queue_size,host=h1,queue=test\ q value=1i `,
+		`queue_leak,dir=front,host=h1,queue=test\ q value=1i `,
+		`queue_wait,host=h1,queue=test\ q count=2i,sum=4,max=3 `,
+	} {
+		if !strings.Contains(out, "\n"+line) && !strings.HasPrefix(out, line) {
+			t.Errorf("line %q not found in output:\n%s", line, out)
+		}
+	}
+
+	t.Run("peak", func(t *testing.T) {
+		w.Reset()
+		m.WorkerWait(0, 2*time.Second)
+		w.err = errors.New("write failed")
+		if err := m.Sync(); err == nil {
+			t.Fatal("error expected")
+		}
+		// Peak of failed flush must survive till the next successful one.
+		w.err = nil
+		m.WorkerWait(0, time.Second)
+		if err := m.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(w.String(), "count=4i,sum=7,max=2 ") {
+			t.Errorf("peak lost:\n%s", w.String())
+		}
+		// Successful flush resets the peak.
+		w.Reset()
+		if err := m.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(w.String(), "count=4i,sum=7,max=0 ") {
+			t.Errorf("peak isn't reset:\n%s", w.String())
+		}
+	})
+}
+
+func TestInfluxEscape(t *testing.T) {
+	for _, tc := range []struct {
+		s, special, expect string
+	}{
+		{"size", ", ", "size"},
+		{"a,b c", ", ", `a\,b\ c`},
+		{"a=b", ", ", "a=b"},
+		{"a=b", ",= ", `a\=b`},
+		{`a\b`, ", ", `a\\b`},
+		// Line breaks escape as spaces.
+		{"queue\nsize\r", ", ", `queue\ size\ `},
+	} {
+		var buf bytes.Buffer
+		if influxEscape(&buf, tc.s, tc.special); buf.String() != tc.expect {
+			t.Errorf("%q: got %q, expected %q", tc.s, buf.String(), tc.expect)
+		}
+	}
+}
@@ -4,44 +4,75 @@ import (
 	"log"
 	"time"
 
+	"github.com/koykov/metrics_writers/internal/loglimit"
 	q "github.com/koykov/queue"
 )
 
 // LogMetrics is Log implementation of queue.MetricsWriter.
 //
-// Don't use in production. Only for debug purposes.
+// Don't use in production without limits (see NewLogMetricsWithLimits). Only for debug purposes.
+//
+// Deprecated: use SlogMetrics instead.
 type LogMetrics struct {
 	name string
+	lim  *loglimit.Limiter
 }
 
-var _ = NewLogMetrics
+var _, _ = NewLogMetrics, NewLogMetricsWithLimits
 
 func NewLogMetrics(name string) *LogMetrics {
-	m := &LogMetrics{name}
+	m := &LogMetrics{name: name}
+	return m
+}
+
+// NewLogMetricsWithLimits makes new writer with sampling and/or rate limiting of events.
+func NewLogMetricsWithLimits(name string, opts ...LogLimitOption) *LogMetrics {
+	m := &LogMetrics{name: name}
+	m.lim = newLogLimiter(opts, func(event string, n uint64) {
+		log.Printf("queue %s: suppressed %d events of type %s\n", name, n, event)
+	})
 	return m
 }
 
 func (m LogMetrics) WorkerSetup(active, sleep, stop uint) {
+	if !m.lim.Allow("WorkerSetup") {
+		return
+	}
 	log.Printf("queue #%s: setup workers %d active, %d sleep and %d stop", m.name, active, sleep, stop)
 }
 
 func (m LogMetrics) WorkerInit(idx uint32) {
+	if !m.lim.Allow("WorkerInit") {
+		return
+	}
 	log.Printf("queue %s: worker %d caught init signal\n", m.name, idx)
 }
 
 func (m LogMetrics) WorkerSleep(idx uint32) {
+	if !m.lim.Allow("WorkerSleep") {
+		return
+	}
 	log.Printf("queue %s: worker %d caught sleep signal\n", m.name, idx)
 }
 
 func (m LogMetrics) WorkerWakeup(idx uint32) {
+	if !m.lim.Allow("WorkerWakeup") {
+		return
+	}
 	log.Printf("queue %s: worker %d caught wakeup signal\n", m.name, idx)
 }
 
 func (m LogMetrics) WorkerWait(idx uint32, delay time.Duration) {
+	if !m.lim.Allow("WorkerWait") {
+		return
+	}
 	log.Printf("queue %s: worker %d waits %s\n", m.name, idx, delay)
 }
 
 func (m LogMetrics) WorkerStop(idx uint32, force bool, status q.WorkerStatus) {
+	if !m.lim.Allow("WorkerStop") {
+		return
+	}
 	if force {
 		log.Printf("queue %s: worker %d caught force stop signal (current status %d)\n", m.name, idx, status)
 	} else {
@@ -50,18 +81,30 @@ func (m LogMetrics) WorkerStop(idx uint32, force bool, status q.WorkerStatus) {
 }
 
 func (m LogMetrics) QueuePut() {
+	if !m.lim.Allow("QueuePut") {
+		return
+	}
 	log.Printf("queue %s: new item come to the queue\n", m.name)
 }
 
 func (m LogMetrics) QueuePull() {
+	if !m.lim.Allow("QueuePull") {
+		return
+	}
 	log.Printf("queue %s: item leave the queue\n", m.name)
 }
 
 func (m LogMetrics) QueueRetry() {
+	if !m.lim.Allow("QueueRetry") {
+		return
+	}
 	log.Printf("queue %s: retry item processing due to fail\n", m.name)
 }
 
 func (m LogMetrics) QueueLeak(dir q.LeakDirection) {
+	if !m.lim.Allow("QueueLeak") {
+		return
+	}
 	dirs := "rear"
 	if dir == q.LeakDirectionFront {
 		dirs = "front"
@@ -70,21 +113,63 @@ func (m LogMetrics) QueueLeak(dir q.LeakDirection) {
 }
 
 func (m LogMetrics) QueueDeadline() {
+	if !m.lim.Allow("QueueDeadline") {
+		return
+	}
 	log.Printf("queue %s: queue deadline\n", m.name)
 }
 
 func (m LogMetrics) QueueLost() {
+	if !m.lim.Allow("QueueLost") {
+		return
+	}
 	log.Printf("queue %s: queue lost\n", m.name)
 }
 
+func (m LogMetrics) SubqPut(subq string) {
+	if !m.lim.Allow("SubqPut") {
+		return
+	}
+	log.Printf("queue %s/%s: new item come to the sub-queue\n", m.name, subq)
+}
+
+func (m LogMetrics) SubqPull(subq string) {
+	if !m.lim.Allow("SubqPull") {
+		return
+	}
+	log.Printf("queue %s/%s: item leave the sub-queue\n", m.name, subq)
+}
+
+func (m LogMetrics) SubqLeak(subq string) {
+	if !m.lim.Allow("SubqLeak") {
+		return
+	}
+	log.Printf("queue %s/%s: sub-queue leak\n", m.name, subq)
+}
+
+// SubQueuePut registers income of new item to the sub-queue.
+//
+// Deprecated: use SubqPut instead.
 func (m LogMetrics) SubQueuePut(subq string) {
-	log.Printf("queue %s/%s: new item come to the queue\n", m.name, subq)
+	m.SubqPut(subq)
 }
 
+// SubQueuePull registers outgoing of item from the sub-queue.
+//
+// Deprecated: use SubqPull instead.
 func (m LogMetrics) SubQueuePull(subq string) {
-	log.Printf("queue %s/%s: item leave the queue\n", m.name, subq)
+	m.SubqPull(subq)
 }
 
+// SubQueueDrop registers item's leak from the full sub-queue.
+//
+// Deprecated: use SubqLeak instead.
 func (m LogMetrics) SubQueueDrop(subq string) {
-	log.Printf("queue %s/%s: queue drop item\n", m.name, subq)
+	m.SubqLeak(subq)
+}
+
+// Close stops reporting of suppressed events.
+func (m LogMetrics) Close() error {
+	m.lim.Close()
+	return nil
 }
@@ -0,0 +1,180 @@
+package queue
+
+import (
+	"io"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// MultiMetrics is fan-out implementation of queue.MetricsWriter.
+//
+// Each event forwards to all child writers in order they were specified. Allows to use several writers at once, eg
+// Prometheus writer in production together with log writer for debug purposes.
+//
+// Child writers must implement upstream queue.MetricsWriter only. Events missing in upstream interface (deadline and
+// sub-queues events) forward to children implement corresponding methods.
+type MultiMetrics struct {
+	w    []q.MetricsWriter
+	conf multiConfig
+}
+
+// Optional methods of child writers.
+type (
+	multiDeadline interface {
+		QueueDeadline()
+	}
+	multiSubq interface {
+		SubqPut(subq string)
+		SubqPull(subq string)
+		SubqLeak(subq string)
+	}
+)
+
+// MultiOption describes MultiMetrics option.
+type MultiOption func(*multiConfig)
+
+type multiConfig struct {
+	isolate bool
+	onPanic func(w q.MetricsWriter, r any)
+}
+
+// WithMultiRecover enables panic isolation: panic in child writer recovers and doesn't affect the rest of writers.
+// Optional fn receives failed writer and recovered value.
+func WithMultiRecover(fn func(w q.MetricsWriter, r any)) MultiOption {
+	return func(c *multiConfig) {
+		c.isolate = true
+		c.onPanic = fn
+	}
+}
+
+var _, _ = NewMultiMetrics, NewMultiMetricsWithOptions
+
+// NewMultiMetrics makes new writer forwards events to given writers.
+func NewMultiMetrics(writers ...q.MetricsWriter) *MultiMetrics {
+	return NewMultiMetricsWithOptions(writers)
+}
+
+// NewMultiMetricsWithOptions makes new writer forwards events to given writers and applies given options to it.
+//
+// Nil writers skips.
+func NewMultiMetricsWithOptions(writers []q.MetricsWriter, opts ...MultiOption) *MultiMetrics {
+	m := &MultiMetrics{w: make([]q.MetricsWriter, 0, len(writers))}
+	for _, w := range writers {
+		if w != nil {
+			m.w = append(m.w, w)
+		}
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	return m
+}
+
+func (m MultiMetrics) WorkerSetup(active, sleep, stop uint) {
+	m.each(func(w q.MetricsWriter) { w.WorkerSetup(active, sleep, stop) })
+}
+
+func (m MultiMetrics) WorkerInit(idx uint32) {
+	m.each(func(w q.MetricsWriter) { w.WorkerInit(idx) })
+}
+
+func (m MultiMetrics) WorkerSleep(idx uint32) {
+	m.each(func(w q.MetricsWriter) { w.WorkerSleep(idx) })
+}
+
+func (m MultiMetrics) WorkerWakeup(idx uint32) {
+	m.each(func(w q.MetricsWriter) { w.WorkerWakeup(idx) })
+}
+
+func (m MultiMetrics) WorkerWait(idx uint32, delay time.Duration) {
+	m.each(func(w q.MetricsWriter) { w.WorkerWait(idx, delay) })
+}
+
+func (m MultiMetrics) WorkerStop(idx uint32, force bool, status q.WorkerStatus) {
+	m.each(func(w q.MetricsWriter) { w.WorkerStop(idx, force, status) })
+}
+
+func (m MultiMetrics) QueuePut() {
+	m.each(func(w q.MetricsWriter) { w.QueuePut() })
+}
+
+func (m MultiMetrics) QueuePull() {
+	m.each(func(w q.MetricsWriter) { w.QueuePull() })
+}
+
+func (m MultiMetrics) QueueRetry() {
+	m.each(func(w q.MetricsWriter) { w.QueueRetry() })
+}
+
+func (m MultiMetrics) QueueLeak(dir q.LeakDirection) {
+	m.each(func(w q.MetricsWriter) { w.QueueLeak(dir) })
+}
+
+func (m MultiMetrics) QueueDeadline() {
+	m.each(func(w q.MetricsWriter) {
+		if d, ok := w.(multiDeadline); ok {
+			d.QueueDeadline()
+		}
+	})
+}
+
+func (m MultiMetrics) QueueLost() {
+	m.each(func(w q.MetricsWriter) { w.QueueLost() })
+}
+
+func (m MultiMetrics) SubqPut(subq string) {
+	m.each(func(w q.MetricsWriter) {
+		if s, ok := w.(multiSubq); ok {
+			s.SubqPut(subq)
+		}
+	})
+}
+
+func (m MultiMetrics) SubqPull(subq string) {
+	m.each(func(w q.MetricsWriter) {
+		if s, ok := w.(multiSubq); ok {
+			s.SubqPull(subq)
+		}
+	})
+}
+
+func (m MultiMetrics) SubqLeak(subq string) {
+	m.each(func(w q.MetricsWriter) {
+		if s, ok := w.(multiSubq); ok {
+			s.SubqLeak(subq)
+		}
+	})
+}
+
+// Close closes all child writers implement io.Closer and returns the first occurred error.
+func (m MultiMetrics) Close() (err error) {
+	m.each(func(w q.MetricsWriter) {
+		if c, ok := w.(io.Closer); ok {
+			if err1 := c.Close(); err == nil {
+				err = err1
+			}
+		}
+	})
+	return
+}
+
+func (m MultiMetrics) each(fn func(w q.MetricsWriter)) {
+	for i := 0; i < len(m.w); i++ {
+		if m.conf.isolate {
+			m.safe(m.w[i], fn)
+		} else {
+			fn(m.w[i])
+		}
+	}
+}
+
+// Call fn and recover panic.
+func (m MultiMetrics) safe(w q.MetricsWriter, fn func(w q.MetricsWriter)) {
+	defer func() {
+		if r := recover(); r != nil && m.conf.onPanic != nil {
+			m.conf.onPanic(w, r)
+		}
+	}()
+	fn(w)
+}
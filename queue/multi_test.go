@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+
+	q "github.com/koykov/queue"
+)
+
+// Child writer logs its id on each event and optionally panics.
+type multiTestWriter struct {
+	q.MetricsWriter
+	id   int
+	log  *[]int
+	fail bool
+}
+
+func (w *multiTestWriter) QueuePut() {
+	*w.log = append(*w.log, w.id)
+	if w.fail {
+		panic("writer failed")
+	}
+}
+
+func TestMultiMetrics(t *testing.T) {
+	var log []int
+	a := &multiTestWriter{id: 1, log: &log}
+	b := &multiTestWriter{id: 2, log: &log}
+	c := &multiTestWriter{id: 3, log: &log}
+
+	t.Run("order", func(t *testing.T) {
+		log = log[:0]
+		NewMultiMetrics(a, nil, b, c).QueuePut()
+		if s := fmt.Sprint(log); s != "[1 2 3]" {
+			t.Errorf("got order %s, expected [1 2 3]", s)
+		}
+	})
+	t.Run("recover", func(t *testing.T) {
+		log = log[:0]
+		b.fail = true
+		defer func() { b.fail = false }()
+		var (
+			failed q.MetricsWriter
+			value  any
+		)
+		m := NewMultiMetricsWithOptions([]q.MetricsWriter{a, b, c}, WithMultiRecover(func(w q.MetricsWriter, r any) {
+			failed, value = w, r
+		}))
+		m.QueuePut()
+		// Panic of one writer doesn't affect the rest of writers.
+		if s := fmt.Sprint(log); s != "[1 2 3]" {
+			t.Errorf("got order %s, expected [1 2 3]", s)
+		}
+		if failed != b || value != "writer failed" {
+			t.Errorf("got failed writer %v and value %v", failed, value)
+		}
+	})
+	t.Run("panic", func(t *testing.T) {
+		log = log[:0]
+		b.fail = true
+		defer func() { b.fail = false }()
+		defer func() {
+			if r := recover(); r != "writer failed" {
+				t.Errorf("panic expected, got %v", r)
+			}
+			// Writers after failed one don't receive event without isolation.
+			if s := fmt.Sprint(log); s != "[1 2]" {
+				t.Errorf("got order %s, expected [1 2]", s)
+			}
+		}()
+		NewMultiMetrics(a, b, c).QueuePut()
+	})
+
+	t.Run("upstream", func(t *testing.T) {
+		// Writer implements upstream interface only, so it misses events absent there.
+		up, full := NewRecorderMetrics("test"), NewRecorderMetrics("test")
+		m := NewMultiMetrics(struct{ q.MetricsWriter }{up}, full)
+		m.QueuePut()
+		m.QueueDeadline()
+		m.SubqPut("hi")
+		if up.Count("QueuePut") != 1 || up.Count("QueueDeadline") != 0 || up.Count("SubqPut") != 0 {
+			t.Errorf("upstream writer: unexpected events %v", up.Events())
+		}
+		if full.Count("QueuePut") != 1 || full.Count("QueueDeadline") != 1 || full.Count("SubqPut", "hi") != 1 {
+			t.Errorf("full writer: unexpected events %v", full.Events())
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	q "github.com/koykov/queue"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics is an OpenTelemetry implementation of queue.MetricsWriter.
+type OTelMetrics struct {
+	name string
+	attr metric.MeasurementOption
+	ins  *otelInstruments
+	done *uint32
+	// Mirror of workers gauges to calculate deltas on setup.
+	wrk *otelWorkers
+}
+
+type otelInstruments struct {
+	queueSize, subqSize, workerIdle, workerActive, workerSleep metric.Int64UpDownCounter
+	queueIn, queueOut, queueRetry, queueLeak, queueDeadline, queueLost,
+	subqIn, subqOut, subqLeak metric.Int64Counter
+
+	workerWait metric.Float64Histogram
+}
+
+type otelWorkers struct {
+	idle, active, sleep int64
+}
+
+// OTelOption describes OTelMetrics option.
+type OTelOption func(*otelConfig)
+
+type otelConfig struct {
+	mp metric.MeterProvider
+}
+
+// WithMeterProvider sets meter provider to use instead of global one.
+func WithMeterProvider(mp metric.MeterProvider) OTelOption {
+	return func(c *otelConfig) {
+		c.mp = mp
+	}
+}
+
+const otelScope = "github.com/koykov/metrics_writers/queue"
+
+var _ = NewOTelMetrics
+
+// NewOTelMetrics makes new OpenTelemetry writer.
+//
+// By default, instruments creates using global meter provider (see otel.GetMeterProvider).
+func NewOTelMetrics(name string, opts ...OTelOption) *OTelMetrics {
+	var c otelConfig
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.mp == nil {
+		c.mp = otel.GetMeterProvider()
+	}
+	m := &OTelMetrics{
+		name: name,
+		attr: metric.WithAttributeSet(attribute.NewSet(attribute.String("queue", name))),
+		ins:  newOTelInstruments(c.mp.Meter(otelScope)),
+		done: new(uint32),
+		wrk:  &otelWorkers{},
+	}
+	return m
+}
+
+func newOTelInstruments(meter metric.Meter) *otelInstruments {
+	ins := &otelInstruments{}
+	ins.workerIdle = otelCheck(meter.Int64UpDownCounter("queue_workers_idle",
+		metric.WithDescription("Indicates how many workers idle.")))
+	ins.workerActive = otelCheck(meter.Int64UpDownCounter("queue_workers_active",
+		metric.WithDescription("Indicates how many workers active.")))
+	ins.workerSleep = otelCheck(meter.Int64UpDownCounter("queue_workers_sleep",
+		metric.WithDescription("Indicates how many workers sleep.")))
+
+	ins.queueSize = otelCheck(meter.Int64UpDownCounter("queue_size",
+		metric.WithDescription("Actual queue size.")))
+
+	ins.queueIn = otelCheck(meter.Int64Counter("queue_in",
+		metric.WithDescription("How many items comes to the queue.")))
+	ins.queueOut = otelCheck(meter.Int64Counter("queue_out",
+		metric.WithDescription("How many items leaves queue.")))
+	ins.queueRetry = otelCheck(meter.Int64Counter("queue_retry",
+		metric.WithDescription("How many retries occurs.")))
+	ins.queueLeak = otelCheck(meter.Int64Counter("queue_leak",
+		metric.WithDescription("How many items dropped on the floor due to queue is full.")))
+	ins.queueDeadline = otelCheck(meter.Int64Counter("queue_deadline",
+		metric.WithDescription("How many processing skips due to deadline.")))
+	ins.queueLost = otelCheck(meter.Int64Counter("queue_lost",
+		metric.WithDescription("How many items throw to the trash due to force close.")))
+
+	ins.workerWait = otelCheck(meter.Float64Histogram("queue_wait",
+		metric.WithDescription("How many worker waits due to delayed execution."),
+		metric.WithUnit("s")))
+
+	ins.subqSize = otelCheck(meter.Int64UpDownCounter("queue_subq_size",
+		metric.WithDescription("Actual queue size.")))
+	ins.subqIn = otelCheck(meter.Int64Counter("queue_subq_in",
+		metric.WithDescription("How many items comes to the sub-queue.")))
+	ins.subqOut = otelCheck(meter.Int64Counter("queue_subq_out",
+		metric.WithDescription("How many items leaves sub-queue.")))
+	ins.subqLeak = otelCheck(meter.Int64Counter("queue_subq_leak",
+		metric.WithDescription("How many items dropped on the floor due to sub-queue is full.")))
+
+	return ins
+}
+
+func (m OTelMetrics) WorkerSetup(active, sleep, stop uint) {
+	if m.closed() {
+		return
+	}
+	// Up-down counters can't be reset, so apply difference between new and current values.
+	ctx := context.Background()
+	m.ins.workerActive.Add(ctx, int64(active)-atomic.SwapInt64(&m.wrk.active, int64(active)), m.attr)
+	m.ins.workerSleep.Add(ctx, int64(sleep)-atomic.SwapInt64(&m.wrk.sleep, int64(sleep)), m.attr)
+	m.ins.workerIdle.Add(ctx, int64(stop)-atomic.SwapInt64(&m.wrk.idle, int64(stop)), m.attr)
+}
+
+func (m OTelMetrics) WorkerInit(_ uint32) {
+	if m.closed() {
+		return
+	}
+	m.workerAdd(&m.wrk.active, m.ins.workerActive, 1)
+	m.workerAdd(&m.wrk.idle, m.ins.workerIdle, -1)
+}
+
+func (m OTelMetrics) WorkerSleep(_ uint32) {
+	if m.closed() {
+		return
+	}
+	m.workerAdd(&m.wrk.sleep, m.ins.workerSleep, 1)
+	m.workerAdd(&m.wrk.active, m.ins.workerActive, -1)
+}
+
+func (m OTelMetrics) WorkerWakeup(_ uint32) {
+	if m.closed() {
+		return
+	}
+	m.workerAdd(&m.wrk.active, m.ins.workerActive, 1)
+	m.workerAdd(&m.wrk.sleep, m.ins.workerSleep, -1)
+}
+
+func (m OTelMetrics) WorkerWait(_ uint32, delay time.Duration) {
+	if m.closed() {
+		return
+	}
+	m.ins.workerWait.Record(context.Background(), delay.Seconds(), m.attr)
+}
+
+func (m OTelMetrics) WorkerStop(_ uint32, force bool, status q.WorkerStatus) {
+	if m.closed() {
+		return
+	}
+	m.workerAdd(&m.wrk.idle, m.ins.workerIdle, 1)
+	if force {
+		switch status {
+		case q.WorkerStatusActive:
+			m.workerAdd(&m.wrk.active, m.ins.workerActive, -1)
+		case q.WorkerStatusSleep:
+			m.workerAdd(&m.wrk.sleep, m.ins.workerSleep, -1)
+		}
+	} else {
+		m.workerAdd(&m.wrk.sleep, m.ins.workerSleep, -1)
+	}
+}
+
+func (m OTelMetrics) QueuePut() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.queueIn.Add(ctx, 1, m.attr)
+	m.ins.queueSize.Add(ctx, 1, m.attr)
+}
+
+func (m OTelMetrics) QueuePull() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.queueOut.Add(ctx, 1, m.attr)
+	m.ins.queueSize.Add(ctx, -1, m.attr)
+}
+
+func (m OTelMetrics) QueueRetry() {
+	if m.closed() {
+		return
+	}
+	m.ins.queueRetry.Add(context.Background(), 1, m.attr)
+}
+
+func (m OTelMetrics) QueueLeak(dir q.LeakDirection) {
+	if m.closed() {
+		return
+	}
+	dirs := "rear"
+	if dir == q.LeakDirectionFront {
+		dirs = "front"
+	}
+	ctx := context.Background()
+	m.ins.queueLeak.Add(ctx, 1, metric.WithAttributes(attribute.String("queue", m.name), attribute.String("dir", dirs)))
+	m.ins.queueSize.Add(ctx, -1, m.attr)
+}
+
+func (m OTelMetrics) QueueDeadline() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.queueDeadline.Add(ctx, 1, m.attr)
+	m.ins.queueSize.Add(ctx, -1, m.attr)
+}
+
+func (m OTelMetrics) QueueLost() {
+	if m.closed() {
+		return
+	}
+	ctx := context.Background()
+	m.ins.queueLost.Add(ctx, 1, m.attr)
+	m.ins.queueSize.Add(ctx, -1, m.attr)
+}
+
+func (m OTelMetrics) SubqPut(subq string) {
+	if m.closed() {
+		return
+	}
+	ctx, attr := context.Background(), m.subqAttr(subq)
+	m.ins.subqIn.Add(ctx, 1, attr)
+	m.ins.subqSize.Add(ctx, 1, attr)
+}
+
+func (m OTelMetrics) SubqPull(subq string) {
+	if m.closed() {
+		return
+	}
+	ctx, attr := context.Background(), m.subqAttr(subq)
+	m.ins.subqOut.Add(ctx, 1, attr)
+	m.ins.subqSize.Add(ctx, -1, attr)
+}
+
+func (m OTelMetrics) SubqLeak(subq string) {
+	if m.closed() {
+		return
+	}
+	ctx, attr := context.Background(), m.subqAttr(subq)
+	m.ins.subqLeak.Add(ctx, 1, attr)
+	m.ins.subqSize.Add(ctx, -1, attr)
+}
+
+func (m OTelMetrics) subqAttr(subq string) metric.MeasurementOption {
+	return metric.WithAttributes(attribute.String("queue", m.name), attribute.String("subq", subq))
+}
+
+func (m OTelMetrics) workerAdd(mirror *int64, ins metric.Int64UpDownCounter, delta int64) {
+	atomic.AddInt64(mirror, delta)
+	ins.Add(context.Background(), delta, m.attr)
+}
+
+// Close stops reporting of the writer, all further events are dropped.
+//
+// Note, OpenTelemetry instruments can't forget attributes sets, so exporter with cumulative temporality keeps reporting
+// last values of closed writer. Use delta temporality to drop its series from exports. Workers gauges resets to zero.
+func (m OTelMetrics) Close() error {
+	if !atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		return nil
+	}
+	ctx := context.Background()
+	m.ins.workerActive.Add(ctx, -atomic.SwapInt64(&m.wrk.active, 0), m.attr)
+	m.ins.workerSleep.Add(ctx, -atomic.SwapInt64(&m.wrk.sleep, 0), m.attr)
+	m.ins.workerIdle.Add(ctx, -atomic.SwapInt64(&m.wrk.idle, 0), m.attr)
+	return nil
+}
+
+func (m OTelMetrics) closed() bool {
+	return atomic.LoadUint32(m.done) == 1
+}
+
+// Pass instrument through and report creation error to global OTel error handler.
+func otelCheck[T any](ins T, err error) T {
+	if err != nil {
+		otel.Handle(err)
+	}
+	return ins
+}
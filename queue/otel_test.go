@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	m := NewOTelMetrics("test", WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))))
+
+	m.WorkerSetup(0, 0, 4)
+	m.WorkerInit(0)
+	m.WorkerInit(1)
+	m.WorkerSleep(1)
+	m.QueuePut()
+	m.QueuePut()
+	m.QueuePut()
+	m.QueuePull()
+	m.WorkerWait(0, 50*time.Millisecond)
+	m.QueueLeak(q.LeakDirectionFront)
+	m.QueueRetry()
+	m.SubqPut("high")
+	m.SubqPut("high")
+	m.SubqPull("high")
+
+	rm := otelCollect(t, r)
+	otelExpectSum(t, rm, "queue_workers_active", 1, "queue", "test")
+	otelExpectSum(t, rm, "queue_workers_sleep", 1, "queue", "test")
+	otelExpectSum(t, rm, "queue_workers_idle", 2, "queue", "test")
+	otelExpectSum(t, rm, "queue_in", 3, "queue", "test")
+	otelExpectSum(t, rm, "queue_out", 1, "queue", "test")
+	otelExpectSum(t, rm, "queue_size", 1, "queue", "test")
+	otelExpectSum(t, rm, "queue_leak", 1, "queue", "test", "dir", "front")
+	otelExpectSum(t, rm, "queue_retry", 1, "queue", "test")
+	otelExpectSum(t, rm, "queue_subq_size", 1, "queue", "test", "subq", "high")
+	otelExpectSum(t, rm, "queue_subq_in", 2, "queue", "test", "subq", "high")
+	otelExpectHist(t, rm, "queue_wait", 1, .05, "queue", "test")
+
+	t.Run("setup", func(t *testing.T) {
+		m.WorkerSetup(3, 1, 0)
+		rm := otelCollect(t, r)
+		otelExpectSum(t, rm, "queue_workers_active", 3, "queue", "test")
+		otelExpectSum(t, rm, "queue_workers_sleep", 1, "queue", "test")
+		otelExpectSum(t, rm, "queue_workers_idle", 0, "queue", "test")
+	})
+	t.Run("close", func(t *testing.T) {
+		if err := m.Close(); err != nil {
+			t.Fatal(err)
+		}
+		m.QueuePut()
+		rm := otelCollect(t, r)
+		otelExpectSum(t, rm, "queue_in", 3, "queue", "test")
+		otelExpectSum(t, rm, "queue_workers_active", 0, "queue", "test")
+	})
+}
+
+func otelCollect(t *testing.T, r *sdkmetric.ManualReader) (rm metricdata.ResourceMetrics) {
+	t.Helper()
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// Find metric by name.
+func otelMetric(rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	for _, sm := range rm.ScopeMetrics {
+		for _, mt := range sm.Metrics {
+			if mt.Name == name {
+				return mt
+			}
+		}
+	}
+	return metricdata.Metrics{}
+}
+
+// Check if attributes set consists of exactly given key-value pairs.
+func otelAttrsMatch(set attribute.Set, kv ...string) bool {
+	if set.Len() != len(kv)/2 {
+		return false
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if v, ok := set.Value(attribute.Key(kv[i])); !ok || v.AsString() != kv[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func otelExpectSum(t *testing.T, rm metricdata.ResourceMetrics, name string, value int64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Sum[int64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Value != value {
+				t.Errorf("%s%v: got %d, expected %d", name, kv, p.Value, value)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
+
+func otelExpectHist(t *testing.T, rm metricdata.ResourceMetrics, name string, count uint64, sum float64, kv ...string) {
+	t.Helper()
+	data, _ := otelMetric(rm, name).Data.(metricdata.Histogram[float64])
+	for _, p := range data.DataPoints {
+		if otelAttrsMatch(p.Attributes, kv...) {
+			if p.Count != count || p.Sum != sum {
+				t.Errorf("%s%v: got count %d sum %g, expected count %d sum %g", name, kv, p.Count, p.Sum, count, sum)
+			}
+			return
+		}
+	}
+	t.Errorf("%s%v: no data point", name, kv)
+}
@@ -1,7 +1,10 @@
 package queue
 
 import (
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	q "github.com/koykov/queue"
 	"github.com/prometheus/client_golang/prometheus"
@@ -10,195 +13,425 @@ import (
 // PrometheusMetrics is a Prometheus implementation of queue.MetricsWriter.
 type PrometheusMetrics struct {
 	name string
+	hist string
 	prec time.Duration
+	sec  bool
+	exm  func() prometheus.Labels
+	vec  *promVecs
+	hnd  *atomic.Pointer[promHandles]
+	done *uint32
+	lim  promLimit
 }
 
-var (
-	promQueueSize, promSubqSize, promWorkerIdle, promWorkerActive, promWorkerSleep *prometheus.GaugeVec
-	promQueueIn, promQueueOut, promQueueRetry, promQueueLeak, promQueueDeadline, promQueueLost,
-	promSubqIn, promSubqOut, promSubqLeak *prometheus.CounterVec
+// Collection of vectors registered in the same registry.
+type promVecs struct {
+	queueSize, subqSize, workerIdle, workerActive, workerSleep *prometheus.GaugeVec
+	queueIn, queueOut, queueRetry, queueLeak, queueDeadline, queueLost,
+	subqIn, subqOut, subqLeak *prometheus.CounterVec
 
-	promWorkerWait *prometheus.HistogramVec
+	workerWait *promHistVec
 
-	_ = NewPrometheusMetrics
-)
+	labelOverflow *prometheus.CounterVec
 
-func init() {
-	promWorkerIdle = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "queue_workers_idle",
-		Help: "Indicates how many workers idle.",
-	}, []string{"queue"})
-	promWorkerActive = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "queue_workers_active",
-		Help: "Indicates how many workers active.",
-	}, []string{"queue"})
-	promWorkerSleep = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "queue_workers_sleep",
-		Help: "Indicates how many workers sleep.",
-	}, []string{"queue"})
-
-	promQueueSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "queue_size",
-		Help: "Actual queue size.",
-	}, []string{"queue"})
-
-	promQueueIn = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_in",
-		Help: "How many items comes to the queue.",
-	}, []string{"queue"})
-	promQueueOut = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_out",
-		Help: "How many items leaves queue.",
-	}, []string{"queue"})
-	promQueueRetry = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_retry",
-		Help: "How many retries occurs.",
-	}, []string{"queue"})
-	promQueueLeak = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_leak",
-		Help: "How many items dropped on the floor due to queue is full.",
-	}, []string{"queue", "dir"})
-	promQueueDeadline = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_deadline",
-		Help: "How many processing skips due to deadline.",
-	}, []string{"queue"})
-	promQueueLost = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_lost",
-		Help: "How many items throw to the trash due to force close.",
-	}, []string{"queue"})
-
-	buckets := append(prometheus.DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000, 5000}...)
-	promWorkerWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "queue_wait",
-		Help:    "How many worker waits due to delayed execution.",
-		Buckets: buckets,
-	}, []string{"queue"})
-
-	promSubqSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "queue_subq_size",
-		Help: "Actual queue size.",
-	}, []string{"queue", "subq"})
-	promSubqIn = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_subq_in",
-		Help: "How many items comes to the sub-queue.",
-	}, []string{"queue", "subq"})
-	promSubqOut = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_subq_out",
-		Help: "How many items leaves sub-queue.",
-	}, []string{"queue", "subq"})
-	promSubqLeak = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: "queue_subq_leak",
-		Help: "How many items dropped on the floor due to sub-queue is full.",
-	}, []string{"queue", "subq"})
-
-	prometheus.MustRegister(promWorkerIdle, promWorkerActive, promWorkerSleep, promQueueSize,
-		promQueueIn, promQueueOut, promQueueRetry, promQueueLeak, promQueueLost, promQueueDeadline,
-		promWorkerWait,
-		promSubqSize, promSubqIn, promSubqOut, promSubqLeak)
+	// Key of the set, collectors registered by the set and count of writers use it.
+	key   promKey
+	colls []prometheus.Collector
+	refs  int
 }
 
+var (
+	// Default buckets of timing metrics, in units of precision.
+	promBuckets = append(prometheus.DefBuckets, []float64{15, 20, 30, 40, 50, 100, 150, 200, 250, 500, 1000, 1500, 2000, 3000, 5000}...)
+
+	promMux sync.Mutex
+	promIdx = make(map[promKey]*promVecs)
+
+	_, _ = NewPrometheusMetrics, NewPrometheusMetricsWithOptions
+)
+
 func NewPrometheusMetrics(name string) *PrometheusMetrics {
-	return NewPrometheusMetricsWP(name, time.Nanosecond)
+	return NewPrometheusMetricsWithOptions(name)
 }
 
+// NewPrometheusMetricsWP makes new writer observes durations in units of given precision.
+//
+// Kept for compatibility, use NewPrometheusMetricsWithOptions with WithPrecision or WithSeconds instead.
 func NewPrometheusMetricsWP(name string, precision time.Duration) *PrometheusMetrics {
-	if precision == 0 {
-		precision = time.Nanosecond
+	return NewPrometheusMetricsWithOptions(name, WithPrecision(precision))
+}
+
+// NewPrometheusMetricsWithOptions makes new writer and applies given options to it.
+//
+// Collectors registers lazily on the first call for each registerer and shares between all writers use the same
+// registerer, namespace, subsystem and constant labels. By default, collectors registers in
+// prometheus.DefaultRegisterer without namespace, subsystem and constant labels.
+//
+// Timing options (precision, seconds, buckets and native histograms settings) belong to the queue name: writers of the
+// name observe durations with options of its first writer, options of the rest are ignored.
+func NewPrometheusMetricsWithOptions(name string, opts ...PrometheusOption) *PrometheusMetrics {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
 	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	vec := getPromVecs(&c)
+	t := vec.workerWait.adopt(name, &c)
 	m := &PrometheusMetrics{
 		name: name,
-		prec: precision,
+		hist: t.hist,
+		prec: t.prec,
+		sec:  t.sec,
+		exm:  c.exemplar,
+		vec:  vec,
+		hnd:  new(atomic.Pointer[promHandles]),
+		done: new(uint32),
+		lim:  promLimit{limit: c.labelLimit, logger: c.labelLogger},
 	}
+	m.hnd.Store(newPromHandles(m.vec, name, m.hist, m.lim))
 	return m
 }
 
-func (m PrometheusMetrics) WorkerSetup(active, sleep, stop uint) {
-	promWorkerActive.DeleteLabelValues(m.name)
-	promWorkerSleep.DeleteLabelValues(m.name)
-	promWorkerIdle.DeleteLabelValues(m.name)
+// Key of collectors set: registerer and options affect metrics descriptions.
+type promKey struct {
+	reg prometheus.Registerer
+	key string
+}
+
+func getPromVecs(c *promConfig) *promVecs {
+	promMux.Lock()
+	defer promMux.Unlock()
+	k := promKey{reg: c.reg, key: c.key()}
+	if v, ok := promIdx[k]; ok {
+		v.refs++
+		return v
+	}
+	v := newPromVecs(c)
+	v.refs = 1
+	promIdx[k] = v
+	return v
+}
 
-	promWorkerActive.WithLabelValues(m.name).Add(float64(active))
-	promWorkerSleep.WithLabelValues(m.name).Add(float64(sleep))
-	promWorkerIdle.WithLabelValues(m.name).Add(float64(stop))
+// Release collectors set by closed writer. The last writer of the set unregisters its collectors and removes the set
+// from the index, so registerer doesn't stay alive.
+func putPromVecs(v *promVecs) {
+	promMux.Lock()
+	defer promMux.Unlock()
+	if v.refs--; v.refs > 0 {
+		return
+	}
+	for _, c := range v.colls {
+		v.key.reg.Unregister(c)
+	}
+	if promIdx[v.key] == v {
+		delete(promIdx, v.key)
+	}
+}
+
+func newPromVecs(c *promConfig) *promVecs {
+	v := &promVecs{key: promKey{reg: c.reg, key: c.key()}}
+	v.workerIdle = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_workers_idle",
+		Help:        "Indicates how many workers idle.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.GaugeVec)
+	v.workerActive = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_workers_active",
+		Help:        "Indicates how many workers active.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.GaugeVec)
+	v.workerSleep = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_workers_sleep",
+		Help:        "Indicates how many workers sleep.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.GaugeVec)
+
+	v.queueSize = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_size",
+		Help:        "Actual queue size.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.GaugeVec)
+
+	v.queueIn = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_in",
+		Help:        "How many items comes to the queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.queueOut = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_out",
+		Help:        "How many items leaves queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.queueRetry = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_retry",
+		Help:        "How many retries occurs.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.queueLeak = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_leak",
+		Help:        "How many items dropped on the floor due to queue is full.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "dir"})).(*prometheus.CounterVec)
+	v.queueDeadline = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_deadline",
+		Help:        "How many processing skips due to deadline.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+	v.queueLost = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_lost",
+		Help:        "How many items throw to the trash due to force close.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*prometheus.CounterVec)
+
+	v.workerWait = v.register(newPromHistVec(prometheus.HistogramOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_wait",
+		Help:        "How many worker waits due to delayed execution.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue"})).(*promHistVec)
+
+	v.subqSize = v.register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_subq_size",
+		Help:        "Actual queue size.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "subq"})).(*prometheus.GaugeVec)
+	v.subqIn = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_subq_in",
+		Help:        "How many items comes to the sub-queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "subq"})).(*prometheus.CounterVec)
+	v.subqOut = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_subq_out",
+		Help:        "How many items leaves sub-queue.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "subq"})).(*prometheus.CounterVec)
+	v.subqLeak = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_subq_leak",
+		Help:        "How many items dropped on the floor due to sub-queue is full.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "subq"})).(*prometheus.CounterVec)
+
+	v.labelOverflow = v.register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   c.namespace,
+		Subsystem:   c.subsystem,
+		Name:        "queue_label_overflow",
+		Help:        "How many events with label values over cardinality limit folded into __other__ value.",
+		ConstLabels: c.constLabels,
+	}, []string{"queue", "label"})).(*prometheus.CounterVec)
+
+	return v
+}
+
+// Convert duration to units of observation.
+func (m PrometheusMetrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+// Observe duration with exemplar if provider specified.
+func (m PrometheusMetrics) observe(o prometheus.Observer, dur time.Duration) {
+	v := m.units(dur)
+	if m.exm != nil {
+		if lbl := m.exm(); len(lbl) > 0 && promExemplarRunes(lbl) <= prometheus.ExemplarMaxRunes {
+			if eo, ok := o.(prometheus.ExemplarObserver); ok {
+				eo.ObserveWithExemplar(v, lbl)
+				return
+			}
+		}
+	}
+	o.Observe(v)
+}
+
+// Count runes of exemplar labels.
+func promExemplarRunes(lbl prometheus.Labels) (n int) {
+	for k, v := range lbl {
+		n += utf8.RuneCountInString(k) + utf8.RuneCountInString(v)
+	}
+	return
+}
+
+// Register collector or return already registered one with the same description.
+func (v *promVecs) register(c prometheus.Collector) prometheus.Collector {
+	if err := v.key.reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	v.colls = append(v.colls, c)
+	return c
+}
+
+func (m PrometheusMetrics) WorkerSetup(active, sleep, stop uint) {
+	h := m.handles()
+	h.workerActive.Set(float64(active))
+	h.workerSleep.Set(float64(sleep))
+	h.workerIdle.Set(float64(stop))
 }
 
 func (m PrometheusMetrics) WorkerInit(_ uint32) {
-	promWorkerActive.WithLabelValues(m.name).Inc()
-	promWorkerIdle.WithLabelValues(m.name).Add(-1)
+	h := m.handles()
+	h.workerActive.Inc()
+	h.workerIdle.Dec()
 }
 
 func (m PrometheusMetrics) WorkerSleep(_ uint32) {
-	promWorkerSleep.WithLabelValues(m.name).Inc()
-	promWorkerActive.WithLabelValues(m.name).Add(-1)
+	h := m.handles()
+	h.workerSleep.Inc()
+	h.workerActive.Dec()
 }
 
 func (m PrometheusMetrics) WorkerWakeup(_ uint32) {
-	promWorkerActive.WithLabelValues(m.name).Inc()
-	promWorkerSleep.WithLabelValues(m.name).Add(-1)
+	h := m.handles()
+	h.workerActive.Inc()
+	h.workerSleep.Dec()
 }
 
 func (m PrometheusMetrics) WorkerWait(_ uint32, delay time.Duration) {
-	promWorkerWait.WithLabelValues(m.name).Observe(float64(delay.Nanoseconds() / int64(m.prec)))
+	m.observe(m.handles().workerWait, delay)
 }
 
 func (m PrometheusMetrics) WorkerStop(_ uint32, force bool, status q.WorkerStatus) {
-	promWorkerIdle.WithLabelValues(m.name).Inc()
+	h := m.handles()
+	h.workerIdle.Inc()
 	if force {
 		switch status {
 		case q.WorkerStatusActive:
-			promWorkerActive.WithLabelValues(m.name).Add(-1)
+			h.workerActive.Dec()
 		case q.WorkerStatusSleep:
-			promWorkerSleep.WithLabelValues(m.name).Add(-1)
+			h.workerSleep.Dec()
 		}
 	} else {
-		promWorkerSleep.WithLabelValues(m.name).Add(-1)
+		h.workerSleep.Dec()
 	}
 }
 
 func (m PrometheusMetrics) QueuePut() {
-	promQueueIn.WithLabelValues(m.name).Inc()
-	promQueueSize.WithLabelValues(m.name).Inc()
+	h := m.handles()
+	h.queueIn.Inc()
+	h.queueSize.Inc()
 }
 
 func (m PrometheusMetrics) QueuePull() {
-	promQueueOut.WithLabelValues(m.name).Inc()
-	promQueueSize.WithLabelValues(m.name).Dec()
+	h := m.handles()
+	h.queueOut.Inc()
+	h.queueSize.Dec()
 }
 
 func (m PrometheusMetrics) QueueRetry() {
-	promQueueRetry.WithLabelValues(m.name).Inc()
+	m.handles().queueRetry.Inc()
 }
 
 func (m PrometheusMetrics) QueueLeak(dir q.LeakDirection) {
-	dirs := "rear"
+	h := m.handles()
 	if dir == q.LeakDirectionFront {
-		dirs = "front"
+		h.queueLeakFront.Inc()
+	} else {
+		h.queueLeakRear.Inc()
 	}
-	promQueueLeak.WithLabelValues(m.name, dirs).Inc()
-	promQueueSize.WithLabelValues(m.name).Dec()
+	h.queueSize.Dec()
 }
 
 func (m PrometheusMetrics) QueueDeadline() {
-	promQueueDeadline.WithLabelValues(m.name).Inc()
-	promQueueSize.WithLabelValues(m.name).Dec()
+	h := m.handles()
+	h.queueDeadline.Inc()
+	h.queueSize.Dec()
 }
 
 func (m PrometheusMetrics) QueueLost() {
-	promQueueLost.WithLabelValues(m.name).Inc()
-	promQueueSize.WithLabelValues(m.name).Dec()
+	h := m.handles()
+	h.queueLost.Inc()
+	h.queueSize.Dec()
 }
 
 func (m PrometheusMetrics) SubqPut(subq string) {
-	promSubqIn.WithLabelValues(m.name, subq).Inc()
-	promSubqSize.WithLabelValues(m.name, subq).Inc()
+	h := m.handles().subq.get(subq)
+	h.in.Inc()
+	h.size.Inc()
 }
 
 func (m PrometheusMetrics) SubqPull(subq string) {
-	promSubqOut.WithLabelValues(m.name, subq).Inc()
-	promSubqSize.WithLabelValues(m.name, subq).Dec()
+	h := m.handles().subq.get(subq)
+	h.out.Inc()
+	h.size.Dec()
 }
 
 func (m PrometheusMetrics) SubqLeak(subq string) {
-	promSubqLeak.WithLabelValues(m.name, subq).Inc()
-	promSubqSize.WithLabelValues(m.name, subq).Dec()
+	h := m.handles().subq.get(subq)
+	h.leak.Inc()
+	h.size.Dec()
+}
+
+// Forget removes all series of the queue and all its sub-queues seen by the writer.
+//
+// Writer may be used further, but series will appear again on the next events. Note, writers of the same queue share
+// series, so forget them after all writers of the queue become unused.
+func (m PrometheusMetrics) Forget() {
+	match := prometheus.Labels{"queue": m.name}
+	m.vec.queueSize.DeletePartialMatch(match)
+	m.vec.subqSize.DeletePartialMatch(match)
+	m.vec.workerIdle.DeletePartialMatch(match)
+	m.vec.workerActive.DeletePartialMatch(match)
+	m.vec.workerSleep.DeletePartialMatch(match)
+	m.vec.queueIn.DeletePartialMatch(match)
+	m.vec.queueOut.DeletePartialMatch(match)
+	m.vec.queueRetry.DeletePartialMatch(match)
+	m.vec.queueLeak.DeletePartialMatch(match)
+	m.vec.queueDeadline.DeletePartialMatch(match)
+	m.vec.queueLost.DeletePartialMatch(match)
+	m.vec.subqIn.DeletePartialMatch(match)
+	m.vec.subqOut.DeletePartialMatch(match)
+	m.vec.subqLeak.DeletePartialMatch(match)
+	m.vec.workerWait.DeletePartialMatch(match)
+	m.vec.labelOverflow.DeletePartialMatch(match)
+	m.hnd.Store(nil)
+}
+
+// Close removes all series of the writer (see Forget) and releases shared collectors. The last closed writer of the
+// registerer unregisters them. Repeated calls do nothing.
+func (m PrometheusMetrics) Close() error {
+	if atomic.CompareAndSwapUint32(m.done, 0, 1) {
+		m.Forget()
+		m.vec.workerWait.release(m.name)
+		putPromVecs(m.vec)
+	}
+	return nil
 }
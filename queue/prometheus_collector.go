@@ -0,0 +1,286 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+	q "github.com/koykov/queue"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CollectorMetrics is a scrape-time Prometheus implementation of queue.MetricsWriter.
+//
+// Events only update sharded atomic counters and metrics values calculates on scrape (see prometheus.Collector), so
+// it's much cheaper than PrometheusMetrics for very hot queues. Metrics names and labels are the same as
+// PrometheusMetrics has, but queue name is a constant label of each writer's collector, so both implementations can't
+// be mixed in the same registerer. Options WithNativeHistograms, WithoutClassicBuckets and WithExemplars are ignored.
+type CollectorMetrics struct {
+	name string
+	reg  prometheus.Registerer
+	prec time.Duration
+	sec  bool
+	desc *collDescs
+	lim  promLimit
+
+	queueSize, workerIdle, workerActive, workerSleep                                       promreg.Counter
+	queueIn, queueOut, queueRetry, queueLeakFront, queueLeakRear, queueDeadline, queueLost promreg.Counter
+
+	workerWait *promreg.Histogram
+
+	subq promCache[collSubq]
+
+	labelOverflow promreg.Counter
+}
+
+type collDescs struct {
+	queueSize, workerIdle, workerActive, workerSleep, queueIn, queueOut, queueRetry, queueLeak, queueDeadline, queueLost,
+	workerWait, subqSize, subqIn, subqOut, subqLeak *prometheus.Desc
+	labelOverflow *prometheus.Desc
+}
+
+// Counters of sub-queue.
+type collSubq struct {
+	size, in, out, leak promreg.Counter
+}
+
+var _ = NewCollectorMetrics
+
+// NewCollectorMetrics makes new scrape-time writer and registers it.
+//
+// Accepts the same options as NewPrometheusMetricsWithOptions. Writers of the same queue and registerer share
+// counters, ie constructor returns already registered writer. Returns an error if writer can't be registered, eg
+// registerer already has PrometheusMetrics collectors with the same namespace, subsystem and constant labels.
+func NewCollectorMetrics(name string, opts ...PrometheusOption) (*CollectorMetrics, error) {
+	c := promConfig{
+		reg:  prometheus.DefaultRegisterer,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = prometheus.DefaultRegisterer
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	m := &CollectorMetrics{
+		name:       name,
+		reg:        c.reg,
+		prec:       c.prec,
+		sec:        c.sec,
+		desc:       newCollDescs(&c, name),
+		lim:        promLimit{limit: c.labelLimit, logger: c.labelLogger},
+		workerWait: promreg.NewHistogram(c.buckets()),
+	}
+	m.subq.bind = func(string) *collSubq {
+		return &collSubq{}
+	}
+	if m.lim.limit > 0 {
+		overflow := m.lim.handler(func() { m.labelOverflow.Add(promreg.Shard(), 1) }, name)
+		m.subq.limit, m.subq.overflow = m.lim.limit, overflow
+	}
+	if err := c.reg.Register(m); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if w, ok := are.ExistingCollector.(*CollectorMetrics); ok {
+				return w, nil
+			}
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func newCollDescs(c *promConfig, name string) *collDescs {
+	desc := func(fqName, help string, labels ...string) *prometheus.Desc {
+		cl := prometheus.Labels{"queue": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return prometheus.NewDesc(prometheus.BuildFQName(c.namespace, c.subsystem, fqName), help, labels, cl)
+	}
+	return &collDescs{
+		workerIdle:    desc("queue_workers_idle", "Indicates how many workers idle."),
+		workerActive:  desc("queue_workers_active", "Indicates how many workers active."),
+		workerSleep:   desc("queue_workers_sleep", "Indicates how many workers sleep."),
+		queueSize:     desc("queue_size", "Actual queue size."),
+		queueIn:       desc("queue_in", "How many items comes to the queue."),
+		queueOut:      desc("queue_out", "How many items leaves queue."),
+		queueRetry:    desc("queue_retry", "How many retries occurs."),
+		queueLeak:     desc("queue_leak", "How many items dropped on the floor due to queue is full.", "dir"),
+		queueDeadline: desc("queue_deadline", "How many processing skips due to deadline."),
+		queueLost:     desc("queue_lost", "How many items throw to the trash due to force close."),
+		workerWait:    desc("queue_wait", "How many worker waits due to delayed execution."),
+		subqSize:      desc("queue_subq_size", "Actual queue size.", "subq"),
+		subqIn:        desc("queue_subq_in", "How many items comes to the sub-queue.", "subq"),
+		subqOut:       desc("queue_subq_out", "How many items leaves sub-queue.", "subq"),
+		subqLeak:      desc("queue_subq_leak", "How many items dropped on the floor due to sub-queue is full.", "subq"),
+		labelOverflow: desc("queue_label_overflow",
+			"How many events with label values over cardinality limit folded into __other__ value.", "label"),
+	}
+}
+
+func (m *CollectorMetrics) Describe(ch chan<- *prometheus.Desc) {
+	d := m.desc
+	for _, desc := range []*prometheus.Desc{d.queueSize, d.workerIdle, d.workerActive, d.workerSleep, d.queueIn,
+		d.queueOut, d.queueRetry, d.queueLeak, d.queueDeadline, d.queueLost, d.workerWait, d.subqSize, d.subqIn,
+		d.subqOut, d.subqLeak, d.labelOverflow} {
+		ch <- desc
+	}
+}
+
+func (m *CollectorMetrics) Collect(ch chan<- prometheus.Metric) {
+	d := m.desc
+	gauge := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c.Load()), lvs...)
+	}
+	counter := func(desc *prometheus.Desc, c *promreg.Counter, lvs ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(c.Load()), lvs...)
+	}
+	gauge(d.workerIdle, &m.workerIdle)
+	gauge(d.workerActive, &m.workerActive)
+	gauge(d.workerSleep, &m.workerSleep)
+	gauge(d.queueSize, &m.queueSize)
+	counter(d.queueIn, &m.queueIn)
+	counter(d.queueOut, &m.queueOut)
+	counter(d.queueRetry, &m.queueRetry)
+	counter(d.queueLeak, &m.queueLeakFront, "front")
+	counter(d.queueLeak, &m.queueLeakRear, "rear")
+	counter(d.queueDeadline, &m.queueDeadline)
+	counter(d.queueLost, &m.queueLost)
+	ch <- collHistogram(d.workerWait, m.workerWait, m.units)
+	m.subq.each(func(subq string, h *collSubq) {
+		gauge(d.subqSize, &h.size, subq)
+		counter(d.subqIn, &h.in, subq)
+		counter(d.subqOut, &h.out, subq)
+		counter(d.subqLeak, &h.leak, subq)
+	})
+	if m.lim.limit > 0 {
+		ch <- prometheus.MustNewConstMetric(d.labelOverflow, prometheus.CounterValue, float64(m.labelOverflow.Load()),
+			promLimitLabel)
+	}
+}
+
+// Convert duration to units of observation.
+func (m *CollectorMetrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+func (m *CollectorMetrics) WorkerSetup(active, sleep, stop uint) {
+	m.workerActive.Set(int64(active))
+	m.workerSleep.Set(int64(sleep))
+	m.workerIdle.Set(int64(stop))
+}
+
+func (m *CollectorMetrics) WorkerInit(_ uint32) {
+	sh := promreg.Shard()
+	m.workerActive.Add(sh, 1)
+	m.workerIdle.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) WorkerSleep(_ uint32) {
+	sh := promreg.Shard()
+	m.workerSleep.Add(sh, 1)
+	m.workerActive.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) WorkerWakeup(_ uint32) {
+	sh := promreg.Shard()
+	m.workerActive.Add(sh, 1)
+	m.workerSleep.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) WorkerWait(_ uint32, delay time.Duration) {
+	m.workerWait.Observe(promreg.Shard(), m.units(delay), delay)
+}
+
+func (m *CollectorMetrics) WorkerStop(_ uint32, force bool, status q.WorkerStatus) {
+	sh := promreg.Shard()
+	m.workerIdle.Add(sh, 1)
+	if force {
+		switch status {
+		case q.WorkerStatusActive:
+			m.workerActive.Add(sh, -1)
+		case q.WorkerStatusSleep:
+			m.workerSleep.Add(sh, -1)
+		}
+	} else {
+		m.workerSleep.Add(sh, -1)
+	}
+}
+
+func (m *CollectorMetrics) QueuePut() {
+	sh := promreg.Shard()
+	m.queueIn.Add(sh, 1)
+	m.queueSize.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) QueuePull() {
+	sh := promreg.Shard()
+	m.queueOut.Add(sh, 1)
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) QueueRetry() {
+	m.queueRetry.Add(promreg.Shard(), 1)
+}
+
+func (m *CollectorMetrics) QueueLeak(dir q.LeakDirection) {
+	sh := promreg.Shard()
+	if dir == q.LeakDirectionFront {
+		m.queueLeakFront.Add(sh, 1)
+	} else {
+		m.queueLeakRear.Add(sh, 1)
+	}
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) QueueDeadline() {
+	sh := promreg.Shard()
+	m.queueDeadline.Add(sh, 1)
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) QueueLost() {
+	sh := promreg.Shard()
+	m.queueLost.Add(sh, 1)
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) SubqPut(subq string) {
+	sh, h := promreg.Shard(), m.subq.get(subq)
+	h.in.Add(sh, 1)
+	h.size.Add(sh, 1)
+}
+
+func (m *CollectorMetrics) SubqPull(subq string) {
+	sh, h := promreg.Shard(), m.subq.get(subq)
+	h.out.Add(sh, 1)
+	h.size.Add(sh, -1)
+}
+
+func (m *CollectorMetrics) SubqLeak(subq string) {
+	sh, h := promreg.Shard(), m.subq.get(subq)
+	h.leak.Add(sh, 1)
+	h.size.Add(sh, -1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *CollectorMetrics) Close() error {
+	m.reg.Unregister(m)
+	return nil
+}
+
+// Make constant histogram of sharded one.
+func collHistogram(desc *prometheus.Desc, h *promreg.Histogram, units func(time.Duration) float64,
+	lvs ...string) prometheus.Metric {
+	upper, cnt, sum := h.Load()
+	buckets := make(map[float64]uint64, len(upper))
+	for j := range upper {
+		buckets[upper[j]] = cnt[j]
+	}
+	return prometheus.MustNewConstHistogram(desc, cnt[len(upper)], units(sum), buckets, lvs...)
+}
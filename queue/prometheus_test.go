@@ -0,0 +1,352 @@
+package queue
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestPrometheusMetricsRegisterer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := []PrometheusOption{WithRegisterer(reg), WithNamespace("app"), WithSubsystem("svc"),
+		WithConstLabels(prometheus.Labels{"env": "test"})}
+	a := NewPrometheusMetricsWithOptions("a", opts...)
+	b := NewPrometheusMetricsWithOptions("b", opts...)
+	a.QueuePut()
+	b.QueuePut()
+	b.QueuePut()
+
+	mfs := promGather(t, reg)
+	for _, name := range []string{"queue_in", "queue_size", "app_queue_in"} {
+		if _, ok := mfs[name]; ok {
+			t.Errorf("unexpected family %s", name)
+		}
+	}
+	mf, ok := mfs["app_svc_queue_in"]
+	if !ok {
+		t.Fatal("family app_svc_queue_in expected")
+	}
+	got := make(map[string]float64)
+	for _, m := range mf.GetMetric() {
+		lbl := promLabels(m)
+		if len(lbl) != 2 || lbl["env"] != "test" {
+			t.Errorf("labels %v: expected queue and env=test", lbl)
+		}
+		got[lbl["queue"]] = m.GetCounter().GetValue()
+	}
+	if len(got) != 2 || got["a"] != 1 || got["b"] != 2 {
+		t.Errorf("app_svc_queue_in: got %v, expected a=1 b=2", got)
+	}
+	// Private registerer doesn't touch the default one.
+	if mfs, err := prometheus.DefaultGatherer.Gather(); err == nil {
+		for _, mf := range mfs {
+			if strings.HasPrefix(mf.GetName(), "app_svc_") {
+				t.Errorf("family %s registered in default registerer", mf.GetName())
+			}
+		}
+	}
+
+	// The last closed writer unregisters collectors and releases registerer.
+	_ = a.Close()
+	if mfs := promGather(t, reg); len(mfs["app_svc_queue_in"].GetMetric()) != 1 {
+		t.Error("series of unclosed writer expected")
+	}
+	_ = b.Close()
+	_ = b.Close()
+	if mfs := promGather(t, reg); len(mfs) != 0 {
+		t.Errorf("no families expected after close, got %d", len(mfs))
+	}
+	promMux.Lock()
+	defer promMux.Unlock()
+	for k := range promIdx {
+		if k.reg == reg {
+			t.Error("closed registerer kept in index")
+		}
+	}
+}
+
+func TestPrometheusMetricsTimingOptions(t *testing.T) {
+	// Histogram of the queue name observed by the writer.
+	hist := func(t *testing.T, g prometheus.Gatherer, name string) *dto.Histogram {
+		t.Helper()
+		for _, mt := range promGather(t, g)["queue_wait"].GetMetric() {
+			for _, lp := range mt.GetLabel() {
+				if lp.GetName() == "queue" && lp.GetValue() == name {
+					return mt.GetHistogram()
+				}
+			}
+		}
+		t.Fatalf("queue_wait series of %q expected", name)
+		return nil
+	}
+	t.Run("default registry", func(t *testing.T) {
+		a := NewPrometheusMetricsWP("timing_a", time.Millisecond)
+		b := NewPrometheusMetricsWP("timing_b", time.Microsecond)
+		a.WorkerWait(0, 1500*time.Microsecond)
+		b.WorkerWait(0, 1500*time.Microsecond)
+		if s := hist(t, prometheus.DefaultGatherer, "timing_a").GetSampleSum(); s != 1.5 {
+			t.Errorf("sum %v in milliseconds, want 1.5", s)
+		}
+		if s := hist(t, prometheus.DefaultGatherer, "timing_b").GetSampleSum(); s != 1500 {
+			t.Errorf("sum %v in microseconds, want 1500", s)
+		}
+		_ = a.Close()
+		_ = b.Close()
+	})
+	t.Run("private registry", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		a := NewPrometheusMetricsWithOptions("a", WithRegisterer(reg), WithSeconds())
+		b := NewPrometheusMetricsWithOptions("b", WithRegisterer(reg), WithSeconds(), WithBuckets(.1, 1, 10))
+		c := NewPrometheusMetricsWithOptions("c", WithRegisterer(reg), WithSeconds(),
+			WithNativeHistograms(1.1, 100, time.Hour), WithoutClassicBuckets())
+		// Writer of the same queue name observes with options of the first one.
+		a1 := NewPrometheusMetricsWithOptions("a", WithRegisterer(reg), WithPrecision(time.Millisecond),
+			WithBuckets(1, 2))
+		for _, m := range []*PrometheusMetrics{a, b, c, a1} {
+			m.WorkerWait(0, 500*time.Millisecond)
+		}
+		if h := hist(t, reg, "a"); len(h.GetBucket()) != len(prometheus.DefBuckets) || h.GetSampleSum() != 1 {
+			t.Errorf("%d buckets and sum %v, want %d and 1", len(h.GetBucket()), h.GetSampleSum(),
+				len(prometheus.DefBuckets))
+		}
+		if h := hist(t, reg, "b"); len(h.GetBucket()) != 3 {
+			t.Errorf("%d buckets, want 3", len(h.GetBucket()))
+		}
+		promExpectNative(t, hist(t, reg, "c"), 1, false)
+
+		// Name without writers may be used with other options.
+		for _, m := range []*PrometheusMetrics{a, b, c, a1} {
+			_ = m.Close()
+		}
+		a = NewPrometheusMetricsWithOptions("a", WithRegisterer(reg), WithPrecision(time.Millisecond))
+		a.WorkerWait(0, 500*time.Millisecond)
+		if s := hist(t, reg, "a").GetSampleSum(); s != 500 {
+			t.Errorf("sum %v in milliseconds, want 500", s)
+		}
+		_ = a.Close()
+	})
+}
+
+func TestPrometheusMetricsExemplars(t *testing.T) {
+	long := prometheus.Labels{"trace_id": strings.Repeat("f", prometheus.ExemplarMaxRunes)}
+	for _, tc := range []struct {
+		name string
+		lbl  prometheus.Labels
+		want bool
+	}{
+		{"labels", prometheus.Labels{"trace_id": "abc"}, true},
+		{"empty", nil, false},
+		{"too long", long, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			m := NewPrometheusMetricsWithOptions("test", WithRegisterer(reg), WithSeconds(),
+				WithExemplars(func() prometheus.Labels { return tc.lbl }))
+			m.WorkerWait(0, 500*time.Millisecond)
+			var exm []*dto.Exemplar
+			for _, mt := range promGather(t, reg)["queue_wait"].GetMetric() {
+				for _, b := range mt.GetHistogram().GetBucket() {
+					if b.GetExemplar() != nil {
+						exm = append(exm, b.GetExemplar())
+					}
+				}
+			}
+			if !tc.want {
+				if len(exm) > 0 {
+					t.Errorf("no exemplars expected, got %v", exm)
+				}
+				return
+			}
+			if len(exm) != 1 {
+				t.Fatalf("one exemplar expected, got %d", len(exm))
+			}
+			if v := exm[0].GetValue(); v != .5 {
+				t.Errorf("exemplar value %v, want .5", v)
+			}
+			lp := exm[0].GetLabel()
+			if len(lp) != 1 || lp[0].GetName() != "trace_id" || lp[0].GetValue() != "abc" {
+				t.Errorf("exemplar labels %v, want trace_id=abc", lp)
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsLabelLimit(t *testing.T) {
+	type writer interface {
+		SubqPut(v string)
+	}
+	for _, tc := range []struct {
+		name string
+		make func(t *testing.T, opts ...PrometheusOption) writer
+	}{
+		{"prometheus", func(t *testing.T, opts ...PrometheusOption) writer {
+			return NewPrometheusMetricsWithOptions("test", opts...)
+		}},
+		{"collector", func(t *testing.T, opts ...PrometheusOption) writer {
+			m, err := NewCollectorMetrics("test", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return m
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			h := newSlogTestHandler(slog.LevelDebug)
+			m := tc.make(t, WithRegisterer(reg), WithLabelLimit(2), WithLabelLimitLogger(slog.New(h)))
+			for _, v := range []string{"a", "b", "c", "d", "a", "c"} {
+				m.SubqPut(v)
+			}
+			mfs := promGather(t, reg)
+			got := make(map[string]float64)
+			for _, mt := range mfs["queue_subq_in"].GetMetric() {
+				got[promLabels(mt)["subq"]] = mt.GetCounter().GetValue()
+			}
+			if want := map[string]float64{"a": 2, "b": 1, promOther: 3}; !reflect.DeepEqual(got, want) {
+				t.Errorf("subq values: got %v, expected %v", got, want)
+			}
+			var overflow []string
+			for _, mt := range mfs["queue_label_overflow"].GetMetric() {
+				overflow = append(overflow, fmt.Sprintf("%v %v", promLabels(mt), mt.GetCounter().GetValue()))
+			}
+			if want := []string{"map[label:subq queue:test] 3"}; !reflect.DeepEqual(overflow, want) {
+				t.Errorf("queue_label_overflow: got %q, expected %q", overflow, want)
+			}
+			// Only the first overflow is logged.
+			h.expect(t, "WARN label cardinality limit exceeded queue=test label=subq value=c limit=2")
+		})
+	}
+}
+
+func TestPrometheusMetricsNativeHistograms(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    []PrometheusOption
+		classic bool
+	}{
+		{"classic", []PrometheusOption{WithSeconds(), WithNativeHistograms(1.1, 100, time.Hour)}, true},
+		{"native", []PrometheusOption{WithSeconds(), WithNativeHistograms(1.1, 100, time.Hour),
+			WithoutClassicBuckets()}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			m := NewPrometheusMetricsWithOptions("test", append(tc.opts, WithRegisterer(reg))...)
+			for _, dur := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 10 * time.Millisecond, time.Second} {
+				m.WorkerWait(0, dur)
+			}
+			var h *dto.Histogram
+			for _, mt := range promGather(t, reg)["queue_wait"].GetMetric() {
+				if mt.GetHistogram().GetSampleCount() > 0 {
+					h = mt.GetHistogram()
+				}
+			}
+			if h == nil {
+				t.Fatal("observed queue_wait series expected")
+			}
+			promExpectNative(t, h, 4, tc.classic)
+		})
+	}
+}
+
+func TestCollectorMetricsRegister(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a, err := NewCollectorMetrics("a", WithRegisterer(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Writers of the same queue share counters.
+	if b, err := NewCollectorMetrics("a", WithRegisterer(reg)); err != nil || b != a {
+		t.Errorf("registered writer expected, got %p, %v", b, err)
+	}
+	// Can't mix with PrometheusMetrics in the same registerer.
+	reg = prometheus.NewRegistry()
+	_ = NewPrometheusMetricsWithOptions("a", WithRegisterer(reg))
+	if _, err := NewCollectorMetrics("b", WithRegisterer(reg)); err == nil {
+		t.Error("registration error expected")
+	}
+}
+
+func BenchmarkPrometheusMetrics_QueuePut(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.QueuePut()
+	}
+}
+
+func BenchmarkPrometheusMetrics_SubqPut(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.SubqPut("high")
+	}
+}
+
+func BenchmarkPrometheusMetrics_WorkerWait(b *testing.B) {
+	m := NewPrometheusMetricsWithOptions("bench", WithRegisterer(prometheus.NewRegistry()))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.WorkerWait(0, time.Millisecond)
+	}
+}
+
+// Check native histogram: bucket factor 1.1 rounds to schema 3 (neighbour bounds ratio 2^(2^-3) ~ 1.09), spans cover
+// all deltas and absolute counts of populated buckets sum to count.
+func promExpectNative(t *testing.T, h *dto.Histogram, count uint64, classic bool) {
+	t.Helper()
+	if h.GetSampleCount() != count {
+		t.Errorf("sample count %d, expected %d", h.GetSampleCount(), count)
+	}
+	if h.Schema == nil || h.GetSchema() != 3 {
+		t.Errorf("schema %v, expected 3", h.Schema)
+	}
+	spans := h.GetPositiveSpan()
+	if len(spans) == 0 {
+		t.Fatal("positive spans expected")
+	}
+	var n uint32
+	for _, sp := range spans {
+		n += sp.GetLength()
+	}
+	if deltas := h.GetPositiveDelta(); int(n) != len(deltas) {
+		t.Errorf("spans cover %d buckets, got %d deltas", n, len(deltas))
+	}
+	var abs, total int64
+	for _, d := range h.GetPositiveDelta() {
+		abs += d
+		total += abs
+	}
+	if uint64(total)+h.GetZeroCount() != count {
+		t.Errorf("native buckets count %d, expected %d", uint64(total)+h.GetZeroCount(), count)
+	}
+	if has := len(h.GetBucket()) > 0; has != classic {
+		t.Errorf("classic buckets presence %t, expected %t", has, classic)
+	}
+}
+
+func promGather(t *testing.T, g prometheus.Gatherer) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := g.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := make(map[string]*dto.MetricFamily, len(mfs))
+	for _, mf := range mfs {
+		r[mf.GetName()] = mf
+	}
+	return r
+}
+
+func promLabels(m *dto.Metric) map[string]string {
+	r := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		r[lp.GetName()] = lp.GetValue()
+	}
+	return r
+}
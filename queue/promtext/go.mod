@@ -0,0 +1,12 @@
+module github.com/koykov/metrics_writers/queue/promtext
+
+go 1.22.0
+
+require (
+	github.com/koykov/metrics_writers/internal v0.1.0
+	github.com/koykov/queue v1.1.4
+)
+
+require github.com/koykov/bitset v1.0.0 // indirect
+
+replace github.com/koykov/metrics_writers/internal => ../../internal
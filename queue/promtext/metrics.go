@@ -0,0 +1,237 @@
+package promtext
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/promreg"
+	q "github.com/koykov/queue"
+)
+
+// Metrics is a dependency-free Prometheus implementation of queue.MetricsWriter.
+//
+// Events only update sharded atomic counters, metrics renders on scrape by Registry in Prometheus text or OpenMetrics
+// format. Metrics names and labels are the same as queue.PrometheusMetrics has, so writer may be used as its drop-in
+// replacement where client_golang isn't wanted.
+type Metrics struct {
+	name    string
+	reg     *Registry
+	prec    time.Duration
+	sec     bool
+	desc    *descs
+	id      string
+	created time.Time
+
+	queueSize, workerIdle, workerActive, workerSleep                                       promreg.Counter
+	queueIn, queueOut, queueRetry, queueLeakFront, queueLeakRear, queueDeadline, queueLost promreg.Counter
+
+	workerWait *promreg.Histogram
+
+	subq promreg.Cache[subqCounters]
+}
+
+type descs struct {
+	queueSize, workerIdle, workerActive, workerSleep, queueIn, queueOut, queueRetry, queueLeak, queueDeadline, queueLost,
+	workerWait, subqSize, subqIn, subqOut, subqLeak *promreg.Desc
+}
+
+// Counters of sub-queue.
+type subqCounters struct {
+	size, in, out, leak promreg.Counter
+}
+
+var _ = NewMetrics
+
+// NewMetrics makes new writer and registers it.
+//
+// Writers of the same queue and registry share counters, ie constructor returns already registered writer.
+func NewMetrics(name string, opts ...Option) *Metrics {
+	c := config{
+		reg:  DefaultRegistry,
+		prec: time.Nanosecond,
+	}
+	for _, fn := range opts {
+		fn(&c)
+	}
+	if c.reg == nil {
+		c.reg = DefaultRegistry
+	}
+	if c.prec == 0 {
+		c.prec = time.Nanosecond
+	}
+	m := &Metrics{
+		name:       name,
+		reg:        c.reg,
+		prec:       c.prec,
+		sec:        c.sec,
+		desc:       newDescs(&c, name),
+		id:         "queue|" + c.namespace + "|" + c.subsystem + "|" + promreg.LabelsKey(c.constLabels) + "|" + name,
+		created:    time.Now(),
+		workerWait: promreg.NewHistogram(c.buckets()),
+	}
+	m.subq.Bind = func(string) *subqCounters {
+		return &subqCounters{}
+	}
+	return c.reg.Register(m.id, m).(*Metrics)
+}
+
+func newDescs(c *config, name string) *descs {
+	desc := func(fqName, help string, labels ...string) *promreg.Desc {
+		cl := map[string]string{"queue": name}
+		for k, v := range c.constLabels {
+			cl[k] = v
+		}
+		return promreg.NewDesc(promreg.BuildFQName(c.namespace, c.subsystem, fqName), help, cl, labels...)
+	}
+	return &descs{
+		workerIdle:    desc("queue_workers_idle", "Indicates how many workers idle."),
+		workerActive:  desc("queue_workers_active", "Indicates how many workers active."),
+		workerSleep:   desc("queue_workers_sleep", "Indicates how many workers sleep."),
+		queueSize:     desc("queue_size", "Actual queue size."),
+		queueIn:       desc("queue_in", "How many items comes to the queue."),
+		queueOut:      desc("queue_out", "How many items leaves queue."),
+		queueRetry:    desc("queue_retry", "How many retries occurs."),
+		queueLeak:     desc("queue_leak", "How many items dropped on the floor due to queue is full.", "dir"),
+		queueDeadline: desc("queue_deadline", "How many processing skips due to deadline."),
+		queueLost:     desc("queue_lost", "How many items throw to the trash due to force close."),
+		workerWait:    desc("queue_wait", "How many worker waits due to delayed execution."),
+		subqSize:      desc("queue_subq_size", "Actual queue size.", "subq"),
+		subqIn:        desc("queue_subq_in", "How many items comes to the sub-queue.", "subq"),
+		subqOut:       desc("queue_subq_out", "How many items leaves sub-queue.", "subq"),
+		subqLeak:      desc("queue_subq_leak", "How many items dropped on the floor due to sub-queue is full.", "subq"),
+	}
+}
+
+func (m *Metrics) Collect(g *promreg.Gatherer) {
+	d := m.desc
+	g.Gauge(d.workerIdle, &m.workerIdle)
+	g.Gauge(d.workerActive, &m.workerActive)
+	g.Gauge(d.workerSleep, &m.workerSleep)
+	g.Gauge(d.queueSize, &m.queueSize)
+	g.Counter(d.queueIn, &m.queueIn, m.created)
+	g.Counter(d.queueOut, &m.queueOut, m.created)
+	g.Counter(d.queueRetry, &m.queueRetry, m.created)
+	g.Counter(d.queueLeak, &m.queueLeakFront, m.created, "front")
+	g.Counter(d.queueLeak, &m.queueLeakRear, m.created, "rear")
+	g.Counter(d.queueDeadline, &m.queueDeadline, m.created)
+	g.Counter(d.queueLost, &m.queueLost, m.created)
+	g.Histogram(d.workerWait, m.workerWait, m.units, m.created)
+	m.subq.Each(func(subq string, h *subqCounters) {
+		g.Gauge(d.subqSize, &h.size, subq)
+		g.Counter(d.subqIn, &h.in, m.created, subq)
+		g.Counter(d.subqOut, &h.out, m.created, subq)
+		g.Counter(d.subqLeak, &h.leak, m.created, subq)
+	})
+}
+
+// Convert duration to units of observation.
+func (m *Metrics) units(dur time.Duration) float64 {
+	if m.sec {
+		return dur.Seconds()
+	}
+	return float64(dur) / float64(m.prec)
+}
+
+func (m *Metrics) WorkerSetup(active, sleep, stop uint) {
+	m.workerActive.Set(int64(active))
+	m.workerSleep.Set(int64(sleep))
+	m.workerIdle.Set(int64(stop))
+}
+
+func (m *Metrics) WorkerInit(_ uint32) {
+	sh := promreg.Shard()
+	m.workerActive.Add(sh, 1)
+	m.workerIdle.Add(sh, -1)
+}
+
+func (m *Metrics) WorkerSleep(_ uint32) {
+	sh := promreg.Shard()
+	m.workerSleep.Add(sh, 1)
+	m.workerActive.Add(sh, -1)
+}
+
+func (m *Metrics) WorkerWakeup(_ uint32) {
+	sh := promreg.Shard()
+	m.workerActive.Add(sh, 1)
+	m.workerSleep.Add(sh, -1)
+}
+
+func (m *Metrics) WorkerWait(_ uint32, delay time.Duration) {
+	m.workerWait.Observe(promreg.Shard(), m.units(delay), delay)
+}
+
+func (m *Metrics) WorkerStop(_ uint32, force bool, status q.WorkerStatus) {
+	sh := promreg.Shard()
+	m.workerIdle.Add(sh, 1)
+	if force {
+		switch status {
+		case q.WorkerStatusActive:
+			m.workerActive.Add(sh, -1)
+		case q.WorkerStatusSleep:
+			m.workerSleep.Add(sh, -1)
+		}
+	} else {
+		m.workerSleep.Add(sh, -1)
+	}
+}
+
+func (m *Metrics) QueuePut() {
+	sh := promreg.Shard()
+	m.queueIn.Add(sh, 1)
+	m.queueSize.Add(sh, 1)
+}
+
+func (m *Metrics) QueuePull() {
+	sh := promreg.Shard()
+	m.queueOut.Add(sh, 1)
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *Metrics) QueueRetry() {
+	m.queueRetry.Add(promreg.Shard(), 1)
+}
+
+func (m *Metrics) QueueLeak(dir q.LeakDirection) {
+	sh := promreg.Shard()
+	if dir == q.LeakDirectionFront {
+		m.queueLeakFront.Add(sh, 1)
+	} else {
+		m.queueLeakRear.Add(sh, 1)
+	}
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *Metrics) QueueDeadline() {
+	sh := promreg.Shard()
+	m.queueDeadline.Add(sh, 1)
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *Metrics) QueueLost() {
+	sh := promreg.Shard()
+	m.queueLost.Add(sh, 1)
+	m.queueSize.Add(sh, -1)
+}
+
+func (m *Metrics) SubqPut(subq string) {
+	sh, h := promreg.Shard(), m.subq.Get(subq)
+	h.in.Add(sh, 1)
+	h.size.Add(sh, 1)
+}
+
+func (m *Metrics) SubqPull(subq string) {
+	sh, h := promreg.Shard(), m.subq.Get(subq)
+	h.out.Add(sh, 1)
+	h.size.Add(sh, -1)
+}
+
+func (m *Metrics) SubqLeak(subq string) {
+	sh, h := promreg.Shard(), m.subq.Get(subq)
+	h.leak.Add(sh, 1)
+	h.size.Add(sh, -1)
+}
+
+// Close unregisters writer, so all its series disappear. Further events are counted, but not exported.
+func (m *Metrics) Close() error {
+	m.reg.Unregister(m.id)
+	return nil
+}
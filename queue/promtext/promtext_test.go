@@ -0,0 +1,63 @@
+package promtext
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestMetricsText(t *testing.T) {
+	reg := NewRegistry()
+	opts := []Option{
+		WithRegistry(reg), WithNamespace("app"), WithConstLabels(map[string]string{"env": "dev"}),
+		WithSeconds(), WithBuckets(.1, 1),
+	}
+	m := NewMetrics("test", opts...)
+	// Writers with the same options share counters.
+	if m1 := NewMetrics("test", opts...); m1 != m {
+		t.Error("registered writer expected")
+	}
+	m.WorkerSetup(2, 0, 2)
+	m.WorkerInit(0)
+	m.QueuePut()
+	m.QueuePut()
+	m.QueuePull()
+	m.QueueLeak(q.LeakDirectionRear)
+	m.QueueRetry()
+	m.WorkerWait(0, 500*time.Millisecond)
+	m.WorkerWait(0, 2*time.Second)
+	m.SubqPut("hi\"gh")
+	m.SubqPull("hi\"gh")
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "metrics.txt")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Errorf("got:\n%s\nexpected:\n%s", buf.Bytes(), expect)
+	}
+
+	// Closed writer disappears from output.
+	_ = m.Close()
+	buf.Reset()
+	if err := reg.WriteText(&buf); err != nil || buf.Len() > 0 {
+		t.Errorf("empty output expected, got %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,236 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// RecorderMetrics is in-memory implementation of queue.MetricsWriter designed for unit tests.
+//
+// Records every call with its arguments and keeps counters, gauges and timers the same way as aggregating writers do
+// (metric families and labels are the same as in PrometheusMetrics). Safe for concurrent use.
+//
+// Usage example:
+//
+//	m.AssertCount(t, "QueueLeak", 3, q.LeakDirectionFront)
+//	n := m.Counter("queue_leak", "dir", "front")
+type RecorderMetrics struct {
+	aggrMetrics
+
+	mux sync.Mutex
+	buf []RecorderEvent
+}
+
+// RecorderEvent describes single recorded call.
+type RecorderEvent struct {
+	// Method name, eg "WorkerSetup".
+	Method string
+	// Arguments in order of method signature.
+	Args []any
+}
+
+// TestingT is a subset of testing.TB used by assertion helpers.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+var _ = NewRecorderMetrics
+
+func NewRecorderMetrics(name string) *RecorderMetrics {
+	m := &RecorderMetrics{
+		aggrMetrics: aggrMetrics{
+			name: name,
+			s:    newAggrStore(),
+		},
+	}
+	return m
+}
+
+func (m *RecorderMetrics) WorkerSetup(active, sleep, stop uint) {
+	m.record("WorkerSetup", active, sleep, stop)
+	m.aggrMetrics.WorkerSetup(active, sleep, stop)
+}
+
+func (m *RecorderMetrics) WorkerInit(idx uint32) {
+	m.record("WorkerInit", idx)
+	m.aggrMetrics.WorkerInit(idx)
+}
+
+func (m *RecorderMetrics) WorkerSleep(idx uint32) {
+	m.record("WorkerSleep", idx)
+	m.aggrMetrics.WorkerSleep(idx)
+}
+
+func (m *RecorderMetrics) WorkerWakeup(idx uint32) {
+	m.record("WorkerWakeup", idx)
+	m.aggrMetrics.WorkerWakeup(idx)
+}
+
+func (m *RecorderMetrics) WorkerWait(idx uint32, delay time.Duration) {
+	m.record("WorkerWait", idx, delay)
+	m.aggrMetrics.WorkerWait(idx, delay)
+}
+
+func (m *RecorderMetrics) WorkerStop(idx uint32, force bool, status q.WorkerStatus) {
+	m.record("WorkerStop", idx, force, status)
+	m.aggrMetrics.WorkerStop(idx, force, status)
+}
+
+func (m *RecorderMetrics) QueuePut() {
+	m.record("QueuePut")
+	m.aggrMetrics.QueuePut()
+}
+
+func (m *RecorderMetrics) QueuePull() {
+	m.record("QueuePull")
+	m.aggrMetrics.QueuePull()
+}
+
+func (m *RecorderMetrics) QueueRetry() {
+	m.record("QueueRetry")
+	m.aggrMetrics.QueueRetry()
+}
+
+func (m *RecorderMetrics) QueueLeak(dir q.LeakDirection) {
+	m.record("QueueLeak", dir)
+	m.aggrMetrics.QueueLeak(dir)
+}
+
+func (m *RecorderMetrics) QueueDeadline() {
+	m.record("QueueDeadline")
+	m.aggrMetrics.QueueDeadline()
+}
+
+func (m *RecorderMetrics) QueueLost() {
+	m.record("QueueLost")
+	m.aggrMetrics.QueueLost()
+}
+
+func (m *RecorderMetrics) SubqPut(subq string) {
+	m.record("SubqPut", subq)
+	m.aggrMetrics.SubqPut(subq)
+}
+
+func (m *RecorderMetrics) SubqPull(subq string) {
+	m.record("SubqPull", subq)
+	m.aggrMetrics.SubqPull(subq)
+}
+
+func (m *RecorderMetrics) SubqLeak(subq string) {
+	m.record("SubqLeak", subq)
+	m.aggrMetrics.SubqLeak(subq)
+}
+
+// Events returns copy of recorded events log.
+func (m *RecorderMetrics) Events() []RecorderEvent {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]RecorderEvent(nil), m.buf...)
+}
+
+// Count returns how many times method was called.
+//
+// Optional args filters events by leading arguments. Arguments compares using ==, so their types must be the same as
+// in method signature.
+func (m *RecorderMetrics) Count(method string, args ...any) (n int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i := 0; i < len(m.buf); i++ {
+		if e := &m.buf[i]; e.Method == method && e.match(args) {
+			n++
+		}
+	}
+	return
+}
+
+// Counter returns value of counter. Labels must be specified as key-value pairs excluding queue name, eg
+// m.Counter("queue_leak", "dir", "front").
+func (m *RecorderMetrics) Counter(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrCounter {
+		return x.load()
+	}
+	return 0
+}
+
+// Gauge returns value of gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Gauge(family string, labels ...string) int64 {
+	if x := m.find(family, labels); x != nil && x.kind == aggrGauge {
+		return x.load()
+	}
+	return 0
+}
+
+// Timer returns count and sum of timer observations. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) Timer(family string, labels ...string) (count int64, sum time.Duration) {
+	if x := m.find(family, labels); x != nil && x.kind == aggrTimer {
+		count, sum = atomic.LoadInt64(&x.count), time.Duration(atomic.LoadInt64(&x.sum))
+	}
+	return
+}
+
+// Clear drops all recorded events and metrics.
+func (m *RecorderMetrics) Clear() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.buf = m.buf[:0]
+	m.s.reset()
+}
+
+// AssertCount checks if method was called exactly n times. Optional args filters events as in Count method.
+func (m *RecorderMetrics) AssertCount(t TestingT, method string, n int, args ...any) bool {
+	t.Helper()
+	if c := m.Count(method, args...); c != n {
+		t.Errorf("%s%s: expected %d calls, got %d", method, fmtArgs(args), n, c)
+		return false
+	}
+	return true
+}
+
+// AssertValue checks value of counter or gauge. Labels specifies the same way as in Counter method.
+func (m *RecorderMetrics) AssertValue(t TestingT, family string, value int64, labels ...string) bool {
+	t.Helper()
+	var v int64
+	if x := m.find(family, labels); x != nil && x.kind != aggrTimer {
+		v = x.load()
+	}
+	if v != value {
+		t.Errorf("%s%v: expected %d, got %d", family, labels, value, v)
+		return false
+	}
+	return true
+}
+
+func (m *RecorderMetrics) record(method string, args ...any) {
+	m.mux.Lock()
+	m.buf = append(m.buf, RecorderEvent{Method: method, Args: args})
+	m.mux.Unlock()
+}
+
+func (m *RecorderMetrics) find(family string, labels []string) *aggrSeries {
+	return m.s.find(family, append([]string{"queue", m.name}, labels...)...)
+}
+
+// Check if leading arguments of event are equal to args.
+func (e *RecorderEvent) match(args []any) bool {
+	if len(args) > len(e.Args) {
+		return false
+	}
+	for i := 0; i < len(args); i++ {
+		if e.Args[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func fmtArgs(args []any) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return fmt.Sprint(args)
+}
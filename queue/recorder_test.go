@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// Fake testing.TB collects error messages.
+type recorderTestT struct {
+	errs []string
+}
+
+func (t *recorderTestT) Helper() {}
+
+func (t *recorderTestT) Errorf(format string, args ...any) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderMetricsAssert(t *testing.T) {
+	m := NewRecorderMetrics("test")
+	m.QueuePut()
+	m.QueuePut()
+	m.QueuePut()
+	m.QueueLeak(q.LeakDirectionFront)
+	m.QueueLeak(q.LeakDirectionFront)
+	m.QueueLeak(q.LeakDirectionRear)
+	m.WorkerWait(0, time.Second)
+
+	var ft recorderTestT
+	if !m.AssertCount(&ft, "QueueLeak", 3) ||
+		!m.AssertCount(&ft, "QueueLeak", 2, q.LeakDirectionFront) ||
+		!m.AssertValue(&ft, "queue_leak", 2, "dir", "front") ||
+		!m.AssertValue(&ft, "queue_size", 0) {
+		t.Fatalf("assertions must pass, got %q", ft.errs)
+	}
+	if len(ft.errs) > 0 {
+		t.Fatalf("passed assertions must not report, got %q", ft.errs)
+	}
+
+	// Failed assertions report what was expected and what was got.
+	for _, tc := range []struct {
+		assert func(t TestingT) bool
+		expect string
+	}{
+		{func(t TestingT) bool { return m.AssertCount(t, "QueuePull", 1) },
+			"QueuePull: expected 1 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertCount(t, "QueueLeak", 1, q.LeakDirectionFront) },
+			"QueueLeak[1]: expected 1 calls, got 2"},
+		// Argument of other type than in method signature never matches.
+		{func(t TestingT) bool { return m.AssertCount(t, "QueueLeak", 2, 1) },
+			"QueueLeak[1]: expected 2 calls, got 0"},
+		{func(t TestingT) bool { return m.AssertValue(t, "queue_leak", 1, "dir", "front") },
+			"queue_leak[dir front]: expected 1, got 2"},
+		{func(t TestingT) bool { return m.AssertValue(t, "queue_wait", 1) },
+			"queue_wait[]: expected 1, got 0"},
+	} {
+		var ft recorderTestT
+		if tc.assert(&ft) {
+			t.Errorf("%s: assertion must fail", tc.expect)
+		}
+		if len(ft.errs) != 1 || ft.errs[0] != tc.expect {
+			t.Errorf("got messages %q, expected %q", ft.errs, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/loglimit"
+	q "github.com/koykov/queue"
+)
+
+// SlogMetrics is a log/slog implementation of queue.MetricsWriter.
+//
+// Each event writes as a separate record with message equal to method name (eg "WorkerStop") and arguments as
+// attributes.
+//
+// Events QueueLeak, QueueDeadline, QueueLost and SubqLeak logs with slog.LevelWarn, the rest with slog.LevelDebug.
+// Levels may be changed using options.
+type SlogMetrics struct {
+	l    *slog.Logger
+	conf slogConfig
+	lim  *loglimit.Limiter
+}
+
+// SlogOption describes SlogMetrics option.
+type SlogOption func(*slogConfig)
+
+type slogConfig struct {
+	level  slog.Level
+	levels map[string]slog.Level
+	limits []LogLimitOption
+}
+
+// WithSlogLevel sets level of events haven't own level.
+func WithSlogLevel(level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.level = level
+	}
+}
+
+// WithSlogEventLevel sets level of given event, eg WithSlogEventLevel("WorkerStop", slog.LevelInfo).
+func WithSlogEventLevel(event string, level slog.Level) SlogOption {
+	return func(c *slogConfig) {
+		c.levels[event] = level
+	}
+}
+
+// WithSlogLimits enables sampling and/or rate limiting of events.
+//
+// Reports about suppressed events writes with level of corresponding event.
+func WithSlogLimits(opts ...LogLimitOption) SlogOption {
+	return func(c *slogConfig) {
+		c.limits = append(c.limits, opts...)
+	}
+}
+
+var _ = NewSlogMetrics
+
+// NewSlogMetrics makes new writer logs events to logger. If logger is nil, slog.Default() will use.
+//
+// Records contain attribute "queue" with queue name.
+func NewSlogMetrics(name string, logger *slog.Logger, opts ...SlogOption) *SlogMetrics {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &SlogMetrics{
+		l: logger.With(slog.String("queue", name)),
+		conf: slogConfig{
+			level: slog.LevelDebug,
+			levels: map[string]slog.Level{
+				"QueueLeak":     slog.LevelWarn,
+				"QueueDeadline": slog.LevelWarn,
+				"QueueLost":     slog.LevelWarn,
+				"SubqLeak":      slog.LevelWarn,
+			},
+		},
+	}
+	for _, fn := range opts {
+		fn(&m.conf)
+	}
+	m.lim = newLogLimiter(m.conf.limits, m.suppressed)
+	return m
+}
+
+func (m SlogMetrics) WorkerSetup(active, sleep, stop uint) {
+	m.log("WorkerSetup", slog.Uint64("active", uint64(active)), slog.Uint64("sleep", uint64(sleep)), slog.Uint64("stop", uint64(stop)))
+}
+
+func (m SlogMetrics) WorkerInit(idx uint32) {
+	m.log("WorkerInit", slog.Uint64("worker", uint64(idx)))
+}
+
+func (m SlogMetrics) WorkerSleep(idx uint32) {
+	m.log("WorkerSleep", slog.Uint64("worker", uint64(idx)))
+}
+
+func (m SlogMetrics) WorkerWakeup(idx uint32) {
+	m.log("WorkerWakeup", slog.Uint64("worker", uint64(idx)))
+}
+
+func (m SlogMetrics) WorkerWait(idx uint32, delay time.Duration) {
+	m.log("WorkerWait", slog.Uint64("worker", uint64(idx)), slog.Duration("duration", delay))
+}
+
+func (m SlogMetrics) WorkerStop(idx uint32, force bool, status q.WorkerStatus) {
+	m.log("WorkerStop", slog.Uint64("worker", uint64(idx)), slog.Bool("force", force), slog.String("status", slogStatus(status)))
+}
+
+func (m SlogMetrics) QueuePut() {
+	m.log("QueuePut")
+}
+
+func (m SlogMetrics) QueuePull() {
+	m.log("QueuePull")
+}
+
+func (m SlogMetrics) QueueRetry() {
+	m.log("QueueRetry")
+}
+
+func (m SlogMetrics) QueueLeak(dir q.LeakDirection) {
+	m.log("QueueLeak", slog.String("dir", slogDir(dir)))
+}
+
+func (m SlogMetrics) QueueDeadline() {
+	m.log("QueueDeadline")
+}
+
+func (m SlogMetrics) QueueLost() {
+	m.log("QueueLost")
+}
+
+func (m SlogMetrics) SubqPut(subq string) {
+	m.log("SubqPut", slog.String("subq", subq))
+}
+
+func (m SlogMetrics) SubqPull(subq string) {
+	m.log("SubqPull", slog.String("subq", subq))
+}
+
+func (m SlogMetrics) SubqLeak(subq string) {
+	m.log("SubqLeak", slog.String("subq", subq))
+}
+
+// Close stops reporting of suppressed events.
+func (m SlogMetrics) Close() error {
+	m.lim.Close()
+	return nil
+}
+
+func (m SlogMetrics) log(event string, attrs ...slog.Attr) {
+	ctx, level := context.Background(), m.level(event)
+	if !m.l.Enabled(ctx, level) || !m.lim.Allow(event) {
+		return
+	}
+	m.l.LogAttrs(ctx, level, event, attrs...)
+}
+
+func (m SlogMetrics) suppressed(event string, n uint64) {
+	m.l.LogAttrs(context.Background(), m.level(event), "suppressed events",
+		slog.String("event", event), slog.Uint64("count", n))
+}
+
+func (m SlogMetrics) level(event string) slog.Level {
+	if level, ok := m.conf.levels[event]; ok {
+		return level
+	}
+	return m.conf.level
+}
+
+func slogStatus(status q.WorkerStatus) string {
+	switch status {
+	case q.WorkerStatusIdle:
+		return "idle"
+	case q.WorkerStatusActive:
+		return "active"
+	case q.WorkerStatusSleep:
+		return "sleep"
+	default:
+		return "unknown"
+	}
+}
+
+func slogDir(dir q.LeakDirection) string {
+	if dir == q.LeakDirectionFront {
+		return "front"
+	}
+	return "rear"
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// Handler records handled records as "LEVEL message key=value ..." lines.
+type slogTestHandler struct {
+	level slog.Level
+	attrs []slog.Attr
+	mux   *sync.Mutex
+	buf   *[]string
+}
+
+func newSlogTestHandler(level slog.Level) *slogTestHandler {
+	return &slogTestHandler{level: level, mux: new(sync.Mutex), buf: new([]string)}
+}
+
+func (h *slogTestHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *slogTestHandler) Handle(_ context.Context, r slog.Record) error {
+	line := []string{r.Level.String(), r.Message}
+	for _, a := range h.attrs {
+		line = append(line, a.String())
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		line = append(line, a.String())
+		return true
+	})
+	h.mux.Lock()
+	*h.buf = append(*h.buf, strings.Join(line, " "))
+	h.mux.Unlock()
+	return nil
+}
+
+func (h *slogTestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cpy := *h
+	cpy.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return &cpy
+}
+
+func (h *slogTestHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// Amount of handled records.
+func (h *slogTestHandler) count() int64 {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return int64(len(*h.buf))
+}
+
+func (h *slogTestHandler) expect(t *testing.T, lines ...string) {
+	t.Helper()
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if got := strings.Join(*h.buf, "\n"); got != strings.Join(lines, "\n") {
+		t.Errorf("got records:\n%s\nexpected:\n%s", got, strings.Join(lines, "\n"))
+	}
+}
+
+func TestSlogMetrics(t *testing.T) {
+	t.Run("levels", func(t *testing.T) {
+		h := newSlogTestHandler(slog.LevelDebug)
+		m := NewSlogMetrics("test", slog.New(h))
+		m.QueuePut()
+		m.QueueLeak(q.LeakDirectionFront)
+		m.WorkerWait(1, time.Second)
+		h.expect(t,
+			"DEBUG QueuePut queue=test",
+			"WARN QueueLeak queue=test dir=front",
+			"DEBUG WorkerWait queue=test worker=1 duration=1s",
+		)
+	})
+	t.Run("options", func(t *testing.T) {
+		h := newSlogTestHandler(slog.LevelInfo)
+		m := NewSlogMetrics("test", slog.New(h), WithSlogLevel(slog.LevelInfo),
+			WithSlogEventLevel("QueuePut", slog.LevelDebug))
+		// Event level is below handler's one, so it's skipped.
+		m.QueuePut()
+		m.SubqPut("hi")
+		h.expect(t, "INFO SubqPut queue=test subq=hi")
+	})
+	t.Run("limits", func(t *testing.T) {
+		h := newSlogTestHandler(slog.LevelDebug)
+		m := NewSlogMetrics("test", slog.New(h), WithSlogLimits(WithLogSampling(2)))
+		m.QueuePut()
+		m.QueuePut()
+		m.QueuePut()
+		// Close reports suppressed events.
+		_ = m.Close()
+		h.expect(t, "DEBUG QueuePut queue=test", "DEBUG QueuePut queue=test",
+			"DEBUG suppressed events queue=test event=QueuePut count=1")
+	})
+}
@@ -0,0 +1,127 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/koykov/metrics_writers/internal/statsd"
+	q "github.com/koykov/queue"
+)
+
+// StatsDMetrics is a StatsD implementation of queue.MetricsWriter.
+//
+// Counters and timers sends as is, gauges keeps locally and sends actual values.
+type StatsDMetrics struct {
+	name string
+	c    *statsd.Client
+}
+
+var _ = NewStatsDMetrics
+
+// NewStatsDMetrics makes new writer sends metrics to StatsD agent listening given UDP address.
+func NewStatsDMetrics(name, addr string, opts ...StatsDOption) (*StatsDMetrics, error) {
+	var conf statsd.Config
+	for _, fn := range opts {
+		fn(&conf)
+	}
+	c, err := statsd.New(addr, conf)
+	if err != nil {
+		return nil, err
+	}
+	m := &StatsDMetrics{
+		name: name,
+		c:    c,
+	}
+	return m, nil
+}
+
+func (m StatsDMetrics) WorkerSetup(active, sleep, stop uint) {
+	m.c.GaugeSet("queue_workers_active", int64(active), "queue", m.name)
+	m.c.GaugeSet("queue_workers_sleep", int64(sleep), "queue", m.name)
+	m.c.GaugeSet("queue_workers_idle", int64(stop), "queue", m.name)
+}
+
+func (m StatsDMetrics) WorkerInit(_ uint32) {
+	m.c.GaugeAdd("queue_workers_active", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_workers_idle", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) WorkerSleep(_ uint32) {
+	m.c.GaugeAdd("queue_workers_sleep", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_workers_active", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) WorkerWakeup(_ uint32) {
+	m.c.GaugeAdd("queue_workers_active", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_workers_sleep", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) WorkerWait(_ uint32, delay time.Duration) {
+	m.c.Timing("queue_wait", delay, "queue", m.name)
+}
+
+func (m StatsDMetrics) WorkerStop(_ uint32, force bool, status q.WorkerStatus) {
+	m.c.GaugeAdd("queue_workers_idle", 1, "queue", m.name)
+	if force {
+		switch status {
+		case q.WorkerStatusActive:
+			m.c.GaugeAdd("queue_workers_active", -1, "queue", m.name)
+		case q.WorkerStatusSleep:
+			m.c.GaugeAdd("queue_workers_sleep", -1, "queue", m.name)
+		}
+	} else {
+		m.c.GaugeAdd("queue_workers_sleep", -1, "queue", m.name)
+	}
+}
+
+func (m StatsDMetrics) QueuePut() {
+	m.c.Count("queue_in", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_size", 1, "queue", m.name)
+}
+
+func (m StatsDMetrics) QueuePull() {
+	m.c.Count("queue_out", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_size", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) QueueRetry() {
+	m.c.Count("queue_retry", 1, "queue", m.name)
+}
+
+func (m StatsDMetrics) QueueLeak(dir q.LeakDirection) {
+	dirs := "rear"
+	if dir == q.LeakDirectionFront {
+		dirs = "front"
+	}
+	m.c.Count("queue_leak", 1, "queue", m.name, "dir", dirs)
+	m.c.GaugeAdd("queue_size", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) QueueDeadline() {
+	m.c.Count("queue_deadline", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_size", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) QueueLost() {
+	m.c.Count("queue_lost", 1, "queue", m.name)
+	m.c.GaugeAdd("queue_size", -1, "queue", m.name)
+}
+
+func (m StatsDMetrics) SubqPut(subq string) {
+	m.c.Count("queue_subq_in", 1, "queue", m.name, "subq", subq)
+	m.c.GaugeAdd("queue_subq_size", 1, "queue", m.name, "subq", subq)
+}
+
+func (m StatsDMetrics) SubqPull(subq string) {
+	m.c.Count("queue_subq_out", 1, "queue", m.name, "subq", subq)
+	m.c.GaugeAdd("queue_subq_size", -1, "queue", m.name, "subq", subq)
+}
+
+func (m StatsDMetrics) SubqLeak(subq string) {
+	m.c.Count("queue_subq_leak", 1, "queue", m.name, "subq", subq)
+	m.c.GaugeAdd("queue_subq_size", -1, "queue", m.name, "subq", subq)
+}
+
+// Close flushes buffered metrics and closes connection. Writer stops reporting, all further events are dropped.
+func (m StatsDMetrics) Close() error {
+	return m.c.Close()
+}
@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+func TestSummaryLogMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	m := NewSummaryLogMetrics("foo", time.Hour)
+	m.QueuePut()
+	m.QueuePut()
+	m.QueueLeak(q.LeakDirectionFront)
+	// Multi writer must stop summary writer on close.
+	if err := NewMultiMetrics(m).Close(); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"queue foo in last", "in 2 (", "leak(front) 1 (", "size 1"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%q not found in digest %q", s, out)
+		}
+	}
+	// Stop after close is no-op.
+	buf.Reset()
+	m.Stop()
+	if buf.Len() > 0 {
+		t.Errorf("unexpected digest after close: %q", buf.String())
+	}
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"time"
+
+	q "github.com/koykov/queue"
+)
+
+// MetricsWriter describes queue.MetricsWriter interface.
+//
+// Declared locally to keep writers independent of upstream package version. All writers of the package implement it.
+type MetricsWriter interface {
+	// WorkerSetup sets initial workers statuses.
+	WorkerSetup(active, sleep, stop uint)
+	// WorkerInit registers worker's start moment.
+	WorkerInit(idx uint32)
+	// WorkerSleep registers when worker puts to sleep.
+	WorkerSleep(idx uint32)
+	// WorkerWakeup registers when slept worker resumes.
+	WorkerWakeup(idx uint32)
+	// WorkerWait registers how many worker waits due to delayed execution.
+	WorkerWait(idx uint32, delay time.Duration)
+	// WorkerStop registers when sleeping worker stops.
+	WorkerStop(idx uint32, force bool, status q.WorkerStatus)
+	// QueuePut registers income of new item to the queue.
+	QueuePut()
+	// QueuePull registers outgoing of item from the queue.
+	QueuePull()
+	// QueueRetry registers total amount of retries.
+	QueueRetry()
+	// QueueLeak registers item's leak from the full queue.
+	QueueLeak(dir q.LeakDirection)
+	// QueueDeadline registers amount of skipped processing due to deadline.
+	QueueDeadline()
+	// QueueLost registers lost items missed queue and DLQ.
+	QueueLost()
+	// SubqPut registers income of new item to the sub-queue.
+	SubqPut(subq string)
+	// SubqPull registers outgoing of item from the sub-queue.
+	SubqPull(subq string)
+	// SubqLeak registers item's leak from the full sub-queue.
+	SubqLeak(subq string)
+}
+
+var (
+	_ MetricsWriter = (*PrometheusMetrics)(nil)
+	_ MetricsWriter = (*CollectorMetrics)(nil)
+	_ MetricsWriter = (*ExpvarMetrics)(nil)
+	_ MetricsWriter = (*RewriteMetrics)(nil)
+	_ MetricsWriter = (*OTelMetrics)(nil)
+	_ MetricsWriter = (*StatsDMetrics)(nil)
+	_ MetricsWriter = (*InfluxMetrics)(nil)
+	_ MetricsWriter = (*GraphiteMetrics)(nil)
+	_ MetricsWriter = (*LogMetrics)(nil)
+	_ MetricsWriter = (*MultiMetrics)(nil)
+	_ MetricsWriter = (*RecorderMetrics)(nil)
+	_ MetricsWriter = (*SlogMetrics)(nil)
+	_ MetricsWriter = (*SummaryLogMetrics)(nil)
+)